@@ -23,6 +23,16 @@ type AppConfig struct {
 	*AliYunAi          `mapstructure:"old_aliyunai"` // 保持小写
 	*RabbitMQConfig    `mapstructure:"rabbitmq"`
 	*SiliconflowConfig `mapstructure:"siliconflow"`
+	*StorageConfig     `mapstructure:"storage"`
+	*RenditionConfig   `mapstructure:"rendition"`
+	*RenderConfig      `mapstructure:"render"`
+	*ScannerConfig     `mapstructure:"scanner"`
+	*VersionConfig     `mapstructure:"version"`
+	*RecycleConfig     `mapstructure:"recycle"`
+	*WatermarkConfig   `mapstructure:"watermark"`
+	*ArchiveConfig     `mapstructure:"archive"`
+	*SearchConfig      `mapstructure:"search"`
+	*ImageSearchConfig `mapstructure:"image_search"`
 }
 
 type MySQLConfig struct {
@@ -69,6 +79,129 @@ type Tcos struct {
 	AppID      string `mapstructure:"app_id"` // 下划线命名
 }
 
+// 对象存储配置，type 可选 cos / s3 / local，未配置时默认使用腾讯云COS
+type StorageConfig struct {
+	Type  string              `mapstructure:"type"`
+	S3    *S3StorageConfig    `mapstructure:"s3"`
+	Local *LocalStorageConfig `mapstructure:"local"`
+	GC    *StorageGCConfig    `mapstructure:"gc"`
+}
+
+// 存储回收任务配置，清理已删除图片和上传失败残留的对象
+type StorageGCConfig struct {
+	Enabled       bool `mapstructure:"enabled"`        // 是否启动后台回收任务
+	IntervalHours int  `mapstructure:"interval_hours"` // 执行间隔，单位小时，默认24
+	RetentionDays int  `mapstructure:"retention_days"` // 软删除记录的对象保留天数，默认30，已配置recycle.retention_days时以其为准
+	DryRun        bool `mapstructure:"dry_run"`        // 只统计孤儿对象，不实际删除
+}
+
+// S3协议兼容的对象存储（AWS S3、MinIO等）
+type S3StorageConfig struct {
+	Endpoint  string `mapstructure:"endpoint"` // 不带协议头，例如 127.0.0.1:9000
+	Region    string `mapstructure:"region"`
+	Bucket    string `mapstructure:"bucket"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+	UseSSL    bool   `mapstructure:"use_ssl"`
+	Host      string `mapstructure:"host"` // 对外访问的域名，为空时使用 endpoint/bucket 拼接
+}
+
+// 本地文件系统存储，适用于私有化部署和测试
+type LocalStorageConfig struct {
+	Root       string `mapstructure:"root"`        // 文件保存的根目录
+	Host       string `mapstructure:"host"`        // 对外访问的地址前缀，例如 http://localhost:8001/storage
+	URLPrefix  string `mapstructure:"url_prefix"`  // 静态文件路由前缀，例如 /storage
+	SignSecret string `mapstructure:"sign_secret"` // 私有对象签名URL的密钥，为空时每次启动随机生成
+}
+
+// 图片衍生版本配置，在webp主图和缩略图之外按集合生成多个尺寸和格式的版本
+// 例如：
+//
+//	rendition:
+//	  profiles:
+//	    basic: [{name: w512, width: 512, format: webp}]
+//	    pro: [{name: w512, width: 512, format: webp}, {name: w1024a, width: 1024, format: avif, quality: 55}]
+//	  space_levels: {"0": basic, "1": pro, "2": pro}
+//	  public: basic
+type RenditionConfig struct {
+	Profiles    map[string][]RenditionSpec `mapstructure:"profiles"`     // 版本集合，key为集合名称
+	SpaceLevels map[string]string          `mapstructure:"space_levels"` // 空间等级到集合名称的映射
+	Public      string                     `mapstructure:"public"`       // 公共图库使用的集合
+}
+
+// 单个衍生版本的规格
+type RenditionSpec struct {
+	Name    string `mapstructure:"name"`    // 版本名称，只能包含小写字母和数字
+	Width   int    `mapstructure:"width"`   // 最大宽度，只缩小不放大
+	Format  string `mapstructure:"format"`  // jpg/jpeg/png/webp/avif
+	Quality int    `mapstructure:"quality"` // 编码质量[1,100]，为0时使用默认值
+}
+
+// 图片渲染接口配置
+type RenderConfig struct {
+	BaseURL    string `mapstructure:"base_url"`    // 外部访问本服务的地址，用于生成交给第三方的渲染地址，例如 https://api.example.com
+	SignSecret string `mapstructure:"sign_secret"` // 渲染地址签名密钥，多实例部署时需保持一致，为空时每次启动随机生成
+}
+
+// 图片历史版本配置，重新上传图片时保留被替换的版本
+type VersionConfig struct {
+	MaxVersions int `mapstructure:"max_versions"` // 每张图片最多保留的历史版本数量，为0时使用默认值10，小于0时不保留
+}
+
+// 回收站配置，删除的图片在保留期内可以恢复，超过保留期后彻底删除并清理存储对象
+type RecycleConfig struct {
+	RetentionDays int `mapstructure:"retention_days"` // 保留天数，为0时沿用storage.gc.retention_days或默认30天，小于0时不自动清理
+	IntervalHours int `mapstructure:"interval_hours"` // 自动清理的执行间隔，单位小时，默认24
+}
+
+// 冷存储归档配置，长期未查看和编辑的图片将原图、主图和衍生版本转移到归档前缀，只保留缩略图，查看原图需申请恢复
+type ArchiveConfig struct {
+	Enabled       bool   `mapstructure:"enabled"`        // 是否启动后台归档任务
+	IdleDays      int    `mapstructure:"idle_days"`      // 超过该天数未查看和编辑的图片会被归档，默认180
+	IntervalHours int    `mapstructure:"interval_hours"` // 执行间隔，单位小时，默认24
+	SpaceLevels   []int  `mapstructure:"space_levels"`   // 参与归档的空间等级，默认只有旗舰版
+	StorageClass  string `mapstructure:"storage_class"`  // 归档对象的存储类型，需可直接读取，例如 S3 和 COS 的 STANDARD_IA，为空时不修改
+}
+
+// 图片搜索配置，默认使用MySQL全文索引（ngram分词）并按相关度排序
+type SearchConfig struct {
+	Mode       string  `mapstructure:"mode"`        // fulltext 或 like，默认fulltext，全文索引创建失败时自动使用like
+	NameBoost  float64 `mapstructure:"name_boost"`  // 名称命中的相关度权重，默认2
+	LabelBoost float64 `mapstructure:"label_boost"` // 分类和标签命中的相关度权重，默认1.5
+}
+
+// 以图搜图配置，请求未指定服务时按providers的顺序依次尝试，失败或没有结果时使用下一个
+type ImageSearchConfig struct {
+	Providers      []string          `mapstructure:"providers"`       // 可选 baidu / bing / local，默认 baidu、bing、local
+	TimeoutSeconds int               `mapstructure:"timeout_seconds"` // 外部搜图服务的请求超时时间，默认10秒
+	Bing           *BingSearchConfig `mapstructure:"bing"`
+}
+
+// Bing Visual Search 配置，未配置密钥时必应识图不可用
+type BingSearchConfig struct {
+	APIKey   string `mapstructure:"api_key"`
+	Endpoint string `mapstructure:"endpoint"` // 为空时使用 https://api.bing.microsoft.com/v7.0/images/visualsearch
+}
+
+// 空间水印配置
+type WatermarkConfig struct {
+	FontPath string `mapstructure:"font_path"` // 文字水印使用的TTF/OTF字体文件，为空时使用内置的Go字体，中文水印需配置包含中文字形的字体
+}
+
+// 上传内容安全检查配置，on_error/on_found 可选 allow / quarantine / reject
+type ScannerConfig struct {
+	Enabled bool          `mapstructure:"enabled"`  // 是否启用，未启用时不做检查
+	OnError string        `mapstructure:"on_error"` // 扫描器出错或超时时的处理，默认quarantine
+	ClamAV  *ClamAVConfig `mapstructure:"clamav"`
+}
+
+// clamd守护进程配置
+type ClamAVConfig struct {
+	Address        string `mapstructure:"address"`         // 例如 tcp://127.0.0.1:3310 或 unix:///var/run/clamav/clamd.ctl
+	TimeoutSeconds int    `mapstructure:"timeout_seconds"` // 单次扫描超时时间，默认10秒
+	OnFound        string `mapstructure:"on_found"`        // 发现病毒时的处理，默认reject
+}
+
 type AliYunAi struct {
 	ApiKey string `mapstructure:"apiKey"`
 }
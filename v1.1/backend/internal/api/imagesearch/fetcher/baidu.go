@@ -0,0 +1,202 @@
+package fetcher
+
+import (
+	"backend/internal/api/imagesearch/model"
+	"backend/internal/consts"
+	"backend/internal/ecode"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type BaiduResponse struct {
+	Status int    `json:"status"` // 0表示成功，非0表示错误
+	Msg    string `json:"msg"`    // 错误信息
+	Data   struct {
+		URL  string `json:"url"`  // 搜索结果的页面URL
+		Sign string `json:"sign"` // 签名参数(用于后续请求)
+	} `json:"data"`
+}
+
+// 百度识图，分三步：上传图片地址获取结果页面，从页面中提取列表接口地址(firstUrl)，请求列表接口
+type Baidu struct {
+	Client    *http.Client
+	UploadURL string // 上传接口地址，测试时替换为本地地址
+}
+
+func NewBaidu(timeout time.Duration) *Baidu {
+	return &Baidu{
+		Client:    &http.Client{Timeout: timeout},
+		UploadURL: "https://graph.baidu.com/upload",
+	}
+}
+
+func (b *Baidu) Name() string {
+	return consts.IMAGE_SEARCH_BAIDU
+}
+
+// 外部服务需要通过图片地址下载图片
+func (b *Baidu) RequiresImageURL() bool {
+	return true
+}
+
+func (b *Baidu) Search(ctx context.Context, query *model.SearchQuery) ([]model.ImageSearchResult, *ecode.ErrorWithCode) {
+	if query.ImageURL == "" {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "图片地址为空")
+	}
+	//1.获取图片页面URL
+	imagePageURL, err := b.GetImagePageURL(ctx, query.ImageURL)
+	if err != nil {
+		return nil, err
+	}
+	firstUrl, err := b.GetImageFirstURL(ctx, imagePageURL)
+	if err != nil {
+		return nil, err
+	}
+	//2.获取图片搜索结果
+	list, err := b.GetImageList(ctx, firstUrl)
+	if err != nil {
+		return nil, err
+	}
+	if query.Limit > 0 && len(list) > query.Limit {
+		list = list[:query.Limit]
+	}
+	for i := range list {
+		list[i].Source = b.Name()
+	}
+	return list, nil
+}
+
+// 调用百度以图搜图接口获取原始搜索结果URL
+// 上传原始图片地址
+// 仅支持20M以下jpg，jpeg，png，bmp，gif等格式的图片
+// webp图片请先通过图片渲染接口转为png格式
+func (b *Baidu) GetImagePageURL(ctx context.Context, imageURL string) (string, *ecode.ErrorWithCode) {
+	// 1. 准备表单数据，图片地址需要先进行一次URL编码
+	formData := url.Values{
+		"image":        {url.QueryEscape(imageURL)}, // 图片URL
+		"tn":           {"pc"},                      // 平台类型(PC端)
+		"from":         {"pc"},                      // 来源(PC端)
+		"image_source": {"PC_UPLOAD_URL"},           // 图片来源(URL上传)
+	}
+
+	// 2. 生成时间戳(防止缓存)，构造请求URL
+	reqUrl := fmt.Sprintf("%s?uptime=%d", b.UploadURL, time.Now().UnixMilli())
+
+	// 3. 发送POST请求
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqUrl, strings.NewReader(formData.Encode()))
+	if err != nil {
+		return "", ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "请求搜图接口失败")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Acs-Token", fmt.Sprintf("%d", rand.IntN(1000))) // 随机请求头(防反爬)
+	body, err := b.do(req)
+
+	// 4. 处理请求错误
+	if err != nil {
+		return "", ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "请求搜图接口失败")
+	}
+
+	// 5. 解析JSON响应
+	var baiduResp BaiduResponse
+	if err := json.Unmarshal(body, &baiduResp); err != nil {
+		return "", ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "解析响应结果失败")
+	}
+
+	// 6. 验证响应状态
+	if baiduResp.Status != 0 || baiduResp.Data.URL == "" {
+		return "", ecode.GetErrWithDetail(ecode.OPERATION_ERROR,
+			"获取图片页面失败，可能是图片格式不支持")
+	}
+
+	// 7. 返回搜索结果页面URL
+	return baiduResp.Data.URL, nil
+}
+
+// 通过GetImagePageURL获取的URL，来获取简略图片信息的请求接口，即FirstURL(负责从搜索结果页面提取获取图片列表的API URL（称为"FirstURL"）)
+func (b *Baidu) GetImageFirstURL(ctx context.Context, searchResultURL string) (string, *ecode.ErrorWithCode) {
+	// 1. 发送HTTP GET请求
+	body, err := b.get(ctx, searchResultURL)
+	if err != nil {
+		return "", ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "请求图片页面失败")
+	}
+	// 2. 从页面中提取firstUrl
+	return ParseBaiduFirstURL(bytes.NewReader(body))
+}
+
+// 从百度识图结果页面中提取firstUrl，页面结构变化时返回错误
+func ParseBaiduFirstURL(page io.Reader) (string, *ecode.ErrorWithCode) {
+	// 1. 解析HTML文档
+	doc, err := goquery.NewDocumentFromReader(page)
+	if err != nil {
+		return "", ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "解析图片页面失败")
+	}
+
+	// 2. 查找所有<script>标签
+	scriptElements := doc.Find("script")
+
+	// 3. 编译正则表达式 - 匹配"firstUrl"字段
+	reg := regexp.MustCompile(`"firstUrl"\s*:\s*"(.*?)"`)
+
+	var firstURL string
+
+	// 4. 遍历所有script标签
+	scriptElements.EachWithBreak(func(i int, s *goquery.Selection) bool {
+		scriptContent := s.Text() // 获取脚本内容
+
+		// 5. 使用正则表达式匹配
+		matches := reg.FindStringSubmatch(scriptContent)
+		if len(matches) > 1 {
+			// 6. 处理URL中的转义字符(\/ -> /)
+			firstURL = strings.ReplaceAll(matches[1], "\\/", "/")
+			return false // 找到后退出循环
+		}
+		return true // 继续查找
+	})
+
+	// 7. 验证是否找到firstUrl
+	if firstURL == "" {
+		return "", ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "搜索失败")
+	}
+
+	return firstURL, nil
+}
+
+// firstURL，它本质上是包含完整搜索上下文的API端点
+func (b *Baidu) GetImageList(ctx context.Context, firstURL string) ([]model.ImageSearchResult, *ecode.ErrorWithCode) {
+	// 1. 发送HTTP GET请求并读取响应数据
+	body, err := b.get(ctx, firstURL)
+	if err != nil {
+		return nil, ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "请求图片列表失败")
+	}
+
+	// 2. 解析JSON数据
+	var apiResp model.APIResponse
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return nil, ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "解析JSON数据失败")
+	}
+
+	// 3. 返回图片列表
+	return apiResp.Data.List, nil
+}
+
+func (b *Baidu) get(ctx context.Context, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	return b.do(req)
+}
+
+func (b *Baidu) do(req *http.Request) ([]byte, error) {
+	return doRequest(b.Client, req)
+}
@@ -0,0 +1,127 @@
+package fetcher
+
+import (
+	"backend/internal/api/imagesearch/model"
+	"backend/internal/consts"
+	"backend/internal/ecode"
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"time"
+)
+
+// Bing Visual Search 的响应，只解析用到的字段
+type BingResponse struct {
+	Tags []struct {
+		Actions []struct {
+			ActionType string `json:"actionType"`
+			Data       struct {
+				Value []struct {
+					Name         string `json:"name"`
+					ThumbnailURL string `json:"thumbnailUrl"`
+					HostPageURL  string `json:"hostPageUrl"`
+				} `json:"value"`
+			} `json:"data"`
+		} `json:"actions"`
+	} `json:"tags"`
+}
+
+// Bing结果中使用的动作，包含该图片的页面优先，其次为视觉相似的图片
+var bingActionTypes = []string{"PagesIncluding", "VisualSearch"}
+
+// 必应识图，使用 Bing Visual Search API，需要配置订阅密钥
+type Bing struct {
+	Client   *http.Client
+	Endpoint string
+	APIKey   string
+}
+
+func NewBing(timeout time.Duration, endpoint string, apiKey string) *Bing {
+	if endpoint == "" {
+		endpoint = "https://api.bing.microsoft.com/v7.0/images/visualsearch"
+	}
+	return &Bing{
+		Client:   &http.Client{Timeout: timeout},
+		Endpoint: endpoint,
+		APIKey:   apiKey,
+	}
+}
+
+func (b *Bing) Name() string {
+	return consts.IMAGE_SEARCH_BING
+}
+
+// 外部服务需要通过图片地址下载图片
+func (b *Bing) RequiresImageURL() bool {
+	return true
+}
+
+func (b *Bing) Search(ctx context.Context, query *model.SearchQuery) ([]model.ImageSearchResult, *ecode.ErrorWithCode) {
+	if b.APIKey == "" {
+		return nil, ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "未配置必应识图")
+	}
+	if query.ImageURL == "" {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "图片地址为空")
+	}
+	// 1. 以表单字段knowledgeRequest提交图片地址
+	knowledge, _ := json.Marshal(map[string]interface{}{
+		"imageInfo": map[string]string{"url": query.ImageURL},
+	})
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	_ = writer.WriteField("knowledgeRequest", string(knowledge))
+	_ = writer.Close()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.Endpoint, &form)
+	if err != nil {
+		return nil, ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "请求搜图接口失败")
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Ocp-Apim-Subscription-Key", b.APIKey)
+	body, err := doRequest(b.Client, req)
+	if err != nil {
+		return nil, ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "请求搜图接口失败")
+	}
+	// 2. 解析结果
+	list, err := ParseBingResponse(body)
+	if err != nil {
+		return nil, ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "解析响应结果失败")
+	}
+	if query.Limit > 0 && len(list) > query.Limit {
+		list = list[:query.Limit]
+	}
+	return list, nil
+}
+
+// 解析 Bing Visual Search 的响应，按来源页面去重
+func ParseBingResponse(body []byte) ([]model.ImageSearchResult, error) {
+	var resp BingResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	list := make([]model.ImageSearchResult, 0)
+	seen := make(map[string]bool)
+	for _, actionType := range bingActionTypes {
+		for _, tag := range resp.Tags {
+			for _, action := range tag.Actions {
+				if action.ActionType != actionType {
+					continue
+				}
+				for _, v := range action.Data.Value {
+					if v.HostPageURL == "" || seen[v.HostPageURL] {
+						continue
+					}
+					seen[v.HostPageURL] = true
+					list = append(list, model.ImageSearchResult{
+						ThumbURL: v.ThumbnailURL,
+						FromURL:  v.HostPageURL,
+						Title:    v.Name,
+						Source:   consts.IMAGE_SEARCH_BING,
+					})
+				}
+			}
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,105 @@
+package fetcher
+
+import (
+	"backend/internal/api/imagesearch/model"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 读取录制的响应，{{SERVER}} 替换为测试服务器地址
+func fixture(t *testing.T, name string, server string) []byte {
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := strings.ReplaceAll(string(data), "{{SERVER_ESCAPED}}", strings.ReplaceAll(server, "/", `\/`))
+	return []byte(strings.ReplaceAll(s, "{{SERVER}}", server))
+}
+
+// 按路径回放录制的响应
+func replayServer(t *testing.T, routes map[string]string) *httptest.Server {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(fixture(t, name, srv.URL))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestBaiduSearch(t *testing.T) {
+	srv := replayServer(t, map[string]string{
+		"/upload":      "baidu_upload.json",
+		"/s":           "baidu_result.html",
+		"/ajax/pcsimi": "baidu_list.json",
+	})
+	b := NewBaidu(5 * time.Second)
+	b.UploadURL = srv.URL + "/upload"
+	list, err := b.Search(context.Background(), &model.SearchQuery{ImageURL: "https://api.example.com/render?id=1", Limit: 2})
+	if err != nil {
+		t.Fatal(err.Msg)
+	}
+	if len(list) != 2 {
+		t.Fatalf("结果数量错误: %d", len(list))
+	}
+	if list[0].FromURL != "https://www.example.com/gallery/sunset.html" || list[0].ThumbURL == "" || list[0].Source != "baidu" {
+		t.Fatalf("结果解析错误: %+v", list[0])
+	}
+}
+
+func TestBaiduPageChanged(t *testing.T) {
+	// 页面结构变化、找不到firstUrl时应返回错误而不是空结果
+	if _, err := ParseBaiduFirstURL(strings.NewReader("<html><script>var a = 1;</script></html>")); err == nil {
+		t.Fatal("应返回错误")
+	}
+	srv := replayServer(t, map[string]string{})
+	b := NewBaidu(5 * time.Second)
+	b.UploadURL = srv.URL + "/upload"
+	if _, err := b.Search(context.Background(), &model.SearchQuery{ImageURL: "https://api.example.com/render?id=1"}); err == nil {
+		t.Fatal("上传接口失败时应返回错误")
+	}
+}
+
+func TestBingSearch(t *testing.T) {
+	var gotKey, gotKnowledge string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotKey = r.Header.Get("Ocp-Apim-Subscription-Key")
+		gotKnowledge = r.FormValue("knowledgeRequest")
+		w.Write(fixture(t, "bing_visualsearch.json", ""))
+	}))
+	defer srv.Close()
+	b := NewBing(5*time.Second, srv.URL, "test-key")
+	list, err := b.Search(context.Background(), &model.SearchQuery{ImageURL: "https://api.example.com/render?id=1"})
+	if err != nil {
+		t.Fatal(err.Msg)
+	}
+	if gotKey != "test-key" || !strings.Contains(gotKnowledge, "https://api.example.com/render?id=1") {
+		t.Fatalf("请求参数错误: %s %s", gotKey, gotKnowledge)
+	}
+	// 包含该图片的页面在前，按来源页面去重，忽略其他类型的动作
+	want := []string{
+		"https://www.example.com/gallery/sunset.html",
+		"https://wall.example.org/beach",
+		"https://photos.example.net/coast",
+	}
+	if len(list) != len(want) {
+		t.Fatalf("结果数量错误: %+v", list)
+	}
+	for i, u := range want {
+		if list[i].FromURL != u || list[i].Source != "bing" {
+			t.Fatalf("第%d个结果错误: %+v", i, list[i])
+		}
+	}
+	if _, err := NewBing(time.Second, srv.URL, "").Search(context.Background(), &model.SearchQuery{ImageURL: "x"}); err == nil {
+		t.Fatal("未配置密钥时应返回错误")
+	}
+}
@@ -0,0 +1,26 @@
+package fetcher
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// 搜图服务响应的最大读取长度
+const maxResponseSize = 10 * 1024 * 1024
+
+// 发送请求并读取响应，状态码不是200时返回错误
+func doRequest(client *http.Client, req *http.Request) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+}
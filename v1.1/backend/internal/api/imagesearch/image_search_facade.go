@@ -1,28 +1,117 @@
 package imagesearch
 
 import (
+	"backend/config"
 	"backend/internal/api/imagesearch/fetcher"
 	"backend/internal/api/imagesearch/model"
+	"backend/internal/consts"
 	"backend/internal/ecode"
+	"context"
+	"log"
+	"sync"
+	"time"
 )
 
 //门面模式，通过统一接口简化多个接口的调用
-//该文件整合封装了百度搜图API的功能
+//该文件定义搜图服务的接口，并按配置的顺序调用已注册的服务
 
-func SearchImage(imageURL string) ([]model.ImageSearchResult, *ecode.ErrorWithCode) {
-	//1.获取图片页面URL
-	imagePageURL, err := fetcher.GetImagePageURL(imageURL)
-	if err != nil {
-		return nil, err
+// Provider 以图搜图服务，返回统一的搜索结果
+type Provider interface {
+	Name() string
+	Search(ctx context.Context, query *model.SearchQuery) ([]model.ImageSearchResult, *ecode.ErrorWithCode)
+}
+
+// ImageURLProvider 需要可被外部访问的图片地址的服务，没有图片地址时按顺序尝试会跳过
+type ImageURLProvider interface {
+	RequiresImageURL() bool
+}
+
+// 服务是否需要图片地址
+func requiresImageURL(p Provider) bool {
+	u, ok := p.(ImageURLProvider)
+	return ok && u.RequiresImageURL()
+}
+
+// 未配置时依次尝试的默认顺序
+var defaultProviders = []string{consts.IMAGE_SEARCH_BAIDU, consts.IMAGE_SEARCH_BING, consts.IMAGE_SEARCH_LOCAL}
+
+var registry = struct {
+	sync.RWMutex
+	providers map[string]Provider
+}{providers: make(map[string]Provider)}
+
+// Init 按配置注册百度和必应识图，本地搜索由图片服务注册
+func Init() {
+	timeout := consts.IMAGE_SEARCH_TIMEOUT
+	var endpoint, apiKey string
+	if cfg := config.LoadConfig().ImageSearchConfig; cfg != nil {
+		if cfg.TimeoutSeconds > 0 {
+			timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+		}
+		if cfg.Bing != nil {
+			endpoint, apiKey = cfg.Bing.Endpoint, cfg.Bing.APIKey
+		}
+	}
+	Register(fetcher.NewBaidu(timeout))
+	Register(fetcher.NewBing(timeout, endpoint, apiKey))
+}
+
+// Register 注册搜图服务，同名的服务会被替换
+func Register(p Provider) {
+	registry.Lock()
+	registry.providers[p.Name()] = p
+	registry.Unlock()
+}
+
+// 获取已注册的服务
+func getProvider(name string) (Provider, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	p, ok := registry.providers[name]
+	return p, ok
+}
+
+// 未指定服务时的尝试顺序
+func providerOrder() []string {
+	if cfg := config.LoadConfig().ImageSearchConfig; cfg != nil && len(cfg.Providers) > 0 {
+		return cfg.Providers
+	}
+	return defaultProviders
+}
+
+// SearchImage 以图搜图，name不为空时只使用指定的服务
+// 为空时按配置的顺序依次尝试，出错或没有结果时使用下一个，全部出错时返回最后一个错误；没有图片地址时跳过需要图片地址的服务
+func SearchImage(ctx context.Context, name string, query *model.SearchQuery) ([]model.ImageSearchResult, *ecode.ErrorWithCode) {
+	if name != "" {
+		p, ok := getProvider(name)
+		if !ok {
+			return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "不支持的搜图服务")
+		}
+		if query.ImageURL == "" && requiresImageURL(p) {
+			return nil, ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "无法生成图片的访问地址")
+		}
+		return p.Search(ctx, query)
 	}
-	firstUrl, err := fetcher.GetImageFirstURL(imagePageURL)
-	if err != nil {
-		return nil, err
+	var lastErr *ecode.ErrorWithCode
+	succeeded := false
+	for _, n := range providerOrder() {
+		p, ok := getProvider(n)
+		if !ok || (query.ImageURL == "" && requiresImageURL(p)) {
+			continue
+		}
+		list, err := p.Search(ctx, query)
+		if err != nil {
+			log.Printf("搜图服务 %s 失败: %s", n, err.Msg)
+			lastErr = err
+			continue
+		}
+		if len(list) > 0 {
+			return list, nil
+		}
+		succeeded = true
 	}
-	//2.获取图片搜索结果
-	imageSearchResult, err := fetcher.GetImageList(firstUrl)
-	if err != nil {
-		return nil, err
+	if !succeeded && lastErr != nil {
+		return nil, lastErr
 	}
-	return imageSearchResult, nil
+	return []model.ImageSearchResult{}, nil
 }
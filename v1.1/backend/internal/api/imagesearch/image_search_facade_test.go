@@ -0,0 +1,97 @@
+package imagesearch
+
+import (
+	"backend/config"
+	"backend/internal/api/imagesearch/model"
+	"backend/internal/ecode"
+	"context"
+	"testing"
+)
+
+type fakeProvider struct {
+	name   string
+	result []model.ImageSearchResult
+	err    *ecode.ErrorWithCode
+	calls  int
+}
+
+func (f *fakeProvider) Name() string { return f.name }
+
+func (f *fakeProvider) Search(ctx context.Context, query *model.SearchQuery) ([]model.ImageSearchResult, *ecode.ErrorWithCode) {
+	f.calls++
+	return f.result, f.err
+}
+
+// 替换全局配置并清空已注册的服务，测试结束后恢复
+func setupFacade(t *testing.T, providers ...string) {
+	oldConf := config.Conf
+	registry.Lock()
+	oldProviders := registry.providers
+	registry.providers = make(map[string]Provider)
+	registry.Unlock()
+	t.Cleanup(func() {
+		config.Conf = oldConf
+		registry.Lock()
+		registry.providers = oldProviders
+		registry.Unlock()
+	})
+	config.Conf = &config.AppConfig{ImageSearchConfig: &config.ImageSearchConfig{Providers: providers}}
+}
+
+func TestSearchImageFallback(t *testing.T) {
+	setupFacade(t, "a", "missing", "b", "c")
+	a := &fakeProvider{name: "a", err: ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "失败")}
+	b := &fakeProvider{name: "b"}
+	c := &fakeProvider{name: "c", result: []model.ImageSearchResult{{FromURL: "https://example.com", Source: "c"}}}
+	for _, p := range []Provider{a, b, c} {
+		Register(p)
+	}
+	// a出错、b没有结果、未注册的服务跳过，使用c的结果
+	list, err := SearchImage(context.Background(), "", &model.SearchQuery{})
+	if err != nil || len(list) != 1 || list[0].Source != "c" {
+		t.Fatalf("SearchImage = %+v, %v", list, err)
+	}
+	if a.calls != 1 || b.calls != 1 || c.calls != 1 {
+		t.Fatalf("调用次数错误: %d %d %d", a.calls, b.calls, c.calls)
+	}
+	// 指定服务时不回退
+	if _, err := SearchImage(context.Background(), "a", &model.SearchQuery{}); err == nil {
+		t.Fatal("指定的服务出错时应返回错误")
+	}
+	if _, err := SearchImage(context.Background(), "unknown", &model.SearchQuery{}); err == nil || err.Code != ecode.PARAMS_ERROR {
+		t.Fatal("未注册的服务应返回参数错误")
+	}
+	// 全部出错时返回错误
+	config.Conf.ImageSearchConfig.Providers = []string{"a"}
+	if _, err := SearchImage(context.Background(), "", &model.SearchQuery{}); err == nil {
+		t.Fatal("全部出错时应返回错误")
+	}
+}
+
+type fakeURLProvider struct {
+	fakeProvider
+}
+
+func (f *fakeURLProvider) RequiresImageURL() bool { return true }
+
+func TestSearchImageWithoutURL(t *testing.T) {
+	setupFacade(t, "external", "local")
+	external := &fakeURLProvider{fakeProvider{name: "external", result: []model.ImageSearchResult{{Source: "external"}}}}
+	local := &fakeProvider{name: "local", result: []model.ImageSearchResult{{Source: "local"}}}
+	Register(external)
+	Register(local)
+	// 没有图片地址时跳过需要地址的服务
+	list, err := SearchImage(context.Background(), "", &model.SearchQuery{})
+	if err != nil || len(list) != 1 || list[0].Source != "local" || external.calls != 0 {
+		t.Fatalf("SearchImage = %+v, %v, external调用%d次", list, err, external.calls)
+	}
+	// 指定需要地址的服务时返回错误
+	if _, err := SearchImage(context.Background(), "external", &model.SearchQuery{}); err == nil || external.calls != 0 {
+		t.Fatal("没有图片地址时指定外部服务应返回错误")
+	}
+	// 有地址时正常调用
+	list, err = SearchImage(context.Background(), "", &model.SearchQuery{ImageURL: "https://example.com/a.png"})
+	if err != nil || len(list) != 1 || list[0].Source != "external" {
+		t.Fatalf("SearchImage = %+v, %v", list, err)
+	}
+}
@@ -1,5 +1,7 @@
 package model
 
+import "backend/internal/model/entity"
+
 type APIResponse struct {
 	Status int       `json:"status"`
 	Data   ImageData `json:"data"`
@@ -12,6 +14,19 @@ type ImageData struct {
 
 // 定义图片搜索结果结构体
 type ImageSearchResult struct {
-	ThumbURL string `json:"thumbURL"` // 缩略图地址
-	FromURL  string `json:"fromURL"`  // 来源地址
+	ThumbURL   string  `json:"thumbURL"`                                         // 缩略图地址
+	FromURL    string  `json:"fromURL"`                                          // 来源地址
+	Title      string  `json:"title,omitempty"`                                  // 标题，部分服务不返回
+	Source     string  `json:"source"`                                           // 结果来自哪个搜图服务
+	PictureID  uint64  `json:"pictureId,string,omitempty" swaggertype:"string"` // 本站图片ID，只有local返回
+	Similarity float64 `json:"similarity,omitempty"`                             // 相似度，只有local返回
+}
+
+// 以图搜图的查询参数，各服务按需使用
+type SearchQuery struct {
+	ImageURL    string       // 可被外部搜图服务直接访问的图片地址
+	PictureID   uint64       // 本站图片ID
+	User        *entity.User // 当前登录用户，local用于校验权限
+	Limit       int          // 最多返回的数量，为0时使用各服务的默认值
+	MaxDistance *int         // local的最大汉明距离，为nil时使用默认值
 }
@@ -0,0 +1,20 @@
+package consts
+
+import "time"
+
+// 图片归档状态
+const (
+	PICTURE_ARCHIVE_NONE      = 0 // 正常
+	PICTURE_ARCHIVED          = 1 // 已归档，只能查看缩略图
+	PICTURE_ARCHIVE_RESTORING = 2 // 恢复中
+)
+
+// 冷存储归档相关常量
+const (
+	ARCHIVE_PREFIX              = "archive"      // 归档对象的存储前缀，私有对象
+	ARCHIVE_IDLE_DAYS           = 180            // 默认超过该天数未查看和编辑的图片会被归档
+	ARCHIVE_INTERVAL_HOURS      = 24             // 归档任务的默认执行间隔，单位小时
+	ARCHIVE_BATCH               = 100            // 每批检查的图片数量
+	ARCHIVE_VIEW_TOUCH_INTERVAL = 24 * time.Hour // 最近查看时间的更新间隔，避免每次查看都写数据库
+	ARCHIVE_RESTORE_RETRY       = time.Hour      // 恢复请求超过该时长仍未完成时重新投递
+)
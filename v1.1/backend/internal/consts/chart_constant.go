@@ -8,6 +8,11 @@ const (
 	MQOutPaintingQueueName  = "out_painting_tasks"
 	OutPaintingConsumerName = "outpainting_consumer"
 
+	// 归档恢复队列和消费者
+	MQArchiveRestoreQueueName  = "archive_restore_tasks"
+	MQArchiveRestoreRoutingKey = "archive.restore"
+	ArchiveRestoreConsumerName = "archive_restore_consumer"
+
 	// 任务类型
 	MQDeadLetterExchangeName = "dlx.exchange"    // 死信交换机名称
 	MQDeadLetterQueueName    = "dlx.queue"       // 死信队列名称
@@ -0,0 +1,11 @@
+package consts
+
+// 按颜色搜索相关常量
+const (
+	COLOR_SEARCH_DEFAULT_THRESHOLD = 10.0 // 默认的ΔE2000色差阈值，2~10为相近的颜色
+	COLOR_SEARCH_MAX_THRESHOLD     = 20.0 // 允许请求的最大色差阈值，颜色索引的候选桶按此范围校验过
+	COLOR_SEARCH_MAX_COLORS        = 5    // 一次最多同时匹配的颜色数
+	COLOR_SEARCH_DEFAULT_PAGE_SIZE = 20   // 默认每页数量
+	COLOR_SEARCH_MAX_PAGE_SIZE     = 50   // 最大每页数量
+	COLOR_BACKFILL_BATCH           = 100  // 补算调色板时每批处理的图片数量
+)
@@ -0,0 +1,29 @@
+package consts
+
+import "time"
+
+// 导出任务状态
+const (
+	EXPORT_STATUS_WAITING = "wait"
+	EXPORT_STATUS_RUNNING = "running"
+	EXPORT_STATUS_SUCCEED = "succeed"
+	EXPORT_STATUS_FAILED  = "failed"
+)
+
+// 导出清单格式
+const (
+	EXPORT_MANIFEST_JSON = "json"
+	EXPORT_MANIFEST_CSV  = "csv"
+)
+
+// 批量导出相关常量
+const (
+	EXPORT_PREFIX          = "export"         // 导出压缩包的存储前缀，私有对象
+	EXPORT_MAX_PICTURES    = 5000             // 单次导出的最大图片数量
+	EXPORT_BATCH           = 100              // 每批读取的图片数量
+	EXPORT_PROGRESS_STEP   = 10               // 每处理该数量的图片更新一次进度
+	EXPORT_JOB_EXPIRE      = 72 * time.Hour   // 导出任务和压缩包的保留时长，超过后由存储回收任务清理
+	EXPORT_DOWNLOAD_EXPIRE = time.Hour        // 下载地址的有效期
+	EXPORT_STALE_TIMEOUT   = 10 * time.Minute // 执行中的任务超过该时长没有进度视为中断
+	EXPORT_POOL_SIZE       = 4                // 同时执行的导出任务数量
+)
@@ -0,0 +1,12 @@
+package consts
+
+import "time"
+
+// 抓取远程地址相关常量
+const (
+	FETCH_TIMEOUT          = 15 * time.Second // 单次抓取的超时时间，包含读取响应体
+	FETCH_MAX_REDIRECTS    = 3                // 最多跟随的重定向次数
+	FETCH_PICTURE_MAX_SIZE = 2 * 1024 * 1024  // 通过URL上传的图片最大为 2MB
+	FETCH_PAGE_MAX_SIZE    = 5 * 1024 * 1024  // 抓取的网页最大为 5MB
+	FETCH_TEMP_DIR         = "tempfile"       // 下载图片的临时目录，文件名随机生成
+)
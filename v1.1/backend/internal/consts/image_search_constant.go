@@ -0,0 +1,12 @@
+package consts
+
+import "time"
+
+// 以图搜图服务名称
+const (
+	IMAGE_SEARCH_BAIDU = "baidu" // 百度识图，返回外部来源链接
+	IMAGE_SEARCH_BING  = "bing"  // 必应识图，需要配置 Bing Visual Search 的订阅密钥
+	IMAGE_SEARCH_LOCAL = "local" // 按感知哈希在图片所在的空间或公共图库中查找相似图片
+)
+
+const IMAGE_SEARCH_TIMEOUT = 10 * time.Second // 外部搜图服务单次请求的默认超时时间
@@ -0,0 +1,23 @@
+package consts
+
+import "time"
+
+// 批量导入单个文件的处理结果
+const (
+	IMPORT_RESULT_IMPORTED  = "imported"  // 导入成功
+	IMPORT_RESULT_DUPLICATE = "duplicate" // 与空间中已有图片或压缩包中的其他文件内容相同，未导入
+	IMPORT_RESULT_SKIPPED   = "skipped"   // 不是图片，未导入
+	IMPORT_RESULT_FAILED    = "failed"    // 校验或上传失败
+)
+
+// 批量导入相关常量，任务状态与导出任务相同
+const (
+	IMPORT_MAX_ZIP_SIZE      = 512 * 1024 * 1024 // 压缩包最大为 512MB
+	IMPORT_MAX_FILES         = 5000              // 压缩包中最多包含的文件数量
+	IMPORT_MANIFEST_MAX_SIZE = 10 * 1024 * 1024  // 清单文件最大为 10MB
+	IMPORT_PROGRESS_STEP     = 10                // 每处理该数量的文件更新一次进度
+	IMPORT_JOB_EXPIRE        = 72 * time.Hour    // 导入任务和结果报告的保留时长
+	IMPORT_POOL_SIZE         = 2                 // 同时执行的导入任务数量
+	IMPORT_NAME_MAX_LEN      = 20                // 图片名称的最大长度，超出时截断
+	IMPORT_INTRO_MAX_SIZE    = 800               // 图片简介的最大字节数，超出时截断
+)
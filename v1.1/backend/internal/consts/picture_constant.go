@@ -7,6 +7,9 @@ const (
 	ALL       = 3 //任意状态
 )
 
+// 每张图片默认保留的历史版本数量
+const PICTURE_MAX_VERSIONS = 10
+
 //校验审核参数是否存在，用于写数据库的WRAPPER
 func ReviewValueExist(value int) bool {
 	switch value {
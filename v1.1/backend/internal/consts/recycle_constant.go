@@ -0,0 +1,8 @@
+package consts
+
+// 回收站相关常量
+const (
+	RECYCLE_INTERVAL_HOURS = 24  // 自动清理的默认执行间隔，单位小时
+	RECYCLE_PURGE_BATCH    = 100 // 每批彻底删除的图片数量
+	RECYCLE_MAX_BATCH      = 100 // 一次恢复或彻底删除的最大图片数量
+)
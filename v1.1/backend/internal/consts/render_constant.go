@@ -0,0 +1,16 @@
+package consts
+
+import "time"
+
+// 图片渲染接口相关常量
+const (
+	RENDER_MAX_SIZE          = 4096                // 渲染结果的最大宽高
+	RENDER_DEFAULT_FORMAT    = "webp"              // 默认输出格式
+	RENDER_VARIANT_PREFIX    = "render"            // 渲染结果在对象存储中的前缀
+	RENDER_VARIANT_RETENTION = 30 * 24 * time.Hour // 存储中的渲染结果保留时长，过期后由存储回收任务清理，再次访问时重新生成
+	RENDER_LOCAL_CACHE_TTL   = time.Hour           // 本地缓存有效期
+	RENDER_LOCAL_CACHE_LIMIT = 2 * 1024 * 1024     // 超过该大小的渲染结果不放入本地缓存
+	RENDER_PUBLIC_MAX_AGE    = 7 * 24 * 3600       // 公共图库图片的浏览器缓存时间，单位秒
+	RENDER_PRIVATE_MAX_AGE   = 600                 // 空间图片的浏览器缓存时间，单位秒
+	RENDER_SIGN_EXPIRE       = 15 * time.Minute    // 签名渲染地址的有效期
+)
@@ -0,0 +1,9 @@
+package consts
+
+import "time"
+
+// 上传内容安全检查相关常量
+const (
+	SCAN_TIMEOUT      = 30 * time.Second // 一次上传的全部扫描器总超时时间
+	QUARANTINE_PREFIX = "quarantine"     // 被隔离的原图在对象存储中的前缀，以私有权限保存，不参与存储回收
+)
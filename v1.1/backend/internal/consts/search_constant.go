@@ -0,0 +1,13 @@
+package consts
+
+// 图片搜索方式
+const (
+	SEARCH_MODE_FULLTEXT = "fulltext" // MySQL全文索引，按相关度排序
+	SEARCH_MODE_LIKE     = "like"     // LIKE模糊查询，不计算相关度
+)
+
+// 相关度权重的默认值，最终相关度 = 全部字段得分 + 名称得分*NAME_BOOST + 分类和标签得分*LABEL_BOOST
+const (
+	SEARCH_NAME_BOOST  = 2.0
+	SEARCH_LABEL_BOOST = 1.5
+)
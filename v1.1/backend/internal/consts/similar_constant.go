@@ -0,0 +1,13 @@
+package consts
+
+import "time"
+
+// 本地相似图片搜索相关常量
+const (
+	SIMILAR_DEFAULT_DISTANCE = 10              // 默认的最大汉明距离，64位哈希中不超过10位不同通常为同一张图的变体
+	SIMILAR_MAX_DISTANCE     = 20              // 允许请求的最大汉明距离
+	SIMILAR_DEFAULT_LIMIT    = 20              // 默认返回数量
+	SIMILAR_MAX_LIMIT        = 50              // 最多返回数量
+	SIMILAR_INDEX_TTL        = 5 * time.Minute // 内存索引的有效期，其他实例新上传的图片最迟在该时间后可以搜到
+	SIMILAR_BACKFILL_BATCH   = 100             // 补算哈希时每批处理的图片数量
+)
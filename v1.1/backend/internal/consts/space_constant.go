@@ -2,31 +2,35 @@ package consts
 
 // SpaceLevel 表示空间等级及其属性。
 type SpaceLevel struct {
-	Value    int    `json:"value"`    //空间的等级
-	Text     string `json:"text"`     //空间的等级名称
-	MaxCount int64  `json:"maxCount"` //空间图片的最大数量
-	MaxSize  int64  `json:"maxSize"`  //空间图片的最大总大小，单位是Byte
+	Value       int    `json:"value"`       //空间的等级
+	Text        string `json:"text"`        //空间的等级名称
+	MaxCount    int64  `json:"maxCount"`    //空间图片的最大数量
+	MaxSize     int64  `json:"maxSize"`     //空间图片的最大总大小，单位是Byte
+	MaxFileSize int64  `json:"maxFileSize"` //单张图片的最大大小，单位是Byte
 }
 
 // 定义每个空间等级及其属性。
 var (
 	COMMON = SpaceLevel{
-		Text:     "普通版",             // 普通版
-		Value:    0,                 // 等级值为 0
-		MaxCount: 100,               // 最大图片数量为 100
-		MaxSize:  100 * 1024 * 1024, // 最大图片总大小为 100MB
+		Text:        "普通版",             // 普通版
+		Value:       0,                 // 等级值为 0
+		MaxCount:    100,               // 最大图片数量为 100
+		MaxSize:     100 * 1024 * 1024, // 最大图片总大小为 100MB
+		MaxFileSize: 10 * 1024 * 1024,  // 单张图片最大为 10MB
 	}
 	PROFESSIONAL = SpaceLevel{
-		Text:     "专业版",              // 专业版
-		Value:    1,                  // 等级值为 1
-		MaxCount: 1000,               // 最大图片数量为 1000
-		MaxSize:  1000 * 1024 * 1024, // 最大图片总大小为 1000MB
+		Text:        "专业版",              // 专业版
+		Value:       1,                  // 等级值为 1
+		MaxCount:    1000,               // 最大图片数量为 1000
+		MaxSize:     1000 * 1024 * 1024, // 最大图片总大小为 1000MB
+		MaxFileSize: 50 * 1024 * 1024,   // 单张图片最大为 50MB
 	}
 	FLAGSHIP = SpaceLevel{
-		Text:     "旗舰版",              // 旗舰版
-		Value:    2,                  // 等级值为 2
-		MaxCount: 10000,              // 最大图片数量为 10000
-		MaxSize:  1000 * 1024 * 1024, // 最大图片总大小为 10000MB
+		Text:        "旗舰版",              // 旗舰版
+		Value:       2,                  // 等级值为 2
+		MaxCount:    10000,              // 最大图片数量为 10000
+		MaxSize:     1000 * 1024 * 1024, // 最大图片总大小为 10000MB
+		MaxFileSize: 100 * 1024 * 1024,  // 单张图片最大为 100MB
 	}
 	FirstSpaceLevel = COMMON.Value   // 默认的空间等级为普通版
 	LastSpaceLevel  = FLAGSHIP.Value // 最高的空间等级为旗舰版
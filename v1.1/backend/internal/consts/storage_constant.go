@@ -0,0 +1,17 @@
+package consts
+
+import "time"
+
+// 存储回收任务相关常量
+const (
+	STORAGE_GC_INTERVAL_HOURS = 24        // 默认执行间隔，单位小时
+	STORAGE_GC_RETENTION_DAYS = 30        // 软删除记录默认保留天数，超过后对象视为孤儿
+	STORAGE_GC_GRACE_PERIOD   = time.Hour // 新写入的对象在该时间内不回收，避免误删上传中的图片
+	STORAGE_GC_REPORT_LIMIT   = 1000      // 报告中最多列出的孤儿对象数量
+)
+
+// 私有空间和团队空间图片的临时访问地址有效期
+const STORAGE_SIGN_URL_EXPIRE = 15 * time.Minute
+
+// 存储回收扫描的对象前缀
+var StorageGCPrefixes = []string{"public/", "space/", "avatar/"}
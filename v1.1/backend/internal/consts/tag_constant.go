@@ -0,0 +1,15 @@
+package consts
+
+// 标签相关常量
+const (
+	TAG_NAME_MAX_LEN          = 32                              // 单个标签名称的最大字符数
+	TAG_MAX_COUNT             = 20                              // 一张图片最多的标签数量
+	TAGS_MAX_SIZE             = 512                             // 图片tags字段的最大字节数，与数据库列的长度一致
+	TAG_MATCH_ANY             = "any"                           // 按标签筛选时匹配任一标签
+	TAG_MATCH_ALL             = "all"                           // 按标签筛选时匹配全部标签，默认值
+	TAG_SUGGEST_DEFAULT_LIMIT = 10                              // 标签自动补全默认返回的数量
+	TAG_SUGGEST_MAX_LIMIT     = 50                              // 标签自动补全最多返回的数量
+	TAG_MIGRATE_BATCH         = 200                             // 迁移历史标签时每批处理的图片数量
+	TAG_REFRESH_BATCH         = 200                             // 重命名、合并、删除标签后每批刷新的图片数量
+	TAG_MIGRATE_DONE_KEY      = "chg:migrate:picture_tags:done" // 历史标签迁移完成的标记，存在时启动不再迁移
+)
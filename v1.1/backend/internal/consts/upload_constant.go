@@ -0,0 +1,15 @@
+package consts
+
+import "time"
+
+// 分片上传相关常量
+const (
+	UPLOAD_PART_SIZE            = 5 * 1024 * 1024  // 分片大小为 5MB，最后一片可以更小
+	UPLOAD_PUBLIC_MAX_FILE_SIZE = 10 * 1024 * 1024 // 公共图库单张图片最大为 10MB
+	UPLOAD_SESSION_EXPIRE       = 24 * time.Hour   // 上传会话的有效期
+	UPLOAD_SESSION_PREFIX       = "upload/session" // 分片在对象存储中的前缀
+	UPLOAD_PRESIGN_EXPIRE       = 15 * time.Minute // 预签名上传URL的有效期
+	UPLOAD_PRESIGN_CONFIRM_TTL  = time.Hour        // 直传记录的有效期，超时未确认的对象由存储回收任务清理
+	UPLOAD_LOCK_EXPIRE          = time.Minute      // 完成上传时分布式锁的过期时间，处理期间定期续期
+	UPLOAD_LOCK_EXTEND_INTERVAL = 20 * time.Second // 分布式锁的续期间隔，小于过期时间的一半
+)
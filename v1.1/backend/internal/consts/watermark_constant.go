@@ -0,0 +1,14 @@
+package consts
+
+// 空间水印相关常量
+const (
+	WATERMARK_PREFIX         = "watermark" // 水印图片的存储前缀，私有对象
+	WATERMARK_TYPE_TEXT      = "text"      // 文字水印
+	WATERMARK_TYPE_IMAGE     = "image"     // 图片水印
+	WATERMARK_TEXT_MAX_LEN   = 64          // 文字水印的最大字符数
+	WATERMARK_IMAGE_MAX_SIZE = 1 << 20     // 水印图片的最大大小，单位Byte
+	WATERMARK_IMAGE_MAX_EDGE = 2048        // 水印图片的最大宽高
+	WATERMARK_DEFAULT_COLOR  = "#FFFFFF"   // 文字水印的默认颜色
+	WATERMARK_DEFAULT_ALPHA  = 0.5         // 默认不透明度
+	WATERMARK_DEFAULT_SCALE  = 0.2         // 默认水印宽度占图片宽度的比例
+)
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"backend/internal/api/imagesearch"
 	"backend/internal/service"
 )
 
@@ -11,4 +12,19 @@ func Init() {
 	sSpaceUser = service.NewSpaceUserService()
 	sUser = service.NewUserService()
 	sITask = service.NewITaskService()
+	sUploadSession = service.NewUploadSessionService()
+	sStorageGC = service.NewStorageGCService()
+	sPictureRender = service.NewPictureRenderService()
+	sPictureVersion = service.NewPictureVersionService()
+	sRecycleBin = service.NewRecycleBinService()
+	sSpaceWatermark = service.NewSpaceWatermarkService()
+	sPictureArchive = service.NewPictureArchiveService()
+	sPictureExport = service.NewPictureExportService()
+	sPictureImport = service.NewPictureImportService()
+	sPictureSimilar = service.NewPictureSimilarService()
+	sPictureColor = service.NewPictureColorService()
+	sTag = service.NewTagService()
+	//注册以图搜图服务，本站的相似图片搜索由sPictureSimilar提供
+	imagesearch.Init()
+	imagesearch.Register(sPictureSimilar)
 }
@@ -4,7 +4,7 @@ import (
 	"backend/internal/common"
 	"backend/internal/ecode"
 	"backend/internal/manager"
-	"backend/pkg/tcos"
+	"backend/pkg/storage"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"io"
@@ -14,7 +14,7 @@ import (
 
 func TestUploadPicture(c *gin.Context) {
 	file, _ := c.FormFile("file")
-	manager.UploadPicture(file, "test")
+	manager.UploadPicture(file, "test", manager.UploadOptions{})
 
 }
 
@@ -26,14 +26,14 @@ func TestUploadPicture(c *gin.Context) {
 // @Accept       mpfd
 // @Produce      json
 // @Param        file formData file true "文件"
-// @Success      200  {object}  common.Response{data=string} "响应文件存储在COS的KEY"
+// @Success      200  {object}  common.Response{data=string} "响应文件在对象存储中的KEY"
 // @Failure      400  {object}  common.Response "更新失败，详情见响应中的code"
 // @Router       /v1/file/test/upload [POST]
 func TestUploadFile(c *gin.Context) {
 
 	file, _ := c.FormFile("file") // 忽略错误，后面会检查
 
-	manager.UploadPicture(file, "test") // 调用业务管理器处理图片（如果有特殊处理）
+	manager.UploadPicture(file, "test", manager.UploadOptions{}) // 调用业务管理器处理图片（如果有特殊处理）
 
 	src, err := file.Open()
 	if err != nil {
@@ -43,22 +43,22 @@ func TestUploadFile(c *gin.Context) {
 	}
 	defer src.Close() // 确保关闭文件描述符，防止资源泄漏
 
-	// 4. 构建COS存储路径
+	// 4. 构建对象存储路径
 
 	key := fmt.Sprintf("test/%s", file.Filename) // 格式：test/文件名
 
-	// 5. 上传到腾讯云COS
+	// 5. 上传到对象存储
 	// 为什么使用PutObject：直接流式上传，避免本地存储
-	err = tcos.PutObject(src, key)
+	err = storage.GetStore().PutObject(key, src, file.Header.Get("Content-Type"))
 	if err != nil {
 		log.Print(err) // 记录详细错误供排查
-		// 为什么返回系统错误：COS操作失败属于后端问题
+		// 为什么返回系统错误：存储操作失败属于后端问题
 		common.BaseResponse(c, nil, "上传失败", ecode.SYSTEM_ERROR)
 		return
 	}
 
 	// 6. 返回成功响应
-	common.Success(c, key) // 返回文件在COS的存储路径。保证上传完成
+	common.Success(c, key) // 返回文件在对象存储中的路径。保证上传完成
 }
 
 // 下载测试接口（流式下载，无显式响应），是的，如果客户端不需要知道文件存储位置，第一个上传接口完全可以改为不显式响应存储路径
@@ -67,7 +67,7 @@ func TestUploadFile(c *gin.Context) {
 // @Summary      测试文件下载接口「管理员」
 // @Tags         file
 // @Produce      octet-stream
-// @Param        key query string true "文件存储在 COS 的 KEY"
+// @Param        key query string true "文件在对象存储中的 KEY"
 // @Success      200 {file} file "返回文件流"
 // @Failure      400 {object} common.Response "下载失败，详情见响应中的 code"
 // @Router       /v1/file/test/download [GET]
@@ -81,9 +81,9 @@ func TestDownloadFile(c *gin.Context) {
 		return
 	}
 
-	// 2. 从腾讯云COS获取文件
+	// 2. 从对象存储获取文件
 	// 为什么返回Reader：流式处理，避免内存中加载大文件
-	reader, err := tcos.GetObject(key)
+	reader, err := storage.GetStore().GetObject(key)
 	if err != nil {
 		log.Printf("文件下载失败: %v", err) // 记录详细错误
 		common.BaseResponse(c, nil, "文件下载失败", ecode.SYSTEM_ERROR)
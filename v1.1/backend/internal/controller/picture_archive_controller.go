@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"backend/internal/common"
+	"backend/internal/ecode"
+	reqPicture "backend/internal/model/request/picture"
+	"backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+var sPictureArchive *service.PictureArchiveService
+
+// RestoreArchivedPicture godoc
+// @Summary      申请恢复已归档的图片「登录校验」
+// @Description  需要有查看图片的权限，恢复为异步执行，完成前图片的归档状态为恢复中
+// @Tags         picture
+// @Accept       json
+// @Produce      json
+// @Param        request body reqPicture.PictureArchiveRestoreRequest true "图片ID"
+// @Success      200  {object}  common.Response{data=bool} "申请成功"
+// @Failure      400  {object}  common.Response "申请失败，详情见响应中的code"
+// @Router       /v1/picture/archive/restore [POST]
+// @Security BearerAuth
+func RestoreArchivedPicture(c *gin.Context) {
+	req := reqPicture.PictureArchiveRestoreRequest{}
+	if err := c.ShouldBind(&req); err != nil {
+		common.BaseResponse(c, nil, "参数绑定错误", ecode.PARAMS_ERROR)
+		return
+	}
+	loginUser, err := sUser.GetLoginUser(c)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	if err := sPictureArchive.RequestRestore(&req, loginUser); err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, true)
+}
+
+// RunPictureArchive godoc
+// @Summary      执行一次冷存储归档「管理员」
+// @Description  归档配置等级的空间中长期未查看和编辑的图片，并重新投递超时未完成的恢复请求
+// @Tags         picture
+// @Produce      json
+// @Success      200  {object}  common.Response{data=resPicture.ArchiveReport} "执行成功，返回归档报告"
+// @Failure      400  {object}  common.Response "执行失败，详情见响应中的code"
+// @Router       /v1/picture/archive/run [POST]
+// @Security BearerAuth
+func RunPictureArchive(c *gin.Context) {
+	report, err := sPictureArchive.RunArchive()
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, *report)
+}
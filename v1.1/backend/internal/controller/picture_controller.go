@@ -10,6 +10,7 @@ import (
 	resPicture "backend/internal/model/response/picture"
 	"backend/internal/service"
 	"github.com/gin-gonic/gin"
+	"slices"
 	"strconv"
 	"strings"
 	//resPicture "CanvasCloud/internal/models/response/picture"
@@ -21,6 +22,8 @@ func dumb2() {
 }
 
 var sPicture *service.PictureService
+var sPictureSimilar *service.PictureSimilarService
+var sPictureColor *service.PictureColorService
 
 // 给忘记了，wc
 // Query String (查询参数)	URL ? 后拼接的键值对	c.Query("key")	搜索过滤、分页参数	/api/users?page=2&limit=10
@@ -242,7 +245,8 @@ func GetPictureVOById(c *gin.Context) {
 		common.BaseResponse(c, nil, "没有权限", ecode.NO_AUTH_ERROR)
 		return
 	}
-	common.Success(c, *picVO)
+	sPictureArchive.TouchView(pic.ID)
+	common.Success(c, sPicture.SignPictureVO(*picVO, picVO.PermissionList))
 }
 
 // 管理员
@@ -345,6 +349,10 @@ func ListPictureVOByPage(c *gin.Context) {
 		for idx := range pics.Records {
 			pics.Records[idx].PermissionList = PermissionList
 		}
+		//空间图片签发临时访问地址，复制一份避免修改缓存中的数据
+		signed := *pics
+		signed.Records = sPicture.SignPictureVOList(pics.Records, PermissionList)
+		pics = &signed
 	}
 	common.Success(c, *pics)
 
@@ -425,6 +433,10 @@ func ListPictureVOByPageWithCache(c *gin.Context) {
 		for idx := range pics.Records {
 			pics.Records[idx].PermissionList = PermissionList
 		}
+		//空间图片签发临时访问地址，复制一份避免修改缓存中的数据
+		signed := *pics
+		signed.Records = sPicture.SignPictureVOList(pics.Records, PermissionList)
+		pics = &signed
 	}
 	common.Success(c, *pics)
 }
@@ -502,6 +514,10 @@ func ProListPictureVOByPageWithCache(c *gin.Context) {
 		for idx := range pics.Records {
 			pics.Records[idx].PermissionList = PermissionList
 		}
+		//空间图片签发临时访问地址，复制一份避免修改缓存中的数据
+		signed := *pics
+		signed.Records = sPicture.SignPictureVOList(pics.Records, PermissionList)
+		pics = &signed
 	}
 	common.Success(c, *pics)
 }
@@ -584,10 +600,11 @@ func DoPictureReview(c *gin.Context) {
 	common.Success(c, true)
 }
 
-// 根据ID的图片去百度搜索图片
+// 根据ID的图片以图搜图，可以指定百度、必应或本站的相似图片搜索，未指定时按配置的顺序依次尝试
 
 // SearchPictureByPicture godoc
 // @Summary      根据图片ID搜索图片
+// @Description  兼容旧的mode字段：provider为空且mode为local时，data为[]resPicture.SimilarPictureVO
 // @Tags         picture
 // @Accept       json
 // @Produce      json
@@ -606,12 +623,61 @@ func SearchPictureByPicture(c *gin.Context) {
 		common.BaseResponse(c, nil, "参数错误", ecode.PARAMS_ERROR)
 		return
 	}
+	//兼容旧的mode字段，local按原格式返回本站的相似图片
+	if req.Provider == "" {
+		switch req.Mode {
+		case "":
+		case consts.IMAGE_SEARCH_BAIDU:
+			req.Provider = consts.IMAGE_SEARCH_BAIDU
+		case consts.IMAGE_SEARCH_LOCAL:
+			loginUser, _ := sUser.GetLoginUser(c)
+			resultList, err := sPictureSimilar.SearchSimilarPictures(req.PictureId, req.MaxDistance, req.Limit, loginUser)
+			if err != nil {
+				common.BaseResponse(c, nil, err.Msg, err.Code)
+				return
+			}
+			common.Success(c, resultList)
+			return
+		default:
+			common.BaseResponse(c, nil, "不支持的搜索方式", ecode.PARAMS_ERROR)
+			return
+		}
+	}
 	oldPic, err := sPicture.GetPictureById(req.PictureId)
 	if err != nil || oldPic == nil {
 		common.BaseResponse(c, nil, "不存在该图片，或图片获取失败", ecode.PARAMS_ERROR)
 		return
 	}
-	resultList, err := imagesearch.SearchImage(oldPic.URL)
+	loginUser, _ := sUser.GetLoginUser(c)
+	//空间图片需要查看权限
+	if oldPic.SpaceID != 0 {
+		space, err := sSpace.GetSpaceById(oldPic.SpaceID)
+		if err != nil {
+			common.BaseResponse(c, nil, err.Msg, err.Code)
+			return
+		}
+		if !slices.Contains(service.GetPermissionList(space, loginUser), "picture:view") {
+			common.BaseResponse(c, nil, "没有权限", ecode.NO_AUTH_ERROR)
+			return
+		}
+	}
+	//外部搜图服务不支持webp，通过渲染接口转为png，并签名以便搜图服务直接访问；未配置渲染服务时使用原图地址；只使用本地搜索时不需要
+	var picURL string
+	if req.Provider != consts.IMAGE_SEARCH_LOCAL {
+		picURL, err = sPictureRender.ExternalPictureURL(oldPic, &reqPicture.PictureRenderRequest{Format: "png"}, consts.RENDER_SIGN_EXPIRE)
+		//指定外部服务时返回错误，未指定时以空地址搜索，跳过需要图片地址的服务
+		if err != nil && req.Provider != "" {
+			common.BaseResponse(c, nil, err.Msg, err.Code)
+			return
+		}
+	}
+	resultList, err := imagesearch.SearchImage(c.Request.Context(), req.Provider, &imgSearchModel.SearchQuery{
+		ImageURL:    picURL,
+		PictureID:   oldPic.ID,
+		User:        loginUser,
+		Limit:       req.Limit,
+		MaxDistance: req.MaxDistance,
+	})
 	if err != nil {
 		common.BaseResponse(c, nil, err.Msg, err.Code)
 		return
@@ -619,15 +685,16 @@ func SearchPictureByPicture(c *gin.Context) {
 	common.Success(c, resultList)
 }
 
-// 根据颜色搜索在指定id空间图片
+// 根据颜色搜索在指定id空间图片，可以同时指定多种颜色，按ΔE2000色差匹配图片的调色板
 
 // SearchPictureByColor godoc
 // @Summary      根据图片的颜色搜索相似图片「登录校验」
+// @Description  图片的调色板中需要有与每种颜色的色差都不超过阈值的颜色，结果按匹配得分从高到低分页返回
 // @Tags         picture
 // @Accept       json
 // @Produce      json
-// @Param		request body reqPicture.PictureSearchByColorRequest true "图片的颜色和空间ID"
-// @Success      200  {object}  common.Response{data=[]resPicture.PictureVO} "获取成功"
+// @Param		request body reqPicture.PictureSearchByColorRequest true "颜色、空间ID、色差阈值和分页参数"
+// @Success      200  {object}  common.Response{data=resPicture.ListColorPictureVOResponse} "获取成功"
 // @Failure      400  {object}  common.Response "获取失败，详情见响应中的code"
 // @Router       /v1/picture/search/color [POST]
 // @Security BearerAuth
@@ -637,12 +704,12 @@ func SearchPictureByColor(c *gin.Context) {
 		common.BaseResponse(c, nil, "参数绑定失败", ecode.PARAMS_ERROR)
 		return
 	}
-	if req.PicColor == "" || req.SpaceID <= 0 {
+	if (req.PicColor == "" && len(req.Colors) == 0) || req.SpaceID <= 0 {
 		common.BaseResponse(c, nil, "参数错误", ecode.PARAMS_ERROR)
 		return
 	}
 	loginUser, _ := sUser.GetLoginUser(c)
-	resultList, err := sPicture.SearchPictureByColor(loginUser, req.PicColor, req.SpaceID)
+	resultList, err := sPictureColor.SearchPictureByColor(&req, loginUser)
 	if err != nil {
 		common.BaseResponse(c, nil, err.Msg, err.Code)
 		return
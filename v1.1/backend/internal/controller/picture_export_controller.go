@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"backend/internal/common"
+	"backend/internal/ecode"
+	reqPicture "backend/internal/model/request/picture"
+	"backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+var sPictureExport *service.PictureExportService
+
+// CreatePictureExportJob godoc
+// @Summary      创建批量导出任务「登录校验」
+// @Description  按查询条件将图片原图和元数据清单打包为ZIP，异步执行；空间中需要有查看图片的权限，不传空间ID时导出公共图库，仅管理员可用
+// @Tags         picture
+// @Accept       json
+// @Produce      json
+// @Param        request body reqPicture.PictureExportRequest true "查询条件和清单格式"
+// @Success      200  {object}  common.Response{data=resPicture.PictureExportJobVO} "创建成功"
+// @Failure      400  {object}  common.Response "创建失败，详情见响应中的code"
+// @Router       /v1/picture/export/create [POST]
+// @Security BearerAuth
+func CreatePictureExportJob(c *gin.Context) {
+	req := reqPicture.PictureExportRequest{}
+	if err := c.ShouldBind(&req); err != nil {
+		common.BaseResponse(c, nil, "参数绑定错误", ecode.PARAMS_ERROR)
+		return
+	}
+	loginUser, err := sUser.GetLoginUser(c)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	job, err := sPictureExport.CreateExportJob(&req, loginUser)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, *job)
+}
+
+// GetPictureExportJob godoc
+// @Summary      获取批量导出任务的进度「登录校验」
+// @Description  任务成功后返回带签名的下载地址，只能查询本人创建的任务
+// @Tags         picture
+// @Produce      json
+// @Param        jobId query string true "导出任务ID"
+// @Success      200  {object}  common.Response{data=resPicture.PictureExportJobVO} "获取成功"
+// @Failure      400  {object}  common.Response "获取失败，详情见响应中的code"
+// @Router       /v1/picture/export/get [GET]
+// @Security BearerAuth
+func GetPictureExportJob(c *gin.Context) {
+	loginUser, err := sUser.GetLoginUser(c)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	job, err := sPictureExport.GetExportJob(c.Query("jobId"), loginUser)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, *job)
+}
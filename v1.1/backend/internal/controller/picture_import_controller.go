@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"backend/internal/common"
+	"backend/internal/ecode"
+	reqPicture "backend/internal/model/request/picture"
+	"backend/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+var sPictureImport *service.PictureImportService
+
+// CreatePictureImportJob godoc
+// @Summary      从ZIP压缩包批量导入图片「登录校验」
+// @Description  需要有空间的上传权限，压缩包不超过512MB；可以带有manifest.json或manifest.csv清单指定名称、简介、分类和标签，内容重复的图片不导入
+// @Tags         picture
+// @Accept       mpfd
+// @Produce      json
+// @Param        file formData file true "ZIP压缩包"
+// @Param        spaceId formData string true "目标空间ID"
+// @Success      200  {object}  common.Response{data=resPicture.PictureImportJobVO} "创建成功"
+// @Failure      400  {object}  common.Response "创建失败，详情见响应中的code"
+// @Router       /v1/picture/import/create [POST]
+// @Security BearerAuth
+func CreatePictureImportJob(c *gin.Context) {
+	file, originErr := c.FormFile("file")
+	if originErr != nil {
+		common.BaseResponse(c, nil, "参数错误", ecode.PARAMS_ERROR)
+		return
+	}
+	spaceId, _ := strconv.ParseUint(c.PostForm("spaceId"), 10, 64)
+	req := &reqPicture.PictureImportRequest{SpaceID: spaceId}
+	loginUser, err := sUser.GetLoginUser(c)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	job, err := sPictureImport.CreateImportJob(req, file, loginUser)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, *job)
+}
+
+// GetPictureImportJob godoc
+// @Summary      获取批量导入任务的进度和结果「登录校验」
+// @Description  返回已处理文件的结果，只能查询本人创建的任务
+// @Tags         picture
+// @Produce      json
+// @Param        jobId query string true "导入任务ID"
+// @Success      200  {object}  common.Response{data=resPicture.PictureImportJobVO} "获取成功"
+// @Failure      400  {object}  common.Response "获取失败，详情见响应中的code"
+// @Router       /v1/picture/import/get [GET]
+// @Security BearerAuth
+func GetPictureImportJob(c *gin.Context) {
+	loginUser, err := sUser.GetLoginUser(c)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	job, err := sPictureImport.GetImportJob(c.Query("jobId"), loginUser)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, *job)
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"backend/internal/common"
+	"backend/internal/consts"
+	"backend/internal/ecode"
+	reqPicture "backend/internal/model/request/picture"
+	"backend/internal/service"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+var sPictureRender *service.PictureRenderService
+
+// RenderPicture godoc
+// @Summary      按尺寸和格式渲染图片
+// @Description  从原图生成指定尺寸、格式和质量的图片并缓存，权限与获取图片详情一致；携带服务端生成的签名时无需登录
+// @Tags         picture
+// @Produce      image/webp,image/jpeg,image/png,image/avif
+// @Param        id path string true "图片的ID"
+// @Param        request query reqPicture.PictureRenderRequest false "渲染参数"
+// @Success      200  {file}  binary "渲染后的图片"
+// @Success      304  "图片未变化"
+// @Failure      400  {object}  common.Response "渲染失败，详情见响应中的code"
+// @Router       /v1/picture/render/{id} [GET]
+// @Security BearerAuth
+func RenderPicture(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if id <= 0 {
+		common.BaseResponse(c, nil, "参数错误", ecode.PARAMS_ERROR)
+		return
+	}
+	var req reqPicture.PictureRenderRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		common.BaseResponse(c, nil, "参数绑定失败", ecode.PARAMS_ERROR)
+		return
+	}
+	loginUser, _ := sUser.GetLoginUser(c)
+	res, err := sPictureRender.RenderPicture(id, &req, loginUser)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	etag := `"` + res.ETag + `"`
+	c.Header("ETag", etag)
+	if res.Public {
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", consts.RENDER_PUBLIC_MAX_AGE))
+	} else {
+		c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", consts.RENDER_PRIVATE_MAX_AGE))
+	}
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, res.ContentType, res.Data)
+}
+
+// GetPictureOriginal godoc
+// @Summary      获取图片的原图地址「登录校验」
+// @Description  原图不添加空间水印，只有拥有编辑权限的成员可以获取，私有空间返回临时访问地址
+// @Tags         picture
+// @Produce      json
+// @Param        id query string true "图片的ID"
+// @Success      200  {object}  common.Response{data=string} "原图地址"
+// @Failure      400  {object}  common.Response "获取失败，详情见响应中的code"
+// @Router       /v1/picture/original [GET]
+// @Security BearerAuth
+func GetPictureOriginal(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Query("id"), 10, 64)
+	if id <= 0 {
+		common.BaseResponse(c, nil, "参数错误", ecode.PARAMS_ERROR)
+		return
+	}
+	loginUser, err := sUser.GetLoginUser(c)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	originURL, err := sPictureRender.GetOriginalURL(id, loginUser)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, originURL)
+}
@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"backend/internal/common"
+	"backend/internal/ecode"
+	reqPicture "backend/internal/model/request/picture"
+	"backend/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+var sTag *service.TagService
+
+// SuggestPictureTags godoc
+// @Summary      标签自动补全「登录校验」
+// @Description  按前缀匹配空间中的标签，不区分大小写，按图片数量从多到少排列；前缀为空时返回最常用的标签，需要对空间有查看权限
+// @Tags         picture
+// @Produce      json
+// @Param        spaceId query string false "空间ID，不传表示公共图库"
+// @Param        prefix query string false "标签前缀"
+// @Param        limit query int false "返回数量，默认10，最大50"
+// @Success      200  {object}  common.Response{data=[]resPicture.PictureTagVO} "获取成功"
+// @Failure      400  {object}  common.Response "获取失败，详情见响应中的code"
+// @Router       /v1/picture/tag/suggest [GET]
+// @Security BearerAuth
+func SuggestPictureTags(c *gin.Context) {
+	var spaceId uint64
+	var limit int
+	var originErr error
+	if s := c.Query("spaceId"); s != "" {
+		if spaceId, originErr = strconv.ParseUint(s, 10, 64); originErr != nil {
+			common.BaseResponse(c, nil, "参数错误", ecode.PARAMS_ERROR)
+			return
+		}
+	}
+	if s := c.Query("limit"); s != "" {
+		if limit, originErr = strconv.Atoi(s); originErr != nil {
+			common.BaseResponse(c, nil, "参数错误", ecode.PARAMS_ERROR)
+			return
+		}
+	}
+	loginUser, _ := sUser.GetLoginUser(c)
+	res, err := sTag.SuggestTags(spaceId, c.Query("prefix"), limit, loginUser)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, res)
+}
+
+// RenamePictureTag godoc
+// @Summary      重命名空间中的标签「登录校验」
+// @Description  带有该标签的图片同步修改，新名称已被其他标签使用时需要改用合并；需要对空间有编辑图片的权限，公共图库仅限管理员
+// @Tags         picture
+// @Accept       json
+// @Produce      json
+// @Param        request body reqPicture.PictureTagRenameRequest true "原名称和新名称"
+// @Success      200  {object}  common.Response{data=resPicture.PictureTagOperateResponse} "重命名成功"
+// @Failure      400  {object}  common.Response "重命名失败，详情见响应中的code"
+// @Router       /v1/picture/tag/rename [POST]
+// @Security BearerAuth
+func RenamePictureTag(c *gin.Context) {
+	req := reqPicture.PictureTagRenameRequest{}
+	if err := c.ShouldBind(&req); err != nil {
+		common.BaseResponse(c, nil, "参数错误", ecode.PARAMS_ERROR)
+		return
+	}
+	loginUser, _ := sUser.GetLoginUser(c)
+	res, err := sTag.RenameTag(&req, loginUser)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, *res)
+}
+
+// MergePictureTags godoc
+// @Summary      合并空间中的标签「登录校验」
+// @Description  源标签的图片改为目标标签，目标标签不存在时创建，源标签合并后删除；需要对空间有编辑图片的权限，公共图库仅限管理员
+// @Tags         picture
+// @Accept       json
+// @Produce      json
+// @Param        request body reqPicture.PictureTagMergeRequest true "源标签和目标标签"
+// @Success      200  {object}  common.Response{data=resPicture.PictureTagOperateResponse} "合并成功"
+// @Failure      400  {object}  common.Response "合并失败，详情见响应中的code"
+// @Router       /v1/picture/tag/merge [POST]
+// @Security BearerAuth
+func MergePictureTags(c *gin.Context) {
+	req := reqPicture.PictureTagMergeRequest{}
+	if err := c.ShouldBind(&req); err != nil {
+		common.BaseResponse(c, nil, "参数错误", ecode.PARAMS_ERROR)
+		return
+	}
+	loginUser, _ := sUser.GetLoginUser(c)
+	res, err := sTag.MergeTags(&req, loginUser)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, *res)
+}
+
+// DeletePictureTag godoc
+// @Summary      删除空间中的标签「登录校验」
+// @Description  同时从空间的所有图片中移除该标签；需要对空间有编辑图片的权限，公共图库仅限管理员
+// @Tags         picture
+// @Accept       json
+// @Produce      json
+// @Param        request body reqPicture.PictureTagDeleteRequest true "标签名称"
+// @Success      200  {object}  common.Response{data=resPicture.PictureTagOperateResponse} "删除成功"
+// @Failure      400  {object}  common.Response "删除失败，详情见响应中的code"
+// @Router       /v1/picture/tag/delete [POST]
+// @Security BearerAuth
+func DeletePictureTag(c *gin.Context) {
+	req := reqPicture.PictureTagDeleteRequest{}
+	if err := c.ShouldBind(&req); err != nil {
+		common.BaseResponse(c, nil, "参数错误", ecode.PARAMS_ERROR)
+		return
+	}
+	loginUser, _ := sUser.GetLoginUser(c)
+	res, err := sTag.DeleteTag(&req, loginUser)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, *res)
+}
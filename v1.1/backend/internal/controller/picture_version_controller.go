@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"backend/internal/common"
+	"backend/internal/ecode"
+	reqPicture "backend/internal/model/request/picture"
+	"backend/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+var sPictureVersion *service.PictureVersionService
+
+// ListPictureVersions godoc
+// @Summary      获取图片的历史版本列表「登录校验」
+// @Description  重新上传图片时被替换的版本，按版本号从新到旧排列，需要有修改图片的权限
+// @Tags         picture
+// @Produce      json
+// @Param        pictureId query string true "图片的ID"
+// @Success      200  {object}  common.Response{data=resPicture.ListPictureVersionResponse} "获取成功"
+// @Failure      400  {object}  common.Response "获取失败，详情见响应中的code"
+// @Router       /v1/picture/version/list [GET]
+// @Security BearerAuth
+func ListPictureVersions(c *gin.Context) {
+	picId, _ := strconv.ParseUint(c.Query("pictureId"), 10, 64)
+	if picId <= 0 {
+		common.BaseResponse(c, nil, "参数错误", ecode.PARAMS_ERROR)
+		return
+	}
+	loginUser, _ := sUser.GetLoginUser(c)
+	res, err := sPictureVersion.ListPictureVersions(picId, loginUser)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, *res)
+}
+
+// GetPictureVersion godoc
+// @Summary      获取图片的单个历史版本「登录校验」
+// @Tags         picture
+// @Produce      json
+// @Param        id query string true "历史版本的ID"
+// @Success      200  {object}  common.Response{data=resPicture.PictureVersionVO} "获取成功"
+// @Failure      400  {object}  common.Response "获取失败，详情见响应中的code"
+// @Router       /v1/picture/version/get [GET]
+// @Security BearerAuth
+func GetPictureVersion(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Query("id"), 10, 64)
+	if id <= 0 {
+		common.BaseResponse(c, nil, "参数错误", ecode.PARAMS_ERROR)
+		return
+	}
+	loginUser, _ := sUser.GetLoginUser(c)
+	res, err := sPictureVersion.GetPictureVersion(id, loginUser)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, *res)
+}
+
+// RestorePictureVersion godoc
+// @Summary      将图片恢复为指定的历史版本「登录校验」
+// @Description  当前内容保存为新的历史版本，恢复后版本号加1并重新进入审核，恢复的内容重新计入空间额度
+// @Tags         picture
+// @Accept       json
+// @Produce      json
+// @Param        request body reqPicture.PictureVersionRestoreRequest true "历史版本的ID"
+// @Success      200  {object}  common.Response{data=resPicture.PictureVO} "恢复成功"
+// @Failure      400  {object}  common.Response "恢复失败，详情见响应中的code"
+// @Router       /v1/picture/version/restore [POST]
+// @Security BearerAuth
+func RestorePictureVersion(c *gin.Context) {
+	req := reqPicture.PictureVersionRestoreRequest{}
+	if err := c.ShouldBind(&req); err != nil || req.ID <= 0 {
+		common.BaseResponse(c, nil, "参数错误", ecode.PARAMS_ERROR)
+		return
+	}
+	loginUser, _ := sUser.GetLoginUser(c)
+	res, err := sPictureVersion.RestorePictureVersion(&req, loginUser)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, *res)
+}
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"backend/internal/common"
+	"backend/internal/ecode"
+	reqPicture "backend/internal/model/request/picture"
+	"backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+var sRecycleBin *service.RecycleBinService
+
+// ListRecyclePictures godoc
+// @Summary      分页获取回收站中的图片「登录校验」
+// @Description  spaceId为空时查询公共图库的回收站，普通用户只能看到自己删除的图片；查询空间的回收站需要有删除图片的权限
+// @Tags         picture
+// @Accept       json
+// @Produce      json
+// @Param        request body reqPicture.PictureRecycleQueryRequest true "空间ID和分页参数"
+// @Success      200  {object}  common.Response{data=resPicture.ListRecyclePictureResponse} "获取成功"
+// @Failure      400  {object}  common.Response "获取失败，详情见响应中的code"
+// @Router       /v1/picture/recycle/list [POST]
+// @Security BearerAuth
+func ListRecyclePictures(c *gin.Context) {
+	req := reqPicture.PictureRecycleQueryRequest{}
+	if err := c.ShouldBind(&req); err != nil {
+		common.BaseResponse(c, nil, "参数错误", ecode.PARAMS_ERROR)
+		return
+	}
+	loginUser, _ := sUser.GetLoginUser(c)
+	res, err := sRecycleBin.ListRecyclePictures(&req, loginUser)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, *res)
+}
+
+// RestoreRecyclePictures godoc
+// @Summary      恢复回收站中的图片「登录校验」
+// @Description  逐张重新校验空间的图片数量和大小额度，返回每张图片的处理结果
+// @Tags         picture
+// @Accept       json
+// @Produce      json
+// @Param        request body reqPicture.PictureRecycleRequest true "图片ID列表"
+// @Success      200  {object}  common.Response{data=[]resPicture.RecycleResult} "处理完成"
+// @Failure      400  {object}  common.Response "处理失败，详情见响应中的code"
+// @Router       /v1/picture/recycle/restore [POST]
+// @Security BearerAuth
+func RestoreRecyclePictures(c *gin.Context) {
+	req := reqPicture.PictureRecycleRequest{}
+	if err := c.ShouldBind(&req); err != nil {
+		common.BaseResponse(c, nil, "参数错误", ecode.PARAMS_ERROR)
+		return
+	}
+	loginUser, _ := sUser.GetLoginUser(c)
+	res, err := sRecycleBin.RestorePictures(&req, loginUser)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, res)
+}
+
+// PurgeRecyclePictures godoc
+// @Summary      彻底删除回收站中的图片「登录校验」
+// @Description  删除图片记录、元数据和历史版本，不再被引用的存储对象随之删除，操作不可恢复
+// @Tags         picture
+// @Accept       json
+// @Produce      json
+// @Param        request body reqPicture.PictureRecycleRequest true "图片ID列表"
+// @Success      200  {object}  common.Response{data=[]resPicture.RecycleResult} "处理完成"
+// @Failure      400  {object}  common.Response "处理失败，详情见响应中的code"
+// @Router       /v1/picture/recycle/purge [POST]
+// @Security BearerAuth
+func PurgeRecyclePictures(c *gin.Context) {
+	req := reqPicture.PictureRecycleRequest{}
+	if err := c.ShouldBind(&req); err != nil {
+		common.BaseResponse(c, nil, "参数错误", ecode.PARAMS_ERROR)
+		return
+	}
+	loginUser, _ := sUser.GetLoginUser(c)
+	res, err := sRecycleBin.PurgePictures(&req, loginUser)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, res)
+}
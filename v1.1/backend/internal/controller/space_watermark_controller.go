@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"backend/internal/common"
+	"backend/internal/consts"
+	"backend/internal/ecode"
+	reqSpace "backend/internal/model/request/space"
+	"backend/internal/service"
+	"io"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+var sSpaceWatermark *service.SpaceWatermarkService
+
+// EditSpaceWatermark godoc
+// @Summary      修改空间的水印设置「登录校验」
+// @Description  需要有空间的管理权限，只对之后上传的图片生效；图片水印需先通过上传接口设置水印图片
+// @Tags         space
+// @Accept       json
+// @Produce      json
+// @Param        request body reqSpace.SpaceWatermarkEditRequest true "水印设置"
+// @Success      200  {object}  common.Response{data=entity.SpaceWatermark} "修改成功"
+// @Failure      400  {object}  common.Response "修改失败，详情见响应中的code"
+// @Router       /v1/space/watermark/edit [POST]
+// @Security BearerAuth
+func EditSpaceWatermark(c *gin.Context) {
+	req := reqSpace.SpaceWatermarkEditRequest{}
+	if err := c.ShouldBind(&req); err != nil {
+		common.BaseResponse(c, nil, "参数绑定错误", ecode.PARAMS_ERROR)
+		return
+	}
+	loginUser, err := sUser.GetLoginUser(c)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	setting, err := sSpaceWatermark.EditWatermark(&req, loginUser)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, *setting)
+}
+
+// UploadSpaceWatermarkImage godoc
+// @Summary      上传空间的水印图片「登录校验」
+// @Description  需要有空间的管理权限，图片不能超过1MB，建议使用带透明通道的PNG；上传后水印类型切换为图片
+// @Tags         space
+// @Accept       mpfd
+// @Produce      json
+// @Param        file formData file true "水印图片"
+// @Param        spaceId formData string true "空间ID"
+// @Success      200  {object}  common.Response{data=entity.SpaceWatermark} "上传成功"
+// @Failure      400  {object}  common.Response "上传失败，详情见响应中的code"
+// @Router       /v1/space/watermark/image [POST]
+// @Security BearerAuth
+func UploadSpaceWatermarkImage(c *gin.Context) {
+	spaceId, _ := strconv.ParseUint(c.PostForm("spaceId"), 10, 64)
+	file, originErr := c.FormFile("file")
+	if originErr != nil || spaceId <= 0 {
+		common.BaseResponse(c, nil, "参数错误", ecode.PARAMS_ERROR)
+		return
+	}
+	if file.Size > consts.WATERMARK_IMAGE_MAX_SIZE {
+		common.BaseResponse(c, nil, "水印图片不能超过1MB", ecode.PARAMS_ERROR)
+		return
+	}
+	loginUser, err := sUser.GetLoginUser(c)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	src, originErr := file.Open()
+	if originErr != nil {
+		common.BaseResponse(c, nil, "文件读取失败", ecode.SYSTEM_ERROR)
+		return
+	}
+	defer src.Close()
+	data, originErr := io.ReadAll(io.LimitReader(src, consts.WATERMARK_IMAGE_MAX_SIZE+1))
+	if originErr != nil {
+		common.BaseResponse(c, nil, "文件读取失败", ecode.SYSTEM_ERROR)
+		return
+	}
+	setting, err := sSpaceWatermark.UploadWatermarkImage(spaceId, data, loginUser)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, *setting)
+}
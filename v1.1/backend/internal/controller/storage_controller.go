@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"backend/internal/common"
+	resStorage "backend/internal/model/response/storage"
+	"backend/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+func dumb7() {
+	_ = resStorage.StorageGCReport{}
+}
+
+var sStorageGC *service.StorageGCService
+
+// RunStorageGC godoc
+// @Summary      执行存储回收「管理员」
+// @Description  扫描存储中不再被图片和用户引用的对象，默认为演练模式只上报不删除，dryRun=false时实际删除
+// @Tags         storage
+// @Produce      json
+// @Param        dryRun query bool false "是否为演练模式，默认true"
+// @Success      200  {object}  common.Response{data=resStorage.StorageGCReport} "执行成功，返回回收报告"
+// @Failure      400  {object}  common.Response "执行失败，详情见响应中的code"
+// @Router       /v1/storage/gc [POST]
+// @Security BearerAuth
+func RunStorageGC(c *gin.Context) {
+	dryRun := c.DefaultQuery("dryRun", "true") != "false"
+	report, err := sStorageGC.RunStorageGC(dryRun)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, *report)
+}
@@ -0,0 +1,219 @@
+package controller
+
+import (
+	"backend/internal/common"
+	"backend/internal/ecode"
+	reqPicture "backend/internal/model/request/picture"
+	resPicture "backend/internal/model/response/picture"
+	"backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+func dumb6() {
+	_ = resPicture.UploadSessionVO{}
+}
+
+var sUploadSession *service.UploadSessionService
+
+// InitUploadSession godoc
+// @Summary      初始化分片上传会话「需要登录校验」
+// @Description  校验图片类型、单文件大小以及空间剩余额度，返回上传会话信息
+// @Tags         picture
+// @Accept       json
+// @Produce      json
+// @Param        request body reqPicture.UploadSessionInitRequest true "文件信息"
+// @Success      200  {object}  common.Response{data=resPicture.UploadSessionVO} "创建成功，返回上传会话"
+// @Failure      400  {object}  common.Response "创建失败，详情见响应中的code"
+// @Router       /v1/picture/upload/session/init [POST]
+// @Security BearerAuth
+func InitUploadSession(c *gin.Context) {
+	req := reqPicture.UploadSessionInitRequest{}
+	if err := c.ShouldBind(&req); err != nil {
+		common.BaseResponse(c, nil, "参数绑定失败", ecode.PARAMS_ERROR)
+		return
+	}
+	loginUser, err := sUser.GetLoginUser(c)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	sessionVO, err := sUploadSession.InitUploadSession(&req, loginUser)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, *sessionVO)
+}
+
+// UploadSessionPart godoc
+// @Summary      上传单个分片「需要登录校验」
+// @Description  分片序号从1开始，重复上传同一分片会覆盖
+// @Tags         picture
+// @Accept       mpfd
+// @Produce      json
+// @Param        file formData file true "分片数据"
+// @Param        uploadId formData string true "上传会话ID"
+// @Param        partNumber formData int true "分片序号"
+// @Success      200  {object}  common.Response{data=resPicture.UploadSessionVO} "上传成功，返回上传会话"
+// @Failure      400  {object}  common.Response "上传失败，详情见响应中的code"
+// @Router       /v1/picture/upload/session/part [POST]
+// @Security BearerAuth
+func UploadSessionPart(c *gin.Context) {
+	part, _ := c.FormFile("file")
+	partNumber, _ := strconv.Atoi(c.PostForm("partNumber"))
+	loginUser, err := sUser.GetLoginUser(c)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	sessionVO, err := sUploadSession.UploadPart(c.PostForm("uploadId"), partNumber, part, loginUser)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, *sessionVO)
+}
+
+// GetUploadSession godoc
+// @Summary      获取分片上传会话「需要登录校验」
+// @Description  返回已上传的分片序号，用于断点续传
+// @Tags         picture
+// @Produce      json
+// @Param        uploadId query string true "上传会话ID"
+// @Success      200  {object}  common.Response{data=resPicture.UploadSessionVO} "获取成功"
+// @Failure      400  {object}  common.Response "获取失败，详情见响应中的code"
+// @Router       /v1/picture/upload/session/get [GET]
+// @Security BearerAuth
+func GetUploadSession(c *gin.Context) {
+	loginUser, err := sUser.GetLoginUser(c)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	sessionVO, err := sUploadSession.GetUploadSession(c.Query("uploadId"), loginUser)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, *sessionVO)
+}
+
+// CompleteUploadSession godoc
+// @Summary      完成分片上传「需要登录校验」
+// @Description  所有分片上传完毕后合并，按普通上传流程入库并返回图片信息视图
+// @Tags         picture
+// @Accept       json
+// @Produce      json
+// @Param        request body reqPicture.UploadSessionRequest true "上传会话ID"
+// @Success      200  {object}  common.Response{data=resPicture.PictureVO} "上传成功，返回图片信息视图"
+// @Failure      400  {object}  common.Response "上传失败，详情见响应中的code"
+// @Router       /v1/picture/upload/session/complete [POST]
+// @Security BearerAuth
+func CompleteUploadSession(c *gin.Context) {
+	req := reqPicture.UploadSessionRequest{}
+	if err := c.ShouldBind(&req); err != nil {
+		common.BaseResponse(c, nil, "参数绑定失败", ecode.PARAMS_ERROR)
+		return
+	}
+	loginUser, err := sUser.GetLoginUser(c)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	picVO, err := sUploadSession.CompleteUploadSession(req.UploadID, loginUser)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, *picVO)
+}
+
+// AbortUploadSession godoc
+// @Summary      取消分片上传「需要登录校验」
+// @Description  删除已上传的分片和会话记录
+// @Tags         picture
+// @Accept       json
+// @Produce      json
+// @Param        request body reqPicture.UploadSessionRequest true "上传会话ID"
+// @Success      200  {object}  common.Response{data=bool} "取消成功"
+// @Failure      400  {object}  common.Response "取消失败，详情见响应中的code"
+// @Router       /v1/picture/upload/session/abort [POST]
+// @Security BearerAuth
+func AbortUploadSession(c *gin.Context) {
+	req := reqPicture.UploadSessionRequest{}
+	if err := c.ShouldBind(&req); err != nil {
+		common.BaseResponse(c, nil, "参数绑定失败", ecode.PARAMS_ERROR)
+		return
+	}
+	loginUser, err := sUser.GetLoginUser(c)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	if err := sUploadSession.AbortUploadSession(req.UploadID, loginUser); err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, true)
+}
+
+// PresignUpload godoc
+// @Summary      申请预签名直传「需要登录校验」
+// @Description  返回预签名上传地址，客户端直接PUT到对象存储后调用确认接口，仅支持私有空间和团队空间
+// @Tags         picture
+// @Accept       json
+// @Produce      json
+// @Param        request body reqPicture.PresignUploadRequest true "文件信息"
+// @Success      200  {object}  common.Response{data=resPicture.PresignUploadVO} "申请成功，返回上传地址"
+// @Failure      400  {object}  common.Response "申请失败，详情见响应中的code"
+// @Router       /v1/picture/upload/presign [POST]
+// @Security BearerAuth
+func PresignUpload(c *gin.Context) {
+	req := reqPicture.PresignUploadRequest{}
+	if err := c.ShouldBind(&req); err != nil {
+		common.BaseResponse(c, nil, "参数绑定失败", ecode.PARAMS_ERROR)
+		return
+	}
+	loginUser, err := sUser.GetLoginUser(c)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	presignVO, err := sUploadSession.PresignUpload(&req, loginUser)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, *presignVO)
+}
+
+// ConfirmPresignUpload godoc
+// @Summary      确认预签名直传「需要登录校验」
+// @Description  校验对象已上传且大小一致，解析图片并写入图片记录和空间额度，返回图片信息视图
+// @Tags         picture
+// @Accept       json
+// @Produce      json
+// @Param        request body reqPicture.UploadSessionRequest true "上传ID"
+// @Success      200  {object}  common.Response{data=resPicture.PictureVO} "上传成功，返回图片信息视图"
+// @Failure      400  {object}  common.Response "确认失败，详情见响应中的code"
+// @Router       /v1/picture/upload/presign/confirm [POST]
+// @Security BearerAuth
+func ConfirmPresignUpload(c *gin.Context) {
+	req := reqPicture.UploadSessionRequest{}
+	if err := c.ShouldBind(&req); err != nil {
+		common.BaseResponse(c, nil, "参数绑定失败", ecode.PARAMS_ERROR)
+		return
+	}
+	loginUser, err := sUser.GetLoginUser(c)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	picVO, err := sUploadSession.ConfirmPresignUpload(req.UploadID, loginUser)
+	if err != nil {
+		common.BaseResponse(c, nil, err.Msg, err.Code)
+		return
+	}
+	common.Success(c, *picVO)
+}
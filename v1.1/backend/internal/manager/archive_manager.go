@@ -0,0 +1,104 @@
+package manager
+
+import (
+	"backend/internal/consts"
+	"backend/internal/model/entity"
+	"backend/pkg/imageproc"
+	"backend/pkg/storage"
+	"bytes"
+	"io"
+	"log"
+	"path"
+	"strings"
+)
+
+// ArchiveKey 归档对象的存储路径，保留原路径便于恢复
+// 例如 space/1/2025-01-01_abc.webp -> archive/space/1/2025-01-01_abc.webp
+func ArchiveKey(key string) string {
+	return consts.ARCHIVE_PREFIX + "/" + key
+}
+
+// ArchivableKeys 获取图片归档时需要转移的对象：原图、webp主图和衍生版本，缩略图保留在原位置继续展示
+func ArchivableKeys(pic *entity.Picture, originKey string) []string {
+	keys := make([]string, 0, 4)
+	seen := make(map[string]bool)
+	add := func(key string) {
+		if key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	add(originKey)
+	urls := []string{pic.URL}
+	for _, r := range pic.GetRenditions() {
+		urls = append(urls, r.URL)
+	}
+	thumbnailKey, _ := storage.ObjectKey(pic.ThumbnailURL)
+	for _, u := range urls {
+		if key, ok := storage.ObjectKey(u); ok && key != thumbnailKey {
+			add(key)
+		}
+	}
+	return keys
+}
+
+// ArchiveObjects 将对象复制到归档前缀，任一对象失败时删除已复制的部分；原对象由调用方在数据库更新后删除
+func ArchiveObjects(keys []string, storageClass string) error {
+	for i, key := range keys {
+		if err := copyObject(key, ArchiveKey(key), func(dst string, data []byte, contentType string) error {
+			return storage.PutArchiveObject(dst, bytes.NewReader(data), contentType, storageClass)
+		}); err != nil {
+			DeleteObjects(archiveKeys(keys[:i]))
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreObjects 将归档对象复制回原位置，按原位置的前缀决定对象的可见性；任一对象失败时删除已复制的部分
+func RestoreObjects(keys []string) error {
+	for i, key := range keys {
+		if err := copyObject(ArchiveKey(key), key, putObject); err != nil {
+			DeleteObjects(keys[:i])
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteArchivedObjects 删除归档前缀下的对象
+func DeleteArchivedObjects(keys []string) {
+	DeleteObjects(archiveKeys(keys))
+}
+
+// DeleteObjects 删除对象，失败时只记录日志
+func DeleteObjects(keys []string) {
+	store := storage.GetStore()
+	for _, key := range keys {
+		if err := store.DeleteObject(key); err != nil {
+			log.Print(err)
+		}
+	}
+}
+
+func archiveKeys(keys []string) []string {
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, ArchiveKey(key))
+	}
+	return result
+}
+
+// copyObject 读取src并通过put写入dst，存储接口没有复制操作，统一经过服务端中转
+func copyObject(src string, dst string, put func(dst string, data []byte, contentType string) error) error {
+	r, err := storage.GetStore().GetObject(src)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return err
+	}
+	return put(dst, data, imageproc.ContentType(strings.TrimPrefix(path.Ext(src), ".")))
+}
@@ -0,0 +1,59 @@
+package manager
+
+import (
+	"backend/internal/consts"
+	"backend/internal/ecode"
+	"backend/pkg/safefetch"
+	"context"
+	"errors"
+	"log"
+)
+
+// 抓取用户提供的图片地址，禁止访问内网地址，限制大小和时长
+var pictureFetcher = safefetch.New(safefetch.Options{
+	Timeout:      consts.FETCH_TIMEOUT,
+	MaxSize:      consts.FETCH_PICTURE_MAX_SIZE,
+	MaxRedirects: consts.FETCH_MAX_REDIRECTS,
+	// 真实格式按文件内容识别，这里只排除明显不是图片的响应
+	ContentTypes: []string{"image/", "application/octet-stream", "binary/octet-stream"},
+})
+
+// 抓取批量导入使用的搜索结果页
+var pageFetcher = safefetch.New(safefetch.Options{
+	Timeout:      consts.FETCH_TIMEOUT,
+	MaxSize:      consts.FETCH_PAGE_MAX_SIZE,
+	MaxRedirects: consts.FETCH_MAX_REDIRECTS,
+	ContentTypes: []string{"text/html", "application/xhtml+xml"},
+	UserAgent:    "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Safari/537.36",
+})
+
+// FetchPage 抓取网页内容
+func FetchPage(pageURL string) ([]byte, *ecode.ErrorWithCode) {
+	body, _, originErr := pageFetcher.Fetch(context.Background(), pageURL)
+	if originErr != nil {
+		return nil, FetchError(originErr)
+	}
+	return body, nil
+}
+
+// FetchError 将抓取错误转化为业务错误，地址本身的问题属于参数错误
+func FetchError(err error) *ecode.ErrorWithCode {
+	for _, target := range []error{
+		safefetch.ErrInvalidURL,
+		safefetch.ErrSchemeNotAllowed,
+		safefetch.ErrBlockedAddress,
+		safefetch.ErrTooManyRedirects,
+		safefetch.ErrTooLarge,
+		safefetch.ErrContentType,
+		safefetch.ErrUnexpectedStatus,
+	} {
+		if errors.Is(err, target) {
+			return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, target.Error())
+		}
+	}
+	if errors.Is(err, safefetch.ErrTimeout) {
+		return ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "下载超时")
+	}
+	log.Println("抓取远程地址失败，错误为", err)
+	return ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "下载失败")
+}
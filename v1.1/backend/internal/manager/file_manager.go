@@ -1,33 +1,36 @@
 package manager
 
 import (
+	"context"
 	"crypto/md5"   // MD5哈希
+	"crypto/sha256"
 	"encoding/hex" // 十六进制编码
 	"fmt"
 	"io"
 	"log"
 	"math"
-	"math/rand"      // 随机数
 	"mime/multipart" // 文件上传处理
-	"net/http"       // HTTP请求
-	"net/url"        // URL解析
 	"os"
-	"strconv" // 字符串转换
 	"strings"
 	"time"
-	"backend/config"                  // 项目配置
+	"backend/internal/consts"
 	"backend/internal/ecode"          // 错误码
 	"backend/internal/model/dto/file" // 文件DTO
-	"backend/pkg/tcos"                // 腾讯云COS操作封装
+	"backend/internal/model/entity"
+	"backend/internal/repository"     // 去重对象查询
+	"backend/pkg/imageproc"           // 本地图片处理
+	"backend/pkg/scanner"             // 内容安全检查
+	"backend/pkg/storage"             // 对象存储抽象
 
 	"github.com/google/uuid" // UUID生成
 )
 
 // UploadPicture 处理文件上传图片
 // multipartFile: 上传的文件对象
-// uploadPrefix: COS存储路径前缀
+// uploadPrefix: 对象存储路径前缀
+// opts: 衍生版本、元数据等处理选项
 // 返回: 上传结果信息和错误
-func UploadPicture(multipartFile *multipart.FileHeader, uploadPrefix string) (*file.UploadPictureResult, *ecode.ErrorWithCode) {
+func UploadPicture(multipartFile *multipart.FileHeader, uploadPrefix string, opts UploadOptions) (*file.UploadPictureResult, *ecode.ErrorWithCode) {
 	// 1. 校验图片文件是否合法
 	if err := ValidPicture(multipartFile); err != nil {
 		return nil, err
@@ -45,53 +48,317 @@ func UploadPicture(multipartFile *multipart.FileHeader, uploadPrefix string) (*f
 	uploadFileName := fmt.Sprintf("%s_%s.%s", time.Now().Format("2006-01-02"), id, fileType)
 	fileNameNoType := uploadFileName[:strings.LastIndex(uploadFileName, ".")]
 
-	// COS存储路径: 前缀/文件名
+	// 对象存储路径: 前缀/文件名
 	uploadPath := fmt.Sprintf("%s/%s", uploadPrefix, uploadFileName)
 
-	// 3. 打开文件流并上传到COS
-	src, _ := multipartFile.Open()
+	// 3. 打开文件流并上传到对象存储
+	src, originErr := multipartFile.Open()
+	if originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "文件打开失败")
+	}
 	defer src.Close()
 
-	// 调用压缩上传函数
-	_, err := tcos.PutPictureWithCompress(src, uploadPath)
+	result, err := storePicture(src, multipartFile.Size, uploadPath, fileType, opts)
 	if err != nil {
-		log.Print(err)
-		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "上传失败")
+		return nil, err
 	}
+	result.PicName = fileNameNoType // 图片名称（不含后缀）
+	return result, nil
+}
 
-	// 4. 获取上传后的图片信息
-	// 压缩后格式变为webp
-	uploadPath = strings.Replace(uploadPath, fileType, "webp", 1)
+// UploadPictureByStream 处理分片上传合并后的图片
+// 单文件大小由上传会话按空间等级校验，这里不再套用2MB的限制
+func UploadPictureByStream(stream *file.PictureStream, uploadPrefix string, opts UploadOptions) (*file.UploadPictureResult, *ecode.ErrorWithCode) {
+	// 1. 校验图片类型
+	if stream == nil || stream.Reader == nil || stream.Size <= 0 {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "文件为空")
+	}
+	if err := ValidPictureType(stream.FileName); err != nil {
+		return nil, err
+	}
+
+	// 2. 生成唯一文件名和存储路径
+	fileType := stream.FileName[strings.LastIndex(stream.FileName, ".")+1:]
+	uploadPath := GenUploadPath(uploadPrefix, fileType)
+
+	// 3. 处理并上传
+	result, err := storePicture(stream.Reader, stream.Size, uploadPath, fileType, opts)
+	if err != nil {
+		return nil, err
+	}
+	result.PicName = picNameOfPath(uploadPath)
+	return result, nil
+}
+
+// UploadPictureByObject 处理客户端通过预签名URL直传到对象存储的图片
+// 原图已经在存储中，只需读取后生成webp主图与缩略图
+func UploadPictureByObject(key string, fileSize int64, opts UploadOptions) (*file.UploadPictureResult, *ecode.ErrorWithCode) {
+	if err := ValidPictureType(key); err != nil {
+		return nil, err
+	}
+	src, originErr := storage.GetStore().GetObject(key)
+	if originErr != nil {
+		log.Print(originErr)
+		return nil, ecode.GetErrWithDetail(ecode.NOT_FOUND_ERROR, "文件未上传")
+	}
+	defer src.Close()
+	// 多读一个字节用于判断是否超出申请的大小
+	data, originErr := io.ReadAll(io.LimitReader(src, fileSize+1))
+	if originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "读取文件失败")
+	}
+	if int64(len(data)) != fileSize {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "文件大小与申请时不一致")
+	}
+	fileType := key[strings.LastIndex(key, ".")+1:]
+	result, err := processPicture(data, key, fileType, false, opts)
+	if err != nil {
+		return nil, err
+	}
+	result.PicName = picNameOfPath(key)
+	return result, nil
+}
+
+// GenUploadPath 生成唯一的存储路径: 前缀/日期_唯一ID.后缀
+func GenUploadPath(uploadPrefix string, fileType string) string {
+	u := uuid.New()
+	hash := md5.Sum(u[:])
+	id := hex.EncodeToString(hash[:])[:16]
+	return fmt.Sprintf("%s/%s_%s.%s", uploadPrefix, time.Now().Format("2006-01-02"), id, fileType)
+}
 
-	// 缩略图路径
-	thumbnailUrl := strings.Replace(uploadPath, ".webp", "_thumbnail."+fileType, 1)
+// picNameOfPath 从存储路径中取出不含后缀的文件名
+func picNameOfPath(uploadPath string) string {
+	name := uploadPath[strings.LastIndex(uploadPath, "/")+1:]
+	return strings.TrimSuffix(name, name[strings.LastIndex(name, "."):])
+}
 
-	// 获取图片元数据
-	picInfo, err := tcos.GetPictureInfo(uploadPath)
+// storePicture 在本地完成图片处理后写入对象存储
+// 原图保持原样保存，另外生成webp主图与 _thumbnail 缩略图，图片信息与主色调均由本地计算
+// size为已校验过的文件大小，最多读取size+1字节，实际大小不一致时拒绝，避免读入超出额度的数据
+func storePicture(src io.Reader, size int64, uploadPath string, fileType string, opts UploadOptions) (*file.UploadPictureResult, *ecode.ErrorWithCode) {
+	data, err := io.ReadAll(io.LimitReader(src, size+1))
 	if err != nil {
-		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "获取图片信息失败")
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "读取文件失败")
+	}
+	if int64(len(data)) != size {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "文件大小与声明的不一致")
+	}
+	return processPicture(data, uploadPath, fileType, true, opts)
+}
+
+// UploadOptions 上传图片时的处理选项
+type UploadOptions struct {
+	Profile   string          // 衍生版本集合名称，为空时不生成衍生版本
+	StripGPS  bool            // 是否去除原图EXIF中的GPS信息
+	Scanner   scanner.Scanner // 内容安全检查，为nil时不检查
+	Watermark *Watermark      // 主图、缩略图和衍生版本上叠加的水印，为nil时不添加，原图始终不添加
+	NoDedup   bool            // 是否跳过内容去重，复用的内容被并发删除后重新上传时使用
+}
+
+// 待写入对象存储的一个对象
+type storeObject struct {
+	key         string
+	data        []byte
+	contentType string
+}
+
+// processPicture 处理图片数据并上传各版本，withOrigin为false时表示原图已在存储中
+func processPicture(data []byte, uploadPath string, fileType string, withOrigin bool, opts UploadOptions) (*file.UploadPictureResult, *ecode.ErrorWithCode) {
+	profile := opts.Profile
+	var mark *imageproc.Watermark
+	if opts.Watermark != nil {
+		profile = WatermarkProfile(profile, opts.Watermark.Fingerprint)
+		mark = opts.Watermark.Mark
+	}
+	// 0. 按文件内容识别图片格式，不信任文件后缀和Content-Type
+	format, originErr := imageproc.Validate(data)
+	if originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, originErr.Error())
+	}
+	// 由服务端写入的原图以真实格式作为后缀
+	if withOrigin && normalizeFileType(fileType) != format {
+		uploadPath = strings.TrimSuffix(uploadPath, "."+fileType) + "." + format
+		fileType = format
+	}
+	// 写入存储前进行内容安全检查，检查的是用户上传的原始内容
+	if err := scanPicture(opts.Scanner, data, uploadPath, format, withOrigin); err != nil {
+		return nil, err
+	}
+	// 按空间设置去除原图中的GPS信息，内容哈希基于实际保存的原图计算
+	stripped := false
+	if opts.StripGPS {
+		data, stripped = imageproc.StripGPS(data)
+	}
+	metadata := toPictureMetadata(imageproc.ReadMetadata(data))
+	// 计算原图内容哈希，图库中已有相同内容时直接复用已存储的对象
+	sum := sha256.Sum256(data)
+	contentHash := hex.EncodeToString(sum[:])
+	// 这里的查询未加锁，调用方需要在事务中锁定复用的内容；直传的原图保留到调用方确认复用后再删除
+	if !opts.NoDedup && isDedupPath(uploadPath) {
+		content, err := repository.NewPictureContentRepository().FindByHash(nil, contentHash, profile, IsPrivateObjectKey(uploadPath))
+		if err != nil {
+			log.Print(err)
+		} else if content != nil {
+			return &file.UploadPictureResult{
+				URL:          content.URL,
+				ThumbnailURL: content.ThumbnailURL,
+				PicSize:      content.PicSize,
+				PicWidth:     content.PicWidth,
+				PicHeight:    content.PicHeight,
+				PicScale:     content.PicScale,
+				PicFormat:    content.PicFormat,
+				PicColor:     content.PicColor,
+				PHash:        content.PHash,
+				DHash:        content.DHash,
+				Palette:      entity.ParsePalette(content.Palette),
+				FrameCount:   max(content.FrameCount, 1),
+				Renditions:   entity.ParseRenditions(content.Renditions),
+				Profile:      profile,
+				Metadata:     metadata,
+				ContentHash:  contentHash,
+				OriginKey:    content.OriginKey,
+				Reused:       true,
+			}, nil
+		}
 	}
 
-	// 获取图片主色调
-	color, err := tcos.GetPictureColor(uploadPath)
+	// 1. 解码并生成webp主图、缩略图，计算图片信息和主色调
+	thumbnailFormat := imageproc.ThumbnailFormat(format)
+	processed, err := imageproc.ProcessWithWatermark(data, thumbnailFormat, mark, renditionSpecs(opts.Profile)...)
 	if err != nil {
-		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "获取图片主色调失败")
+		log.Print(err)
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "图片解析失败")
+	}
+
+	// 2. 计算各版本的存储路径
+	// 压缩后格式变为webp
+	webpPath := strings.TrimSuffix(uploadPath, "."+fileType) + ".webp"
+	// 缩略图路径，HEIC等无法直接编码的格式使用jpg或png
+	thumbnailPath := strings.TrimSuffix(uploadPath, "."+fileType) + "_thumbnail." + thumbnailFormat
+
+	// 3. 上传原图、主图和缩略图
+	store := storage.GetStore()
+	objects := []storeObject{
+		{webpPath, processed.WebP, imageproc.ContentType("webp")},
+		{thumbnailPath, processed.Thumbnail, imageproc.ContentType(thumbnailFormat)},
+	}
+	// 直传的私有原图由客户端上传，权限未知，需要以私有权限重新写入；去除了GPS的原图也需要重新写入
+	if withOrigin || stripped || IsPrivateObjectKey(uploadPath) {
+		objects = append(objects, storeObject{uploadPath, data, imageproc.ContentType(format)})
+	}
+	renditions := make([]entity.PictureRendition, 0, len(processed.Renditions))
+	for _, r := range processed.Renditions {
+		key := renditionKey(uploadPath, fileType, r.Spec)
+		objects = append(objects, storeObject{key, r.Data, imageproc.ContentType(r.Spec.Format)})
+		renditions = append(renditions, entity.PictureRendition{
+			Name:   r.Spec.Name,
+			URL:    store.ObjectURL(key),
+			Width:  r.Width,
+			Height: r.Height,
+			Format: r.Spec.Format,
+		})
+	}
+	for _, obj := range objects {
+		if err := putObject(obj.key, obj.data, obj.contentType); err != nil {
+			log.Print(err)
+			return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "上传失败")
+		}
 	}
 
-	// 5. 构造返回结果
+	// 4. 构造返回结果
+	picInfo := processed.Info
 	return &file.UploadPictureResult{
-		URL:          config.LoadConfig().Tcos.Host + "/" + uploadPath,                     // 完整URL
-		ThumbnailURL: config.LoadConfig().Tcos.Host + "/" + thumbnailUrl,                   // 缩略图URL
-		PicName:      fileNameNoType,                                                       // 图片名称（不含后缀）
+		URL:          store.ObjectURL(webpPath),                                            // 完整URL
+		ThumbnailURL: store.ObjectURL(thumbnailPath),                                       // 缩略图URL
 		PicSize:      picInfo.Size,                                                         // 文件大小
 		PicWidth:     picInfo.Width,                                                        // 图片宽度
 		PicHeight:    picInfo.Height,                                                       // 图片高度
 		PicScale:     math.Round(float64(picInfo.Width)/float64(picInfo.Height)*100) / 100, // 宽高比
-		PicFormat:    picInfo.Format,                                                       // 图片格式
-		PicColor:     color,                                                                // 主色调
+		PicFormat:    processed.Format,                                                     // 原图的真实格式
+		PicColor:     processed.Color,                                                      // 主色调
+		PHash:        imageproc.FormatHash(processed.PHash),                                // 感知哈希
+		DHash:        imageproc.FormatHash(processed.DHash),                                // 差异哈希
+		Palette:      toEntityPalette(processed.Palette),                                   // 调色板
+		FrameCount:   picInfo.FrameCount,                                                   // 帧数
+		Renditions:   renditions,                                                           // 衍生版本
+		Profile:      profile,                                                              // 衍生版本集合
+		Metadata:     metadata,                                                             // EXIF元数据
+		ContentHash:  contentHash,                                                          // 原图内容哈希
+		OriginKey:    uploadPath,                                                           // 原图key
 	}, nil
 }
 
+// 转化为图片元数据表的记录，图片ID由调用方填充
+func toPictureMetadata(meta *imageproc.Metadata) *entity.PictureMetadata {
+	if meta == nil {
+		return nil
+	}
+	return &entity.PictureMetadata{
+		Make:         meta.Make,
+		Model:        meta.Model,
+		LensModel:    meta.LensModel,
+		ExposureTime: meta.ExposureTime,
+		FNumber:      meta.FNumber,
+		ISO:          meta.ISO,
+		FocalLength:  meta.FocalLength,
+		TakenAt:      meta.TakenAt,
+		Latitude:     meta.Latitude,
+		Longitude:    meta.Longitude,
+		Orientation:  meta.Orientation,
+	}
+}
+
+// 转化为图片表中存储的调色板
+func toEntityPalette(palette []imageproc.PaletteColor) []entity.PaletteColor {
+	result := make([]entity.PaletteColor, 0, len(palette))
+	for _, c := range palette {
+		result = append(result, entity.PaletteColor{Color: c.Color, Weight: c.Weight})
+	}
+	return result
+}
+
+// 只有图库中的图片参与去重，头像等其他对象不参与
+func isDedupPath(uploadPath string) bool {
+	return strings.HasPrefix(uploadPath, "public/") || strings.HasPrefix(uploadPath, "space/")
+}
+
+// DeletePictureObjects 删除一张图片的原图、webp主图、缩略图和衍生版本
+func DeletePictureObjects(originKey string, url string, thumbnailURL string, renditions []entity.PictureRendition) {
+	store := storage.GetStore()
+	keys := []string{originKey}
+	urls := []string{url, thumbnailURL}
+	for _, r := range renditions {
+		urls = append(urls, r.URL)
+	}
+	for _, u := range urls {
+		if key, ok := storage.ObjectKey(u); ok {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		if err := store.DeleteObject(key); err != nil {
+			log.Print(err)
+		}
+	}
+}
+
+// ObjectBaseKey 获取对象所属图片的公共前缀，原图、webp主图、缩略图和衍生版本得到的结果相同
+// 例如 space/1/2025-01-01_abc_thumbnail.png -> space/1/2025-01-01_abc
+func ObjectBaseKey(key string) string {
+	slash := strings.LastIndex(key, "/")
+	if i := strings.LastIndex(key, "."); i > slash {
+		key = key[:i]
+	}
+	if i := strings.LastIndex(key, renditionKeyTag); i > slash {
+		key = key[:i]
+	}
+	return strings.TrimSuffix(key, "_thumbnail")
+}
+
 // ValidPicture 验证上传的图片文件是否合法
 func ValidPicture(multipartFile *multipart.FileHeader) *ecode.ErrorWithCode {
 	// 1. 检查文件是否为空
@@ -107,16 +374,21 @@ func ValidPicture(multipartFile *multipart.FileHeader) *ecode.ErrorWithCode {
 	}
 
 	// 3. 检查文件类型
-	lastDotIndex := strings.LastIndex(multipartFile.Filename, ".")
+	return ValidPictureType(multipartFile.Filename)
+}
+
+// ValidPictureType 根据文件名后缀校验图片类型
+func ValidPictureType(fileName string) *ecode.ErrorWithCode {
+	lastDotIndex := strings.LastIndex(fileName, ".")
 	if lastDotIndex == -1 {
 		return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "文件不是图片")
 	}
 
-	// 获取文件后缀（带点）
-	fileType := multipartFile.Filename[lastDotIndex:]
+	// 获取文件后缀（带点），手机拍摄的图片后缀常为大写
+	fileType := strings.ToLower(fileName[lastDotIndex:])
 
-	// 允许的文件类型
-	allowType := []string{".jpg", ".jpeg", ".png", ".webp"}
+	// 允许的文件类型，文件内容在处理时另行校验
+	allowType := []string{".jpg", ".jpeg", ".png", ".webp", ".gif", ".bmp", ".tif", ".tiff", ".heic", ".heif", ".avif"}
 	isAllow := false
 	for _, v := range allowType {
 		if fileType == v {
@@ -132,203 +404,51 @@ func ValidPicture(multipartFile *multipart.FileHeader) *ecode.ErrorWithCode {
 	return nil
 }
 
+// normalizeFileType 将文件后缀转化为imageproc.Sniff返回的格式名称
+func normalizeFileType(fileType string) string {
+	switch fileType = strings.ToLower(fileType); fileType {
+	case "jpeg":
+		return "jpg"
+	case "tif":
+		return "tiff"
+	case "heif":
+		return "heic"
+	}
+	return fileType
+}
+
 // UploadPictureByURL 通过URL上传图片
-func UploadPictureByURL(fileURL string, uploadPrefix string, picName string) (*file.UploadPictureResult, *ecode.ErrorWithCode) {
+// 下载由pictureFetcher完成：禁止访问内网地址，限制大小和时长，临时文件名随机生成
+func UploadPictureByURL(fileURL string, uploadPrefix string, picName string, opts UploadOptions) (*file.UploadPictureResult, *ecode.ErrorWithCode) {
 	// 1. 处理图片名称
 	if picName == "" {
 		picName = "临时图片"
 	}
 
-	// 添加随机后缀防止并发覆盖
-	picName = picName + strconv.Itoa(rand.Intn(999999)+1)
-
-	// 2. 校验URL图片
-	if err := ValidPictureByURL(fileURL, &picName); err != nil {
-		return nil, err
-	}
-
-	// 3. 下载图片到本地临时文件
-	localFilePath, err := downLoadPictureByURL(fileURL, &picName)
-	if err != nil {
-		return nil, err
+	// 2. 下载图片到本地临时文件
+	localFilePath, _, originErr := pictureFetcher.Download(context.Background(), fileURL, consts.FETCH_TEMP_DIR)
+	if originErr != nil {
+		return nil, FetchError(originErr)
 	}
-
 	// 确保删除临时文件
-	defer deleteTempFile(localFilePath)
-
-	// 4. 生成唯一文件名和存储路径
-	u := uuid.New()
-	hash := md5.Sum(u[:])
-	id := hex.EncodeToString(hash[:])[:16]
-
-	// 获取文件后缀
-	fileType := localFilePath[strings.LastIndex(localFilePath, ".")+1:]
-
-	// 构造文件名: 日期_唯一ID.后缀
-	uploadFileName := fmt.Sprintf("%s_%s.%s", time.Now().Format("2006-01-02"), id, fileType)
-
-	// COS存储路径
-	uploadPath := fmt.Sprintf("%s/%s", uploadPrefix, uploadFileName)
-
-	// 5. 打开文件流并上传到COS
-	src, _ := os.Open(localFilePath)
-	defer src.Close()
-
-	_, errr := tcos.PutPictureWithCompress(src, uploadPath)
-	if errr != nil {
-		log.Print(errr)
-		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "上传失败")
-	}
-
-	// 6. 获取上传后的图片信息
-	uploadPath = strings.Replace(uploadPath, fileType, "webp", 1)                   // 压缩后格式变为webp
-	thumbnailUrl := strings.Replace(uploadPath, ".webp", "_thumbnail."+fileType, 1) // 缩略图路径
-
-	picInfo, errr := tcos.GetPictureInfo(uploadPath)
-	if errr != nil {
-		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "获取图片信息失败")
-	}
-
-	color, errr := tcos.GetPictureColor(uploadPath)
-	if errr != nil {
-		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "获取图片主色调失败")
-	}
+	defer os.Remove(localFilePath)
 
-	// 7. 构造返回结果
-	picNameNoType := picName[:strings.LastIndex(picName, ".")] // 去除后缀的名称
-	return &file.UploadPictureResult{
-		URL:          config.LoadConfig().Tcos.Host + "/" + uploadPath,
-		ThumbnailURL: config.LoadConfig().Tcos.Host + "/" + thumbnailUrl,
-		PicName:      picNameNoType,
-		PicSize:      picInfo.Size,
-		PicWidth:     picInfo.Width,
-		PicHeight:    picInfo.Height,
-		PicScale:     math.Round(float64(picInfo.Width)/float64(picInfo.Height)*100) / 100,
-		PicFormat:    picInfo.Format,
-		PicColor:     color,
-	}, nil
-}
-
-// downLoadPictureByURL 下载URL图片到本地临时文件
-func downLoadPictureByURL(fileURL string, picName *string) (string, *ecode.ErrorWithCode) {
-	// 1. 下载图片
-	resp, err := http.Get(fileURL)
-	if err != nil {
-		return "", ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "下载图片失败")
+	// 3. 按文件内容确定格式并生成存储路径
+	data, originErr := os.ReadFile(localFilePath)
+	if originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "读取临时文件失败")
 	}
-	defer resp.Body.Close()
-
-	// 2. 检查并补充文件后缀
-	if lastDotIndex := strings.LastIndex(*picName, "."); lastDotIndex == -1 {
-		if err := ValidPictureByHeader(resp, picName); err != nil {
-			return "", err
-		}
+	format, originErr := imageproc.Sniff(data)
+	if originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, originErr.Error())
 	}
+	uploadPath := GenUploadPath(uploadPrefix, format)
 
-	// 3. 创建临时目录
-	tempDir := "tempfile"
-	if err := os.MkdirAll(tempDir, os.ModePerm); err != nil {
-		return "", ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "创建临时文件夹失败")
-	}
-
-	// 4. 创建临时文件
-	tempFilePath := tempDir + "/" + *picName
-	file, err := os.Create(tempFilePath)
+	// 4. 处理并上传
+	result, err := processPicture(data, uploadPath, format, true, opts)
 	if err != nil {
-		log.Println(err)
-		return "", ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "链接格式不支持")
-	}
-	defer file.Close()
-
-	// 5. 写入文件
-	_, err = io.Copy(file, resp.Body)
-	if err != nil {
-		return "", ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "写入文件失败")
-	}
-
-	return tempFilePath, nil
-}
-
-// deleteTempFile 删除临时文件
-func deleteTempFile(tempFilePath string) {
-	if tempFilePath != "" {
-		os.Remove(tempFilePath)
-	}
-}
-
-// ValidPictureByURL 验证URL图片是否合法
-func ValidPictureByURL(fileURL string, picName *string) *ecode.ErrorWithCode {
-	// 1. 检查URL是否为空
-	if fileURL == "" {
-		return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "URL为空")
-	}
-
-	// 2. 检查URL格式
-	_, err := url.ParseRequestURI(fileURL)
-	if err != nil {
-		return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "URL格式错误")
-	}
-
-	// 3. 检查URL协议
-	if !strings.HasPrefix(fileURL, "http") && !strings.HasPrefix(fileURL, "https") {
-		return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "仅支持 HTTP 或 HTTPS 协议的文件地址")
-	}
-
-	// 4. 发送HEAD请求验证文件
-	resp, err := http.Head(fileURL)
-	if err != nil {
-		return nil // 无法连接时跳过其他检查
-	}
-	defer resp.Body.Close()
-
-	// 5. 检查HTTP状态码
-	if resp.StatusCode != http.StatusOK {
-		return nil
-	}
-
-	// 6. 检查文件类型
-	if err := ValidPictureByHeader(resp, picName); err != nil {
-		return err
-	}
-
-	// 7. 检查文件大小
-	contentLength := resp.Header.Get("Content-Length")
-	if contentLength != "" {
-		size, err := strconv.ParseUint(contentLength, 10, 64)
-		if err != nil {
-			return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "文件大小格式异常")
-		}
-
-		ONE_M := uint64(1024 * 1024)
-		if size > 2*ONE_M {
-			return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "文件过大，不能超过2MB")
-		}
-	}
-
-	return nil
-}
-
-// ValidPictureByHeader 通过HTTP响应头验证图片类型
-func ValidPictureByHeader(resp *http.Response, picName *string) *ecode.ErrorWithCode {
-	contentType := resp.Header.Get("Content-Type")
-	if contentType != "" {
-		// 允许的MIME类型
-		allowType := []string{"image/jpeg", "image/jpg", "image/png", "image/webp"}
-		isAllow := false
-
-		for _, v := range allowType {
-			if contentType == v {
-				// 添加文件后缀
-				*picName = *picName + "." + strings.Split(contentType, "/")[1]
-				isAllow = true
-				break
-			}
-		}
-
-		if !isAllow {
-			return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "文件类型不支持")
-		}
+		return nil, err
 	}
-
-	return nil
+	result.PicName = picName
+	return result, nil
 }
@@ -0,0 +1,47 @@
+package manager
+
+import (
+	"backend/internal/consts"
+	"backend/pkg/storage"
+	"bytes"
+	"log"
+	"strings"
+)
+
+// 私有对象的前缀，以私有权限上传，只能通过签名URL访问
+// 私有空间和团队空间的图片存放在space/下，渲染结果只能通过渲染接口访问，被隔离的图片只供人工复核，水印图片只在上传时读取
+// 归档对象需要恢复后才能访问，导出的压缩包只能通过任务返回的下载地址访问
+var privateObjectPrefixes = []string{"space/", consts.RENDER_VARIANT_PREFIX + "/", consts.QUARANTINE_PREFIX + "/",
+	consts.WATERMARK_PREFIX + "/", consts.ARCHIVE_PREFIX + "/", consts.EXPORT_PREFIX + "/"}
+
+// IsPrivateObjectKey 判断对象是否为私有对象
+func IsPrivateObjectKey(key string) bool {
+	for _, prefix := range privateObjectPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// putObject 上传对象，私有前缀下的对象使用私有权限
+func putObject(key string, data []byte, contentType string) error {
+	if IsPrivateObjectKey(key) {
+		return storage.PutPrivateObject(key, bytes.NewReader(data), contentType)
+	}
+	return storage.GetStore().PutObject(key, bytes.NewReader(data), contentType)
+}
+
+// SignObjectURL 为私有对象签发临时访问地址，公开对象原样返回，签发失败时返回空字符串
+func SignObjectURL(objectURL string) string {
+	key, ok := storage.ObjectKey(objectURL)
+	if !ok || !IsPrivateObjectKey(key) {
+		return objectURL
+	}
+	signed, err := storage.SignObjectURL(objectURL, consts.STORAGE_SIGN_URL_EXPIRE)
+	if err != nil {
+		log.Println("签发临时访问地址失败，错误为", err)
+		return ""
+	}
+	return signed
+}
@@ -0,0 +1,55 @@
+package manager
+
+import (
+	"backend/config"
+	"backend/internal/model/entity"
+	"backend/pkg/imageproc"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 衍生版本对象key中的标记，例如 space/1/2025-01-01_abc_r-w512.avif
+const renditionKeyTag = "_r-"
+
+var renditionNamePattern = regexp.MustCompile(`^[a-z0-9]+$`)
+
+// RenditionProfile 获取图片所在空间使用的衍生版本集合名称，未配置时返回空字符串
+func RenditionProfile(space *entity.Space) string {
+	cfg := config.LoadConfig().RenditionConfig
+	if cfg == nil {
+		return ""
+	}
+	if space == nil {
+		return cfg.Public
+	}
+	return cfg.SpaceLevels[strconv.Itoa(space.SpaceLevel)]
+}
+
+// renditionSpecs 获取集合中的版本规格，非法的规格会被跳过
+func renditionSpecs(profile string) []imageproc.RenditionSpec {
+	cfg := config.LoadConfig().RenditionConfig
+	if cfg == nil || profile == "" {
+		return nil
+	}
+	specs := make([]imageproc.RenditionSpec, 0, len(cfg.Profiles[profile]))
+	for _, spec := range cfg.Profiles[profile] {
+		if !renditionNamePattern.MatchString(spec.Name) || spec.Width <= 0 || !imageproc.IsEncodeFormat(spec.Format) {
+			log.Printf("衍生版本配置错误，已跳过: %s %+v", profile, spec)
+			continue
+		}
+		specs = append(specs, imageproc.RenditionSpec{
+			Name:    spec.Name,
+			Width:   spec.Width,
+			Format:  strings.ToLower(spec.Format),
+			Quality: spec.Quality,
+		})
+	}
+	return specs
+}
+
+// renditionKey 计算衍生版本的存储路径
+func renditionKey(uploadPath string, fileType string, spec imageproc.RenditionSpec) string {
+	return strings.TrimSuffix(uploadPath, "."+fileType) + renditionKeyTag + spec.Name + "." + spec.Format
+}
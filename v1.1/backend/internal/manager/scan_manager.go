@@ -0,0 +1,51 @@
+package manager
+
+import (
+	"backend/internal/consts"
+	"backend/internal/ecode"
+	"backend/pkg/imageproc"
+	"backend/pkg/scanner"
+	"backend/pkg/storage"
+	"context"
+	"log"
+)
+
+// QuarantineKey 被隔离图片的存储路径，保留原路径便于复核时定位来源
+// 例如 space/1/2025-01-01_abc.png -> quarantine/space/1/2025-01-01_abc.png
+func QuarantineKey(uploadPath string) string {
+	return consts.QUARANTINE_PREFIX + "/" + uploadPath
+}
+
+// scanPicture 在写入存储前检查图片内容，未配置扫描器时直接放行
+// 隔离时原图以私有权限保存到隔离区，拒绝时直接丢弃；两种情况下客户端直传的原图都会被删除
+func scanPicture(s scanner.Scanner, data []byte, uploadPath string, format string, withOrigin bool) *ecode.ErrorWithCode {
+	if s == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), consts.SCAN_TIMEOUT)
+	defer cancel()
+	result, originErr := s.Scan(ctx, data)
+	if originErr != nil {
+		log.Print(originErr)
+		return ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "内容安全检查失败")
+	}
+	if result.Verdict == scanner.Allow {
+		return nil
+	}
+	log.Printf("图片未通过内容安全检查: path=%s verdict=%s scanner=%s reason=%s", uploadPath, result.Verdict, result.Scanner, result.Reason)
+	if result.Verdict == scanner.Quarantine {
+		if err := putObject(QuarantineKey(uploadPath), data, imageproc.ContentType(format)); err != nil {
+			log.Print(err)
+			return ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "上传失败")
+		}
+	}
+	if !withOrigin {
+		if err := storage.GetStore().DeleteObject(uploadPath); err != nil {
+			log.Print(err)
+		}
+	}
+	if result.Verdict == scanner.Quarantine {
+		return ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "图片已被隔离，等待人工复核")
+	}
+	return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "图片未通过内容安全检查")
+}
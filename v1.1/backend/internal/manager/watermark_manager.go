@@ -0,0 +1,146 @@
+package manager
+
+import (
+	"backend/config"
+	"backend/internal/consts"
+	"backend/internal/ecode"
+	"backend/internal/model/entity"
+	"backend/pkg/imageproc"
+	"backend/pkg/storage"
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font/opentype"
+)
+
+// Watermark 上传时叠加到主图、缩略图和衍生版本上的水印
+type Watermark struct {
+	Mark        *imageproc.Watermark
+	Fingerprint string // 水印设置的摘要，参与内容复用的判断
+}
+
+var (
+	watermarkFontMu   sync.Mutex
+	watermarkFontPath string
+	watermarkFont     *opentype.Font
+)
+
+// LoadWatermark 按空间的水印设置准备水印，空间不存在或未开启水印时返回nil
+func LoadWatermark(space *entity.Space) (*Watermark, error) {
+	setting := space.ActiveWatermark()
+	if setting == nil {
+		return nil, nil
+	}
+	mark := &imageproc.Watermark{
+		Position: setting.Position,
+		Opacity:  setting.Opacity,
+		Scale:    setting.Scale,
+	}
+	switch setting.Type {
+	case consts.WATERMARK_TYPE_IMAGE:
+		if setting.ImageKey == "" {
+			return nil, nil
+		}
+		r, err := storage.GetStore().GetObject(setting.ImageKey)
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+		if mark.Image, err = imageproc.Decode(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+	default:
+		if setting.Text == "" {
+			return nil, nil
+		}
+		font, err := loadWatermarkFont()
+		if err != nil {
+			return nil, err
+		}
+		mark.Text = setting.Text
+		mark.Font = font
+		mark.Color, _ = ParseHexColor(setting.Color)
+	}
+	return &Watermark{Mark: mark, Fingerprint: setting.Fingerprint()}, nil
+}
+
+// WatermarkProfile 添加水印的内容使用的复用集合名称，水印设置不同的内容不复用
+func WatermarkProfile(profile string, fingerprint string) string {
+	return profile + "@wm-" + fingerprint
+}
+
+// SaveWatermarkImage 校验水印图片并统一转为PNG保存，返回对象key
+func SaveWatermarkImage(spaceId uint64, data []byte) (string, *ecode.ErrorWithCode) {
+	if len(data) > consts.WATERMARK_IMAGE_MAX_SIZE {
+		return "", ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "水印图片不能超过1MB")
+	}
+	if _, err := imageproc.Validate(data); err != nil {
+		return "", ecode.GetErrWithDetail(ecode.PARAMS_ERROR, err.Error())
+	}
+	img, err := imageproc.DecodeOriented(data)
+	if err != nil {
+		return "", ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "图片解析失败")
+	}
+	img = imageproc.Resize(img, consts.WATERMARK_IMAGE_MAX_EDGE, consts.WATERMARK_IMAGE_MAX_EDGE)
+	var buf bytes.Buffer
+	if err := imageproc.Encode(&buf, img, "png"); err != nil {
+		log.Print(err)
+		return "", ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "图片处理失败")
+	}
+	key := GenUploadPath(fmt.Sprintf("%s/%d", consts.WATERMARK_PREFIX, spaceId), "png")
+	if err := putObject(key, buf.Bytes(), imageproc.ContentType("png")); err != nil {
+		log.Print(err)
+		return "", ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "上传失败")
+	}
+	return key, nil
+}
+
+// ParseHexColor 解析 #RRGGBB 格式的颜色，格式错误时返回白色和false
+func ParseHexColor(s string) (color.NRGBA, bool) {
+	white := color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 {
+		return white, false
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return white, false
+	}
+	return color.NRGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xFF}, true
+}
+
+// loadWatermarkFont 加载配置的水印字体，未配置时返回nil使用内置字体；配置变化后重新加载
+func loadWatermarkFont() (*opentype.Font, error) {
+	path := ""
+	if cfg := config.LoadConfig().WatermarkConfig; cfg != nil {
+		path = cfg.FontPath
+	}
+	watermarkFontMu.Lock()
+	defer watermarkFontMu.Unlock()
+	if path == watermarkFontPath {
+		return watermarkFont, nil
+	}
+	var font *opentype.Font
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取水印字体失败: %w", err)
+		}
+		if font, err = imageproc.ParseFont(data); err != nil {
+			return nil, err
+		}
+	}
+	watermarkFontPath, watermarkFont = path, font
+	return font, nil
+}
@@ -65,6 +65,19 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// JWTAuthUnlessSigned 请求携带签名参数时跳过令牌校验，由接口自行校验签名，否则与JWTAuthMiddleware一致
+// 用于需要交给第三方服务访问的接口，例如图片渲染
+func JWTAuthUnlessSigned() gin.HandlerFunc {
+	auth := JWTAuthMiddleware()
+	return func(c *gin.Context) {
+		if c.Query("sign") != "" {
+			c.Next()
+			return
+		}
+		auth(c)
+	}
+}
+
 // 检查用户是否在黑名单
 func isUserBlacklisted(userID uint64) bool {
 	blacklistKey := fmt.Sprintf("jwt:blacklist:%d", userID)
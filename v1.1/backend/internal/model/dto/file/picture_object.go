@@ -0,0 +1,7 @@
+package file
+
+// 客户端通过预签名URL直传到对象存储的图片
+type PictureObject struct {
+	Key  string // 对象存储中的key
+	Size int64  // 申请直传时声明的文件大小
+}
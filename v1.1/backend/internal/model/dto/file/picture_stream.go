@@ -0,0 +1,10 @@
+package file
+
+import "io"
+
+// 分片上传合并后的图片数据流，交给图片服务统一处理
+type PictureStream struct {
+	Reader   io.Reader
+	FileName string // 原始文件名，用于获取后缀
+	Size     int64  // 文件总大小
+}
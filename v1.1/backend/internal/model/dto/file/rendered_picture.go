@@ -0,0 +1,9 @@
+package file
+
+// 图片渲染结果
+type RenderedPicture struct {
+	Data        []byte
+	ContentType string
+	ETag        string // 由图片地址和渲染参数计算，图片更新后随之变化
+	Public      bool   // 是否为公共图库的图片，决定浏览器缓存策略
+}
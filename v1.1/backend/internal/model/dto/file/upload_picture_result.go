@@ -1,17 +1,29 @@
 package file
 
+import "backend/internal/model/entity"
+
 //Data Transfer Objects
 //定义上传图片返回结果
 
 // 用于接收图片解析信息
 type UploadPictureResult struct {
-	URL          string  `json:"url"`
-	ThumbnailURL string  `json:"thumbnailUrl"`
-	PicName      string  `json:"picName"`
-	PicSize      int64   `json:"picSize"`
-	PicWidth     int     `json:"picWidth"`
-	PicHeight    int     `json:"picHeight"`
-	PicScale     float64 `json:"picScale"`
-	PicFormat    string  `json:"picFormat"`
-	PicColor     string  `json:"picColor"`
+	URL          string                    `json:"url"`
+	ThumbnailURL string                    `json:"thumbnailUrl"`
+	PicName      string                    `json:"picName"`
+	PicSize      int64                     `json:"picSize"`
+	PicWidth     int                       `json:"picWidth"`
+	PicHeight    int                       `json:"picHeight"`
+	PicScale     float64                   `json:"picScale"`
+	PicFormat    string                    `json:"picFormat"`
+	PicColor     string                    `json:"picColor"`
+	PHash        string                    `json:"pHash"`       // 感知哈希，十六进制
+	DHash        string                    `json:"dHash"`       // 差异哈希，十六进制
+	Palette      []entity.PaletteColor     `json:"palette"`     // 调色板
+	FrameCount   int                       `json:"frameCount"`  // 帧数，静态图片为1
+	Renditions   []entity.PictureRendition `json:"renditions"`  // 衍生版本
+	Profile      string                    `json:"profile"`     // 衍生版本集合名称
+	Metadata     *entity.PictureMetadata   `json:"metadata"`    // EXIF元数据，没有时为nil
+	ContentHash  string                    `json:"contentHash"` // 原图内容哈希
+	OriginKey    string                    `json:"originKey"`   // 原图在对象存储中的key
+	Reused       bool                      `json:"reused"`      // 是否复用了已存储的相同内容
 }
@@ -1,35 +1,109 @@
 package entity
 
 import (
+	"backend/pkg/snowflake"
+	"encoding/json"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"time"
-	"backend/pkg/snowflake"
 )
 
 type Picture struct {
-	ID            uint64         `gorm:"primaryKey;comment:id" json:"id,string" swaggertype:"string"`
-	URL           string         `gorm:"type:varchar(512);not null;comment:图片 url" json:"url"`
-	ThumbnailURL  string         `gorm:"type:varchar(512);comment:缩略图 url;default:null" json:"thumbnailUrl"`
-	Name          string         `gorm:"type:varchar(128);not null;index:idx_name;comment:图片名称" json:"name"`
-	Introduction  string         `gorm:"type:varchar(512);index:idx_introduction;comment:简介" json:"introduction"`
-	Category      string         `gorm:"type:varchar(64);index:idx_category;comment:分类" json:"category"`
-	Tags          string         `gorm:"type:varchar(512);index:idx_tags;comment:标签（JSON 数组）" json:"tags"` //存储的格式：["golang","java","c++"]
-	PicSize       int64          `gorm:"comment:图片体积" json:"picSize"`
-	PicWidth      int            `gorm:"comment:图片宽度" json:"picWidth"`
-	PicHeight     int            `gorm:"comment:图片高度" json:"picHeight"`
-	PicScale      float64        `gorm:"comment:图片宽高比例" json:"picScale"`
-	PicFormat     string         `gorm:"type:varchar(32);comment:图片格式" json:"picFormat"`
-	UserID        uint64         `gorm:"not null;index:idx_userId;comment:创建用户 id" json:"userId,string" swaggertype:"string"`
-	EditTime      time.Time      `gorm:"type:datetime;default:CURRENT_TIMESTAMP;not null;comment:编辑时间" json:"editTime"`
-	CreateTime    time.Time      `gorm:"autoCreateTime;comment:创建时间" json:"createTime"`
-	UpdateTime    time.Time      `gorm:"autoUpdateTime;comment:更新时间" json:"updateTime"`
-	IsDelete      gorm.DeletedAt `gorm:"comment:是否删除" json:"isDelete" swaggerignore:"true"`
-	ReviewStatus  int            `gorm:"default:0;comment:审核状态：0-待审核；1-通过；2-拒绝;not null;index:idx_reviewStatus" json:"reviewStatus"`
-	ReviewMessage string         `gorm:"type:varchar(512);comment:审核信息" json:"reviewMessage"`
-	ReviewerID    uint64         `gorm:"comment:审核人 ID" json:"reviewerId,string" swaggertype:"string"`
-	ReviewTime    *time.Time     `gorm:"type:datetime;comment:审核时间" json:"reviewTime,omitempty"`
-	SpaceID       uint64         `gorm:"index:idx_spaceId;comment:空间 id;default:null" json:"spaceId,string" swaggertype:"string"`
-	PicColor      string         `gorm:"type:varchar(16);comment:主色调" json:"picColor"`
+	ID               uint64         `gorm:"primaryKey;comment:id" json:"id,string" swaggertype:"string"`
+	URL              string         `gorm:"type:varchar(512);not null;comment:图片 url" json:"url"`
+	ThumbnailURL     string         `gorm:"type:varchar(512);comment:缩略图 url;default:null" json:"thumbnailUrl"`
+	Name             string         `gorm:"type:varchar(128);not null;index:idx_name;comment:图片名称" json:"name"`
+	Introduction     string         `gorm:"type:varchar(512);index:idx_introduction;comment:简介" json:"introduction"`
+	Category         string         `gorm:"type:varchar(64);index:idx_category;comment:分类" json:"category"`
+	Tags             string         `gorm:"type:varchar(512);index:idx_tags;comment:标签（JSON 数组）" json:"tags"` //存储的格式：["golang","java","c++"]，是picture_tags的副本，按标签筛选使用picture_tags
+	PicSize          int64          `gorm:"comment:图片体积" json:"picSize"`
+	PicWidth         int            `gorm:"comment:图片宽度" json:"picWidth"`
+	PicHeight        int            `gorm:"comment:图片高度" json:"picHeight"`
+	PicScale         float64        `gorm:"comment:图片宽高比例" json:"picScale"`
+	PicFormat        string         `gorm:"type:varchar(32);comment:图片格式" json:"picFormat"`
+	UserID           uint64         `gorm:"not null;index:idx_userId;comment:创建用户 id" json:"userId,string" swaggertype:"string"`
+	EditTime         time.Time      `gorm:"type:datetime;default:CURRENT_TIMESTAMP;not null;comment:编辑时间" json:"editTime"`
+	CreateTime       time.Time      `gorm:"autoCreateTime;comment:创建时间" json:"createTime"`
+	UpdateTime       time.Time      `gorm:"autoUpdateTime;comment:更新时间" json:"updateTime"`
+	IsDelete         gorm.DeletedAt `gorm:"comment:是否删除" json:"isDelete" swaggerignore:"true"`
+	ReviewStatus     int            `gorm:"default:0;comment:审核状态：0-待审核；1-通过；2-拒绝;not null;index:idx_reviewStatus" json:"reviewStatus"`
+	ReviewMessage    string         `gorm:"type:varchar(512);comment:审核信息" json:"reviewMessage"`
+	ReviewerID       uint64         `gorm:"comment:审核人 ID" json:"reviewerId,string" swaggertype:"string"`
+	ReviewTime       *time.Time     `gorm:"type:datetime;comment:审核时间" json:"reviewTime,omitempty"`
+	SpaceID          uint64         `gorm:"index:idx_spaceId;comment:空间 id;default:null" json:"spaceId,string" swaggertype:"string"`
+	PicColor         string         `gorm:"type:varchar(16);comment:主色调" json:"picColor"`
+	PHash            string         `gorm:"type:char(16);comment:感知哈希（16位十六进制），为空时尚未计算" json:"pHash"`
+	DHash            string         `gorm:"type:char(16);comment:差异哈希（16位十六进制），为空时尚未计算" json:"dHash"`
+	Palette          string         `gorm:"type:varchar(512);comment:调色板（JSON 数组），为空时尚未计算" json:"palette"` //存储的格式：[{"color":"0x736246","weight":0.42}]
+	ContentHash      string         `gorm:"type:varchar(64);index:idx_contentHash;comment:原图内容哈希" json:"contentHash"`
+	Renditions       string         `gorm:"type:text;comment:衍生版本（JSON 数组）" json:"renditions"`
+	RenditionProfile string         `gorm:"type:varchar(64);comment:衍生版本集合名称" json:"renditionProfile"`
+	FrameCount       int            `gorm:"default:1;comment:帧数，静态图片为1" json:"frameCount"`
+	IsAnimated       bool           `gorm:"default:false;index:idx_isAnimated;comment:是否为动图" json:"isAnimated"`
+	Version          int            `gorm:"default:1;comment:当前版本号，每次重新上传或恢复历史版本时加1" json:"version"`
+	ArchiveStatus    int            `gorm:"default:0;not null;index:idx_archiveStatus;comment:归档状态：0-正常；1-已归档；2-恢复中" json:"archiveStatus"`
+	LastViewTime     *time.Time     `gorm:"type:datetime;comment:最近查看时间，按天更新" json:"lastViewTime,omitempty"`
+}
+
+// 图片的衍生版本，以JSON数组的形式存储在Renditions中
+type PictureRendition struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Format string `json:"format"`
+}
+
+// 解析衍生版本，数据为空或格式错误时返回空数组
+func ParseRenditions(data string) []PictureRendition {
+	renditions := []PictureRendition{}
+	if data != "" {
+		_ = json.Unmarshal([]byte(data), &renditions)
+	}
+	return renditions
+}
+
+// 获取图片的衍生版本列表
+func (p *Picture) GetRenditions() []PictureRendition {
+	return ParseRenditions(p.Renditions)
+}
+
+// 序列化衍生版本，没有衍生版本时返回空字符串
+func FormatRenditions(renditions []PictureRendition) string {
+	if len(renditions) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(renditions)
+	return string(data)
+}
+
+// 图片调色板中的一种颜色，以JSON数组的形式存储在Palette中，按占比从高到低排列
+type PaletteColor struct {
+	Color  string  `json:"color"`  // 十六进制颜色，例如：0x736246
+	Weight float64 `json:"weight"` // 像素占比
+}
+
+// 解析调色板，数据为空或格式错误时返回空数组
+func ParsePalette(data string) []PaletteColor {
+	palette := []PaletteColor{}
+	if data != "" {
+		_ = json.Unmarshal([]byte(data), &palette)
+	}
+	return palette
+}
+
+// 获取图片的调色板
+func (p *Picture) GetPalette() []PaletteColor {
+	return ParsePalette(p.Palette)
+}
+
+// 序列化调色板，没有颜色时返回空字符串
+func FormatPalette(palette []PaletteColor) string {
+	if len(palette) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(palette)
+	return string(data)
 }
 
 // AutoMigratePicture 执行数据库迁移
@@ -40,6 +114,41 @@ func AutoMigratePicture(db *gorm.DB) {
 	}
 }
 
+// 图片的全文索引，使用ngram分词支持中文，需要MySQL 5.7.6及以上
+// ft_picture_text 用于过滤，其余用于按名称、简介、分类和标签分别计算相关度
+var pictureFullTextIndexes = []struct {
+	Name    string
+	Columns []string
+}{
+	{"ft_picture_text", []string{"name", "introduction", "category", "tags"}},
+	{"ft_picture_name", []string{"name"}},
+	{"ft_picture_intro", []string{"introduction"}},
+	{"ft_picture_label", []string{"category", "tags"}},
+}
+
+// AutoMigratePictureFullText 创建图片的全文索引，已存在的跳过
+func AutoMigratePictureFullText(db *gorm.DB) error {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&Picture{}); err != nil {
+		return err
+	}
+	for _, idx := range pictureFullTextIndexes {
+		if db.Migrator().HasIndex(&Picture{}, idx.Name) {
+			continue
+		}
+		columns := make([]clause.Column, 0, len(idx.Columns))
+		for _, c := range idx.Columns {
+			columns = append(columns, clause.Column{Name: c})
+		}
+		err := db.Exec("ALTER TABLE ? ADD FULLTEXT INDEX ? ? WITH PARSER ngram",
+			clause.Table{Name: stmt.Schema.Table}, clause.Column{Name: idx.Name}, columns).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // 钩子，使用sonyflake生成ID
 func (p *Picture) BeforeCreate(tx *gorm.DB) error {
 	if p.ID == 0 {
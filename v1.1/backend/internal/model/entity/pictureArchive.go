@@ -0,0 +1,43 @@
+package entity
+
+import (
+	"encoding/json"
+	"gorm.io/gorm"
+	"time"
+)
+
+// PictureArchive 已归档图片的归档记录，图片恢复后删除
+// 归档时图片不再引用内容表中的记录，存储对象由归档记录持有：缩略图保留在原位置，其余对象转移到归档前缀
+type PictureArchive struct {
+	PictureID    uint64     `gorm:"primaryKey;comment:图片 id" json:"pictureId,string" swaggertype:"string"`
+	OriginKey    string     `gorm:"type:varchar(512);comment:原图对象key，旧数据可能为空" json:"-"`
+	ObjectKeys   string     `gorm:"type:text;comment:转移到归档前缀的对象原key（JSON 数组）" json:"-"`
+	StorageClass string     `gorm:"type:varchar(32);comment:归档对象的存储类型" json:"storageClass"`
+	ArchiveTime  time.Time  `gorm:"type:datetime;not null;comment:归档时间" json:"archiveTime"`
+	RestoreTime  *time.Time `gorm:"type:datetime;index:idx_restoreTime;comment:申请恢复的时间" json:"restoreTime,omitempty"`
+	RestoreBy    uint64     `gorm:"comment:申请恢复的用户 id" json:"restoreBy,string" swaggertype:"string"`
+	CreateTime   time.Time  `gorm:"autoCreateTime;comment:创建时间" json:"createTime"`
+	UpdateTime   time.Time  `gorm:"autoUpdateTime;comment:更新时间" json:"updateTime"`
+}
+
+// 获取转移到归档前缀的对象原key
+func (a *PictureArchive) GetObjectKeys() []string {
+	keys := []string{}
+	if a.ObjectKeys != "" {
+		_ = json.Unmarshal([]byte(a.ObjectKeys), &keys)
+	}
+	return keys
+}
+
+// 设置转移到归档前缀的对象原key
+func (a *PictureArchive) SetObjectKeys(keys []string) {
+	data, _ := json.Marshal(keys)
+	a.ObjectKeys = string(data)
+}
+
+func AutoMigratePictureArchive(db *gorm.DB) {
+	err := db.AutoMigrate(&PictureArchive{})
+	if err != nil {
+		panic("⚠️ 图片归档表迁移失败: " + err.Error())
+	}
+}
@@ -0,0 +1,24 @@
+package entity
+
+import "gorm.io/gorm"
+
+// PictureColor 图片调色板的颜色索引，调色板中的每种颜色一条记录
+// Bucket 为颜色所在的桶，按颜色搜索时只读取目标颜色附近的桶，不需要扫描整个空间的图片
+type PictureColor struct {
+	ID        uint64  `gorm:"primaryKey;autoIncrement;comment:id" json:"-"`
+	PictureID uint64  `gorm:"not null;index:idx_pictureId;comment:图片 id" json:"pictureId,string" swaggertype:"string"`
+	SpaceID   uint64  `gorm:"not null;default:0;index:idx_space_bucket,priority:1;comment:空间 id，公共图库为0" json:"spaceId,string" swaggertype:"string"`
+	Bucket    int     `gorm:"not null;index:idx_space_bucket,priority:2;comment:颜色所在的桶编号" json:"bucket"`
+	Color     string  `gorm:"type:varchar(16);not null;comment:十六进制颜色" json:"color"`
+	L         float64 `gorm:"not null;comment:CIELAB的L" json:"l"`
+	A         float64 `gorm:"not null;comment:CIELAB的a" json:"a"`
+	B         float64 `gorm:"not null;comment:CIELAB的b" json:"b"`
+	Weight    float64 `gorm:"not null;comment:像素占比" json:"weight"`
+}
+
+func AutoMigratePictureColor(db *gorm.DB) {
+	err := db.AutoMigrate(&PictureColor{})
+	if err != nil {
+		panic("⚠️ 图片颜色索引表迁移失败: " + err.Error())
+	}
+}
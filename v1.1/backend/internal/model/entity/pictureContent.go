@@ -0,0 +1,38 @@
+package entity
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// PictureContent 按原图内容哈希去重后的存储对象，多张图片可以引用同一份对象
+// RefCount 为引用该对象的图片数量（含回收站中尚未彻底删除的图片），归零时删除存储中的对象
+type PictureContent struct {
+	ContentHash      string    `gorm:"primaryKey;type:varchar(64);comment:原图内容的sha256" json:"contentHash"`
+	RenditionProfile string    `gorm:"primaryKey;type:varchar(64);comment:衍生版本集合名称，集合不同的内容不复用" json:"renditionProfile"`
+	Private          bool      `gorm:"primaryKey;comment:是否为私有对象，公开图库和空间之间不复用" json:"private"`
+	OriginKey        string    `gorm:"type:varchar(512);not null;comment:原图对象key" json:"originKey"`
+	URL              string    `gorm:"type:varchar(512);not null;comment:图片 url" json:"url"`
+	ThumbnailURL     string    `gorm:"type:varchar(512);comment:缩略图 url" json:"thumbnailUrl"`
+	PicSize          int64     `gorm:"comment:图片体积" json:"picSize"`
+	PicWidth         int       `gorm:"comment:图片宽度" json:"picWidth"`
+	PicHeight        int       `gorm:"comment:图片高度" json:"picHeight"`
+	PicScale         float64   `gorm:"comment:图片宽高比例" json:"picScale"`
+	PicFormat        string    `gorm:"type:varchar(32);comment:图片格式" json:"picFormat"`
+	PicColor         string    `gorm:"type:varchar(16);comment:主色调" json:"picColor"`
+	PHash            string    `gorm:"type:char(16);comment:感知哈希" json:"pHash"`
+	DHash            string    `gorm:"type:char(16);comment:差异哈希" json:"dHash"`
+	Palette          string    `gorm:"type:varchar(512);comment:调色板（JSON 数组）" json:"palette"`
+	Renditions       string    `gorm:"type:text;comment:衍生版本（JSON 数组）" json:"renditions"`
+	FrameCount       int       `gorm:"default:1;comment:帧数，静态图片为1" json:"frameCount"`
+	RefCount         int64     `gorm:"default:0;not null;comment:引用计数" json:"refCount"`
+	CreateTime       time.Time `gorm:"autoCreateTime;comment:创建时间" json:"createTime"`
+	UpdateTime       time.Time `gorm:"autoUpdateTime;comment:更新时间" json:"updateTime"`
+}
+
+func AutoMigratePictureContent(db *gorm.DB) {
+	err := db.AutoMigrate(&PictureContent{})
+	if err != nil {
+		panic("⚠️ 图片内容表迁移失败: " + err.Error())
+	}
+}
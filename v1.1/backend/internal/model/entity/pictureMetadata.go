@@ -0,0 +1,32 @@
+package entity
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// PictureMetadata 上传时从原图中提取的EXIF元数据，每张图片一条记录
+// 所在空间未开启保留GPS时，原图中的GPS信息已被去除，不记录经纬度
+type PictureMetadata struct {
+	PictureID    uint64     `gorm:"primaryKey;autoIncrement:false;comment:图片 id" json:"-"`
+	Make         string     `gorm:"type:varchar(64);index:idx_make;comment:相机厂商" json:"make"`
+	Model        string     `gorm:"type:varchar(128);index:idx_model;comment:相机型号" json:"model"`
+	LensModel    string     `gorm:"type:varchar(128);comment:镜头型号" json:"lensModel"`
+	ExposureTime string     `gorm:"type:varchar(32);comment:曝光时间，例如 1/125" json:"exposureTime"`
+	FNumber      float64    `gorm:"comment:光圈值" json:"fNumber"`
+	ISO          int        `gorm:"comment:感光度" json:"iso"`
+	FocalLength  float64    `gorm:"comment:焦距，单位mm" json:"focalLength"`
+	TakenAt      *time.Time `gorm:"index:idx_takenAt;comment:拍摄时间" json:"takenAt"`
+	Latitude     *float64   `gorm:"comment:纬度，南纬为负" json:"latitude"`
+	Longitude    *float64   `gorm:"comment:经度，西经为负" json:"longitude"`
+	Orientation  int        `gorm:"comment:原图的EXIF方向，处理时已摆正" json:"orientation"`
+	CreateTime   time.Time  `gorm:"autoCreateTime;comment:创建时间" json:"-"`
+	UpdateTime   time.Time  `gorm:"autoUpdateTime;comment:更新时间" json:"-"`
+}
+
+func AutoMigratePictureMetadata(db *gorm.DB) {
+	err := db.AutoMigrate(&PictureMetadata{})
+	if err != nil {
+		panic("⚠️ 图片元数据表迁移失败: " + err.Error())
+	}
+}
@@ -0,0 +1,121 @@
+package entity
+
+import (
+	"backend/pkg/snowflake"
+	"encoding/json"
+	"gorm.io/gorm"
+	"time"
+)
+
+// PictureVersion 图片被重新上传替换前的历史版本，保存当时的存储对象和图片信息
+// 历史版本与图片一样持有存储对象的引用，体积计入所在空间的已用额度，超过保留数量时删除最早的版本
+type PictureVersion struct {
+	ID               uint64    `gorm:"primaryKey;comment:id" json:"id,string" swaggertype:"string"`
+	PictureID        uint64    `gorm:"not null;uniqueIndex:uk_pictureId_version,priority:1;comment:图片 id" json:"pictureId,string" swaggertype:"string"`
+	Version          int       `gorm:"not null;uniqueIndex:uk_pictureId_version,priority:2;comment:版本号，从1开始递增" json:"version"`
+	SpaceID          uint64    `gorm:"index:idx_spaceId;comment:空间 id;default:null" json:"spaceId,string" swaggertype:"string"`
+	UserID           uint64    `gorm:"not null;comment:上传该版本的用户 id" json:"userId,string" swaggertype:"string"`
+	OriginKey        string    `gorm:"type:varchar(512);comment:原图对象key，旧数据可能为空" json:"-"`
+	URL              string    `gorm:"type:varchar(512);not null;comment:图片 url" json:"url"`
+	ThumbnailURL     string    `gorm:"type:varchar(512);comment:缩略图 url" json:"thumbnailUrl"`
+	PicSize          int64     `gorm:"comment:图片体积" json:"picSize"`
+	PicWidth         int       `gorm:"comment:图片宽度" json:"picWidth"`
+	PicHeight        int       `gorm:"comment:图片高度" json:"picHeight"`
+	PicScale         float64   `gorm:"comment:图片宽高比例" json:"picScale"`
+	PicFormat        string    `gorm:"type:varchar(32);comment:图片格式" json:"picFormat"`
+	PicColor         string    `gorm:"type:varchar(16);comment:主色调" json:"picColor"`
+	PHash            string    `gorm:"type:char(16);comment:感知哈希" json:"pHash"`
+	DHash            string    `gorm:"type:char(16);comment:差异哈希" json:"dHash"`
+	Palette          string    `gorm:"type:varchar(512);comment:调色板（JSON 数组）" json:"palette"`
+	ContentHash      string    `gorm:"type:varchar(64);comment:原图内容哈希" json:"contentHash"`
+	Renditions       string    `gorm:"type:text;comment:衍生版本（JSON 数组）" json:"renditions"`
+	RenditionProfile string    `gorm:"type:varchar(64);comment:衍生版本集合名称" json:"renditionProfile"`
+	FrameCount       int       `gorm:"default:1;comment:帧数，静态图片为1" json:"frameCount"`
+	Metadata         string    `gorm:"type:text;comment:EXIF元数据（JSON）" json:"metadata"`
+	EditTime         time.Time `gorm:"type:datetime;comment:该版本的上传时间" json:"editTime"`
+	CreateTime       time.Time `gorm:"autoCreateTime;comment:成为历史版本的时间" json:"createTime"`
+}
+
+// NewPictureVersion 根据图片当前的内容生成历史版本，originKey和metadata可以为空
+func NewPictureVersion(pic *Picture, originKey string, metadata *PictureMetadata) *PictureVersion {
+	v := &PictureVersion{
+		PictureID:        pic.ID,
+		Version:          max(pic.Version, 1),
+		SpaceID:          pic.SpaceID,
+		UserID:           pic.UserID,
+		OriginKey:        originKey,
+		URL:              pic.URL,
+		ThumbnailURL:     pic.ThumbnailURL,
+		PicSize:          pic.PicSize,
+		PicWidth:         pic.PicWidth,
+		PicHeight:        pic.PicHeight,
+		PicScale:         pic.PicScale,
+		PicFormat:        pic.PicFormat,
+		PicColor:         pic.PicColor,
+		PHash:            pic.PHash,
+		DHash:            pic.DHash,
+		Palette:          pic.Palette,
+		ContentHash:      pic.ContentHash,
+		Renditions:       pic.Renditions,
+		RenditionProfile: pic.RenditionProfile,
+		FrameCount:       max(pic.FrameCount, 1),
+		EditTime:         pic.EditTime,
+	}
+	if metadata != nil {
+		data, _ := json.Marshal(metadata)
+		v.Metadata = string(data)
+	}
+	return v
+}
+
+// GetMetadata 解析历史版本的元数据，没有时返回nil
+func (v *PictureVersion) GetMetadata() *PictureMetadata {
+	if v.Metadata == "" {
+		return nil
+	}
+	var metadata PictureMetadata
+	if err := json.Unmarshal([]byte(v.Metadata), &metadata); err != nil {
+		return nil
+	}
+	metadata.PictureID = v.PictureID
+	return &metadata
+}
+
+// ToContent 历史版本对应的存储对象记录，用于恢复时增加引用
+func (v *PictureVersion) ToContent() *PictureContent {
+	return &PictureContent{
+		ContentHash:      v.ContentHash,
+		RenditionProfile: v.RenditionProfile,
+		Private:          v.SpaceID != 0,
+		OriginKey:        v.OriginKey,
+		URL:              v.URL,
+		ThumbnailURL:     v.ThumbnailURL,
+		PicSize:          v.PicSize,
+		PicWidth:         v.PicWidth,
+		PicHeight:        v.PicHeight,
+		PicScale:         v.PicScale,
+		PicFormat:        v.PicFormat,
+		PicColor:         v.PicColor,
+		PHash:            v.PHash,
+		DHash:            v.DHash,
+		Palette:          v.Palette,
+		Renditions:       v.Renditions,
+		FrameCount:       v.FrameCount,
+	}
+}
+
+func AutoMigratePictureVersion(db *gorm.DB) {
+	err := db.AutoMigrate(&PictureVersion{})
+	if err != nil {
+		panic("⚠️ 图片历史版本表迁移失败: " + err.Error())
+	}
+}
+
+// 钩子，使用sonyflake生成ID
+func (v *PictureVersion) BeforeCreate(tx *gorm.DB) error {
+	if v.ID == 0 {
+		id, _ := snowflake.GenID()
+		v.ID = id
+	}
+	return nil
+}
@@ -1,9 +1,12 @@
 package entity
 
 import (
+	"backend/pkg/snowflake"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"gorm.io/gorm"
 	"time"
-	"backend/pkg/snowflake"
 )
 
 type Space struct {
@@ -20,6 +23,49 @@ type Space struct {
 	UpdateTime time.Time      `gorm:"autoUpdateTime;comment:更新时间" json:"updateTime"`
 	IsDelete   gorm.DeletedAt `gorm:"comment:是否删除" json:"isDelete" swaggerignore:"true"`
 	SpaceType  int            `gorm:"default:0;comment:空间类型：0-个人空间 1-团队空间;index:idx_spaceType" json:"spaceType"`
+	KeepGPS    bool           `gorm:"default:false;comment:是否保留图片EXIF中的GPS信息" json:"keepGps"`
+	Watermark  string         `gorm:"type:text;comment:水印设置，JSON格式" json:"watermark"`
+}
+
+// SpaceWatermark 空间的水印设置，开启后新上传图片的主图、缩略图和衍生版本都会添加水印
+// 原图不添加水印，只有拥有编辑权限的成员可以访问
+type SpaceWatermark struct {
+	Enabled  bool    `json:"enabled"`  // 是否开启
+	Type     string  `json:"type"`     // 水印类型：text-文字 image-图片
+	Text     string  `json:"text"`     // 文字内容
+	Color    string  `json:"color"`    // 文字颜色，例如 #FFFFFF
+	ImageKey string  `json:"imageKey"` // 水印图片的对象key
+	Position string  `json:"position"` // 位置：top-left/top/top-right/left/center/right/bottom-left/bottom/bottom-right/tile
+	Opacity  float64 `json:"opacity"`  // 不透明度(0,1]
+	Scale    float64 `json:"scale"`    // 水印宽度占图片宽度的比例(0,1]
+}
+
+// GetWatermark 解析空间的水印设置，未设置或格式错误时返回nil
+func (p *Space) GetWatermark() *SpaceWatermark {
+	if p == nil || p.Watermark == "" {
+		return nil
+	}
+	var wm SpaceWatermark
+	if err := json.Unmarshal([]byte(p.Watermark), &wm); err != nil {
+		return nil
+	}
+	return &wm
+}
+
+// ActiveWatermark 获取生效中的水印设置，未开启时返回nil
+func (p *Space) ActiveWatermark() *SpaceWatermark {
+	wm := p.GetWatermark()
+	if wm == nil || !wm.Enabled {
+		return nil
+	}
+	return wm
+}
+
+// Fingerprint 水印设置的摘要，设置相同的水印得到相同的结果
+func (w *SpaceWatermark) Fingerprint() string {
+	data, _ := json.Marshal(w)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
 }
 
 // AutoMigrateSpace 执行数据库迁移
@@ -0,0 +1,31 @@
+package entity
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// Tag 标签，按空间隔离，公共图库的SpaceID为0
+// 同一空间内名称唯一，比较时不区分大小写（由数据库的排序规则保证）
+type Tag struct {
+	ID         uint64    `gorm:"primaryKey;autoIncrement;comment:id" json:"id,string" swaggertype:"string"`
+	SpaceID    uint64    `gorm:"not null;default:0;uniqueIndex:uk_space_name,priority:1;comment:空间 id，公共图库为0" json:"spaceId,string" swaggertype:"string"`
+	Name       string    `gorm:"type:varchar(64);not null;uniqueIndex:uk_space_name,priority:2;comment:标签名称" json:"name"`
+	CreateTime time.Time `gorm:"autoCreateTime;comment:创建时间" json:"createTime"`
+	UpdateTime time.Time `gorm:"autoUpdateTime;comment:更新时间" json:"updateTime"`
+}
+
+// PictureTag 图片与标签的关联，Sort为标签在图片中的顺序
+// 图片表的Tags保存标签名称的副本，用于展示和全文检索，按标签筛选和统计使用该表
+type PictureTag struct {
+	PictureID uint64 `gorm:"primaryKey;autoIncrement:false;comment:图片 id" json:"pictureId,string" swaggertype:"string"`
+	TagID     uint64 `gorm:"primaryKey;autoIncrement:false;index:idx_tagId;comment:标签 id" json:"tagId,string" swaggertype:"string"`
+	Sort      int    `gorm:"not null;default:0;comment:标签在图片中的顺序" json:"sort"`
+}
+
+func AutoMigrateTag(db *gorm.DB) {
+	err := db.AutoMigrate(&Tag{}, &PictureTag{})
+	if err != nil {
+		panic("⚠️ 标签表迁移失败: " + err.Error())
+	}
+}
@@ -0,0 +1,6 @@
+package picture
+
+// 申请恢复已归档图片请求
+type PictureArchiveRestoreRequest struct {
+	PictureID uint64 `json:"pictureId,string" swaggertype:"string"` //已归档图片的ID
+}
@@ -0,0 +1,7 @@
+package picture
+
+// 批量导出请求，查询条件与分页查询一致，分页参数和排序不生效
+type PictureExportRequest struct {
+	PictureQueryRequest
+	ManifestFormat string `json:"manifestFormat"` //清单格式 json/csv，默认为json
+}
@@ -0,0 +1,6 @@
+package picture
+
+// 批量导入请求，压缩包通过表单的file字段上传
+type PictureImportRequest struct {
+	SpaceID uint64 `form:"spaceId" json:"spaceId,string" swaggertype:"string"` //目标空间ID
+}
@@ -11,11 +11,13 @@ type PictureQueryRequest struct {
 	Introduction string   `json:"introduction"`
 	Category     string   `json:"category"`
 	Tags         []string `json:"tags"`
+	TagMode      string   `json:"tagMode"` //标签匹配方式，any为匹配任一标签，all为匹配全部标签，默认all
 	PicSize      int64    `json:"picSize"`
 	PicWidth     int      `json:"picWidth"`
 	PicHeight    int      `json:"picHeight"`
 	PicScale     float64  `json:"picScale"`
 	PicFormat    string   `json:"picFormat"`
+	IsAnimated   *bool    `json:"isAnimated"`                         //是否为动图，不传则不筛选
 	UserID       uint64   `json:"userId,string" swaggertype:"string"` //图片上传人信息
 	SearchText   string   `json:"searchText"`                         //搜索词
 	common.PageRequest
@@ -28,4 +30,11 @@ type PictureQueryRequest struct {
 	IsNullSpaceID bool      `json:"isNullSpaceId"`                       //是否查询空间ID为空的图片
 	StartEditTime time.Time `json:"startEditTime"`                       //开始编辑时间
 	EndEditTime   time.Time `json:"endEditTime"`                         //结束编辑时间
+	//EXIF元数据筛选字段
+	CameraMake   string    `json:"cameraMake"`   //相机厂商，模糊匹配
+	CameraModel  string    `json:"cameraModel"`  //相机型号，模糊匹配
+	LensModel    string    `json:"lensModel"`    //镜头型号，模糊匹配
+	StartTakenAt time.Time `json:"startTakenAt"` //开始拍摄时间
+	EndTakenAt   time.Time `json:"endTakenAt"`   //结束拍摄时间
+	HasGPS       *bool     `json:"hasGps"`       //是否带有GPS信息，不传则不筛选
 }
@@ -0,0 +1,14 @@
+package picture
+
+import "backend/internal/common"
+
+// 查询回收站请求，spaceId为空时查询公共图库的回收站
+type PictureRecycleQueryRequest struct {
+	common.PageRequest
+	SpaceID uint64 `json:"spaceId,string" swaggertype:"string"` //空间ID
+}
+
+// 恢复或彻底删除回收站中的图片
+type PictureRecycleRequest struct {
+	PictureIdList []uint64 `json:"pictureIdList" swaggertype:"array,string"` // 图片ID列表
+}
@@ -0,0 +1,12 @@
+package picture
+
+// 图片渲染请求，参数通过query传入
+type PictureRenderRequest struct {
+	Width   int    `form:"width"`   //目标宽度，为0时不限制，只缩小不放大
+	Height  int    `form:"height"`  //目标高度，为0时不限制，只缩小不放大
+	Fit     string `form:"fit"`     //缩放方式 contain/cover/fill，默认contain
+	Format  string `form:"format"`  //输出格式 jpg/png/webp/avif，默认webp
+	Quality int    `form:"quality"` //编码质量[1,100]，为0时使用默认值
+	Expires int64  `form:"expires"` //签名的过期时间戳，单位秒
+	Sign    string `form:"sign"`    //服务端生成的签名，携带有效签名时无需登录
+}
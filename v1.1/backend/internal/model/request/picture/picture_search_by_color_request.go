@@ -1,6 +1,10 @@
 package picture
 
 type PictureSearchByColorRequest struct {
-	PicColor string `json:"picColor"`                            // 图片颜色
-	SpaceID  uint64 `json:"spaceId,string" swaggertype:"string"` //空间ID
+	PicColor  string   `json:"picColor"`                            // 图片颜色，与Colors合并使用，兼容只传一种颜色的旧接口
+	Colors    []string `json:"colors"`                              // 要同时匹配的颜色，例如：["#FF0000","0x1428C8"]，最多5种
+	SpaceID   uint64   `json:"spaceId,string" swaggertype:"string"` //空间ID
+	Threshold float64  `json:"threshold"`                           // ΔE2000色差阈值，范围0~20，为0时使用默认值10
+	Current   int      `json:"current"`                             //当前页数
+	PageSize  int      `json:"pageSize"`                            //页面大小，最大50
 }
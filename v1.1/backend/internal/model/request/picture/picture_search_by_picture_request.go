@@ -1,5 +1,9 @@
 package picture
 
 type PictureSearchByPictureRequest struct {
-	PictureId uint64 `json:"pictureId,string" swaggertype:"string"` // 图片ID
+	PictureId   uint64 `json:"pictureId,string" swaggertype:"string"` // 图片ID
+	Provider    string `json:"provider"`                              // 搜图服务：baidu / bing / local，为空时按配置的顺序依次尝试
+	Mode        string `json:"mode"`                                  // 旧的搜索方式字段，provider为空时生效：baidu同provider，local按原格式返回本站的相似图片
+	MaxDistance *int   `json:"maxDistance"`                           // local的感知哈希最大汉明距离，范围0~20，不传时为10
+	Limit       int    `json:"limit"`                                 // 最多返回的数量，为0时使用各服务的默认值，local最多50
 }
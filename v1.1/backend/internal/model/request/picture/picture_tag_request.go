@@ -0,0 +1,21 @@
+package picture
+
+// 重命名标签请求
+type PictureTagRenameRequest struct {
+	SpaceID uint64 `json:"spaceId,string" swaggertype:"string"` //空间ID，0表示公共图库
+	Name    string `json:"name"`                                //原标签名称
+	NewName string `json:"newName"`                             //新标签名称
+}
+
+// 合并标签请求，源标签的图片改为目标标签，源标签随后删除
+type PictureTagMergeRequest struct {
+	SpaceID     uint64   `json:"spaceId,string" swaggertype:"string"` //空间ID，0表示公共图库
+	SourceNames []string `json:"sourceNames"`                         //要合并的标签名称
+	TargetName  string   `json:"targetName"`                          //合并后的标签名称，不存在时创建
+}
+
+// 删除标签请求，同时从所有图片中移除该标签
+type PictureTagDeleteRequest struct {
+	SpaceID uint64 `json:"spaceId,string" swaggertype:"string"` //空间ID，0表示公共图库
+	Name    string `json:"name"`                                //标签名称
+}
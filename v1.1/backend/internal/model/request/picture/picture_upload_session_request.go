@@ -0,0 +1,22 @@
+package picture
+
+// 初始化分片上传会话请求
+type UploadSessionInitRequest struct {
+	FileName string `json:"fileName"`                            //原始文件名，需携带后缀
+	FileSize int64  `json:"fileSize"`                            //文件总大小，单位是Byte
+	ID       uint64 `json:"id,string" swaggertype:"string"`      //图片ID，更新图片时传入
+	SpaceID  uint64 `json:"spaceId,string" swaggertype:"string"` //空间ID
+}
+
+// 完成或取消分片上传会话请求
+type UploadSessionRequest struct {
+	UploadID string `json:"uploadId"` //上传会话ID
+}
+
+// 申请预签名直传请求
+type PresignUploadRequest struct {
+	FileName string `json:"fileName"`                            //原始文件名，需携带后缀
+	FileSize int64  `json:"fileSize"`                            //文件大小，单位是Byte，需与实际上传的一致
+	ID       uint64 `json:"id,string" swaggertype:"string"`      //图片ID，更新图片时传入
+	SpaceID  uint64 `json:"spaceId,string" swaggertype:"string"` //空间ID，直传仅支持私有空间和团队空间
+}
@@ -0,0 +1,6 @@
+package picture
+
+// 恢复图片历史版本请求
+type PictureVersionRestoreRequest struct {
+	ID uint64 `json:"id,string" swaggertype:"string"` //历史版本的ID
+}
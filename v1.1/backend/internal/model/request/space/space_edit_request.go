@@ -3,4 +3,5 @@ package space
 type SpaceEditRequest struct {
 	ID        uint64 `json:"id,string" swaggertype:"string"` // Space ID
 	SpaceName string `json:"spaceName"`                      // Space name
+	KeepGPS   *bool  `json:"keepGps"`                        // 是否保留之后上传图片中的GPS信息，不传则不修改
 }
@@ -0,0 +1,12 @@
+package space
+
+type SpaceWatermarkEditRequest struct {
+	SpaceID  uint64  `json:"spaceId,string" swaggertype:"string"` // Space ID
+	Enabled  bool    `json:"enabled"`                             // 是否开启水印
+	Type     string  `json:"type"`                                // 水印类型：text-文字 image-图片，图片需先通过上传接口设置
+	Text     string  `json:"text"`                                // 文字内容，最多64个字符
+	Color    string  `json:"color"`                               // 文字颜色，例如 #FFFFFF，默认白色
+	Position string  `json:"position"`                            // 位置：top-left/top/top-right/left/center/right/bottom-left/bottom/bottom-right/tile，默认bottom-right
+	Opacity  float64 `json:"opacity"`                             // 不透明度(0,1]，默认0.5
+	Scale    float64 `json:"scale"`                               // 水印宽度占图片宽度的比例(0,1]，默认0.2
+}
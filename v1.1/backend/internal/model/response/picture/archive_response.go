@@ -0,0 +1,12 @@
+package picture
+
+// 归档任务报告
+type ArchiveReport struct {
+	Scanned   int   `json:"scanned"`   //检查的图片数量
+	Archived  int   `json:"archived"`  //成功归档的图片数量
+	Skipped   int   `json:"skipped"`   //跳过的图片数量，例如内容被其他图片共享或归档期间被修改
+	Failed    int   `json:"failed"`    //归档失败的图片数量
+	Requeued  int   `json:"requeued"`  //重新投递的超时恢复请求数量
+	StartTime int64 `json:"startTime"` //开始时间，毫秒时间戳
+	EndTime   int64 `json:"endTime"`   //结束时间，毫秒时间戳
+}
@@ -0,0 +1,14 @@
+package picture
+
+import "backend/internal/common"
+
+// 按颜色搜索的结果，按匹配得分从高到低排列
+type ColorPictureVO struct {
+	PictureVO
+	Score float64 `json:"score"` // 匹配得分，范围(0,1]，越大越接近
+}
+
+type ListColorPictureVOResponse struct {
+	common.PageResponse
+	Records []ColorPictureVO `json:"records"`
+}
@@ -0,0 +1,16 @@
+package picture
+
+// 导出任务视图
+type PictureExportJobVO struct {
+	JobID       string `json:"jobId"`       //导出任务ID
+	Status      string `json:"status"`      //任务状态 wait/running/succeed/failed
+	Total       int    `json:"total"`       //需要导出的图片数量
+	Processed   int    `json:"processed"`   //已处理的图片数量
+	Failed      int    `json:"failed"`      //原图读取失败或已归档的图片数量，清单中有记录
+	Progress    int    `json:"progress"`    //进度百分比
+	Message     string `json:"message"`     //失败原因
+	DownloadURL string `json:"downloadUrl"` //下载地址，任务成功后返回，有效期1小时
+	FileSize    int64  `json:"fileSize"`    //压缩包大小
+	CreateTime  int64  `json:"createTime"`  //创建时间，毫秒时间戳
+	ExpireTime  int64  `json:"expireTime"`  //过期时间，毫秒时间戳，过期后压缩包被删除
+}
@@ -0,0 +1,27 @@
+package picture
+
+// 批量导入中单个文件的处理结果
+type ImportFileResult struct {
+	File      string `json:"file"`                                  //压缩包中的文件路径
+	Status    string `json:"status"`                                //处理结果 imported/duplicate/skipped/failed
+	PictureID uint64 `json:"pictureId,string" swaggertype:"string"` //导入的图片ID，内容重复时为已有图片的ID
+	Message   string `json:"message"`                               //未导入的原因
+}
+
+// 导入任务视图
+type PictureImportJobVO struct {
+	JobID      string             `json:"jobId"`                               //导入任务ID
+	SpaceID    uint64             `json:"spaceId,string" swaggertype:"string"` //目标空间ID
+	Status     string             `json:"status"`                              //任务状态 wait/running/succeed/failed
+	Total      int                `json:"total"`                               //压缩包中的文件数量
+	Processed  int                `json:"processed"`                           //已处理的文件数量
+	Imported   int                `json:"imported"`                            //导入成功的数量
+	Duplicated int                `json:"duplicated"`                          //内容重复未导入的数量
+	Skipped    int                `json:"skipped"`                             //不是图片未导入的数量
+	Failed     int                `json:"failed"`                              //失败的数量
+	Progress   int                `json:"progress"`                            //进度百分比
+	Message    string             `json:"message"`                             //任务失败的原因
+	Results    []ImportFileResult `json:"results"`                             //每个文件的处理结果
+	CreateTime int64              `json:"createTime"`                          //创建时间，毫秒时间戳
+	ExpireTime int64              `json:"expireTime"`                          //过期时间，毫秒时间戳
+}
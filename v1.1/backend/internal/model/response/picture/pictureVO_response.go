@@ -20,14 +20,28 @@ type PictureVO struct {
 	PicHeight      int            `json:"picHeight"`
 	PicScale       float64        `json:"picScale"`
 	PicFormat      string         `json:"picFormat"`
-	UserID         uint64         `json:"userId,string", swaggertype:"string"`
+	UserID         uint64         `json:"userId,string" swaggertype:"string"`
 	EditTime       time.Time      `json:"editTime"`
 	CreateTime     time.Time      `json:"createTime"`
 	UpdateTime     time.Time      `json:"updateTime"`
 	User           resUser.UserVO `json:"user" comment:"用户信息"`
 	SpaceID        uint64         `json:"spaceId,string" comment:"空间ID"`
 	PicColor       string         `json:"picColor"`
+	Palette        []entity.PaletteColor     `json:"palette"`        // 调色板，按占比从高到低排列
 	PermissionList []string       `json:"permissionList"` // 空间的权限列表
+	Renditions     []entity.PictureRendition `json:"renditions"` // 衍生版本
+	Metadata       *entity.PictureMetadata   `json:"metadata"`   // EXIF元数据，没有时为null
+	FrameCount     int                       `json:"frameCount"` // 帧数，静态图片为1
+	IsAnimated     bool                      `json:"isAnimated"` // 是否为动图
+	Version        int                       `json:"version"`    // 当前版本号
+	ArchiveStatus  int                       `json:"archiveStatus"` // 归档状态 0-未归档 1-已归档 2-恢复中
+	Highlight      *PictureHighlight         `json:"highlight,omitempty"` // 搜索命中的高亮片段，只在搜索结果中返回
+}
+
+// 搜索结果的高亮片段，内容已做HTML转义，命中的关键词用<em>包裹，未命中的字段为空
+type PictureHighlight struct {
+	Name         string `json:"name,omitempty"`
+	Introduction string `json:"introduction,omitempty"`
 }
 
 // 封装类转化为数据库对象
@@ -53,6 +67,11 @@ func VOToEntity(vo PictureVO) entity.Picture {
 		UpdateTime:   vo.UpdateTime,
 		SpaceID:      vo.SpaceID,
 		PicColor:     vo.PicColor,
+		Palette:      entity.FormatPalette(vo.Palette),
+		Renditions:   entity.FormatRenditions(vo.Renditions),
+		FrameCount:   vo.FrameCount,
+		IsAnimated:   vo.IsAnimated,
+		Version:      vo.Version,
 	}
 }
 
@@ -61,9 +80,14 @@ func EntityToVO(entity entity.Picture, userVO resUser.UserVO) PictureVO {
 	//tags转化为数组
 	var tags []string
 	_ = json.Unmarshal([]byte(entity.Tags), &tags)
+	//已归档的图片只保留缩略图
+	url, renditions := entity.URL, entity.GetRenditions()
+	if entity.ArchiveStatus != 0 {
+		url, renditions = entity.ThumbnailURL, renditions[:0]
+	}
 	return PictureVO{
 		ID:           entity.ID,
-		URL:          entity.URL,
+		URL:          url,
 		ThumbnailURL: entity.ThumbnailURL,
 		Name:         entity.Name,
 		Introduction: entity.Introduction,
@@ -81,5 +105,11 @@ func EntityToVO(entity entity.Picture, userVO resUser.UserVO) PictureVO {
 		User:         userVO,
 		SpaceID:      entity.SpaceID,
 		PicColor:     entity.PicColor,
+		Palette:      entity.GetPalette(),
+		Renditions:   renditions,
+		FrameCount:   entity.FrameCount,
+		IsAnimated:   entity.IsAnimated,
+		Version:      entity.Version,
+		ArchiveStatus: entity.ArchiveStatus,
 	}
 }
@@ -0,0 +1,59 @@
+package picture
+
+import (
+	"backend/internal/model/entity"
+	"time"
+)
+
+// 图片历史版本
+type PictureVersionVO struct {
+	ID           uint64                    `json:"id,string" swaggertype:"string"`
+	PictureID    uint64                    `json:"pictureId,string" swaggertype:"string"`
+	Version      int                       `json:"version"` // 版本号
+	SpaceID      uint64                    `json:"spaceId,string" swaggertype:"string"`
+	UserID       uint64                    `json:"userId,string" swaggertype:"string"`
+	URL          string                    `json:"url"`
+	ThumbnailURL string                    `json:"thumbnailUrl"`
+	PicSize      int64                     `json:"picSize"`
+	PicWidth     int                       `json:"picWidth"`
+	PicHeight    int                       `json:"picHeight"`
+	PicScale     float64                   `json:"picScale"`
+	PicFormat    string                    `json:"picFormat"`
+	PicColor     string                    `json:"picColor"`
+	FrameCount   int                       `json:"frameCount"`
+	Renditions   []entity.PictureRendition `json:"renditions"`
+	Metadata     *entity.PictureMetadata   `json:"metadata"`   // EXIF元数据，没有时为null
+	EditTime     time.Time                 `json:"editTime"`   // 该版本的上传时间
+	CreateTime   time.Time                 `json:"createTime"` // 被替换的时间
+}
+
+// 图片的历史版本列表
+type ListPictureVersionResponse struct {
+	CurrentVersion int                `json:"currentVersion"` // 图片当前的版本号
+	MaxVersions    int                `json:"maxVersions"`    // 最多保留的历史版本数量
+	Records        []PictureVersionVO `json:"records"`        // 按版本号从新到旧排列
+}
+
+// 数据库对象转化为封装类
+func VersionToVO(v entity.PictureVersion) PictureVersionVO {
+	return PictureVersionVO{
+		ID:           v.ID,
+		PictureID:    v.PictureID,
+		Version:      v.Version,
+		SpaceID:      v.SpaceID,
+		UserID:       v.UserID,
+		URL:          v.URL,
+		ThumbnailURL: v.ThumbnailURL,
+		PicSize:      v.PicSize,
+		PicWidth:     v.PicWidth,
+		PicHeight:    v.PicHeight,
+		PicScale:     v.PicScale,
+		PicFormat:    v.PicFormat,
+		PicColor:     v.PicColor,
+		FrameCount:   v.FrameCount,
+		Renditions:   entity.ParseRenditions(v.Renditions),
+		Metadata:     v.GetMetadata(),
+		EditTime:     v.EditTime,
+		CreateTime:   v.CreateTime,
+	}
+}
@@ -0,0 +1,25 @@
+package picture
+
+import (
+	"backend/internal/common"
+	"time"
+)
+
+// 回收站中的图片
+type RecyclePictureVO struct {
+	PictureVO
+	DeleteTime time.Time  `json:"deleteTime"` // 删除时间
+	ExpireTime *time.Time `json:"expireTime"` // 自动彻底删除的时间，未开启自动清理时为null
+}
+
+type ListRecyclePictureResponse struct {
+	common.PageResponse
+	Records []RecyclePictureVO `json:"records"`
+}
+
+// 批量恢复或彻底删除时单张图片的处理结果
+type RecycleResult struct {
+	ID      uint64 `json:"id,string" swaggertype:"string"`
+	Success bool   `json:"success"`
+	Message string `json:"message"` // 失败原因
+}
@@ -0,0 +1,8 @@
+package picture
+
+// 本地以图搜图的结果，按相似度从高到低排列
+type SimilarPictureVO struct {
+	PictureVO
+	Distance   int     `json:"distance"`   // 感知哈希的汉明距离，0表示几乎相同
+	Similarity float64 `json:"similarity"` // 相似度，1-distance/64，保留两位小数
+}
@@ -0,0 +1,13 @@
+package picture
+
+// 空间中的标签及其图片数量
+type PictureTagVO struct {
+	ID    uint64 `json:"id,string" swaggertype:"string"`
+	Name  string `json:"name"`
+	Count int64  `json:"count"` //带有该标签的图片数量
+}
+
+// 标签操作影响的图片数量
+type PictureTagOperateResponse struct {
+	Affected int `json:"affected"`
+}
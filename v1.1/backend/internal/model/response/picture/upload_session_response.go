@@ -0,0 +1,21 @@
+package picture
+
+// 分片上传会话视图，客户端断线后可根据UploadedParts续传
+type UploadSessionVO struct {
+	UploadID      string `json:"uploadId"`      //上传会话ID
+	FileName      string `json:"fileName"`      //原始文件名
+	FileSize      int64  `json:"fileSize"`      //文件总大小
+	PartSize      int64  `json:"partSize"`      //分片大小，最后一片可以更小
+	PartCount     int    `json:"partCount"`     //分片总数
+	UploadedParts []int  `json:"uploadedParts"` //已上传的分片序号，从1开始
+	ExpireTime    int64  `json:"expireTime"`    //会话过期时间，毫秒时间戳
+}
+
+// 预签名直传视图，客户端使用Method向UploadURL上传文件后调用确认接口
+type PresignUploadVO struct {
+	UploadID   string `json:"uploadId"`   //上传ID，确认时传入
+	UploadURL  string `json:"uploadUrl"`  //预签名上传地址
+	Method     string `json:"method"`     //上传使用的HTTP方法
+	Key        string `json:"key"`        //对象存储中的key
+	ExpireTime int64  `json:"expireTime"` //上传地址过期时间，毫秒时间戳
+}
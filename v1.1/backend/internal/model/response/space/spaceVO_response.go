@@ -7,20 +7,22 @@ import (
 )
 
 type SpaceVO struct {
-	ID             uint64         `json:"id,string" swaggertype:"string"` // Space ID
-	SpaceName      string         `json:"spaceName"`
-	SpaceLevel     int            `json:"spaceLevel"`
-	MaxSize        int64          `json:"maxSize"`
-	MaxCount       int64          `json:"maxCount"`
-	TotalSize      int64          `json:"totalSize"`
-	TotalCount     int64          `json:"totalCount"`
-	UserID         uint64         `json:"userId,string" swaggertype:"string"` // User ID
-	CreateTime     time.Time      `json:"createTime"`
-	EditTime       time.Time      `json:"editTime"`
-	UpdateTime     time.Time      `json:"updateTime"`
-	User           resUser.UserVO `json:"user"`
-	SpaceType      int            `json:"spaceType"`      // Space type: 0 - 私人空间, 1 - 团队空间
-	PermissionList []string       `json:"permissionList"` // 空间的权限列表
+	ID             uint64                 `json:"id,string" swaggertype:"string"` // Space ID
+	SpaceName      string                 `json:"spaceName"`
+	SpaceLevel     int                    `json:"spaceLevel"`
+	MaxSize        int64                  `json:"maxSize"`
+	MaxCount       int64                  `json:"maxCount"`
+	TotalSize      int64                  `json:"totalSize"`
+	TotalCount     int64                  `json:"totalCount"`
+	UserID         uint64                 `json:"userId,string" swaggertype:"string"` // User ID
+	CreateTime     time.Time              `json:"createTime"`
+	EditTime       time.Time              `json:"editTime"`
+	UpdateTime     time.Time              `json:"updateTime"`
+	User           resUser.UserVO         `json:"user"`
+	SpaceType      int                    `json:"spaceType"`      // Space type: 0 - 私人空间, 1 - 团队空间
+	KeepGPS        bool                   `json:"keepGps"`        // 是否保留图片中的GPS信息
+	Watermark      *entity.SpaceWatermark `json:"watermark"`      // 水印设置，未设置时为null
+	PermissionList []string               `json:"permissionList"` // 空间的权限列表
 }
 
 // Convert SpaceVO to entity.Space
@@ -38,6 +40,7 @@ func VOToEntity(vo SpaceVO) entity.Space {
 		EditTime:   vo.EditTime,
 		UpdateTime: vo.UpdateTime,
 		SpaceType:  vo.SpaceType,
+		KeepGPS:    vo.KeepGPS,
 	}
 }
 
@@ -57,5 +60,7 @@ func EntityToVO(entity entity.Space, userVO resUser.UserVO) SpaceVO {
 		UpdateTime: entity.UpdateTime,
 		User:       userVO,
 		SpaceType:  entity.SpaceType,
+		KeepGPS:    entity.KeepGPS,
+		Watermark:  entity.GetWatermark(),
 	}
 }
@@ -0,0 +1,15 @@
+package storage
+
+// 存储回收报告
+type StorageGCReport struct {
+	DryRun     bool     `json:"dryRun"`     //是否为演练模式，演练模式下不删除对象
+	Scanned    int      `json:"scanned"`    //扫描的对象数量
+	Orphaned   int      `json:"orphaned"`   //孤儿对象数量
+	OrphanSize int64    `json:"orphanSize"` //孤儿对象总大小，单位是Byte
+	Deleted    int      `json:"deleted"`    //成功删除的对象数量
+	Failed     int      `json:"failed"`     //删除失败的对象数量
+	Purged     int      `json:"purged"`     //彻底删除的过期图片记录数量
+	Orphans    []string `json:"orphans"`    //孤儿对象key，最多列出1000个
+	StartTime  int64    `json:"startTime"`  //开始时间，毫秒时间戳
+	EndTime    int64    `json:"endTime"`    //结束时间，毫秒时间戳
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"backend/internal/model/entity"
+	"backend/pkg/mysql"
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+type PictureArchiveRepository struct {
+	db *gorm.DB
+}
+
+func NewPictureArchiveRepository() *PictureArchiveRepository {
+	return &PictureArchiveRepository{mysql.LoadDB()}
+}
+
+// 保存归档记录
+func (r *PictureArchiveRepository) Create(tx *gorm.DB, archive *entity.PictureArchive) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(archive).Error
+}
+
+// 根据图片ID查找归档记录
+func (r *PictureArchiveRepository) FindByPictureId(tx *gorm.DB, picId uint64) (*entity.PictureArchive, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var archive entity.PictureArchive
+	if err := tx.Where("picture_id = ?", picId).First(&archive).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // 无记录
+		}
+		return nil, err
+	}
+	return &archive, nil
+}
+
+func (r *PictureArchiveRepository) UpdateByPictureId(tx *gorm.DB, picId uint64, updateMap map[string]interface{}) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&entity.PictureArchive{}).Where("picture_id = ?", picId).Updates(updateMap).Error
+}
+
+func (r *PictureArchiveRepository) DeleteByPictureId(tx *gorm.DB, picId uint64) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Where("picture_id = ?", picId).Delete(&entity.PictureArchive{}).Error
+}
+
+// 查询申请恢复的时间早于before、仍未完成恢复的记录
+func (r *PictureArchiveRepository) ListRestoreBefore(tx *gorm.DB, before time.Time, limit int) ([]entity.PictureArchive, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var archives []entity.PictureArchive
+	err := tx.Where("restore_time IS NOT NULL AND restore_time < ?", before).
+		Order("restore_time").Limit(limit).Find(&archives).Error
+	return archives, err
+}
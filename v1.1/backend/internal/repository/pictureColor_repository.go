@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"backend/internal/model/entity"
+	"backend/pkg/mysql"
+	"gorm.io/gorm"
+)
+
+type PictureColorRepository struct {
+	db *gorm.DB
+}
+
+func NewPictureColorRepository() *PictureColorRepository {
+	return &PictureColorRepository{mysql.LoadDB()}
+}
+
+// 替换图片的颜色索引，colors为空时只删除
+func (r *PictureColorRepository) ReplaceByPicture(tx *gorm.DB, picId uint64, colors []entity.PictureColor) error {
+	if tx == nil {
+		tx = r.db
+	}
+	if err := tx.Where("picture_id = ?", picId).Delete(&entity.PictureColor{}).Error; err != nil {
+		return err
+	}
+	if len(colors) == 0 {
+		return nil
+	}
+	return tx.Create(&colors).Error
+}
+
+// 删除图片的颜色索引
+func (r *PictureColorRepository) DeleteByPictureId(tx *gorm.DB, picId uint64) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Where("picture_id = ?", picId).Delete(&entity.PictureColor{}).Error
+}
+
+// 查询空间中位于指定桶内的颜色，spaceId为0时查询公共图库，buckets为nil时不限制桶
+// 只返回未删除的图片，公共图库只返回审核通过的图片
+func (r *PictureColorRepository) FindByBuckets(tx *gorm.DB, spaceId uint64, buckets []int) ([]entity.PictureColor, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var list []entity.PictureColor
+	if buckets != nil && len(buckets) == 0 {
+		return list, nil
+	}
+	pictures := tx.Model(&entity.Picture{}).Select("id")
+	if spaceId == 0 {
+		pictures = pictures.Where("space_id IS NULL AND review_status = ?", 1)
+	} else {
+		pictures = pictures.Where("space_id = ?", spaceId)
+	}
+	query := tx.Select("picture_id", "l", "a", "b", "weight").Where("space_id = ?", spaceId)
+	if buckets != nil {
+		query = query.Where("bucket IN ?", buckets)
+	}
+	err := query.Where("picture_id IN (?)", pictures).Find(&list).Error
+	return list, err
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"backend/internal/model/entity"
+	"backend/pkg/mysql"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PictureContentRepository struct {
+	db *gorm.DB
+}
+
+func NewPictureContentRepository() *PictureContentRepository {
+	return &PictureContentRepository{mysql.LoadDB()}
+}
+
+// 根据内容哈希、衍生版本集合和可见性查找存储对象
+func (r *PictureContentRepository) FindByHash(tx *gorm.DB, hash string, profile string, private bool) (*entity.PictureContent, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var content entity.PictureContent
+	err := tx.Where("content_hash = ? AND rendition_profile = ? AND private = ?", hash, profile, private).First(&content).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // 无记录
+		}
+		return nil, err
+	}
+	return &content, nil
+}
+
+// 查找并锁定存储对象记录，需在事务中调用
+func (r *PictureContentRepository) LockByHash(tx *gorm.DB, hash string, profile string, private bool) (*entity.PictureContent, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var content entity.PictureContent
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("content_hash = ? AND rendition_profile = ? AND private = ?", hash, profile, private).First(&content).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &content, nil
+}
+
+// 增加一次引用，记录不存在时以content插入，返回最终生效的记录
+// 并发上传相同内容时，以先插入的记录为准
+func (r *PictureContentRepository) Acquire(tx *gorm.DB, content *entity.PictureContent) (*entity.PictureContent, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	content.RefCount = 1
+	err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "content_hash"}, {Name: "rendition_profile"}, {Name: "private"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"ref_count": gorm.Expr("ref_count + 1")}),
+	}).Create(content).Error
+	if err != nil {
+		return nil, err
+	}
+	var saved entity.PictureContent
+	err = tx.Where("content_hash = ? AND rendition_profile = ? AND private = ?",
+		content.ContentHash, content.RenditionProfile, content.Private).First(&saved).Error
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// 释放一次引用，引用归零时删除记录并返回该记录，调用方负责在事务提交后删除存储对象
+func (r *PictureContentRepository) Release(tx *gorm.DB, hash string, profile string, private bool) (*entity.PictureContent, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var content entity.PictureContent
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("content_hash = ? AND rendition_profile = ? AND private = ?", hash, profile, private).First(&content).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if content.RefCount > 1 {
+		return nil, tx.Model(&entity.PictureContent{}).
+			Where("content_hash = ? AND rendition_profile = ? AND private = ?", hash, profile, private).
+			Update("ref_count", gorm.Expr("ref_count - 1")).Error
+	}
+	if err := tx.Where("content_hash = ? AND rendition_profile = ? AND private = ?", hash, profile, private).
+		Delete(&entity.PictureContent{}).Error; err != nil {
+		return nil, err
+	}
+	return &content, nil
+}
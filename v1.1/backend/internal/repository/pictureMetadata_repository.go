@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"backend/internal/model/entity"
+	"backend/pkg/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PictureMetadataRepository struct {
+	db *gorm.DB
+}
+
+func NewPictureMetadataRepository() *PictureMetadataRepository {
+	return &PictureMetadataRepository{mysql.LoadDB()}
+}
+
+// 保存图片元数据，已存在时整体覆盖
+func (r *PictureMetadataRepository) Save(tx *gorm.DB, metadata *entity.PictureMetadata) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(metadata).Error
+}
+
+// 删除图片的元数据
+func (r *PictureMetadataRepository) DeleteByPictureId(tx *gorm.DB, picId uint64) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Where("picture_id = ?", picId).Delete(&entity.PictureMetadata{}).Error
+}
+
+// 批量查询图片元数据，返回图片ID到元数据的映射
+func (r *PictureMetadataRepository) FindByPictureIds(tx *gorm.DB, picIds []uint64) (map[uint64]*entity.PictureMetadata, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	res := make(map[uint64]*entity.PictureMetadata, len(picIds))
+	if len(picIds) == 0 {
+		return res, nil
+	}
+	var list []entity.PictureMetadata
+	if err := tx.Where("picture_id IN ?", picIds).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	for i := range list {
+		res[list[i].PictureID] = &list[i]
+	}
+	return res, nil
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"backend/internal/model/entity"
+	"backend/pkg/mysql"
+	"errors"
+	"gorm.io/gorm"
+	"math"
+)
+
+type PictureVersionRepository struct {
+	db *gorm.DB
+}
+
+func NewPictureVersionRepository() *PictureVersionRepository {
+	return &PictureVersionRepository{mysql.LoadDB()}
+}
+
+// 保存一个历史版本
+func (r *PictureVersionRepository) Create(tx *gorm.DB, version *entity.PictureVersion) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(version).Error
+}
+
+// 根据ID查找历史版本
+func (r *PictureVersionRepository) FindById(tx *gorm.DB, id uint64) (*entity.PictureVersion, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var version entity.PictureVersion
+	if err := tx.Where("id = ?", id).First(&version).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // 无记录
+		}
+		return nil, err
+	}
+	return &version, nil
+}
+
+// 查询图片的全部历史版本，按版本号从新到旧排列
+func (r *PictureVersionRepository) ListByPictureId(tx *gorm.DB, picId uint64) ([]entity.PictureVersion, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var versions []entity.PictureVersion
+	err := tx.Where("picture_id = ?", picId).Order("version DESC").Find(&versions).Error
+	return versions, err
+}
+
+// 查询超出保留数量的历史版本，即除最新的keep个之外的版本
+func (r *PictureVersionRepository) ListExpired(tx *gorm.DB, picId uint64, keep int) ([]entity.PictureVersion, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var versions []entity.PictureVersion
+	err := expiredVersionsQuery(tx, picId, keep).Find(&versions).Error
+	return versions, err
+}
+
+// 超出保留数量的历史版本的查询条件
+// MySQL不支持只有OFFSET没有LIMIT，而Limit(-1)会被gorm省略，因此使用最大值作为LIMIT
+func expiredVersionsQuery(tx *gorm.DB, picId uint64, keep int) *gorm.DB {
+	return tx.Where("picture_id = ?", picId).Order("version DESC").
+		Offset(keep).Limit(math.MaxInt32)
+}
+
+// 计算图片全部历史版本的体积之和
+func (r *PictureVersionRepository) SumSizeByPictureId(tx *gorm.DB, picId uint64) (int64, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var total int64
+	err := tx.Model(&entity.PictureVersion{}).Where("picture_id = ?", picId).
+		Select("COALESCE(SUM(pic_size), 0)").Scan(&total).Error
+	return total, err
+}
+
+// 批量删除历史版本
+func (r *PictureVersionRepository) DeleteByIds(tx *gorm.DB, ids []uint64) error {
+	if tx == nil {
+		tx = r.db
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return tx.Where("id IN ?", ids).Delete(&entity.PictureVersion{}).Error
+}
+
+// 查询全部历史版本的对象地址，用于存储回收判断对象是否仍被引用
+func (r *PictureVersionRepository) ListObjectURLs(tx *gorm.DB) ([]entity.PictureVersion, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var versions []entity.PictureVersion
+	err := tx.Select("url", "thumbnail_url", "renditions").Find(&versions).Error
+	return versions, err
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"backend/internal/model/entity"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// 不连接数据库，只生成SQL
+func dryRunDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		DSN:                       "user:pass@tcp(127.0.0.1:3306)/test",
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestExpiredVersionsQuery(t *testing.T) {
+	var versions []entity.PictureVersion
+	stmt := expiredVersionsQuery(dryRunDB(t), 1, 5).Find(&versions).Statement
+	sql := stmt.SQL.String()
+	// MySQL要求OFFSET之前必须有LIMIT
+	limit, offset := strings.Index(sql, "LIMIT"), strings.Index(sql, "OFFSET")
+	if limit < 0 || offset < 0 || limit > offset {
+		t.Fatalf("SQL缺少LIMIT或顺序错误: %s", sql)
+	}
+	if !strings.Contains(sql, "ORDER BY version DESC") {
+		t.Fatalf("SQL缺少排序: %s", sql)
+	}
+	if len(stmt.Vars) == 0 || stmt.Vars[len(stmt.Vars)-1] != 5 {
+		t.Fatalf("OFFSET参数错误: %v", stmt.Vars)
+	}
+}
@@ -3,6 +3,8 @@ package repository
 import (
 	"errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
 	"backend/internal/model/entity"
 	"backend/pkg/mysql"
 )
@@ -111,3 +113,193 @@ func (r *PictureRepository) UpdatePicturesByBatch(tx *gorm.DB, pics []entity.Pic
 			}).Error
 	})
 }
+
+// 查询仍然引用存储对象的图片地址，包含删除时间晚于deletedAfter的软删除记录
+func (r *PictureRepository) ListObjectURLs(tx *gorm.DB, deletedAfter time.Time) ([]entity.Picture, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var pics []entity.Picture
+	err := tx.Unscoped().Select("url", "thumbnail_url", "renditions").
+		Where("is_delete IS NULL OR is_delete > ?", deletedAfter).
+		Find(&pics).Error
+	return pics, err
+}
+
+// 查询删除时间早于deletedBefore的软删除图片
+func (r *PictureRepository) ListDeletedBefore(tx *gorm.DB, deletedBefore time.Time, limit int) ([]entity.Picture, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var pics []entity.Picture
+	err := tx.Unscoped().Where("is_delete IS NOT NULL AND is_delete <= ?", deletedBefore).
+		Limit(limit).Find(&pics).Error
+	return pics, err
+}
+
+// 彻底删除图片记录
+func (r *PictureRepository) HardDeleteById(tx *gorm.DB, id uint64) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Unscoped().Where("id = ?", id).Delete(&entity.Picture{}).Error
+}
+
+// 查找并锁定回收站中的图片，图片不存在或未被删除时返回nil，需在事务中调用
+func (r *PictureRepository) LockDeletedById(tx *gorm.DB, id uint64) (*entity.Picture, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var picture entity.Picture
+	err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND is_delete IS NOT NULL", id).First(&picture).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // 无记录
+		}
+		return nil, err
+	}
+	return &picture, nil
+}
+
+// 将回收站中的图片恢复为正常状态
+func (r *PictureRepository) RestoreById(tx *gorm.DB, id uint64) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Unscoped().Model(&entity.Picture{}).Where("id = ?", id).Update("is_delete", nil).Error
+}
+
+// 查找并锁定图片，包含回收站中的图片，图片不存在时返回nil，需在事务中调用
+func (r *PictureRepository) LockById(tx *gorm.DB, id uint64) (*entity.Picture, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var picture entity.Picture
+	err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&picture).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // 无记录
+		}
+		return nil, err
+	}
+	return &picture, nil
+}
+
+// 查找空间中内容哈希相同的图片ID，不存在时返回0
+func (r *PictureRepository) FindIdByContentHash(tx *gorm.DB, spaceId uint64, contentHash string) (uint64, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var ids []uint64
+	err := tx.Model(&entity.Picture{}).Where("space_id = ? AND content_hash = ?", spaceId, contentHash).
+		Limit(1).Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+// 更新图片的最近查看时间，上次记录的时间晚于before时不更新；不修改更新时间
+func (r *PictureRepository) TouchViewTime(tx *gorm.DB, id uint64, now time.Time, before time.Time) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&entity.Picture{}).
+		Where("id = ? AND (last_view_time IS NULL OR last_view_time < ?)", id, before).
+		UpdateColumn("last_view_time", now).Error
+}
+
+// 查询可以归档的图片：位于指定等级的空间中，且在idleBefore之后没有查看和编辑过，按ID递增分批查询
+func (r *PictureRepository) ListArchiveCandidates(tx *gorm.DB, spaceLevels []int, idleBefore time.Time, afterId uint64, limit int) ([]entity.Picture, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	spaceIds := tx.Model(&entity.Space{}).Select("id").Where("space_level IN ?", spaceLevels)
+	var pics []entity.Picture
+	err := tx.Where("space_id IN (?) AND archive_status = 0 AND id > ?", spaceIds, afterId).
+		Where("edit_time < ? AND COALESCE(last_view_time, create_time) < ?", idleBefore, idleBefore).
+		Order("id").Limit(limit).Find(&pics).Error
+	return pics, err
+}
+
+// 查询空间中已计算感知哈希的图片，只查询ID和哈希；spaceId为0时查询公共图库，reviewStatus不为nil时只查询该审核状态的图片
+func (r *PictureRepository) ListPerceptualHashes(tx *gorm.DB, spaceId uint64, reviewStatus *int) ([]entity.Picture, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	query := tx.Select("id", "p_hash", "d_hash").Where("p_hash IS NOT NULL AND p_hash != ''")
+	if spaceId == 0 {
+		query = query.Where("space_id IS NULL")
+	} else {
+		query = query.Where("space_id = ?", spaceId)
+	}
+	if reviewStatus != nil {
+		query = query.Where("review_status = ?", *reviewStatus)
+	}
+	var pics []entity.Picture
+	err := query.Find(&pics).Error
+	return pics, err
+}
+
+// 查询尚未计算感知哈希的图片，按ID递增分批查询
+func (r *PictureRepository) ListMissingPerceptualHash(tx *gorm.DB, afterId uint64, limit int) ([]entity.Picture, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var pics []entity.Picture
+	err := tx.Select("id", "thumbnail_url", "url").
+		Where("(p_hash IS NULL OR p_hash = '') AND id > ?", afterId).
+		Order("id").Limit(limit).Find(&pics).Error
+	return pics, err
+}
+
+// 写入图片的感知哈希，不修改更新时间
+func (r *PictureRepository) UpdatePerceptualHash(tx *gorm.DB, id uint64, pHash string, dHash string) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&entity.Picture{}).Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"p_hash": pHash, "d_hash": dHash}).Error
+}
+
+// 查询尚未提取调色板的图片，按ID递增分批查询
+func (r *PictureRepository) ListMissingPalette(tx *gorm.DB, afterId uint64, limit int) ([]entity.Picture, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var pics []entity.Picture
+	err := tx.Select("id", "space_id", "thumbnail_url", "url", "pic_color").
+		Where("(palette IS NULL OR palette = '') AND id > ?", afterId).
+		Order("id").Limit(limit).Find(&pics).Error
+	return pics, err
+}
+
+// 写入图片的调色板，不修改更新时间
+func (r *PictureRepository) UpdatePalette(tx *gorm.DB, id uint64, palette string) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&entity.Picture{}).Where("id = ?", id).UpdateColumn("palette", palette).Error
+}
+
+// 写入图片的标签副本，包含回收站中的图片，不修改更新时间
+func (r *PictureRepository) UpdateTags(tx *gorm.DB, id uint64, tags string) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Unscoped().Model(&entity.Picture{}).Where("id = ?", id).UpdateColumn("tags", tags).Error
+}
+
+// 根据ID批量查询图片，不保证顺序
+func (r *PictureRepository) FindByIds(tx *gorm.DB, ids []uint64) ([]entity.Picture, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var pics []entity.Picture
+	if len(ids) == 0 {
+		return pics, nil
+	}
+	err := tx.Where("id IN ?", ids).Find(&pics).Error
+	return pics, err
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"backend/internal/model/entity"
 	"backend/pkg/mysql"
 )
@@ -32,6 +33,22 @@ func (r *SpaceRepository) GetSpaceById(tx *gorm.DB, id uint64) (*entity.Space, e
 	return space, nil
 }
 
+// 查找并锁定空间，用于在事务中校验并修改额度，空间不存在时返回nil
+func (r *SpaceRepository) LockSpaceById(tx *gorm.DB, id uint64) (*entity.Space, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	space := &entity.Space{}
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(space).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return space, nil
+}
+
 func (r *SpaceRepository) UpdateSpaceById(tx *gorm.DB, id uint64, updateMap map[string]interface{}) error {
 	if tx == nil {
 		tx = r.db
@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"backend/internal/model/entity"
+	"backend/pkg/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TagRepository struct {
+	db *gorm.DB
+}
+
+func NewTagRepository() *TagRepository {
+	return &TagRepository{mysql.LoadDB()}
+}
+
+// 开启事务
+func (r *TagRepository) BeginTransaction() *gorm.DB {
+	return r.db.Begin()
+}
+
+// 标签及其图片数量
+type TagCount struct {
+	ID           uint64
+	Name         string
+	PictureCount int64
+}
+
+// 查询空间中的标签，名称比较使用数据库的排序规则（不区分大小写），不存在时返回nil
+func (r *TagRepository) FindByName(tx *gorm.DB, spaceId uint64, name string) (*entity.Tag, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var tags []entity.Tag
+	if err := tx.Where("space_id = ? AND name = ?", spaceId, name).Limit(1).Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	return &tags[0], nil
+}
+
+// 获取空间中的标签，不存在时创建，并发创建同名标签时以先创建的为准
+func (r *TagRepository) FindOrCreate(tx *gorm.DB, spaceId uint64, name string) (*entity.Tag, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	tag := &entity.Tag{SpaceID: spaceId, Name: name}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(tag).Error; err != nil {
+		return nil, err
+	}
+	// 已存在时Create不会返回ID，重新查询
+	if tag.ID == 0 {
+		return r.FindByName(tx, spaceId, name)
+	}
+	return tag, nil
+}
+
+// 替换图片的标签，tagIds的顺序即标签的顺序
+func (r *TagRepository) ReplacePictureTags(tx *gorm.DB, picId uint64, tagIds []uint64) error {
+	if tx == nil {
+		tx = r.db
+	}
+	if err := tx.Where("picture_id = ?", picId).Delete(&entity.PictureTag{}).Error; err != nil {
+		return err
+	}
+	if len(tagIds) == 0 {
+		return nil
+	}
+	rows := make([]entity.PictureTag, 0, len(tagIds))
+	for i, id := range tagIds {
+		rows = append(rows, entity.PictureTag{PictureID: picId, TagID: id, Sort: i})
+	}
+	return tx.Create(&rows).Error
+}
+
+// 删除图片的全部标签关联
+func (r *TagRepository) DeleteByPictureId(tx *gorm.DB, picId uint64) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Where("picture_id = ?", picId).Delete(&entity.PictureTag{}).Error
+}
+
+// 修改标签名称
+func (r *TagRepository) Rename(tx *gorm.DB, tagId uint64, name string) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&entity.Tag{}).Where("id = ?", tagId).Update("name", name).Error
+}
+
+// 把源标签的图片关联移动到目标标签，已有目标标签的图片只删除源标签的关联
+func (r *TagRepository) MoveRelations(tx *gorm.DB, fromId uint64, toId uint64) error {
+	if tx == nil {
+		tx = r.db
+	}
+	// MySQL不允许在UPDATE的子查询中读取同一张表，先查出已有目标标签的图片
+	var existIds []uint64
+	if err := tx.Model(&entity.PictureTag{}).Where("tag_id = ?", toId).Pluck("picture_id", &existIds).Error; err != nil {
+		return err
+	}
+	query := tx.Model(&entity.PictureTag{}).Where("tag_id = ?", fromId)
+	if len(existIds) > 0 {
+		query = query.Where("picture_id NOT IN ?", existIds)
+	}
+	if err := query.Update("tag_id", toId).Error; err != nil {
+		return err
+	}
+	return tx.Where("tag_id = ?", fromId).Delete(&entity.PictureTag{}).Error
+}
+
+// 删除标签及其全部图片关联
+func (r *TagRepository) DeleteById(tx *gorm.DB, tagId uint64) error {
+	if tx == nil {
+		tx = r.db
+	}
+	if err := tx.Where("tag_id = ?", tagId).Delete(&entity.PictureTag{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("id = ?", tagId).Delete(&entity.Tag{}).Error
+}
+
+// 查询带有标签的图片ID，包含回收站中的图片
+func (r *TagRepository) ListPictureIds(tx *gorm.DB, tagIds []uint64) ([]uint64, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var ids []uint64
+	err := tx.Model(&entity.PictureTag{}).Distinct("picture_id").Where("tag_id IN ?", tagIds).Pluck("picture_id", &ids).Error
+	return ids, err
+}
+
+// 按顺序查询图片的标签名称，返回图片ID到标签名称的映射，没有标签的图片不在结果中
+func (r *TagRepository) ListNamesByPictureIds(tx *gorm.DB, picIds []uint64) (map[uint64][]string, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var rows []struct {
+		PictureID uint64
+		Name      string
+	}
+	err := tx.Model(&entity.PictureTag{}).Select("picture_tags.picture_id, tags.name").
+		Joins("JOIN tags ON tags.id = picture_tags.tag_id").
+		Where("picture_tags.picture_id IN ?", picIds).
+		Order("picture_tags.picture_id, picture_tags.sort").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[uint64][]string, len(picIds))
+	for _, row := range rows {
+		res[row.PictureID] = append(res[row.PictureID], row.Name)
+	}
+	return res, nil
+}
+
+// 按名称查询空间中的标签，按未删除的图片数量从多到少排列，没有图片的标签不返回
+// namePattern为LIKE表达式，由调用方转义；公共图库只统计审核通过的图片
+func (r *TagRepository) Suggest(tx *gorm.DB, spaceId uint64, namePattern string, limit int) ([]TagCount, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var list []TagCount
+	pictures := tx.Model(&entity.Picture{}).Select("id")
+	if spaceId == 0 {
+		pictures = pictures.Where("space_id IS NULL AND review_status = ?", 1)
+	} else {
+		pictures = pictures.Where("space_id = ?", spaceId)
+	}
+	err := tx.Model(&entity.Tag{}).Select("tags.id, tags.name, COUNT(*) AS picture_count").
+		Joins("JOIN picture_tags ON picture_tags.tag_id = tags.id").
+		Where("tags.space_id = ? AND tags.name LIKE ?", spaceId, namePattern).
+		Where("picture_tags.picture_id IN (?)", pictures).
+		Group("tags.id, tags.name").
+		Order("picture_count DESC, tags.name").
+		Limit(limit).Scan(&list).Error
+	return list, err
+}
+
+// 统计图片的标签，pictures为只选择图片ID的子查询；不同空间的同名标签分别统计
+func (r *TagRepository) CountByPictures(tx *gorm.DB, pictures *gorm.DB) ([]TagCount, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var list []TagCount
+	err := tx.Model(&entity.PictureTag{}).Select("tags.id, tags.name, COUNT(*) AS picture_count").
+		Joins("JOIN tags ON tags.id = picture_tags.tag_id").
+		Where("picture_tags.picture_id IN (?)", pictures).
+		Group("tags.id, tags.name").
+		Order("picture_count DESC, tags.name").
+		Scan(&list).Error
+	return list, err
+}
+
+// 查询有标签但没有标签关联的图片（包含回收站中的图片），按ID递增分批查询，用于迁移历史数据
+func (r *TagRepository) ListPicturesWithoutRelation(tx *gorm.DB, afterId uint64, limit int) ([]entity.Picture, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var pics []entity.Picture
+	err := tx.Unscoped().Select("id", "space_id", "tags").
+		Where("tags IS NOT NULL AND tags NOT IN ?", []string{"", "[]", "null"}).
+		Where("id > ?", afterId).
+		Where("id NOT IN (?)", tx.Model(&entity.PictureTag{}).Select("picture_id")).
+		Order("id").Limit(limit).Find(&pics).Error
+	return pics, err
+}
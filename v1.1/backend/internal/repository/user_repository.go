@@ -5,6 +5,7 @@ import (
 	"backend/pkg/mysql"
 	"errors"
 	"gorm.io/gorm"
+	"time"
 )
 
 type UserRepository struct {
@@ -155,3 +156,16 @@ func (r *UserRepository) GetQueryUsersNum(tx *gorm.DB, query *gorm.DB) (int, err
 	query.Find(&[]entity.User{}).Count(&total)
 	return int(total), nil
 }
+
+// 查询仍然引用存储对象的用户头像，包含删除时间晚于deletedAfter的软删除用户
+func (r *UserRepository) ListAvatarURLs(tx *gorm.DB, deletedAfter time.Time) ([]string, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var avatars []string
+	err := tx.Unscoped().Model(&entity.User{}).
+		Where("deleted_at IS NULL OR deleted_at > ?", deletedAfter).
+		Where("user_avatar <> ''").
+		Pluck("user_avatar", &avatars).Error
+	return avatars, err
+}
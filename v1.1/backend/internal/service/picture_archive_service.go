@@ -0,0 +1,451 @@
+package service
+
+import (
+	"backend/config"
+	"backend/internal/consts"
+	"backend/internal/ecode"
+	"backend/internal/manager"
+	"backend/internal/model/entity"
+	reqPicture "backend/internal/model/request/picture"
+	resPicture "backend/internal/model/response/picture"
+	"backend/internal/repository"
+	"backend/pkg/mq"
+	"backend/pkg/redlock"
+	"backend/pkg/storage"
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-redsync/redsync/v4"
+)
+
+// 冷存储归档：指定等级空间中长期未查看和编辑的图片，原图、webp主图和衍生版本转移到归档前缀，只保留缩略图
+// 归档时图片释放对内容表的引用，存储对象改由归档记录持有；被其他图片或历史版本共享的内容不归档
+// 用户申请恢复后图片进入恢复中状态，由消息队列的消费者异步将对象复制回原位置并重新引用内容
+
+type PictureArchiveService struct {
+	PictureRepo *repository.PictureRepository
+	ArchiveRepo *repository.PictureArchiveRepository
+	ContentRepo *repository.PictureContentRepository
+}
+
+func NewPictureArchiveService() *PictureArchiveService {
+	return &PictureArchiveService{
+		PictureRepo: repository.NewPictureRepository(),
+		ArchiveRepo: repository.NewPictureArchiveRepository(),
+		ContentRepo: repository.NewPictureContentRepository(),
+	}
+}
+
+// 后台协程，按配置的间隔定期归档长期未访问的图片，并重新投递超时未完成的恢复请求
+func ArchiveBackgroundService() {
+	cfg := config.LoadConfig().ArchiveConfig
+	if cfg == nil || !cfg.Enabled {
+		return
+	}
+	interval := consts.ARCHIVE_INTERVAL_HOURS * time.Hour
+	if cfg.IntervalHours > 0 {
+		interval = time.Duration(cfg.IntervalHours) * time.Hour
+	}
+	log.Printf("启动图片归档服务，间隔 %s", interval)
+	s := NewPictureArchiveService()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		report, err := s.RunArchive()
+		if err != nil {
+			log.Println("图片归档失败，错误为", err.Msg)
+			continue
+		}
+		log.Printf("图片归档完成，检查 %d 张，归档 %d 张，跳过 %d 张，失败 %d 张，重新投递恢复请求 %d 个",
+			report.Scanned, report.Archived, report.Skipped, report.Failed, report.Requeued)
+	}
+}
+
+// 后台协程，消费归档恢复队列，处理失败的消息进入死信队列
+func ArchiveRestoreBackgroundService() {
+	ch := mq.GetChannel()
+	defer mq.ReleaseChannel(ch)
+	msgs, err := ch.Consume(
+		consts.MQArchiveRestoreQueueName,
+		consts.ArchiveRestoreConsumerName,
+		false, // 手动ACK
+		false, // 非排他
+		false, // 非阻塞
+		false, // 不等待
+		nil,   // 额外参数
+	)
+	if err != nil {
+		log.Panicf("注册归档恢复消费者失败: %v", err)
+	}
+	log.Printf("成功注册消费者: %s", consts.ArchiveRestoreConsumerName)
+	s := NewPictureArchiveService()
+	for d := range msgs {
+		picId, _ := strconv.ParseUint(string(d.Body), 10, 64)
+		if err := s.RestorePicture(picId); err != nil {
+			log.Printf("[图片 %d] 归档恢复失败: %v", picId, err)
+			s.cancelRestore(picId)
+			d.Nack(false, false)
+			continue
+		}
+		log.Printf("[图片 %d] 归档恢复完成", picId)
+		d.Ack(false)
+	}
+}
+
+// 执行一次归档，多实例部署时同一时间只有一个实例执行
+func (s *PictureArchiveService) RunArchive() (*resPicture.ArchiveReport, *ecode.ErrorWithCode) {
+	lock := redlock.GetRedSync().NewMutex("chg:archive:run:lock", redsync.WithExpiry(6*time.Hour), redsync.WithTries(1))
+	if err := lock.Lock(); err != nil {
+		return nil, ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "归档任务正在执行")
+	}
+	defer lock.Unlock()
+	report := &resPicture.ArchiveReport{StartTime: time.Now().UnixMilli()}
+	idleDays, spaceLevels, storageClass := archivePolicy()
+	idleBefore := time.Now().AddDate(0, 0, -idleDays)
+	var afterId uint64
+	for {
+		pics, originErr := s.PictureRepo.ListArchiveCandidates(nil, spaceLevels, idleBefore, afterId, consts.ARCHIVE_BATCH)
+		if originErr != nil {
+			return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+		}
+		if len(pics) == 0 {
+			break
+		}
+		for i := range pics {
+			afterId = pics[i].ID
+			report.Scanned++
+			archived, originErr := s.archivePicture(&pics[i], storageClass)
+			switch {
+			case originErr != nil:
+				log.Printf("[图片 %d] 归档失败: %v", pics[i].ID, originErr)
+				report.Failed++
+			case archived:
+				report.Archived++
+			default:
+				report.Skipped++
+			}
+		}
+	}
+	report.Requeued = s.requeueStaleRestores()
+	report.EndTime = time.Now().UnixMilli()
+	return report, nil
+}
+
+// 申请恢复已归档的图片，需要有查看图片的权限；图片已在恢复中时直接返回
+func (s *PictureArchiveService) RequestRestore(req *reqPicture.PictureArchiveRestoreRequest, loginUser *entity.User) *ecode.ErrorWithCode {
+	pic, err := NewPictureService().GetPictureById(req.PictureID)
+	if err != nil {
+		return err
+	}
+	var space *entity.Space
+	if pic.SpaceID != 0 {
+		if space, err = NewSpaceService().GetSpaceById(pic.SpaceID); err != nil {
+			return err
+		}
+	}
+	if !slices.Contains(GetPermissionList(space, loginUser), "picture:view") {
+		return ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "没有权限")
+	}
+	switch pic.ArchiveStatus {
+	case consts.PICTURE_ARCHIVE_NONE:
+		return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "图片未归档")
+	case consts.PICTURE_ARCHIVE_RESTORING:
+		return nil
+	}
+	tx := s.PictureRepo.BeginTransaction()
+	locked, originErr := s.PictureRepo.LockById(tx, pic.ID)
+	if originErr != nil {
+		tx.Rollback()
+		return ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	if locked == nil || locked.ArchiveStatus != consts.PICTURE_ARCHIVED {
+		tx.Rollback()
+		return nil
+	}
+	if originErr := s.PictureRepo.UpdateById(tx, pic.ID, map[string]interface{}{
+		"archive_status": consts.PICTURE_ARCHIVE_RESTORING,
+	}); originErr != nil {
+		tx.Rollback()
+		return ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	if originErr := s.ArchiveRepo.UpdateByPictureId(tx, pic.ID, map[string]interface{}{
+		"restore_time": time.Now(),
+		"restore_by":   loginUser.ID,
+	}); originErr != nil {
+		tx.Rollback()
+		return ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	if originErr := tx.Commit().Error; originErr != nil {
+		return ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	if originErr := s.publishRestore(pic.ID); originErr != nil {
+		log.Printf("[图片 %d] 恢复请求投递失败: %v", pic.ID, originErr)
+		s.cancelRestore(pic.ID)
+		return ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "提交恢复请求失败")
+	}
+	return nil
+}
+
+// 恢复一张图片：将归档对象复制回原位置，重新引用内容，删除归档记录和归档对象
+// 恢复期间有相同内容的图片重新上传时，直接引用已有的对象，复制回来的这一份随后删除
+func (s *PictureArchiveService) RestorePicture(picId uint64) error {
+	archive, err := s.ArchiveRepo.FindByPictureId(nil, picId)
+	if err != nil {
+		return err
+	}
+	//已经恢复或被彻底删除
+	if archive == nil {
+		return nil
+	}
+	keys := archive.GetObjectKeys()
+	if err := manager.RestoreObjects(keys); err != nil {
+		return err
+	}
+	tx := s.PictureRepo.BeginTransaction()
+	pic, err := s.PictureRepo.LockById(tx, picId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if pic == nil {
+		//恢复期间图片被彻底删除，复制回来的对象没有引用
+		tx.Rollback()
+		manager.DeleteObjects(keys)
+		return nil
+	}
+	if pic.ArchiveStatus != consts.PICTURE_ARCHIVE_RESTORING {
+		tx.Rollback()
+		return nil
+	}
+	updateMap := map[string]interface{}{
+		"archive_status": consts.PICTURE_ARCHIVE_NONE,
+		"last_view_time": time.Now(),
+	}
+	var duplicated []string
+	if pic.ContentHash != "" {
+		content, err := s.ContentRepo.Acquire(tx, &entity.PictureContent{
+			ContentHash:      pic.ContentHash,
+			RenditionProfile: pic.RenditionProfile,
+			Private:          pic.SpaceID != 0,
+			OriginKey:        archive.OriginKey,
+			URL:              pic.URL,
+			ThumbnailURL:     pic.ThumbnailURL,
+			PicSize:          pic.PicSize,
+			PicWidth:         pic.PicWidth,
+			PicHeight:        pic.PicHeight,
+			PicScale:         pic.PicScale,
+			PicFormat:        pic.PicFormat,
+			PicColor:         pic.PicColor,
+			PHash:            pic.PHash,
+			DHash:            pic.DHash,
+			Palette:          pic.Palette,
+			FrameCount:       max(pic.FrameCount, 1),
+			Renditions:       pic.Renditions,
+		})
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if content.OriginKey != archive.OriginKey {
+			duplicated = keys
+			if thumbnailKey, ok := storage.ObjectKey(pic.ThumbnailURL); ok {
+				duplicated = append(duplicated, thumbnailKey)
+			}
+			updateMap["url"] = content.URL
+			updateMap["thumbnail_url"] = content.ThumbnailURL
+			updateMap["renditions"] = content.Renditions
+		}
+	}
+	if err := s.PictureRepo.UpdateById(tx, pic.ID, updateMap); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := s.ArchiveRepo.DeleteByPictureId(tx, pic.ID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	manager.DeleteArchivedObjects(keys)
+	manager.DeleteObjects(duplicated)
+	return nil
+}
+
+// 记录图片被查看，用于判断是否长期未访问；距上次记录不足一天时不更新
+func (s *PictureArchiveService) TouchView(picId uint64) {
+	now := time.Now()
+	if err := s.PictureRepo.TouchViewTime(nil, picId, now, now.Add(-consts.ARCHIVE_VIEW_TOUCH_INTERVAL)); err != nil {
+		log.Println("更新图片查看时间失败，错误为", err)
+	}
+}
+
+// 已归档的图片只能查看缩略图，需要读取原图或主图的操作先检查
+func checkPictureNotArchived(pic *entity.Picture) *ecode.ErrorWithCode {
+	switch pic.ArchiveStatus {
+	case consts.PICTURE_ARCHIVED:
+		return ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "图片已归档，请先申请恢复")
+	case consts.PICTURE_ARCHIVE_RESTORING:
+		return ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "图片正在恢复中，请稍后再试")
+	}
+	return nil
+}
+
+// 归档一张图片，返回是否归档；内容被共享或复制期间图片被修改时跳过
+func (s *PictureArchiveService) archivePicture(pic *entity.Picture, storageClass string) (bool, error) {
+	private := pic.SpaceID != 0
+	originKey := ""
+	if pic.ContentHash != "" {
+		content, err := s.ContentRepo.FindByHash(nil, pic.ContentHash, pic.RenditionProfile, private)
+		if err != nil {
+			return false, err
+		}
+		if content != nil {
+			if content.RefCount > 1 {
+				return false, nil
+			}
+			originKey = content.OriginKey
+		}
+	}
+	keys := manager.ArchivableKeys(pic, originKey)
+	if len(keys) == 0 {
+		return false, nil
+	}
+	//1.先复制到归档前缀，数据库更新成功后再删除原位置的对象
+	if err := manager.ArchiveObjects(keys, storageClass); err != nil {
+		return false, err
+	}
+	skip := func() (bool, error) {
+		manager.DeleteArchivedObjects(keys)
+		return false, nil
+	}
+	fail := func(err error) (bool, error) {
+		manager.DeleteArchivedObjects(keys)
+		return false, err
+	}
+	//2.在事务中确认图片和内容没有变化，释放内容引用并保存归档记录
+	tx := s.PictureRepo.BeginTransaction()
+	locked, err := s.PictureRepo.LockById(tx, pic.ID)
+	if err != nil {
+		tx.Rollback()
+		return fail(err)
+	}
+	if locked == nil || locked.IsDelete.Valid || locked.ArchiveStatus != consts.PICTURE_ARCHIVE_NONE ||
+		locked.URL != pic.URL || locked.Version != pic.Version {
+		tx.Rollback()
+		return skip()
+	}
+	if pic.ContentHash != "" {
+		content, err := s.ContentRepo.LockByHash(tx, pic.ContentHash, pic.RenditionProfile, private)
+		if err != nil {
+			tx.Rollback()
+			return fail(err)
+		}
+		if content != nil {
+			if content.RefCount > 1 || content.OriginKey != originKey {
+				tx.Rollback()
+				return skip()
+			}
+			if _, err := s.ContentRepo.Release(tx, pic.ContentHash, pic.RenditionProfile, private); err != nil {
+				tx.Rollback()
+				return fail(err)
+			}
+		}
+	}
+	archive := &entity.PictureArchive{
+		PictureID:    pic.ID,
+		OriginKey:    originKey,
+		StorageClass: storageClass,
+		ArchiveTime:  time.Now(),
+	}
+	archive.SetObjectKeys(keys)
+	if err := s.ArchiveRepo.Create(tx, archive); err != nil {
+		tx.Rollback()
+		return fail(err)
+	}
+	if err := s.PictureRepo.UpdateById(tx, pic.ID, map[string]interface{}{
+		"archive_status": consts.PICTURE_ARCHIVED,
+	}); err != nil {
+		tx.Rollback()
+		return fail(err)
+	}
+	if err := tx.Commit().Error; err != nil {
+		return fail(err)
+	}
+	//3.删除原位置的对象
+	manager.DeleteObjects(keys)
+	return true, nil
+}
+
+// 重新投递超时仍未完成的恢复请求，返回投递的数量
+func (s *PictureArchiveService) requeueStaleRestores() int {
+	archives, err := s.ArchiveRepo.ListRestoreBefore(nil, time.Now().Add(-consts.ARCHIVE_RESTORE_RETRY), consts.ARCHIVE_BATCH)
+	if err != nil {
+		log.Println("查询超时的恢复请求失败，错误为", err)
+		return 0
+	}
+	requeued := 0
+	for _, archive := range archives {
+		if err := s.publishRestore(archive.PictureID); err != nil {
+			log.Printf("[图片 %d] 恢复请求重新投递失败: %v", archive.PictureID, err)
+			continue
+		}
+		//刷新申请时间，避免下一轮重复投递
+		if err := s.ArchiveRepo.UpdateByPictureId(nil, archive.PictureID, map[string]interface{}{"restore_time": time.Now()}); err != nil {
+			log.Println("更新恢复申请时间失败，错误为", err)
+		}
+		requeued++
+	}
+	return requeued
+}
+
+// 取消恢复，图片回到已归档状态，用户可以重新申请
+func (s *PictureArchiveService) cancelRestore(picId uint64) {
+	tx := s.PictureRepo.BeginTransaction()
+	pic, err := s.PictureRepo.LockById(tx, picId)
+	if err != nil || pic == nil || pic.ArchiveStatus != consts.PICTURE_ARCHIVE_RESTORING {
+		tx.Rollback()
+		return
+	}
+	if err := s.PictureRepo.UpdateById(tx, picId, map[string]interface{}{"archive_status": consts.PICTURE_ARCHIVED}); err != nil {
+		tx.Rollback()
+		log.Println("取消恢复失败，错误为", err)
+		return
+	}
+	if err := s.ArchiveRepo.UpdateByPictureId(tx, picId, map[string]interface{}{"restore_time": nil}); err != nil {
+		tx.Rollback()
+		log.Println("取消恢复失败，错误为", err)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Println("取消恢复失败，错误为", err)
+	}
+}
+
+func (s *PictureArchiveService) publishRestore(picId uint64) error {
+	pool := mq.GetChannelPool()
+	if pool == nil {
+		return errors.New("消息队列未初始化")
+	}
+	return pool.PublishMessageWithKey(consts.MQArchiveRestoreRoutingKey, []byte(strconv.FormatUint(picId, 10)))
+}
+
+// 归档策略：未访问天数、参与归档的空间等级和归档对象的存储类型
+func archivePolicy() (int, []int, string) {
+	idleDays := consts.ARCHIVE_IDLE_DAYS
+	spaceLevels := []int{consts.FLAGSHIP.Value}
+	storageClass := ""
+	if cfg := config.LoadConfig().ArchiveConfig; cfg != nil {
+		if cfg.IdleDays > 0 {
+			idleDays = cfg.IdleDays
+		}
+		if len(cfg.SpaceLevels) > 0 {
+			spaceLevels = cfg.SpaceLevels
+		}
+		storageClass = cfg.StorageClass
+	}
+	return idleDays, spaceLevels, storageClass
+}
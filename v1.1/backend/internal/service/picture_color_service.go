@@ -0,0 +1,278 @@
+package service
+
+import (
+	"backend/internal/common"
+	"backend/internal/consts"
+	"backend/internal/ecode"
+	"backend/internal/model/entity"
+	reqPicture "backend/internal/model/request/picture"
+	resPicture "backend/internal/model/response/picture"
+	resUser "backend/internal/model/response/user"
+	"backend/internal/repository"
+	"backend/pkg/colorspace"
+	"backend/pkg/imageproc"
+	"log"
+	"math"
+	"slices"
+	"sort"
+)
+
+type PictureColorService struct {
+	PictureRepo *repository.PictureRepository
+	ColorRepo   *repository.PictureColorRepository
+}
+
+func NewPictureColorService() *PictureColorService {
+	return &PictureColorService{
+		PictureRepo: repository.NewPictureRepository(),
+		ColorRepo:   repository.NewPictureColorRepository(),
+	}
+}
+
+// 后台协程，为没有调色板的历史图片提取调色板并建立颜色索引，启动时执行一次
+func PictureColorBackfillService() {
+	count, err := NewPictureColorService().BackfillPalettes()
+	if err != nil {
+		log.Println("补算图片调色板失败，错误为", err)
+		return
+	}
+	if count > 0 {
+		log.Printf("补算图片调色板完成，共 %d 张", count)
+	}
+}
+
+// 由调色板生成图片的颜色索引记录，格式错误的颜色跳过
+func pictureColorRows(picId uint64, spaceId uint64, palette []entity.PaletteColor) []entity.PictureColor {
+	rows := make([]entity.PictureColor, 0, len(palette))
+	for _, c := range palette {
+		rgb, err := colorspace.ParseHex(c.Color)
+		if err != nil {
+			continue
+		}
+		lab := rgb.Lab()
+		rows = append(rows, entity.PictureColor{
+			PictureID: picId,
+			SpaceID:   spaceId,
+			Bucket:    colorspace.Bucket(rgb),
+			Color:     rgb.Hex(),
+			L:         lab.L,
+			A:         lab.A,
+			B:         lab.B,
+			Weight:    c.Weight,
+		})
+	}
+	return rows
+}
+
+// 按颜色搜索空间中的图片，需要对空间有查看权限
+// 图片的调色板中必须有与每种目标颜色的ΔE2000不超过阈值的颜色，匹配得分按色差和该颜色的占比计算，按得分从高到低分页返回
+func (s *PictureColorService) SearchPictureByColor(req *reqPicture.PictureSearchByColorRequest, loginUser *entity.User) (*resPicture.ListColorPictureVOResponse, *ecode.ErrorWithCode) {
+	//1.参数校验
+	if loginUser == nil {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "用户未登录")
+	}
+	if req.SpaceID <= 0 {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "参数错误")
+	}
+	colors := req.Colors
+	if req.PicColor != "" {
+		colors = append([]string{req.PicColor}, colors...)
+	}
+	targets := make([]colorspace.Lab, 0, len(colors))
+	seen := make(map[colorspace.RGB]bool, len(colors))
+	for _, c := range colors {
+		rgb, originErr := colorspace.ParseHex(c)
+		if originErr != nil {
+			return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "颜色格式错误")
+		}
+		if !seen[rgb] {
+			seen[rgb] = true
+			targets = append(targets, rgb.Lab())
+		}
+	}
+	if len(targets) == 0 {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "请指定要搜索的颜色")
+	}
+	if len(targets) > consts.COLOR_SEARCH_MAX_COLORS {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "颜色数量超出范围")
+	}
+	threshold := req.Threshold
+	if threshold < 0 || threshold > consts.COLOR_SEARCH_MAX_THRESHOLD {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "色差阈值超出范围")
+	}
+	if threshold == 0 {
+		threshold = consts.COLOR_SEARCH_DEFAULT_THRESHOLD
+	}
+	if req.PageSize > consts.COLOR_SEARCH_MAX_PAGE_SIZE {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "每页数量超出范围")
+	}
+	current, pageSize := max(req.Current, 1), req.PageSize
+	if pageSize <= 0 {
+		pageSize = consts.COLOR_SEARCH_DEFAULT_PAGE_SIZE
+	}
+	//2.空间权限校验
+	space, err := NewSpaceService().GetSpaceById(req.SpaceID)
+	if err != nil {
+		return nil, err
+	}
+	permissionList := GetPermissionList(space, loginUser)
+	if !slices.Contains(permissionList, "picture:view") {
+		return nil, ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "没有权限")
+	}
+	//3.计算匹配得分
+	scores, originErr := s.matchColors(req.SpaceID, targets, threshold)
+	if originErr != nil {
+		log.Print(originErr)
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	ids := make([]uint64, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] > ids[j]
+	})
+	//4.分页查询图片，索引中的图片在查询间隙被删除时本页会少于pageSize
+	total := len(ids)
+	start := min((current-1)*pageSize, total)
+	ids = ids[start:min(start+pageSize, total)]
+	pics, originErr := s.PictureRepo.FindByIds(nil, ids)
+	if originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	picMap := make(map[uint64]entity.Picture, len(pics))
+	for _, p := range pics {
+		picMap[p.ID] = p
+	}
+	picService := NewPictureService()
+	records := make([]resPicture.ColorPictureVO, 0, len(ids))
+	for _, id := range ids {
+		p, ok := picMap[id]
+		if !ok {
+			continue
+		}
+		//不需要用户信息，减少用户的查询
+		vo := resPicture.EntityToVO(p, resUser.UserVO{})
+		vo.PermissionList = permissionList
+		records = append(records, resPicture.ColorPictureVO{
+			PictureVO: picService.SignPictureVO(vo, permissionList),
+			Score:     math.Round(scores[id]*10000) / 10000,
+		})
+	}
+	return &resPicture.ListColorPictureVOResponse{
+		Records: records,
+		PageResponse: common.PageResponse{
+			Total:   total,
+			Size:    pageSize,
+			Pages:   int(math.Ceil(float64(total) / float64(pageSize))),
+			Current: current,
+		},
+	}, nil
+}
+
+// 计算空间中匹配全部目标颜色的图片及其得分，得分范围(0,1]
+// 每种目标颜色只读取附近桶中的颜色索引；单种颜色的得分为调色板中色差不超过阈值的颜色的 占比×(1-色差/阈值) 之和，不超过1
+// 图片的得分为各目标颜色得分的平均值
+func (s *PictureColorService) matchColors(spaceId uint64, targets []colorspace.Lab, threshold float64) (map[uint64]float64, error) {
+	var scores map[uint64]float64
+	for i, target := range targets {
+		buckets := colorspace.NearBuckets(target, threshold)
+		if len(buckets) == colorspace.BucketCount {
+			//覆盖所有桶时不按桶过滤
+			buckets = nil
+		}
+		rows, err := s.ColorRepo.FindByBuckets(nil, spaceId, buckets)
+		if err != nil {
+			return nil, err
+		}
+		matched := make(map[uint64]float64)
+		for _, row := range rows {
+			// 前一种颜色没有匹配的图片不再计算
+			if i > 0 {
+				if _, ok := scores[row.PictureID]; !ok {
+					continue
+				}
+			}
+			d := colorspace.DeltaE2000(target, colorspace.Lab{L: row.L, A: row.A, B: row.B})
+			if d <= threshold {
+				matched[row.PictureID] += row.Weight * (1 - d/threshold)
+			}
+		}
+		next := make(map[uint64]float64, len(matched))
+		for id, score := range matched {
+			next[id] = scores[id] + min(score, 1)/float64(len(targets))
+		}
+		scores = next
+		if len(scores) == 0 {
+			break
+		}
+	}
+	// 与目标颜色完全相同但占比极低时得分可能为0，保证匹配的图片得分为正
+	for id, score := range scores {
+		scores[id] = max(score, 1e-4)
+	}
+	return scores, nil
+}
+
+// 为没有调色板的图片提取调色板并建立颜色索引，返回成功补算的数量
+// 读取缩略图失败或没有不透明像素时以主色调作为唯一的颜色，保证历史图片都可以按颜色搜索
+func (s *PictureColorService) BackfillPalettes() (int, error) {
+	var lastId uint64
+	count := 0
+	for {
+		pics, err := s.PictureRepo.ListMissingPalette(nil, lastId, consts.COLOR_BACKFILL_BATCH)
+		if err != nil {
+			return count, err
+		}
+		if len(pics) == 0 {
+			return count, nil
+		}
+		for _, pic := range pics {
+			lastId = pic.ID
+			palette, err := computePicturePalette(&pic)
+			if err != nil {
+				log.Printf("提取图片 %d 的调色板失败: %v", pic.ID, err)
+			}
+			if len(palette) == 0 {
+				if _, parseErr := colorspace.ParseHex(pic.PicColor); parseErr != nil {
+					continue
+				}
+				palette = []entity.PaletteColor{{Color: pic.PicColor, Weight: 1}}
+			}
+			if err := s.savePalette(pic.ID, pic.SpaceID, palette); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+}
+
+// 在同一事务中写入图片的调色板和颜色索引
+func (s *PictureColorService) savePalette(picId uint64, spaceId uint64, palette []entity.PaletteColor) error {
+	tx := s.PictureRepo.BeginTransaction()
+	if err := s.PictureRepo.UpdatePalette(tx, picId, entity.FormatPalette(palette)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := s.ColorRepo.ReplaceByPicture(tx, picId, pictureColorRows(picId, spaceId, palette)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// 读取缩略图提取调色板
+func computePicturePalette(pic *entity.Picture) ([]entity.PaletteColor, error) {
+	img, err := loadPictureThumbnail(pic)
+	if err != nil {
+		return nil, err
+	}
+	palette := make([]entity.PaletteColor, 0, imageproc.PaletteSize)
+	for _, c := range imageproc.Palette(img) {
+		palette = append(palette, entity.PaletteColor{Color: c.Color, Weight: c.Weight})
+	}
+	return palette, nil
+}
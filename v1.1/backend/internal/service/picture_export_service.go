@@ -0,0 +1,440 @@
+package service
+
+import (
+	"archive/zip"
+	"backend/internal/consts"
+	"backend/internal/ecode"
+	"backend/internal/model/entity"
+	reqPicture "backend/internal/model/request/picture"
+	resPicture "backend/internal/model/response/picture"
+	"backend/pkg/mysql"
+	"backend/pkg/redis"
+	"backend/pkg/storage"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/panjf2000/ants/v2"
+	"gorm.io/gorm"
+)
+
+// 批量导出：按查询条件将空间或公共图库的图片原图和元数据清单打包为ZIP，写入对象存储后返回带签名的下载地址
+// 导出任务保存在redis中，由协程池异步执行，前端轮询任务获取进度
+
+// 导出任务，保存在redis中
+type exportJob struct {
+	JobID      string    `json:"jobId"`
+	UserID     uint64    `json:"userId"`
+	SpaceID    uint64    `json:"spaceId"`
+	Status     string    `json:"status"`
+	Total      int       `json:"total"`
+	Processed  int       `json:"processed"`
+	Failed     int       `json:"failed"`
+	ObjectKey  string    `json:"objectKey"`
+	FileSize   int64     `json:"fileSize"`
+	Message    string    `json:"message"`
+	CreateTime time.Time `json:"createTime"`
+	UpdateTime time.Time `json:"updateTime"`
+	ExpireTime time.Time `json:"expireTime"`
+}
+
+// 清单中的一条图片记录
+type exportManifestItem struct {
+	ID           string    `json:"id"`
+	File         string    `json:"file"` // 压缩包中的文件路径，原图未导出时为空
+	Name         string    `json:"name"`
+	Introduction string    `json:"introduction"`
+	Category     string    `json:"category"`
+	Tags         []string  `json:"tags"`
+	PicColor     string    `json:"picColor"`
+	PicFormat    string    `json:"picFormat"`
+	PicWidth     int       `json:"picWidth"`
+	PicHeight    int       `json:"picHeight"`
+	PicSize      int64     `json:"picSize"`
+	CreateTime   time.Time `json:"createTime"`
+	EditTime     time.Time `json:"editTime"`
+	Status       string    `json:"status"` // ok-已导出 archived-已归档未导出 failed-读取失败
+}
+
+type PictureExportService struct {
+	PictureService *PictureService
+	RenderService  *PictureRenderService
+}
+
+func NewPictureExportService() *PictureExportService {
+	return &PictureExportService{
+		PictureService: NewPictureService(),
+		RenderService:  NewPictureRenderService(),
+	}
+}
+
+var exportPool *ants.Pool
+var exportPoolOnce sync.Once
+
+// 导出任务的协程池，执行中的任务已满时提交失败
+func getExportPool() *ants.Pool {
+	exportPoolOnce.Do(func() {
+		var err error
+		exportPool, err = ants.NewPool(consts.EXPORT_POOL_SIZE, ants.WithNonblocking(true))
+		if err != nil {
+			panic(fmt.Sprintf("创建协程池失败: %v", err))
+		}
+	})
+	return exportPool
+}
+
+func exportJobKey(jobId string) string {
+	return fmt.Sprintf("chg:export:job:%s", jobId)
+}
+
+// 创建导出任务，空间中需要有查看图片的权限，公共图库只有管理员可以导出
+// 空间开启水印且没有编辑权限时，导出添加了水印的主图代替原图
+func (s *PictureExportService) CreateExportJob(req *reqPicture.PictureExportRequest, loginUser *entity.User) (*resPicture.PictureExportJobVO, *ecode.ErrorWithCode) {
+	switch req.ManifestFormat {
+	case "":
+		req.ManifestFormat = consts.EXPORT_MANIFEST_JSON
+	case consts.EXPORT_MANIFEST_JSON, consts.EXPORT_MANIFEST_CSV:
+	default:
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "清单格式只支持json和csv")
+	}
+	withWatermark := false
+	if req.SpaceID != 0 {
+		space, err := NewSpaceService().GetSpaceById(req.SpaceID)
+		if err != nil {
+			return nil, err
+		}
+		permissionList := GetPermissionList(space, loginUser)
+		if !slices.Contains(permissionList, "picture:view") {
+			return nil, ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "没有空间权限")
+		}
+		withWatermark = space.ActiveWatermark() != nil && !slices.Contains(permissionList, "picture:edit")
+	} else {
+		if loginUser.UserRole != consts.ADMIN_ROLE {
+			return nil, ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "只有管理员可以导出公共图库")
+		}
+		req.IsNullSpaceID = true
+	}
+	//导出按ID顺序分批读取，排序不生效
+	req.SortField = ""
+	query, err := s.PictureService.GetQueryWrapper(mysql.LoadDB(), &req.PictureQueryRequest)
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	if originErr := query.Model(&entity.Picture{}).Count(&total).Error; originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	if total == 0 {
+		return nil, ecode.GetErrWithDetail(ecode.NOT_FOUND_ERROR, "没有符合条件的图片")
+	}
+	if total > consts.EXPORT_MAX_PICTURES {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, fmt.Sprintf("单次最多导出%d张图片，请缩小查询范围", consts.EXPORT_MAX_PICTURES))
+	}
+	now := time.Now()
+	job := &exportJob{
+		JobID:      uuid.NewString(),
+		UserID:     loginUser.ID,
+		SpaceID:    req.SpaceID,
+		Status:     consts.EXPORT_STATUS_WAITING,
+		Total:      int(total),
+		CreateTime: now,
+		UpdateTime: now,
+		ExpireTime: now.Add(consts.EXPORT_JOB_EXPIRE),
+	}
+	job.ObjectKey = fmt.Sprintf("%s/%d/%s.zip", consts.EXPORT_PREFIX, loginUser.ID, job.JobID)
+	if originErr := s.saveJob(job); originErr != nil {
+		log.Println("导出任务写入redis失败，错误为", originErr)
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "创建导出任务失败")
+	}
+	reqCopy := *req
+	if originErr := getExportPool().Submit(func() {
+		s.runJob(job, &reqCopy, withWatermark)
+	}); originErr != nil {
+		redis.GetRedisClient().Del(context.Background(), exportJobKey(job.JobID))
+		return nil, ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "导出任务过多，请稍后再试")
+	}
+	return job.toVO(""), nil
+}
+
+// 获取导出任务的进度，任务成功时返回带签名的下载地址
+func (s *PictureExportService) GetExportJob(jobId string, loginUser *entity.User) (*resPicture.PictureExportJobVO, *ecode.ErrorWithCode) {
+	if jobId == "" {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "导出任务ID为空")
+	}
+	data, originErr := redis.GetRedisClient().Get(context.Background(), exportJobKey(jobId)).Bytes()
+	if redis.IsNilErr(originErr) {
+		return nil, ecode.GetErrWithDetail(ecode.NOT_FOUND_ERROR, "导出任务不存在或已过期")
+	}
+	if originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "读取导出任务失败")
+	}
+	job := &exportJob{}
+	if originErr := json.Unmarshal(data, job); originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "导出任务解析失败")
+	}
+	if job.UserID != loginUser.ID {
+		return nil, ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "无权访问该导出任务")
+	}
+	//执行任务的实例重启后任务不会继续，长时间没有进度时标记为失败
+	if (job.Status == consts.EXPORT_STATUS_RUNNING || job.Status == consts.EXPORT_STATUS_WAITING) &&
+		time.Since(job.UpdateTime) > consts.EXPORT_STALE_TIMEOUT {
+		job.Status = consts.EXPORT_STATUS_FAILED
+		job.Message = "导出任务已中断，请重新导出"
+	}
+	downloadURL := ""
+	if job.Status == consts.EXPORT_STATUS_SUCCEED {
+		downloadURL, originErr = storage.SignObjectURL(storage.GetStore().ObjectURL(job.ObjectKey), consts.EXPORT_DOWNLOAD_EXPIRE)
+		if originErr != nil {
+			log.Println("签发下载地址失败，错误为", originErr)
+			return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "签发下载地址失败")
+		}
+	}
+	return job.toVO(downloadURL), nil
+}
+
+// 执行导出任务，压缩包通过管道边生成边上传，不在本地落盘
+func (s *PictureExportService) runJob(job *exportJob, req *reqPicture.PictureExportRequest, withWatermark bool) {
+	job.Status = consts.EXPORT_STATUS_RUNNING
+	s.updateJob(job)
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pw.CloseWithError(s.writeZip(pw, job, req, withWatermark))
+	}()
+	counter := &countingReader{r: pr}
+	err := storage.PutPrivateObject(job.ObjectKey, counter, "application/zip")
+	//上传失败时停止生成压缩包，等待写入的协程退出后再更新任务
+	pr.CloseWithError(err)
+	<-done
+	if err != nil {
+		log.Printf("[导出任务 %s] 导出失败: %v", job.JobID, err)
+		if originErr := storage.GetStore().DeleteObject(job.ObjectKey); originErr != nil {
+			log.Println("删除导出文件失败，错误为", originErr)
+		}
+		job.Status = consts.EXPORT_STATUS_FAILED
+		job.Message = "导出失败"
+		s.updateJob(job)
+		return
+	}
+	job.Status = consts.EXPORT_STATUS_SUCCEED
+	job.FileSize = counter.n
+	s.updateJob(job)
+}
+
+// 按ID顺序分批读取图片，写入原图和清单
+func (s *PictureExportService) writeZip(w io.Writer, job *exportJob, req *reqPicture.PictureExportRequest, withWatermark bool) error {
+	zw := zip.NewWriter(w)
+	//按ID分批读取，忽略请求中的排序字段
+	queryReq := req.PictureQueryRequest
+	queryReq.SortField = ""
+	query, err := s.PictureService.GetQueryWrapper(mysql.LoadDB(), &queryReq)
+	if err != nil {
+		return errors.New(err.Msg)
+	}
+	//每批查询在新的会话上追加条件，避免条件累积
+	query = query.Session(&gorm.Session{})
+	items := make([]exportManifestItem, 0, job.Total)
+	var lastId uint64
+	//只导出创建任务时统计的数量，执行期间新增的图片不导出
+	for len(items) < job.Total {
+		var pics []entity.Picture
+		if err := query.Where("id > ?", lastId).Order("id ASC").
+			Limit(min(consts.EXPORT_BATCH, job.Total-len(items))).Find(&pics).Error; err != nil {
+			return err
+		}
+		if len(pics) == 0 {
+			break
+		}
+		for i := range pics {
+			pic := &pics[i]
+			lastId = pic.ID
+			item, err := s.writePicture(zw, pic, withWatermark)
+			if err != nil {
+				return err
+			}
+			if item.Status != "ok" {
+				job.Failed++
+			}
+			items = append(items, item)
+			job.Processed++
+			if job.Processed%consts.EXPORT_PROGRESS_STEP == 0 {
+				s.updateJob(job)
+			}
+		}
+	}
+	if err := writeManifest(zw, items, req.ManifestFormat); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// 写入一张图片，原图读取失败时只在清单中记录，返回的错误表示压缩包写入失败
+func (s *PictureExportService) writePicture(zw *zip.Writer, pic *entity.Picture, withWatermark bool) (exportManifestItem, error) {
+	var tags []string
+	_ = json.Unmarshal([]byte(pic.Tags), &tags)
+	item := exportManifestItem{
+		ID:           strconv.FormatUint(pic.ID, 10),
+		Name:         pic.Name,
+		Introduction: pic.Introduction,
+		Category:     pic.Category,
+		Tags:         tags,
+		PicColor:     pic.PicColor,
+		PicFormat:    pic.PicFormat,
+		PicWidth:     pic.PicWidth,
+		PicHeight:    pic.PicHeight,
+		PicSize:      pic.PicSize,
+		CreateTime:   pic.CreateTime,
+		EditTime:     pic.EditTime,
+		Status:       "ok",
+	}
+	if pic.ArchiveStatus != consts.PICTURE_ARCHIVE_NONE {
+		item.Status = "archived"
+		return item, nil
+	}
+	var key string
+	var originErr error
+	if withWatermark {
+		//主图在上传时已添加水印
+		if k, ok := storage.ObjectKey(pic.URL); ok {
+			key = k
+		} else {
+			originErr = errors.New("图片地址不属于当前存储")
+		}
+	} else {
+		key, originErr = s.RenderService.sourceKey(pic)
+	}
+	var data []byte
+	if originErr == nil {
+		data, originErr = readObject(key)
+	}
+	if originErr != nil {
+		log.Printf("[图片 %d] 导出时读取原图失败: %v", pic.ID, originErr)
+		item.Status = "failed"
+		return item, nil
+	}
+	item.File = fmt.Sprintf("pictures/%d_%s%s", pic.ID, exportFileName(pic.Name), path.Ext(key))
+	//图片本身已压缩，直接存储
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: item.File, Method: zip.Store, Modified: pic.EditTime})
+	if err != nil {
+		return item, err
+	}
+	_, err = fw.Write(data)
+	return item, err
+}
+
+// 写入元数据清单，csv带BOM以便Excel正确识别中文
+func writeManifest(zw *zip.Writer, items []exportManifestItem, format string) error {
+	fw, err := zw.Create("manifest." + format)
+	if err != nil {
+		return err
+	}
+	if format == consts.EXPORT_MANIFEST_CSV {
+		if _, err := fw.Write([]byte("\ufeff")); err != nil {
+			return err
+		}
+		cw := csv.NewWriter(fw)
+		cw.Write([]string{"id", "file", "name", "introduction", "category", "tags", "picColor", "picFormat",
+			"picWidth", "picHeight", "picSize", "createTime", "editTime", "status"})
+		for _, item := range items {
+			cw.Write([]string{item.ID, item.File, item.Name, item.Introduction, item.Category,
+				strings.Join(item.Tags, ";"), item.PicColor, item.PicFormat,
+				strconv.Itoa(item.PicWidth), strconv.Itoa(item.PicHeight), strconv.FormatInt(item.PicSize, 10),
+				item.CreateTime.Format(time.RFC3339), item.EditTime.Format(time.RFC3339), item.Status})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	enc := json.NewEncoder(fw)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]interface{}{"pictures": items})
+}
+
+// 图片名称转为文件名，去掉路径分隔符等不能出现在文件名中的字符
+func exportFileName(name string) string {
+	var b strings.Builder
+	count := 0
+	for _, r := range name {
+		if count >= 50 {
+			break
+		}
+		if r < 0x20 || strings.ContainsRune(`/\:*?"<>|`, r) {
+			r = '_'
+		}
+		b.WriteRune(r)
+		count++
+	}
+	if b.Len() == 0 {
+		return "picture"
+	}
+	return b.String()
+}
+
+func readObject(key string) ([]byte, error) {
+	r, err := storage.GetStore().GetObject(key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func (s *PictureExportService) saveJob(job *exportJob) error {
+	data, _ := json.Marshal(job)
+	return redis.GetRedisClient().Set(context.Background(), exportJobKey(job.JobID), data, time.Until(job.ExpireTime)).Err()
+}
+
+// 更新任务进度，写入失败只记录日志
+func (s *PictureExportService) updateJob(job *exportJob) {
+	job.UpdateTime = time.Now()
+	if err := s.saveJob(job); err != nil {
+		log.Println("更新导出任务失败，错误为", err)
+	}
+}
+
+func (job *exportJob) toVO(downloadURL string) *resPicture.PictureExportJobVO {
+	progress := 0
+	if job.Total > 0 {
+		progress = job.Processed * 100 / job.Total
+	}
+	//压缩包上传完成前进度不到100
+	if job.Status != consts.EXPORT_STATUS_SUCCEED {
+		progress = min(progress, 99)
+	}
+	return &resPicture.PictureExportJobVO{
+		JobID:       job.JobID,
+		Status:      job.Status,
+		Total:       job.Total,
+		Processed:   job.Processed,
+		Failed:      job.Failed,
+		Progress:    progress,
+		Message:     job.Message,
+		DownloadURL: downloadURL,
+		FileSize:    job.FileSize,
+		CreateTime:  job.CreateTime.UnixMilli(),
+		ExpireTime:  job.ExpireTime.UnixMilli(),
+	}
+}
+
+// 统计读取的字节数，用于记录压缩包大小
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,568 @@
+package service
+
+import (
+	"archive/zip"
+	"backend/internal/consts"
+	"backend/internal/ecode"
+	"backend/internal/manager"
+	"backend/internal/model/dto/file"
+	"backend/internal/model/entity"
+	reqPicture "backend/internal/model/request/picture"
+	resPicture "backend/internal/model/response/picture"
+	"backend/internal/repository"
+	"backend/pkg/imageproc"
+	"backend/pkg/redis"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/panjf2000/ants/v2"
+)
+
+// 批量导入：上传ZIP压缩包，逐个校验其中的图片后导入到空间，每个文件单独入库和计入额度，额度不足的文件导入失败
+// 压缩包中可以带有 manifest.json 或 manifest.csv 清单，按文件路径指定名称、简介、分类和标签，格式与批量导出的清单相同
+// 与空间中已有图片或压缩包中其他文件内容相同的图片不重复导入，任务结束后可以查看每个文件的处理结果
+
+// 导入任务，保存在redis中，每个文件的处理结果单独保存在列表中
+type importJob struct {
+	JobID      string    `json:"jobId"`
+	UserID     uint64    `json:"userId"`
+	SpaceID    uint64    `json:"spaceId"`
+	Status     string    `json:"status"`
+	Total      int       `json:"total"`
+	Processed  int       `json:"processed"`
+	Imported   int       `json:"imported"`
+	Duplicated int       `json:"duplicated"`
+	Skipped    int       `json:"skipped"`
+	Failed     int       `json:"failed"`
+	Message    string    `json:"message"`
+	FilePath   string    `json:"filePath"` // 压缩包在本机的临时路径
+	CreateTime time.Time `json:"createTime"`
+	UpdateTime time.Time `json:"updateTime"`
+	ExpireTime time.Time `json:"expireTime"`
+}
+
+// 清单中一个文件的信息，字段与导出清单一致
+type importManifestItem struct {
+	File         string   `json:"file"`
+	Name         string   `json:"name"`
+	Introduction string   `json:"introduction"`
+	Category     string   `json:"category"`
+	Tags         []string `json:"tags"`
+}
+
+type PictureImportService struct {
+	PictureService *PictureService
+	PictureRepo    *repository.PictureRepository
+}
+
+func NewPictureImportService() *PictureImportService {
+	return &PictureImportService{
+		PictureService: NewPictureService(),
+		PictureRepo:    repository.NewPictureRepository(),
+	}
+}
+
+var importPool *ants.Pool
+var importPoolOnce sync.Once
+
+// 导入任务的协程池，执行中的任务已满时提交失败
+func getImportPool() *ants.Pool {
+	importPoolOnce.Do(func() {
+		var err error
+		importPool, err = ants.NewPool(consts.IMPORT_POOL_SIZE, ants.WithNonblocking(true))
+		if err != nil {
+			panic(fmt.Sprintf("创建协程池失败: %v", err))
+		}
+	})
+	return importPool
+}
+
+func importJobKey(jobId string) string {
+	return fmt.Sprintf("chg:import:job:%s", jobId)
+}
+
+func importResultsKey(jobId string) string {
+	return fmt.Sprintf("chg:import:job:%s:results", jobId)
+}
+
+// 创建导入任务，需要有空间的上传权限；压缩包先保存到本机的临时文件，由执行任务的协程读取
+func (s *PictureImportService) CreateImportJob(req *reqPicture.PictureImportRequest, zipFile *multipart.FileHeader, loginUser *entity.User) (*resPicture.PictureImportJobVO, *ecode.ErrorWithCode) {
+	if zipFile == nil {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "文件为空")
+	}
+	if zipFile.Size > consts.IMPORT_MAX_ZIP_SIZE {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "压缩包不能超过512MB")
+	}
+	if req.SpaceID == 0 {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "空间ID不能为空")
+	}
+	space, err := NewSpaceService().GetSpaceById(req.SpaceID)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(GetPermissionList(space, loginUser), "picture:upload") {
+		return nil, ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "没有空间权限")
+	}
+	if space.TotalCount >= space.MaxCount {
+		return nil, ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "空间图片数量已满")
+	}
+	filePath, err := saveImportFile(zipFile)
+	if err != nil {
+		return nil, err
+	}
+	total, err := countImportEntries(filePath)
+	if err != nil {
+		os.Remove(filePath)
+		return nil, err
+	}
+	now := time.Now()
+	job := &importJob{
+		JobID:      uuid.NewString(),
+		UserID:     loginUser.ID,
+		SpaceID:    space.ID,
+		Status:     consts.EXPORT_STATUS_WAITING,
+		Total:      total,
+		FilePath:   filePath,
+		CreateTime: now,
+		UpdateTime: now,
+		ExpireTime: now.Add(consts.IMPORT_JOB_EXPIRE),
+	}
+	if originErr := s.saveJob(job); originErr != nil {
+		log.Println("导入任务写入redis失败，错误为", originErr)
+		os.Remove(filePath)
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "创建导入任务失败")
+	}
+	if originErr := getImportPool().Submit(func() {
+		s.runJob(job, loginUser)
+	}); originErr != nil {
+		redis.GetRedisClient().Del(context.Background(), importJobKey(job.JobID))
+		os.Remove(filePath)
+		return nil, ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "导入任务过多，请稍后再试")
+	}
+	return job.toVO(nil), nil
+}
+
+// 获取导入任务的进度和已处理文件的结果
+func (s *PictureImportService) GetImportJob(jobId string, loginUser *entity.User) (*resPicture.PictureImportJobVO, *ecode.ErrorWithCode) {
+	if jobId == "" {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "导入任务ID为空")
+	}
+	ctx := context.Background()
+	data, originErr := redis.GetRedisClient().Get(ctx, importJobKey(jobId)).Bytes()
+	if redis.IsNilErr(originErr) {
+		return nil, ecode.GetErrWithDetail(ecode.NOT_FOUND_ERROR, "导入任务不存在或已过期")
+	}
+	if originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "读取导入任务失败")
+	}
+	job := &importJob{}
+	if originErr := json.Unmarshal(data, job); originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "导入任务解析失败")
+	}
+	if job.UserID != loginUser.ID {
+		return nil, ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "无权访问该导入任务")
+	}
+	//执行任务的实例重启后任务不会继续，长时间没有进度时标记为失败，已导入的图片保留
+	if (job.Status == consts.EXPORT_STATUS_RUNNING || job.Status == consts.EXPORT_STATUS_WAITING) &&
+		time.Since(job.UpdateTime) > consts.EXPORT_STALE_TIMEOUT {
+		job.Status = consts.EXPORT_STATUS_FAILED
+		job.Message = "导入任务已中断，已导入的图片不受影响"
+	}
+	items, originErr := redis.GetRedisClient().LRange(ctx, importResultsKey(jobId), 0, -1).Result()
+	if originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "读取导入结果失败")
+	}
+	results := make([]resPicture.ImportFileResult, 0, len(items))
+	for _, item := range items {
+		var result resPicture.ImportFileResult
+		if json.Unmarshal([]byte(item), &result) == nil {
+			results = append(results, result)
+		}
+	}
+	return job.toVO(results), nil
+}
+
+// 执行导入任务，逐个处理压缩包中的文件，结束后删除临时文件
+func (s *PictureImportService) runJob(job *importJob, loginUser *entity.User) {
+	defer os.Remove(job.FilePath)
+	job.Status = consts.EXPORT_STATUS_RUNNING
+	s.updateJob(job)
+	if err := s.importZip(job, loginUser); err != nil {
+		log.Printf("[导入任务 %s] 导入失败: %v", job.JobID, err)
+		job.Status = consts.EXPORT_STATUS_FAILED
+		job.Message = err.Error()
+		s.updateJob(job)
+		return
+	}
+	job.Status = consts.EXPORT_STATUS_SUCCEED
+	s.updateJob(job)
+}
+
+func (s *PictureImportService) importZip(job *importJob, loginUser *entity.User) error {
+	zr, originErr := zip.OpenReader(job.FilePath)
+	if originErr != nil {
+		return errors.New("压缩包解析失败")
+	}
+	defer zr.Close()
+	manifest, originErr := readImportManifest(&zr.Reader)
+	if originErr != nil {
+		return originErr
+	}
+	//文件大小上限按空间等级确定
+	space, err := NewSpaceService().GetSpaceById(job.SpaceID)
+	if err != nil {
+		return errors.New(err.Msg)
+	}
+	maxFileSize := int64(consts.UPLOAD_PUBLIC_MAX_FILE_SIZE)
+	if spaceLevel := consts.GetSpaceLevelByValue(space.SpaceLevel); spaceLevel != nil {
+		maxFileSize = spaceLevel.MaxFileSize
+	}
+	seen := make(map[string]string)
+	for _, f := range zr.File {
+		if !isImportEntry(f) {
+			continue
+		}
+		result := s.importFile(f, space, maxFileSize, manifest, seen, loginUser)
+		switch result.Status {
+		case consts.IMPORT_RESULT_IMPORTED:
+			job.Imported++
+		case consts.IMPORT_RESULT_DUPLICATE:
+			job.Duplicated++
+		case consts.IMPORT_RESULT_SKIPPED:
+			job.Skipped++
+		default:
+			job.Failed++
+		}
+		s.appendResult(job, result)
+		job.Processed++
+		if job.Processed%consts.IMPORT_PROGRESS_STEP == 0 {
+			s.updateJob(job)
+		}
+	}
+	return nil
+}
+
+// 导入一个文件：校验类型和大小，按内容去重后通过UploadPicture入库，再写入清单中的信息
+// seen记录压缩包中已处理文件的内容哈希
+func (s *PictureImportService) importFile(f *zip.File, space *entity.Space, maxFileSize int64, manifest map[string]importManifestItem,
+	seen map[string]string, loginUser *entity.User) resPicture.ImportFileResult {
+	result := resPicture.ImportFileResult{File: f.Name}
+	fail := func(status string, message string) resPicture.ImportFileResult {
+		result.Status = status
+		result.Message = message
+		return result
+	}
+	if manager.ValidPictureType(f.Name) != nil {
+		return fail(consts.IMPORT_RESULT_SKIPPED, "不支持的文件类型")
+	}
+	if f.UncompressedSize64 > uint64(maxFileSize) {
+		return fail(consts.IMPORT_RESULT_FAILED, fmt.Sprintf("文件过大，不能超过%dMB", maxFileSize/1024/1024))
+	}
+	data, originErr := readZipFile(f, maxFileSize)
+	if originErr != nil {
+		return fail(consts.IMPORT_RESULT_FAILED, originErr.Error())
+	}
+	//按与上传时相同的方式计算内容哈希，空间未开启保留GPS时原图去除位置信息后再计算
+	hashData := data
+	if !space.KeepGPS {
+		hashData, _ = imageproc.StripGPS(data)
+	}
+	sum := sha256.Sum256(hashData)
+	contentHash := hex.EncodeToString(sum[:])
+	if first, ok := seen[contentHash]; ok {
+		return fail(consts.IMPORT_RESULT_DUPLICATE, "与压缩包中的 "+first+" 内容相同")
+	}
+	seen[contentHash] = f.Name
+	existId, originErr := s.PictureRepo.FindIdByContentHash(nil, space.ID, contentHash)
+	if originErr != nil {
+		return fail(consts.IMPORT_RESULT_FAILED, "数据库错误")
+	}
+	if existId != 0 {
+		result.PictureID = existId
+		return fail(consts.IMPORT_RESULT_DUPLICATE, "空间中已有相同的图片")
+	}
+	picVO, err := s.PictureService.UploadPicture(&file.PictureStream{
+		Reader:   bytes.NewReader(data),
+		FileName: path.Base(f.Name),
+		Size:     int64(len(data)),
+	}, &reqPicture.PictureUploadRequest{SpaceID: space.ID}, loginUser)
+	if err != nil {
+		return fail(consts.IMPORT_RESULT_FAILED, err.Msg)
+	}
+	result.PictureID = picVO.ID
+	result.Status = consts.IMPORT_RESULT_IMPORTED
+	//写入名称和清单中的信息，失败时图片保留上传生成的名称
+	item, ok := manifest[f.Name]
+	if !ok {
+		item = manifest[path.Base(f.Name)]
+	}
+	if item.Name == "" {
+		base := path.Base(f.Name)
+		item.Name = strings.TrimSuffix(base, path.Ext(base))
+	}
+	tags := fitTags(item.Tags)
+	updateMap := map[string]interface{}{
+		"name":         truncateRunes(item.Name, consts.IMPORT_NAME_MAX_LEN),
+		"introduction": truncateBytes(item.Introduction, consts.IMPORT_INTRO_MAX_SIZE),
+		"category":     item.Category,
+		"tags":         formatTags(tags),
+	}
+	tx := s.PictureRepo.BeginTransaction()
+	originErr = s.PictureRepo.UpdateById(tx, picVO.ID, updateMap)
+	if originErr == nil {
+		originErr = NewTagService().setPictureTags(tx, []uint64{picVO.ID}, space.ID, tags)
+	}
+	if originErr == nil {
+		originErr = tx.Commit().Error
+	} else {
+		tx.Rollback()
+	}
+	if originErr != nil {
+		log.Println("写入导入图片的信息失败，错误为", originErr)
+		result.Message = "图片已导入，写入名称和标签失败"
+	}
+	return result
+}
+
+// 读取压缩包中的清单文件，没有清单时返回空集合；清单以文件路径为键，同时支持只写文件名
+func readImportManifest(zr *zip.Reader) (map[string]importManifestItem, error) {
+	manifest := make(map[string]importManifestItem)
+	for _, f := range zr.File {
+		name := strings.ToLower(f.Name)
+		if name != "manifest.json" && name != "manifest.csv" {
+			continue
+		}
+		data, err := readZipFile(f, consts.IMPORT_MANIFEST_MAX_SIZE)
+		if err != nil {
+			return nil, fmt.Errorf("读取清单失败: %w", err)
+		}
+		data = bytes.TrimPrefix(data, []byte("\ufeff"))
+		var items []importManifestItem
+		if name == "manifest.json" {
+			items, err = parseManifestJSON(data)
+		} else {
+			items, err = parseManifestCSV(data)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			if item.File != "" {
+				manifest[item.File] = item
+			}
+		}
+		return manifest, nil
+	}
+	return manifest, nil
+}
+
+// JSON清单可以是数组，也可以是导出清单的 {"pictures": [...]} 格式
+func parseManifestJSON(data []byte) ([]importManifestItem, error) {
+	var items []importManifestItem
+	if err := json.Unmarshal(data, &items); err == nil {
+		return items, nil
+	}
+	var wrapper struct {
+		Pictures []importManifestItem `json:"pictures"`
+	}
+	if err := json.Unmarshal(data, &wrapper); err != nil {
+		return nil, errors.New("清单格式错误")
+	}
+	return wrapper.Pictures, nil
+}
+
+// CSV清单第一行为表头，需要有file列，标签以分号分隔
+func parseManifestCSV(data []byte) ([]importManifestItem, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil || len(records) == 0 {
+		return nil, errors.New("清单格式错误")
+	}
+	columns := make(map[string]int)
+	for i, header := range records[0] {
+		columns[strings.TrimSpace(header)] = i
+	}
+	if _, ok := columns["file"]; !ok {
+		return nil, errors.New("清单缺少file列")
+	}
+	get := func(record []string, column string) string {
+		if i, ok := columns[column]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	items := make([]importManifestItem, 0, len(records)-1)
+	for _, record := range records[1:] {
+		item := importManifestItem{
+			File:         get(record, "file"),
+			Name:         get(record, "name"),
+			Introduction: get(record, "introduction"),
+			Category:     get(record, "category"),
+		}
+		for _, tag := range strings.Split(get(record, "tags"), ";") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				item.Tags = append(item.Tags, tag)
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// 是否为需要处理的文件，目录、清单和系统生成的隐藏文件不处理
+func isImportEntry(f *zip.File) bool {
+	if f.FileInfo().IsDir() {
+		return false
+	}
+	name := strings.ToLower(f.Name)
+	if name == "manifest.json" || name == "manifest.csv" || strings.HasPrefix(name, "__macosx/") {
+		return false
+	}
+	return !strings.HasPrefix(path.Base(f.Name), ".")
+}
+
+// 读取压缩包中的一个文件，按实际解压的大小限制，避免压缩炸弹
+func readZipFile(f *zip.File, limit int64) ([]byte, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, errors.New("文件解压失败")
+	}
+	defer r.Close()
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, errors.New("文件解压失败")
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("文件过大，不能超过%dMB", limit/1024/1024)
+	}
+	return data, nil
+}
+
+// 将上传的压缩包保存到临时文件
+func saveImportFile(zipFile *multipart.FileHeader) (string, *ecode.ErrorWithCode) {
+	src, originErr := zipFile.Open()
+	if originErr != nil {
+		return "", ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "文件读取失败")
+	}
+	defer src.Close()
+	dst, originErr := os.CreateTemp("", "picture-import-*.zip")
+	if originErr != nil {
+		log.Println("创建临时文件失败，错误为", originErr)
+		return "", ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "保存文件失败")
+	}
+	defer dst.Close()
+	if _, originErr := io.Copy(dst, io.LimitReader(src, consts.IMPORT_MAX_ZIP_SIZE)); originErr != nil {
+		os.Remove(dst.Name())
+		return "", ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "保存文件失败")
+	}
+	return dst.Name(), nil
+}
+
+// 校验压缩包格式并统计需要处理的文件数量
+func countImportEntries(filePath string) (int, *ecode.ErrorWithCode) {
+	zr, originErr := zip.OpenReader(filePath)
+	if originErr != nil {
+		return 0, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "文件不是有效的ZIP压缩包")
+	}
+	defer zr.Close()
+	total := 0
+	for _, f := range zr.File {
+		if isImportEntry(f) {
+			total++
+		}
+	}
+	if total == 0 {
+		return 0, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "压缩包中没有文件")
+	}
+	if total > consts.IMPORT_MAX_FILES {
+		return 0, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, fmt.Sprintf("压缩包中最多包含%d个文件", consts.IMPORT_MAX_FILES))
+	}
+	return total, nil
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// 按字节数截断，不截断在多字节字符中间
+func truncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func (s *PictureImportService) saveJob(job *importJob) error {
+	data, _ := json.Marshal(job)
+	return redis.GetRedisClient().Set(context.Background(), importJobKey(job.JobID), data, time.Until(job.ExpireTime)).Err()
+}
+
+// 更新任务进度，写入失败只记录日志
+func (s *PictureImportService) updateJob(job *importJob) {
+	job.UpdateTime = time.Now()
+	if err := s.saveJob(job); err != nil {
+		log.Println("更新导入任务失败，错误为", err)
+	}
+}
+
+// 追加一个文件的处理结果，写入失败只记录日志
+func (s *PictureImportService) appendResult(job *importJob, result resPicture.ImportFileResult) {
+	ctx := context.Background()
+	data, _ := json.Marshal(result)
+	key := importResultsKey(job.JobID)
+	if err := redis.GetRedisClient().RPush(ctx, key, data).Err(); err != nil {
+		log.Println("保存导入结果失败，错误为", err)
+		return
+	}
+	redis.GetRedisClient().ExpireAt(ctx, key, job.ExpireTime)
+}
+
+func (job *importJob) toVO(results []resPicture.ImportFileResult) *resPicture.PictureImportJobVO {
+	progress := 0
+	if job.Total > 0 {
+		progress = job.Processed * 100 / job.Total
+	}
+	if results == nil {
+		results = []resPicture.ImportFileResult{}
+	}
+	return &resPicture.PictureImportJobVO{
+		JobID:      job.JobID,
+		SpaceID:    job.SpaceID,
+		Status:     job.Status,
+		Total:      job.Total,
+		Processed:  job.Processed,
+		Imported:   job.Imported,
+		Duplicated: job.Duplicated,
+		Skipped:    job.Skipped,
+		Failed:     job.Failed,
+		Progress:   progress,
+		Message:    job.Message,
+		Results:    results,
+		CreateTime: job.CreateTime.UnixMilli(),
+		ExpireTime: job.ExpireTime.UnixMilli(),
+	}
+}
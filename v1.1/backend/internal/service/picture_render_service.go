@@ -0,0 +1,370 @@
+package service
+
+import (
+	"backend/config"
+	"backend/internal/consts"
+	"backend/internal/ecode"
+	"backend/internal/manager"
+	"backend/internal/model/dto/file"
+	"backend/internal/model/entity"
+	reqPicture "backend/internal/model/request/picture"
+	"backend/internal/repository"
+	"backend/pkg/cache"
+	"backend/pkg/imageproc"
+	"backend/pkg/storage"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+type PictureRenderService struct {
+	PictureRepo *repository.PictureRepository
+	ContentRepo *repository.PictureContentRepository
+	SpaceRepo   *repository.SpaceRepository
+}
+
+func NewPictureRenderService() *PictureRenderService {
+	return &PictureRenderService{
+		PictureRepo: repository.NewPictureRepository(),
+		ContentRepo: repository.NewPictureContentRepository(),
+		SpaceRepo:   repository.NewSpaceRepository(),
+	}
+}
+
+// 相同的渲染请求只处理一次
+var renderGroup singleflight.Group
+
+var (
+	renderSecretOnce sync.Once
+	renderSecret     []byte
+)
+
+// 渲染图片，先后查找本地缓存、存储中的渲染结果，都没有时从原图生成
+// 携带有效签名时跳过权限校验，否则与获取图片详情的权限一致
+func (s *PictureRenderService) RenderPicture(picId uint64, req *reqPicture.PictureRenderRequest, loginUser *entity.User) (*file.RenderedPicture, *ecode.ErrorWithCode) {
+	if err := s.normalizeRequest(req); err != nil {
+		return nil, err
+	}
+	pic, originErr := s.PictureRepo.FindById(nil, picId)
+	if originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	if pic == nil {
+		return nil, ecode.GetErrWithDetail(ecode.NOT_FOUND_ERROR, "图片不存在")
+	}
+	space, err := s.getSpace(pic)
+	if err != nil {
+		return nil, err
+	}
+	//权限校验
+	var permissionList []string
+	if req.Sign != "" {
+		if !s.verifySign(picId, req) {
+			return nil, ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "签名无效或已过期")
+		}
+	} else {
+		if loginUser == nil {
+			return nil, ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "未登录")
+		}
+		permissionList = GetPermissionList(space, loginUser)
+		if !slices.Contains(permissionList, "picture:view") {
+			return nil, ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "没有权限")
+		}
+		NewPictureArchiveService().TouchView(pic.ID)
+	}
+	//已归档的图片只保留缩略图，无法渲染
+	if err := checkPictureNotArchived(pic); err != nil {
+		return nil, err
+	}
+	//空间开启水印时，没有编辑权限的成员和持有签名的第三方只能获取添加了水印的结果
+	var watermark *entity.SpaceWatermark
+	if !slices.Contains(permissionList, "picture:edit") {
+		watermark = space.ActiveWatermark()
+	}
+	etag := s.variantHash(pic, req, watermark)
+	result := &file.RenderedPicture{
+		ContentType: imageproc.ContentType(req.Format),
+		ETag:        etag,
+		Public:      pic.SpaceID == 0,
+	}
+	//1.本地缓存
+	cacheKey := "chg:render:" + etag
+	if v, found := cache.GetCache().Get(cacheKey); found {
+		if data, ok := v.([]byte); ok {
+			result.Data = data
+			return result, nil
+		}
+	}
+	//2.存储中的渲染结果，或者从原图生成
+	v, originErr, _ := renderGroup.Do(etag, func() (interface{}, error) {
+		return s.loadOrRenderVariant(pic, space, watermark != nil, req, etag)
+	})
+	if originErr != nil {
+		log.Println("渲染图片失败，错误为", originErr)
+		return nil, ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "渲染图片失败")
+	}
+	result.Data = v.([]byte)
+	if len(result.Data) <= consts.RENDER_LOCAL_CACHE_LIMIT {
+		cache.GetCache().SetWithTTL(cacheKey, result.Data, int64(len(result.Data)), consts.RENDER_LOCAL_CACHE_TTL)
+	}
+	return result, nil
+}
+
+// 生成带签名的渲染地址，交给搜图等第三方服务访问，调用方需先完成权限校验
+func (s *PictureRenderService) SignedRenderURL(picId uint64, req *reqPicture.PictureRenderRequest, expire time.Duration) (string, *ecode.ErrorWithCode) {
+	cfg := config.LoadConfig().RenderConfig
+	if cfg == nil || cfg.BaseURL == "" {
+		return "", ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "未配置 render.base_url")
+	}
+	if err := s.normalizeRequest(req); err != nil {
+		return "", err
+	}
+	req.Expires = time.Now().Add(expire).Unix()
+	query := url.Values{}
+	if req.Width > 0 {
+		query.Set("width", strconv.Itoa(req.Width))
+	}
+	if req.Height > 0 {
+		query.Set("height", strconv.Itoa(req.Height))
+	}
+	if req.Quality > 0 {
+		query.Set("quality", strconv.Itoa(req.Quality))
+	}
+	query.Set("fit", req.Fit)
+	query.Set("format", req.Format)
+	query.Set("expires", strconv.FormatInt(req.Expires, 10))
+	query.Set("sign", s.sign(picId, req))
+	return fmt.Sprintf("%s/v1/picture/render/%d?%s", strings.TrimRight(cfg.BaseURL, "/"), picId, query.Encode()), nil
+}
+
+// 生成外部服务可以直接访问的图片地址，优先使用签名的渲染地址
+// 未配置渲染服务时使用原图地址，私有空间的原图签发临时访问地址
+func (s *PictureRenderService) ExternalPictureURL(pic *entity.Picture, req *reqPicture.PictureRenderRequest, expire time.Duration) (string, *ecode.ErrorWithCode) {
+	signed, err := s.SignedRenderURL(pic.ID, req, expire)
+	if err == nil {
+		return signed, nil
+	}
+	objectURL := manager.SignObjectURL(pic.URL)
+	if objectURL == "" {
+		return "", ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "无法生成图片的访问地址")
+	}
+	return objectURL, nil
+}
+
+// 校验参数并填充默认值，签名和缓存都基于规范化后的参数
+func (s *PictureRenderService) normalizeRequest(req *reqPicture.PictureRenderRequest) *ecode.ErrorWithCode {
+	if req.Width < 0 || req.Height < 0 || req.Width > consts.RENDER_MAX_SIZE || req.Height > consts.RENDER_MAX_SIZE {
+		return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, fmt.Sprintf("宽高需在0~%d之间", consts.RENDER_MAX_SIZE))
+	}
+	if req.Quality < 0 || req.Quality > 100 {
+		return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "编码质量需在1~100之间")
+	}
+	req.Fit = strings.ToLower(req.Fit)
+	if req.Fit == "" {
+		req.Fit = imageproc.FitContain
+	}
+	if !imageproc.IsFitMode(req.Fit) {
+		return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "不支持的缩放方式")
+	}
+	req.Format = strings.ToLower(req.Format)
+	switch req.Format {
+	case "":
+		req.Format = consts.RENDER_DEFAULT_FORMAT
+	case "jpeg":
+		req.Format = "jpg"
+	}
+	if !imageproc.IsEncodeFormat(req.Format) {
+		return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "不支持的输出格式")
+	}
+	return nil
+}
+
+// 获取原图地址，原图不添加水印，只有拥有编辑权限的成员可以获取；私有对象返回临时访问地址
+func (s *PictureRenderService) GetOriginalURL(picId uint64, loginUser *entity.User) (string, *ecode.ErrorWithCode) {
+	pic, originErr := s.PictureRepo.FindById(nil, picId)
+	if originErr != nil {
+		return "", ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	if pic == nil {
+		return "", ecode.GetErrWithDetail(ecode.NOT_FOUND_ERROR, "图片不存在")
+	}
+	space, err := s.getSpace(pic)
+	if err != nil {
+		return "", err
+	}
+	//公共图库没有水印，上传者本人也可以获取原图
+	canEdit := slices.Contains(GetPermissionList(space, loginUser), "picture:edit")
+	if !canEdit && !(space == nil && pic.UserID == loginUser.ID) {
+		return "", ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "没有权限")
+	}
+	if err := checkPictureNotArchived(pic); err != nil {
+		return "", err
+	}
+	key, originErr := s.sourceKey(pic)
+	if originErr != nil {
+		log.Println("获取原图失败，错误为", originErr)
+		return "", ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "获取原图失败")
+	}
+	originURL := manager.SignObjectURL(storage.GetStore().ObjectURL(key))
+	if originURL == "" {
+		return "", ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "签发访问地址失败")
+	}
+	return originURL, nil
+}
+
+// 获取图片所在的空间，公共图库返回nil
+func (s *PictureRenderService) getSpace(pic *entity.Picture) (*entity.Space, *ecode.ErrorWithCode) {
+	if pic.SpaceID == 0 {
+		return nil, nil
+	}
+	space, originErr := s.SpaceRepo.GetSpaceById(nil, pic.SpaceID)
+	if originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	if space == nil {
+		return nil, ecode.GetErrWithDetail(ecode.NOT_FOUND_ERROR, "空间不存在")
+	}
+	return space, nil
+}
+
+// 读取存储中已生成的渲染结果，不存在时从原图生成并写入存储，withWatermark为true时叠加空间的水印
+func (s *PictureRenderService) loadOrRenderVariant(pic *entity.Picture, space *entity.Space, withWatermark bool, req *reqPicture.PictureRenderRequest, etag string) ([]byte, error) {
+	store := storage.GetStore()
+	variantKey := fmt.Sprintf("%s/%d/%s.%s", consts.RENDER_VARIANT_PREFIX, pic.ID, etag, req.Format)
+	if r, err := store.GetObject(variantKey); err == nil {
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err == nil {
+			return data, nil
+		}
+	}
+	sourceKey, err := s.sourceKey(pic)
+	if err != nil {
+		return nil, err
+	}
+	r, err := store.GetObject(sourceKey)
+	if err != nil {
+		return nil, err
+	}
+	src, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return nil, err
+	}
+	var mark *imageproc.Watermark
+	if withWatermark {
+		watermark, err := manager.LoadWatermark(space)
+		if err != nil {
+			return nil, err
+		}
+		if watermark != nil {
+			mark = watermark.Mark
+		}
+	}
+	var buf bytes.Buffer
+	if err := s.render(&buf, pic, src, req, mark); err != nil {
+		return nil, err
+	}
+	//渲染结果只能通过本接口访问，统一以私有权限保存，写入失败不影响本次返回
+	if err := storage.PutPrivateObject(variantKey, bytes.NewReader(buf.Bytes()), imageproc.ContentType(req.Format)); err != nil {
+		log.Println("保存渲染结果失败，错误为", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// 按请求参数渲染原图，动图输出为webp时保留动画，其他情况使用第一帧；mark不为nil时在缩放后叠加水印
+func (s *PictureRenderService) render(w io.Writer, pic *entity.Picture, src []byte, req *reqPicture.PictureRenderRequest, mark *imageproc.Watermark) error {
+	applyMark := func(img image.Image) image.Image { return img }
+	if mark != nil {
+		applyMark = mark.Applier()
+	}
+	transform := func(img image.Image) image.Image {
+		return applyMark(imageproc.Transform(img, req.Width, req.Height, req.Fit))
+	}
+	if pic.IsAnimated && req.Format == "webp" {
+		anim, err := imageproc.DecodeAnimation(src)
+		if err != nil {
+			return err
+		}
+		if anim != nil {
+			return imageproc.EncodeAnimatedWebP(w, anim.MapFrames(transform))
+		}
+	}
+	//原图保留了EXIF方向，渲染前先摆正
+	img, err := imageproc.DecodeOriented(src)
+	if err != nil {
+		return err
+	}
+	return imageproc.EncodeWithQuality(w, transform(img), req.Format, req.Quality)
+}
+
+// 渲染使用的源对象，优先使用原图，旧数据没有原图记录时使用webp主图
+func (s *PictureRenderService) sourceKey(pic *entity.Picture) (string, error) {
+	if pic.ContentHash != "" {
+		content, err := s.ContentRepo.FindByHash(nil, pic.ContentHash, pic.RenditionProfile, pic.SpaceID != 0)
+		if err != nil {
+			return "", err
+		}
+		if content != nil && content.OriginKey != "" {
+			return content.OriginKey, nil
+		}
+	}
+	key, ok := storage.ObjectKey(pic.URL)
+	if !ok {
+		return "", errors.New("图片地址不属于当前存储")
+	}
+	return key, nil
+}
+
+// 由图片地址和渲染参数计算渲染结果的标识，图片更新后地址变化，标识随之变化
+// 添加水印的结果额外包含水印设置的摘要，水印设置修改后重新生成
+func (s *PictureRenderService) variantHash(pic *entity.Picture, req *reqPicture.PictureRenderRequest, watermark *entity.SpaceWatermark) string {
+	key := fmt.Sprintf("%s|%d|%d|%s|%s|%d", pic.URL, req.Width, req.Height, req.Fit, req.Format, req.Quality)
+	if watermark != nil {
+		key += "|wm-" + watermark.Fingerprint()
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
+}
+
+func (s *PictureRenderService) sign(picId uint64, req *reqPicture.PictureRenderRequest) string {
+	mac := hmac.New(sha256.New, renderSignSecret())
+	fmt.Fprintf(mac, "%d|%d|%d|%s|%s|%d|%d", picId, req.Width, req.Height, req.Fit, req.Format, req.Quality, req.Expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *PictureRenderService) verifySign(picId uint64, req *reqPicture.PictureRenderRequest) bool {
+	if time.Now().Unix() > req.Expires {
+		return false
+	}
+	return hmac.Equal([]byte(req.Sign), []byte(s.sign(picId, req)))
+}
+
+// 渲染地址的签名密钥，未配置时随机生成
+func renderSignSecret() []byte {
+	renderSecretOnce.Do(func() {
+		if cfg := config.LoadConfig().RenderConfig; cfg != nil && cfg.SignSecret != "" {
+			renderSecret = []byte(cfg.SignSecret)
+			return
+		}
+		renderSecret = make([]byte, 32)
+		_, _ = rand.Read(renderSecret)
+		log.Println("render.sign_secret 未配置，使用随机密钥")
+	})
+	return renderSecret
+}
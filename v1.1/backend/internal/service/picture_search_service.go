@@ -0,0 +1,113 @@
+package service
+
+import (
+	"backend/config"
+	"backend/internal/consts"
+	resPicture "backend/internal/model/response/picture"
+	"backend/pkg/mysql"
+	"backend/pkg/search"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 与entity中创建的全文索引一一对应，MATCH的列必须与某个全文索引的列完全相同
+const (
+	fullTextColumns      = "name, introduction, category, tags"
+	fullTextLabelColumns = "category, tags"
+)
+
+// 是否使用全文索引搜索，未配置时默认使用，索引创建失败时退化为LIKE查询
+func useFullText() bool {
+	if cfg := config.LoadConfig().SearchConfig; cfg != nil && cfg.Mode == consts.SEARCH_MODE_LIKE {
+		return false
+	}
+	return mysql.FullTextReady()
+}
+
+// 名称和分类标签的相关度权重
+func searchBoost() (nameBoost float64, labelBoost float64) {
+	nameBoost, labelBoost = consts.SEARCH_NAME_BOOST, consts.SEARCH_LABEL_BOOST
+	if cfg := config.LoadConfig().SearchConfig; cfg != nil {
+		if cfg.NameBoost > 0 {
+			nameBoost = cfg.NameBoost
+		}
+		if cfg.LabelBoost > 0 {
+			labelBoost = cfg.LabelBoost
+		}
+	}
+	return
+}
+
+// 按搜索文本过滤，名称、简介、分类和标签中需包含所有关键词
+// 长度不足ngram分词长度的关键词无法走全文索引，使用LIKE匹配
+func applySearchText(query *gorm.DB, text string) *gorm.DB {
+	terms := search.Terms(text)
+	if useFullText() {
+		indexed, short := search.Split(terms)
+		if len(indexed) > 0 {
+			query = query.Where("MATCH("+fullTextColumns+") AGAINST(? IN BOOLEAN MODE)", search.BooleanQuery(indexed, true))
+		}
+		terms = short
+	}
+	for _, t := range terms {
+		like := "%" + search.EscapeLike(t) + "%"
+		query = query.Where("name LIKE ? OR introduction LIKE ? OR category LIKE ? OR tags LIKE ?", like, like, like, like)
+	}
+	return query
+}
+
+// 按单个字段过滤，字段中需包含所有关键词，column需要有单独的全文索引
+func applyColumnSearch(query *gorm.DB, column string, text string) *gorm.DB {
+	terms := search.Terms(text)
+	if useFullText() {
+		indexed, short := search.Split(terms)
+		if len(indexed) > 0 {
+			query = query.Where("MATCH("+column+") AGAINST(? IN BOOLEAN MODE)", search.BooleanQuery(indexed, true))
+		}
+		terms = short
+	}
+	for _, t := range terms {
+		query = query.Where(column+" LIKE ?", "%"+search.EscapeLike(t)+"%")
+	}
+	return query
+}
+
+// 按相关度排序的子句，名称命中和分类标签命中会额外加权；不使用全文索引或没有可索引的关键词时返回false
+func searchRelevanceOrder(text string) (clause.OrderBy, bool) {
+	if !useFullText() {
+		return clause.OrderBy{}, false
+	}
+	indexed, _ := search.Split(search.Terms(text))
+	if len(indexed) == 0 {
+		return clause.OrderBy{}, false
+	}
+	q := search.BooleanQuery(indexed, false)
+	nameBoost, labelBoost := searchBoost()
+	return clause.OrderBy{Expression: clause.Expr{
+		SQL: "MATCH(" + fullTextColumns + ") AGAINST(? IN BOOLEAN MODE)" +
+			" + ? * MATCH(name) AGAINST(? IN BOOLEAN MODE)" +
+			" + ? * MATCH(" + fullTextLabelColumns + ") AGAINST(? IN BOOLEAN MODE) DESC, id DESC",
+		Vars:               []interface{}{q, nameBoost, q, labelBoost, q},
+		WithoutParentheses: true,
+	}}, true
+}
+
+// 为搜索结果的名称和简介生成高亮片段，命中的关键词用<em>包裹
+func highlightPictureVOList(list []resPicture.PictureVO, searchText string, name string, introduction string) {
+	textTerms := search.Terms(searchText)
+	nameTerms := append(search.Terms(name), textTerms...)
+	introTerms := append(search.Terms(introduction), textTerms...)
+	if len(nameTerms) == 0 && len(introTerms) == 0 {
+		return
+	}
+	for i := range list {
+		h := resPicture.PictureHighlight{
+			Name:         search.Highlight(list[i].Name, nameTerms),
+			Introduction: search.Highlight(list[i].Introduction, introTerms),
+		}
+		if h.Name != "" || h.Introduction != "" {
+			list[i].Highlight = &h
+		}
+	}
+}
@@ -12,10 +12,12 @@ import (
 	resPicture "backend/internal/model/response/picture"
 	resUser "backend/internal/model/response/user"
 	"backend/internal/repository"
-	"backend/internal/utils"
 	"backend/pkg/cache"
 	"backend/pkg/mysql"
 	"backend/pkg/redis"
+	"backend/pkg/scanner"
+	"backend/pkg/storage"
+	"bytes"
 	"context"
 	"crypto/md5"
 	"encoding/hex"
@@ -25,13 +27,13 @@ import (
 	"github.com/PuerkitoBio/goquery"
 	"golang.org/x/sync/singleflight"
 	"gorm.io/gorm"
+	"io"
 	"log"
 	"math"
 	"math/rand/v2"
 	"mime/multipart"
-	"net/http"
 	"net/url"
-	"sort"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -42,11 +44,19 @@ var listGroup singleflight.Group
 
 type PictureService struct {
 	PictureRepo *repository.PictureRepository
+	ContentRepo  *repository.PictureContentRepository
+	MetadataRepo *repository.PictureMetadataRepository
+	ColorRepo    *repository.PictureColorRepository
+	TagRepo      *repository.TagRepository
 }
 
 func NewPictureService() *PictureService {
 	return &PictureService{
-		PictureRepo: repository.NewPictureRepository(),
+		PictureRepo:  repository.NewPictureRepository(),
+		ContentRepo:  repository.NewPictureContentRepository(),
+		MetadataRepo: repository.NewPictureMetadataRepository(),
+		ColorRepo:    repository.NewPictureColorRepository(),
+		TagRepo:      repository.NewTagRepository(),
 	}
 }
 
@@ -59,6 +69,7 @@ func (s *PictureService) UploadPicture(picFile interface{}, PictureUploadRequest
 		picId = PictureUploadRequest.ID
 	}
 	var space *entity.Space
+	var oldPicture *entity.Picture
 	//校验空间ID是否存在
 	//若存在，则需要校验空间是否存在以及是否有权限上传
 	fmt.Println("ok")
@@ -126,6 +137,10 @@ func (s *PictureService) UploadPicture(picFile interface{}, PictureUploadRequest
 				}
 			}
 		}
+		//已归档的图片需要先恢复，再上传新的内容
+		if err := checkPictureNotArchived(oldpic); err != nil {
+			return nil, err
+		}
 		//校验空间是否一致
 		if space != nil && oldpic.SpaceID != PictureUploadRequest.SpaceID {
 			return nil, ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "空间不一致")
@@ -134,6 +149,7 @@ func (s *PictureService) UploadPicture(picFile interface{}, PictureUploadRequest
 		if space == nil {
 			PictureUploadRequest.SpaceID = oldpic.SpaceID
 		}
+		oldPicture = oldpic
 	}
 	//上传图片，得到信息
 	//去要区分上传到公共图库还是私人图库
@@ -144,57 +160,238 @@ func (s *PictureService) UploadPicture(picFile interface{}, PictureUploadRequest
 		//存在space，则上传到私人图库
 		uploadPathPrefix = fmt.Sprintf("space/%d", PictureUploadRequest.SpaceID)
 	}
+	//根据空间等级选择需要生成的衍生版本，空间未开启保留GPS时去除原图中的位置信息，写入存储前进行内容安全检查
+	opts := manager.UploadOptions{
+		Profile:  manager.RenditionProfile(space),
+		StripGPS: space == nil || !space.KeepGPS,
+		Scanner:  scanner.Get(),
+	}
+	//空间开启水印时，对外展示的主图、缩略图和衍生版本添加水印
+	watermark, originErr := manager.LoadWatermark(space)
+	if originErr != nil {
+		log.Println("加载空间水印失败，错误为", originErr)
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "加载空间水印失败")
+	}
+	opts.Watermark = watermark
 
-	var info *file.UploadPictureResult
-	var err *ecode.ErrorWithCode
 	//根据参数的不同类型，调用不同的方法。请保证传入的正确性。
-	switch v := picFile.(type) {
-	case *multipart.FileHeader:
-		info, err = manager.UploadPicture(v, uploadPathPrefix)
-	case string:
-		info, err = manager.UploadPictureByURL(v, uploadPathPrefix, PictureUploadRequest.PicName)
-	default:
-		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "参数错误")
+	upload := func(opts manager.UploadOptions) (*file.UploadPictureResult, *ecode.ErrorWithCode) {
+		switch v := picFile.(type) {
+		case *multipart.FileHeader:
+			return manager.UploadPicture(v, uploadPathPrefix, opts)
+		case string:
+			return manager.UploadPictureByURL(v, uploadPathPrefix, PictureUploadRequest.PicName, opts)
+		case *file.PictureStream:
+			//重新上传时需要从头读取
+			if opts.NoDedup {
+				seeker, ok := v.Reader.(io.Seeker)
+				if !ok {
+					return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "上传失败")
+				}
+				if _, originErr := seeker.Seek(0, io.SeekStart); originErr != nil {
+					log.Println("重新读取上传内容失败，错误为", originErr)
+					return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "上传失败")
+				}
+			}
+			return manager.UploadPictureByStream(v, uploadPathPrefix, opts)
+		case *file.PictureObject:
+			//直传的对象必须位于目标空间的路径下
+			if !strings.HasPrefix(v.Key, uploadPathPrefix+"/") {
+				return nil, ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "文件路径与空间不一致")
+			}
+			return manager.UploadPictureByObject(v.Key, v.Size, opts)
+		default:
+			return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "参数错误")
+		}
 	}
-	if err != nil {
-		return nil, err
+	//直传的原图在复用已有内容时是多余的，提交后删除
+	directKey := ""
+	if v, ok := picFile.(*file.PictureObject); ok {
+		directKey = v.Key
 	}
-	//构造插入数据库的实体
-	pic := &entity.Picture{
-		URL:          info.URL,
-		ThumbnailURL: info.ThumbnailURL,
-		Name:         info.PicName,
-		PicSize:      info.PicSize,
-		PicWidth:     info.PicWidth,
-		PicHeight:    info.PicHeight,
-		PicScale:     info.PicScale,
-		PicFormat:    info.PicFormat,
-		PicColor:     info.PicColor,
-		UserID:       loginUser.ID,
-		EditTime:     time.Now(),
-		SpaceID:      PictureUploadRequest.SpaceID, //指定空间id
-	}
-	//补充审核校验参数
-	s.FillReviewParamsInPic(pic, loginUser)
-	//若是更新，则需要更新ID
-	if picId != 0 {
-		pic.ID = picId
+
+	var info *file.UploadPictureResult
+	var pic *entity.Picture
+	var tx *gorm.DB
+	duplicated := false
+	//复用已有内容时，查找内容的查询未加锁，需要在事务中锁定并引用该内容
+	//内容在此之前被并发删除时，回滚并跳过去重重新上传，避免使用已删除的对象
+	for {
+		var err *ecode.ErrorWithCode
+		info, err = upload(opts)
+		if err != nil {
+			return nil, err
+		}
+		//构造插入数据库的实体
+		renditions := entity.FormatRenditions(info.Renditions)
+		palette := entity.FormatPalette(info.Palette)
+		pic = &entity.Picture{
+			URL:              info.URL,
+			ThumbnailURL:     info.ThumbnailURL,
+			Name:             info.PicName,
+			PicSize:          info.PicSize,
+			PicWidth:         info.PicWidth,
+			PicHeight:        info.PicHeight,
+			PicScale:         info.PicScale,
+			PicFormat:        info.PicFormat,
+			PicColor:         info.PicColor,
+			PHash:            info.PHash,
+			DHash:            info.DHash,
+			Palette:          palette,
+			FrameCount:       info.FrameCount,
+			IsAnimated:       info.FrameCount > 1,
+			ContentHash:      info.ContentHash,
+			Renditions:       renditions,
+			RenditionProfile: info.Profile,
+			UserID:           loginUser.ID,
+			EditTime:         time.Now(),
+			SpaceID:          PictureUploadRequest.SpaceID, //指定空间id
+			Version:          1,
+		}
+		//补充审核校验参数
+		s.FillReviewParamsInPic(pic, loginUser)
+		//若是更新，则需要更新ID
+		if picId != 0 {
+			pic.ID = picId
+		}
+		//开启事务
+		tx = s.PictureRepo.BeginTransaction()
+		if info.ContentHash == "" {
+			break
+		}
+		//锁定复用的内容，避免在提交前被并发释放
+		if info.Reused {
+			existing, originErr := s.ContentRepo.LockByHash(tx, info.ContentHash, info.Profile, pic.SpaceID != 0)
+			if originErr != nil {
+				tx.Rollback()
+				return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+			}
+			if existing == nil {
+				tx.Rollback()
+				opts.NoDedup = true
+				continue
+			}
+		}
+		//增加存储对象的引用计数
+		content, originErr := s.ContentRepo.Acquire(tx, &entity.PictureContent{
+			ContentHash:      info.ContentHash,
+			RenditionProfile: info.Profile,
+			Private:          pic.SpaceID != 0,
+			OriginKey:        info.OriginKey,
+			URL:              info.URL,
+			ThumbnailURL:     info.ThumbnailURL,
+			PicSize:          info.PicSize,
+			PicWidth:         info.PicWidth,
+			PicHeight:        info.PicHeight,
+			PicScale:         info.PicScale,
+			PicFormat:        info.PicFormat,
+			PicColor:         info.PicColor,
+			PHash:            info.PHash,
+			DHash:            info.DHash,
+			Palette:          palette,
+			FrameCount:       info.FrameCount,
+			Renditions:       renditions,
+		})
+		if originErr != nil {
+			tx.Rollback()
+			return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+		}
+		//复用的内容是新插入的记录，说明原记录及其对象已被删除
+		if info.Reused && content.RefCount == 1 {
+			tx.Rollback()
+			opts.NoDedup = true
+			continue
+		}
+		//并发上传了相同内容，以先入库的对象为准，刚上传的这一份在提交后删除
+		if !info.Reused && content.OriginKey != info.OriginKey {
+			duplicated = true
+			pic.URL = content.URL
+			pic.ThumbnailURL = content.ThumbnailURL
+			pic.Renditions = content.Renditions
+		}
+		break
+	}
+	//更新图片时旧内容保存为历史版本，由历史版本继续持有存储对象的引用，超出保留数量的最早版本被删除
+	var releasedContents []*entity.PictureContent
+	var prunedSize int64
+	if oldPicture != nil {
+		versionService := NewPictureVersionService()
+		if originErr := versionService.archive(tx, oldPicture); originErr != nil {
+			tx.Rollback()
+			return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+		}
+		var originErr error
+		prunedSize, releasedContents, originErr = versionService.prune(tx, oldPicture.ID)
+		if originErr != nil {
+			tx.Rollback()
+			return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+		}
+		pic.Version = max(oldPicture.Version, 1) + 1
 	}
-	//开启事务
-	tx := s.PictureRepo.BeginTransaction()
 	//进行插入或者更新操作，即save
-	originErr := s.PictureRepo.SavePicture(tx, pic)
+	originErr = s.PictureRepo.SavePicture(tx, pic)
+	if originErr != nil {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	//保存EXIF元数据，更新后的图片没有元数据时删除旧记录
+	if info.Metadata != nil {
+		info.Metadata.PictureID = pic.ID
+		originErr = s.MetadataRepo.Save(tx, info.Metadata)
+	} else if oldPicture != nil {
+		originErr = s.MetadataRepo.DeleteByPictureId(tx, pic.ID)
+	}
 	if originErr != nil {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	//重新上传的图片不保留标签，同步删除标签关联
+	if oldPicture != nil {
+		if originErr = s.TagRepo.DeleteByPictureId(tx, pic.ID); originErr != nil {
+			tx.Rollback()
+			return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+		}
+	}
+	//更新调色板的颜色索引
+	if originErr = s.ColorRepo.ReplaceByPicture(tx, pic.ID, pictureColorRows(pic.ID, pic.SpaceID, info.Palette)); originErr != nil {
+		tx.Rollback()
 		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
 	}
-	//修改空间的额度
+	//修改空间的额度，即使内容被复用，每个空间也按图片大小计费
 	if space != nil {
+		//锁定空间后再次校验额度，避免并发上传超出额度；超出时删除刚上传的对象
+		locked, originErr := NewSpaceService().SpaceRepo.LockSpaceById(tx, space.ID)
+		if originErr != nil || locked == nil {
+			tx.Rollback()
+			return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+		}
+		var quotaErr *ecode.ErrorWithCode
+		if oldPicture == nil && locked.TotalCount+1 > locked.MaxCount {
+			quotaErr = ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "空间图片数量已满")
+		} else if locked.TotalSize+pic.PicSize-prunedSize > locked.MaxSize {
+			quotaErr = ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "空间图片大小已满")
+		}
+		if quotaErr != nil {
+			tx.Rollback()
+			if !info.Reused {
+				manager.DeletePictureObjects(info.OriginKey, info.URL, info.ThumbnailURL, info.Renditions)
+			} else {
+				deleteReusedDirectObject(directKey, info)
+			}
+			return nil, quotaErr
+		}
 		//设置更新字段
 		updateMap := make(map[string]interface{}, 2)
-		updateMap["total_count"] = gorm.Expr("total_count + 1")
-		updateMap["total_size"] = gorm.Expr("total_size + ?", pic.PicSize)
+		if oldPicture != nil {
+			//更新图片不改变数量，旧内容作为历史版本继续计入大小，减去被删除的历史版本
+			updateMap["total_size"] = gorm.Expr("total_size + ?", pic.PicSize-prunedSize)
+		} else {
+			updateMap["total_count"] = gorm.Expr("total_count + 1")
+			updateMap["total_size"] = gorm.Expr("total_size + ?", pic.PicSize)
+		}
 		err := NewSpaceService().SpaceRepo.UpdateSpaceById(tx, space.ID, updateMap)
 		if err != nil {
+			tx.Rollback()
 			return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
 		}
 	}
@@ -203,11 +400,31 @@ func (s *PictureService) UploadPicture(picFile interface{}, PictureUploadRequest
 	if originErr != nil {
 		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
 	}
+	//事务提交后再删除对象，避免回滚后对象丢失
+	if duplicated {
+		manager.DeletePictureObjects(info.OriginKey, info.URL, info.ThumbnailURL, info.Renditions)
+	} else if info.Reused {
+		deleteReusedDirectObject(directKey, info)
+	}
+	deleteReleasedContents(releasedContents)
+	invalidateSimilarIndex(pic.SpaceID)
 	userVO := resUser.GetUserVO(*loginUser)
 	picVO := resPicture.EntityToVO(*pic, userVO)
+	picVO.Metadata = info.Metadata
+	picVO = s.SignPictureVO(picVO, GetPermissionList(space, loginUser))
 	return &picVO, nil
 }
 
+// 直传的原图复用了已有内容时，删除多余的这一份
+func deleteReusedDirectObject(directKey string, info *file.UploadPictureResult) {
+	if directKey == "" || directKey == info.OriginKey {
+		return
+	}
+	if err := storage.GetStore().DeleteObject(directKey); err != nil {
+		log.Println("删除直传的重复原图失败，错误为", err)
+	}
+}
+
 // 填充审核参数到指定的Pic中
 func (s *PictureService) FillReviewParamsInPic(Pic *entity.Picture, LoginUser *entity.User) {
 	if LoginUser.UserRole == consts.ADMIN_ROLE {
@@ -244,22 +461,27 @@ func (s *PictureService) DeletePicture(loginUser *entity.User, deleReq *common.D
 	//进行删除图片操作
 	originErr = s.PictureRepo.DeleteById(tx, deleReq.Id)
 	if originErr != nil {
+		tx.Rollback()
 		return ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
 	}
-	//修改空间的额度
+	//修改空间的额度，图片移入回收站时即释放额度，历史版本保留到彻底删除，其大小同样在此时释放
 	if space != nil {
+		versionSize, originErr := repository.NewPictureVersionRepository().SumSizeByPictureId(tx, oldPic.ID)
+		if originErr != nil {
+			tx.Rollback()
+			return ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+		}
 		//设置更新字段
 		updateMap := make(map[string]interface{}, 2)
 		updateMap["total_count"] = gorm.Expr("total_count - 1")
-		updateMap["total_size"] = gorm.Expr("total_size - ?", oldPic.PicSize)
-		err := NewSpaceService().SpaceRepo.UpdateSpaceById(tx, space.ID, updateMap)
-		if err != nil {
+		updateMap["total_size"] = gorm.Expr("total_size - ?", oldPic.PicSize+versionSize)
+		if originErr := NewSpaceService().SpaceRepo.UpdateSpaceById(tx, space.ID, updateMap); originErr != nil {
+			tx.Rollback()
 			return ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
 		}
 	}
 	//提交事务
-	originErr = tx.Commit().Error
-	if err != nil {
+	if originErr = tx.Commit().Error; originErr != nil {
 		return ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
 	}
 	return nil
@@ -317,8 +539,45 @@ func (s *PictureService) GetPictureVO(Picture *entity.Picture) *resPicture.Pictu
 	} else {
 		picVO = resPicture.EntityToVO(*Picture, resUser.UserVO{})
 	}
+	metadata, err := s.MetadataRepo.FindByPictureIds(nil, []uint64{Picture.ID})
+	if err == nil {
+		picVO.Metadata = metadata[Picture.ID]
+	}
 	return &picVO
 }
+
+// 为私有空间和团队空间的图片签发临时访问地址，permissionList需由GetPermissionList获取
+// 没有查看权限时不返回图片地址
+func (s *PictureService) SignPictureVO(picVO resPicture.PictureVO, permissionList []string) resPicture.PictureVO {
+	if picVO.SpaceID == 0 {
+		return picVO
+	}
+	canView := slices.Contains(permissionList, "picture:view")
+	sign := func(objectURL string) string {
+		if !canView {
+			return ""
+		}
+		return manager.SignObjectURL(objectURL)
+	}
+	picVO.URL = sign(picVO.URL)
+	picVO.ThumbnailURL = sign(picVO.ThumbnailURL)
+	renditions := make([]entity.PictureRendition, len(picVO.Renditions))
+	for i, r := range picVO.Renditions {
+		r.URL = sign(r.URL)
+		renditions[i] = r
+	}
+	picVO.Renditions = renditions
+	return picVO
+}
+
+// 批量签发临时访问地址，返回新的列表，不修改传入的数据（可能来自缓存）
+func (s *PictureService) SignPictureVOList(picVOs []resPicture.PictureVO, permissionList []string) []resPicture.PictureVO {
+	signed := make([]resPicture.PictureVO, len(picVOs))
+	for i, picVO := range picVOs {
+		signed[i] = s.SignPictureVO(picVO, permissionList)
+	}
+	return signed
+}
 func (s *PictureService) ListPictureByPage(req *reqPicture.PictureQueryRequest) (*resPicture.ListPictureResponse, *ecode.ErrorWithCode) {
 	// 参数校验与默认值
 	if req.Current <= 0 {
@@ -344,6 +603,13 @@ func (s *PictureService) ListPictureByPage(req *reqPicture.PictureQueryRequest)
 	offset := (req.Current - 1) * req.PageSize
 	totalPages := int(math.Ceil(float64(total) / float64(req.PageSize)))
 
+	// 有搜索文本且未指定排序字段时，按相关度排序
+	if req.SortField == "" && req.SearchText != "" {
+		if order, ok := searchRelevanceOrder(req.SearchText); ok {
+			query = query.Order(order)
+		}
+	}
+
 	// 分页查询
 	var Pictures []entity.Picture
 	if err := query.Offset(offset).Limit(req.PageSize).Find(&Pictures).Error; err != nil {
@@ -365,8 +631,9 @@ func (s *PictureService) ListPictureByPage(req *reqPicture.PictureQueryRequest)
 // 获取一个链式查询对象
 func (s *PictureService) GetQueryWrapper(db *gorm.DB, req *reqPicture.PictureQueryRequest) (*gorm.DB, *ecode.ErrorWithCode) {
 	query := db.Session(&gorm.Session{})
+	//搜索文本、名称和简介优先使用全文索引
 	if req.SearchText != "" {
-		query = query.Where("name LIKE ? OR introduction LIKE ?", "%"+req.SearchText+"%", "%"+req.SearchText+"%")
+		query = applySearchText(query, req.SearchText)
 	}
 	if req.ID != 0 {
 		query = query.Where("id = ?", req.ID)
@@ -375,10 +642,10 @@ func (s *PictureService) GetQueryWrapper(db *gorm.DB, req *reqPicture.PictureQue
 		query = query.Where("user_id = ?", req.UserID)
 	}
 	if req.Name != "" {
-		query = query.Where("name LIKE ?", "%"+req.Name+"%")
+		query = applyColumnSearch(query, "name", req.Name)
 	}
 	if req.Introduction != "" {
-		query = query.Where("introduction LIKE ?", "%"+req.Introduction+"%")
+		query = applyColumnSearch(query, "introduction", req.Introduction)
 	}
 	if req.PicFormat != "" {
 		query = query.Where("pic_format LIKE ?", "%"+req.PicFormat+"%")
@@ -398,6 +665,9 @@ func (s *PictureService) GetQueryWrapper(db *gorm.DB, req *reqPicture.PictureQue
 	if req.PicScale != 0 {
 		query = query.Where("pic_scale = ?", req.PicScale)
 	}
+	if req.IsAnimated != nil {
+		query = query.Where("is_animated = ?", *req.IsAnimated)
+	}
 	//补充审核字段条件
 	if req.ReviewStatus != nil {
 		query = query.Where("review_status = ?", *req.ReviewStatus)
@@ -421,12 +691,26 @@ func (s *PictureService) GetQueryWrapper(db *gorm.DB, req *reqPicture.PictureQue
 	if !req.EndEditTime.IsZero() {
 		query = query.Where("edit_time < ?", req.EndEditTime)
 	}
-	//tags在数据库中的存储格式：["golang","java","c++"]
-	if len(req.Tags) > 0 {
-		//and (tags LIKE %"commic" and tags LIKE %"manga"% ...)
-		for _, tag := range req.Tags {
-			query = query.Where("tags LIKE ?", "%\""+tag+"\"%")
+	//按EXIF元数据筛选
+	if metadataQuery := s.getMetadataQuery(db, req); metadataQuery != nil {
+		query = query.Where("id IN (?)", metadataQuery)
+	}
+	if req.HasGPS != nil {
+		gpsQuery := db.Session(&gorm.Session{NewDB: true}).Model(&entity.PictureMetadata{}).
+			Select("picture_id").Where("latitude IS NOT NULL")
+		if *req.HasGPS {
+			query = query.Where("id IN (?)", gpsQuery)
+		} else {
+			query = query.Where("id NOT IN (?)", gpsQuery)
+		}
+	}
+	//按标签关联筛选，标签名称不区分大小写，不同空间的同名标签都会匹配
+	if tags := normalizeTags(req.Tags); len(tags) > 0 {
+		tagQuery, err := s.getTagQuery(db, tags, req.TagMode)
+		if err != nil {
+			return nil, err
 		}
+		query = query.Where("id IN (?)", tagQuery)
 	}
 	if req.SortField != "" {
 		sortOrder := "ASC"
@@ -438,6 +722,50 @@ func (s *PictureService) GetQueryWrapper(db *gorm.DB, req *reqPicture.PictureQue
 	return query, nil
 }
 
+// 构造按标签筛选图片ID的子查询，mode为any时匹配任一标签，为all或空时匹配全部标签
+func (s *PictureService) getTagQuery(db *gorm.DB, tags []string, mode string) (*gorm.DB, *ecode.ErrorWithCode) {
+	if len(tags) > consts.TAG_MAX_COUNT {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, fmt.Sprintf("标签不能超过%d个", consts.TAG_MAX_COUNT))
+	}
+	tagIds := db.Session(&gorm.Session{NewDB: true}).Model(&entity.Tag{}).Select("id").Where("name IN ?", tags)
+	sub := db.Session(&gorm.Session{NewDB: true}).Model(&entity.PictureTag{}).Select("picture_id").Where("tag_id IN (?)", tagIds)
+	switch mode {
+	case consts.TAG_MATCH_ANY:
+		return sub, nil
+	case consts.TAG_MATCH_ALL, "":
+		//同一空间中名称不区分大小写唯一，每张图片的每个筛选标签最多对应一条关联
+		return sub.Group("picture_id").Having("COUNT(*) = ?", len(tags)), nil
+	default:
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "标签匹配方式错误")
+	}
+}
+
+// 根据相机、镜头和拍摄时间构造元数据子查询，没有相关条件时返回nil
+func (s *PictureService) getMetadataQuery(db *gorm.DB, req *reqPicture.PictureQueryRequest) *gorm.DB {
+	if req.CameraMake == "" && req.CameraModel == "" && req.LensModel == "" &&
+		req.StartTakenAt.IsZero() && req.EndTakenAt.IsZero() {
+		return nil
+	}
+	sub := db.Session(&gorm.Session{NewDB: true}).Model(&entity.PictureMetadata{}).Select("picture_id")
+	if req.CameraMake != "" {
+		sub = sub.Where("make LIKE ?", "%"+req.CameraMake+"%")
+	}
+	if req.CameraModel != "" {
+		sub = sub.Where("model LIKE ?", "%"+req.CameraModel+"%")
+	}
+	if req.LensModel != "" {
+		sub = sub.Where("lens_model LIKE ?", "%"+req.LensModel+"%")
+	}
+	//StartTakenAt<=拍摄时间<EndTakenAt
+	if !req.StartTakenAt.IsZero() {
+		sub = sub.Where("taken_at >= ?", req.StartTakenAt)
+	}
+	if !req.EndTakenAt.IsZero() {
+		sub = sub.Where("taken_at < ?", req.EndTakenAt)
+	}
+	return sub
+}
+
 // 分页查询图片视图
 func (s *PictureService) ListPictureVOByPage(req *reqPicture.PictureQueryRequest) (*resPicture.ListPictureVOResponse, *ecode.ErrorWithCode) {
 	//调用PictureList
@@ -450,6 +778,7 @@ func (s *PictureService) ListPictureVOByPage(req *reqPicture.PictureQueryRequest
 		PageResponse: list.PageResponse,
 		Records:      s.GetPictureVOList(list.Records),
 	}
+	highlightPictureVOList(listVO.Records, req.SearchText, req.Name, req.Introduction)
 	return listVO, nil
 }
 
@@ -489,6 +818,16 @@ func (s *PictureService) GetPictureVOList(Pictures []entity.Picture) []resPictur
 		}
 	}
 
+	// 批量查询图片的EXIF元数据，查询失败时不返回元数据
+	picIds := make([]uint64, 0, len(Pictures))
+	for _, v := range Pictures {
+		picIds = append(picIds, v.ID)
+	}
+	metadataMap, err := s.MetadataRepo.FindByPictureIds(nil, picIds)
+	if err != nil {
+		metadataMap = map[uint64]*entity.PictureMetadata{}
+	}
+
 	// 转换所有图片为VO对象
 	for _, v := range Pictures {
 		// 确保用户信息存在
@@ -497,7 +836,9 @@ func (s *PictureService) GetPictureVOList(Pictures []entity.Picture) []resPictur
 			userVO = defaultUserVO
 		}
 
-		picVOList = append(picVOList, resPicture.EntityToVO(v, userVO))
+		picVO := resPicture.EntityToVO(v, userVO)
+		picVO.Metadata = metadataMap[v.ID]
+		picVOList = append(picVOList, picVO)
 	}
 
 	return picVOList
@@ -742,18 +1083,30 @@ func (s *PictureService) UpdatePicture(updateReq *reqPicture.PictureUpdateReques
 	if err := s.ValidPicture(oldPic); err != nil {
 		return err
 	}
+	tags := normalizeTags(updateReq.Tags)
+	if err := validateTags(tags); err != nil {
+		return err
+	}
 	//保留更新字段
 	updateMap := make(map[string]interface{}, 8)
 	updateMap["name"] = oldPic.Name
 	updateMap["introduction"] = oldPic.Introduction
 	updateMap["category"] = oldPic.Category
-	tags, _ := json.Marshal(updateReq.Tags)
-	updateMap["tags"] = string(tags)
+	updateMap["tags"] = formatTags(tags)
 	updateMap["edit_time"] = time.Now()
 	//填充审核参数
 	s.FillReviewParamsInMap(oldPic, loginUser, updateMap)
-	//更新
-	if err := s.PictureRepo.UpdateById(nil, updateReq.ID, updateMap); err != nil {
+	//更新，同一事务中更新标签关联
+	tx := s.PictureRepo.BeginTransaction()
+	if err := s.PictureRepo.UpdateById(tx, updateReq.ID, updateMap); err != nil {
+		tx.Rollback()
+		return ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	if err := NewTagService().setPictureTags(tx, []uint64{oldPic.ID}, oldPic.SpaceID, tags); err != nil {
+		tx.Rollback()
+		return ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	if err := tx.Commit().Error; err != nil {
 		return ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
 	}
 	return nil
@@ -821,60 +1174,6 @@ func (s *PictureService) DoPictureReview(req *reqPicture.PictureReviewRequest, u
 	}
 	return nil
 }
-func (s *PictureService) SearchPictureByColor(loginUser *entity.User, picColor string, spaceId uint64) ([]resPicture.PictureVO, *ecode.ErrorWithCode) {
-	//1.参数校验
-	if spaceId <= 0 || picColor == "" {
-		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "参数错误")
-	}
-	if loginUser == nil {
-		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "用户未登录")
-	}
-
-	//获取空间
-	space, err := repository.NewSpaceRepository().GetSpaceById(nil, spaceId)
-	if err != nil {
-		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库异常")
-	}
-	if space == nil {
-		return nil, ecode.GetErrWithDetail(ecode.NOT_FOUND_ERROR, "空间不存在")
-	}
-	//空间权限校验
-	if err := s.CheckPictureAuth(loginUser, nil, space); err != nil {
-		return nil, err
-	}
-	//3.查询该空间下的所有图片，必须拥有主色调
-	//构造一个查询请求，调用QueryWrapper
-	queryRequest := &reqPicture.PictureQueryRequest{
-		SpaceID: spaceId,
-	}
-	query, _ := s.GetQueryWrapper(mysql.LoadDB(), queryRequest)
-	//添加条件，查询所有拥有主色调的图片
-	query = query.Where("pic_color IS NOT NULL AND pic_color != ''")
-	//执行查询
-	var pictures []entity.Picture
-	err = query.Find(&pictures).Error
-	if err != nil {
-		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库异常")
-	}
-	if len(pictures) == 0 {
-		return nil, nil //若无图片，返回空列表
-	}
-	//4.计算相似度并且排序
-	sort.Slice(pictures, func(i, j int) bool {
-		//计算相似度，使用utils包中的函数
-		similarityI := utils.ColorSimilarity(pictures[i].PicColor, picColor)
-		similarityJ := utils.ColorSimilarity(pictures[j].PicColor, picColor)
-		return similarityI > similarityJ // 降序排列
-	})
-	//5.返回结果
-	//因为不需要用户信息，所以调用响应里自带的方法，减少用户的查询
-	var picVOList []resPicture.PictureVO
-	for _, picture := range pictures {
-		picVOList = append(picVOList, resPicture.EntityToVO(picture, resUser.UserVO{}))
-	}
-	return picVOList, nil
-}
-
 func (s *PictureService) PictureEditByBatch(req *reqPicture.PictureEditByBatchRequest, loginUser *entity.User) (bool, *ecode.ErrorWithCode) {
 	//1.参数校验
 	if loginUser == nil {
@@ -909,13 +1208,25 @@ func (s *PictureService) PictureEditByBatch(req *reqPicture.PictureEditByBatchRe
 	//填充名称字段
 	s.fillPictureNameWithRule(picList, req.NameRule)
 	//设置更新字段
-	tags, originErr := json.Marshal(&req.Tags)
-	if originErr != nil {
-		return false, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "标签参数错误")
+	tags := normalizeTags(req.Tags)
+	if err := validateTags(tags); err != nil {
+		return false, err
 	}
-	//批量更新
-	originErr = s.PictureRepo.UpdatePicturesByBatch(nil, picList, string(tags), req.Category)
-	if originErr != nil {
+	picIds := make([]uint64, 0, len(picList))
+	for _, pic := range picList {
+		picIds = append(picIds, pic.ID)
+	}
+	//批量更新，同一事务中更新标签关联
+	tx := s.PictureRepo.BeginTransaction()
+	if originErr := s.PictureRepo.UpdatePicturesByBatch(tx, picList, formatTags(tags), req.Category); originErr != nil {
+		tx.Rollback()
+		return false, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库批量更新图片异常")
+	}
+	if originErr := NewTagService().setPictureTags(tx, picIds, req.SpaceID, tags); originErr != nil {
+		tx.Rollback()
+		return false, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库批量更新图片异常")
+	}
+	if originErr := tx.Commit().Error; originErr != nil {
 		return false, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库批量更新图片异常")
 	}
 	return true, nil
@@ -945,9 +1256,12 @@ func (s *PictureService) CreatePictureOutPaintingTask(req *reqPicture.CreateOutP
 	if err != nil {
 		return nil, err
 	}
+	if err := checkPictureNotArchived(pic); err != nil {
+		return nil, err
+	}
 	//3.创建任务
-	//将前端请求转化为阿里云API请求
-	createOutPaintReq := req.ToAliAiRequest(pic.URL)
+	//将前端请求转化为阿里云API请求，私有图片使用临时访问地址
+	createOutPaintReq := req.ToAliAiRequest(manager.SignObjectURL(pic.URL))
 	//发送任务
 	res, err := aliFetcher.CreateOutPaintingTask(createOutPaintReq)
 	if err != nil {
@@ -996,18 +1310,15 @@ func (s *PictureService) UploadPictureByBatch(req *reqPicture.PictureUploadByBat
 		encodedSearchText, randInt)
 
 	// 3. 发送HTTP请求 - 基础网络通信
-	// 为什么使用标准库http.Get：简单场景下的最佳选择
-	res, err := http.Get(fetchUrl)
-	if err != nil {
-		// 为什么返回系统错误：区分网络错误和业务错误
-		return 0, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "网络请求失败")
+	// 为什么使用manager.FetchPage：统一带超时和大小限制，避免结果页异常时拖垮服务
+	body, fetchErr := manager.FetchPage(fetchUrl)
+	if fetchErr != nil {
+		return 0, fetchErr
 	}
-	// 为什么需要defer关闭：确保网络资源正确释放，防止内存泄漏
-	defer res.Body.Close()
 
 	// 4. 解析HTML内容 - 从HTML中提取结构化数据
 	// 为什么选择goquery：提供jQuery风格的API，简化HTML解析
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
 		// 为什么记录详细错误：便于生产环境问题排查
 		log.Println("解析失败，错误为", err)
@@ -1041,7 +1352,7 @@ func (s *PictureService) UploadPictureByBatch(req *reqPicture.PictureUploadByBat
 			fileUrl = fileUrl[:idx]
 		}
 
-		// 8. 上传单张图片 - 复用现有服务
+		// 8. 上传单张图片 - 复用现有服务，图片地址来自第三方页面，同样经过安全抓取校验
 		// 为什么构建独立请求：符合服务接口契约，确保参数一致性
 		uploadReq := &reqPicture.PictureUploadRequest{
 			FileUrl: fileUrl,        // 原始图片URL
@@ -0,0 +1,277 @@
+package service
+
+import (
+	imgSearchModel "backend/internal/api/imagesearch/model"
+	"backend/internal/consts"
+	"backend/internal/ecode"
+	"backend/internal/model/entity"
+	resPicture "backend/internal/model/response/picture"
+	"backend/internal/repository"
+	"backend/pkg/bktree"
+	"backend/pkg/imageproc"
+	"backend/pkg/storage"
+	"context"
+	"errors"
+	"image"
+	"log"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+type PictureSimilarService struct {
+	PictureRepo *repository.PictureRepository
+}
+
+func NewPictureSimilarService() *PictureSimilarService {
+	return &PictureSimilarService{
+		PictureRepo: repository.NewPictureRepository(),
+	}
+}
+
+// 一个空间（或公共图库）的相似图片索引，以pHash构建BK树，dHash用于距离相同时的二次排序
+type similarIndex struct {
+	tree    *bktree.Tree
+	dHashes map[uint64]uint64
+	builtAt time.Time
+}
+
+// 按空间缓存的索引，0为公共图库；索引过期或本实例有新图片上传时重新构建
+var similarIndexes = struct {
+	sync.Mutex
+	m map[uint64]*similarIndex
+}{m: make(map[uint64]*similarIndex)}
+
+// 使空间的相似图片索引失效，下次搜索时重新构建
+func invalidateSimilarIndex(spaceId uint64) {
+	similarIndexes.Lock()
+	delete(similarIndexes.m, spaceId)
+	similarIndexes.Unlock()
+}
+
+// 后台协程，为没有感知哈希的历史图片补算哈希，启动时执行一次
+func PictureHashBackfillService() {
+	count, err := NewPictureSimilarService().BackfillHashes()
+	if err != nil {
+		log.Println("补算图片感知哈希失败，错误为", err)
+		return
+	}
+	if count > 0 {
+		log.Printf("补算图片感知哈希完成，共 %d 张", count)
+	}
+}
+
+// 作为以图搜图服务注册时的名称
+func (s *PictureSimilarService) Name() string {
+	return consts.IMAGE_SEARCH_LOCAL
+}
+
+// 以图搜图服务接口，返回本站的相似图片，来源地址为图片地址
+func (s *PictureSimilarService) Search(ctx context.Context, query *imgSearchModel.SearchQuery) ([]imgSearchModel.ImageSearchResult, *ecode.ErrorWithCode) {
+	list, err := s.SearchSimilarPictures(query.PictureID, query.MaxDistance, query.Limit, query.User)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]imgSearchModel.ImageSearchResult, 0, len(list))
+	for _, vo := range list {
+		results = append(results, imgSearchModel.ImageSearchResult{
+			ThumbURL:   vo.ThumbnailURL,
+			FromURL:    vo.URL,
+			Title:      vo.Name,
+			Source:     s.Name(),
+			PictureID:  vo.ID,
+			Similarity: vo.Similarity,
+		})
+	}
+	return results, nil
+}
+
+// 按相似度搜索图片所在空间或公共图库中的其他图片，需要对原图有查看权限
+// maxDistance为nil时使用默认值，为0时只返回哈希完全相同的图片；limit为0时使用默认值
+func (s *PictureSimilarService) SearchSimilarPictures(pictureId uint64, maxDistancePtr *int, limit int, loginUser *entity.User) ([]resPicture.SimilarPictureVO, *ecode.ErrorWithCode) {
+	maxDistance := consts.SIMILAR_DEFAULT_DISTANCE
+	if maxDistancePtr != nil {
+		maxDistance = *maxDistancePtr
+	}
+	if maxDistance < 0 || maxDistance > consts.SIMILAR_MAX_DISTANCE {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "最大距离超出范围")
+	}
+	if limit < 0 || limit > consts.SIMILAR_MAX_LIMIT {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "返回数量超出范围")
+	}
+	if limit == 0 {
+		limit = consts.SIMILAR_DEFAULT_LIMIT
+	}
+	pic, originErr := s.PictureRepo.FindById(nil, pictureId)
+	if originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	if pic == nil {
+		return nil, ecode.GetErrWithDetail(ecode.NOT_FOUND_ERROR, "图片不存在")
+	}
+	var space *entity.Space
+	if pic.SpaceID != 0 {
+		var err *ecode.ErrorWithCode
+		space, err = NewSpaceService().GetSpaceById(pic.SpaceID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	permissionList := GetPermissionList(space, loginUser)
+	if space != nil && !slices.Contains(permissionList, "picture:view") {
+		return nil, ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "没有权限")
+	}
+	pHash, ok := imageproc.ParseHash(pic.PHash)
+	if !ok {
+		return nil, ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "图片尚未生成特征，请稍后再试")
+	}
+	dHash, _ := imageproc.ParseHash(pic.DHash)
+
+	idx, originErr := s.getIndex(pic.SpaceID)
+	if originErr != nil {
+		log.Print(originErr)
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	matches := idx.tree.Search(pHash, maxDistance)
+	matches = slices.DeleteFunc(matches, func(m bktree.Match) bool { return m.ID == pic.ID })
+	// pHash距离相同时按dHash距离排序
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Distance != matches[j].Distance {
+			return matches[i].Distance < matches[j].Distance
+		}
+		return imageproc.HashDistance(idx.dHashes[matches[i].ID], dHash) < imageproc.HashDistance(idx.dHashes[matches[j].ID], dHash)
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	ids := make([]uint64, 0, len(matches))
+	for _, m := range matches {
+		ids = append(ids, m.ID)
+	}
+	// 索引可能包含已删除的图片，以数据库中实际存在的为准
+	pics, originErr := s.PictureRepo.FindByIds(nil, ids)
+	if originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	picMap := make(map[uint64]entity.Picture, len(pics))
+	for _, p := range pics {
+		picMap[p.ID] = p
+	}
+	ordered := make([]entity.Picture, 0, len(pics))
+	distances := make([]int, 0, len(pics))
+	for _, m := range matches {
+		if p, ok := picMap[m.ID]; ok {
+			ordered = append(ordered, p)
+			distances = append(distances, m.Distance)
+		}
+	}
+	picService := NewPictureService()
+	voList := picService.GetPictureVOList(ordered)
+	result := make([]resPicture.SimilarPictureVO, 0, len(voList))
+	for i, vo := range voList {
+		vo.PermissionList = permissionList
+		result = append(result, resPicture.SimilarPictureVO{
+			PictureVO:  picService.SignPictureVO(vo, permissionList),
+			Distance:   distances[i],
+			Similarity: math.Round((1-float64(distances[i])/64)*100) / 100,
+		})
+	}
+	return result, nil
+}
+
+// 获取空间的相似图片索引，不存在或已过期时重新构建
+func (s *PictureSimilarService) getIndex(spaceId uint64) (*similarIndex, error) {
+	similarIndexes.Lock()
+	idx, ok := similarIndexes.m[spaceId]
+	similarIndexes.Unlock()
+	if ok && time.Since(idx.builtAt) < consts.SIMILAR_INDEX_TTL {
+		return idx, nil
+	}
+	// 公共图库只搜索审核通过的图片
+	var reviewStatus *int
+	if spaceId == 0 {
+		status := consts.PASS
+		reviewStatus = &status
+	}
+	pics, err := s.PictureRepo.ListPerceptualHashes(nil, spaceId, reviewStatus)
+	if err != nil {
+		return nil, err
+	}
+	idx = &similarIndex{
+		tree:    bktree.New(),
+		dHashes: make(map[uint64]uint64, len(pics)),
+		builtAt: time.Now(),
+	}
+	for _, p := range pics {
+		pHash, ok := imageproc.ParseHash(p.PHash)
+		if !ok {
+			continue
+		}
+		idx.tree.Add(pHash, p.ID)
+		idx.dHashes[p.ID], _ = imageproc.ParseHash(p.DHash)
+	}
+	similarIndexes.Lock()
+	similarIndexes.m[spaceId] = idx
+	similarIndexes.Unlock()
+	return idx, nil
+}
+
+// 为没有感知哈希的图片补算哈希，基于缩略图计算（与上传时一致），返回成功补算的数量
+// 单张图片失败时跳过，下次启动时重试
+func (s *PictureSimilarService) BackfillHashes() (int, error) {
+	var lastId uint64
+	count := 0
+	for {
+		pics, err := s.PictureRepo.ListMissingPerceptualHash(nil, lastId, consts.SIMILAR_BACKFILL_BATCH)
+		if err != nil {
+			return count, err
+		}
+		if len(pics) == 0 {
+			return count, nil
+		}
+		for _, pic := range pics {
+			lastId = pic.ID
+			pHash, dHash, err := computePictureHash(&pic)
+			if err != nil {
+				log.Printf("计算图片 %d 的感知哈希失败: %v", pic.ID, err)
+				continue
+			}
+			if err := s.PictureRepo.UpdatePerceptualHash(nil, pic.ID, pHash, dHash); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+}
+
+// 读取缩略图计算哈希
+func computePictureHash(pic *entity.Picture) (string, string, error) {
+	img, err := loadPictureThumbnail(pic)
+	if err != nil {
+		return "", "", err
+	}
+	return imageproc.FormatHash(imageproc.PHash(img)), imageproc.FormatHash(imageproc.DHash(img)), nil
+}
+
+// 读取并解码图片的缩略图，缩放到与上传时相同的尺寸，没有缩略图时使用主图
+func loadPictureThumbnail(pic *entity.Picture) (image.Image, error) {
+	key, ok := storage.ObjectKey(pic.ThumbnailURL)
+	if !ok {
+		key, ok = storage.ObjectKey(pic.URL)
+	}
+	if !ok {
+		return nil, errors.New("无法解析图片的存储路径")
+	}
+	r, err := storage.GetStore().GetObject(key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	img, err := imageproc.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	return imageproc.Resize(img, imageproc.ThumbnailSize, imageproc.ThumbnailSize), nil
+}
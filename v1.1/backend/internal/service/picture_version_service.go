@@ -0,0 +1,310 @@
+package service
+
+import (
+	"backend/config"
+	"backend/internal/consts"
+	"backend/internal/ecode"
+	"backend/internal/manager"
+	"backend/internal/model/entity"
+	reqPicture "backend/internal/model/request/picture"
+	resPicture "backend/internal/model/response/picture"
+	resUser "backend/internal/model/response/user"
+	"backend/internal/repository"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 图片历史版本：重新上传图片时，被替换的内容保存为历史版本，可以查看和恢复
+// 历史版本持有存储对象的引用，体积计入空间额度；超过保留数量时删除最早的版本并释放引用
+
+type PictureVersionService struct {
+	PictureRepo  *repository.PictureRepository
+	VersionRepo  *repository.PictureVersionRepository
+	ContentRepo  *repository.PictureContentRepository
+	MetadataRepo *repository.PictureMetadataRepository
+	ColorRepo    *repository.PictureColorRepository
+}
+
+func NewPictureVersionService() *PictureVersionService {
+	return &PictureVersionService{
+		PictureRepo:  repository.NewPictureRepository(),
+		VersionRepo:  repository.NewPictureVersionRepository(),
+		ContentRepo:  repository.NewPictureContentRepository(),
+		MetadataRepo: repository.NewPictureMetadataRepository(),
+		ColorRepo:    repository.NewPictureColorRepository(),
+	}
+}
+
+// 每张图片最多保留的历史版本数量
+func (s *PictureVersionService) MaxVersions() int {
+	cfg := config.LoadConfig().VersionConfig
+	if cfg == nil || cfg.MaxVersions == 0 {
+		return consts.PICTURE_MAX_VERSIONS
+	}
+	return max(cfg.MaxVersions, 0)
+}
+
+// 获取图片的历史版本列表，需要有修改图片的权限
+func (s *PictureVersionService) ListPictureVersions(picId uint64, loginUser *entity.User) (*resPicture.ListPictureVersionResponse, *ecode.ErrorWithCode) {
+	pic, space, err := s.getPictureWithAuth(picId, loginUser)
+	if err != nil {
+		return nil, err
+	}
+	versions, originErr := s.VersionRepo.ListByPictureId(nil, pic.ID)
+	if originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	permissionList := GetPermissionList(space, loginUser)
+	records := make([]resPicture.PictureVersionVO, 0, len(versions))
+	for _, v := range versions {
+		records = append(records, s.signVersionVO(resPicture.VersionToVO(v), permissionList))
+	}
+	return &resPicture.ListPictureVersionResponse{
+		CurrentVersion: max(pic.Version, 1),
+		MaxVersions:    s.MaxVersions(),
+		Records:        records,
+	}, nil
+}
+
+// 获取单个历史版本，需要有修改图片的权限
+func (s *PictureVersionService) GetPictureVersion(id uint64, loginUser *entity.User) (*resPicture.PictureVersionVO, *ecode.ErrorWithCode) {
+	version, err := s.getVersion(id)
+	if err != nil {
+		return nil, err
+	}
+	_, space, err := s.getPictureWithAuth(version.PictureID, loginUser)
+	if err != nil {
+		return nil, err
+	}
+	vo := s.signVersionVO(resPicture.VersionToVO(*version), GetPermissionList(space, loginUser))
+	return &vo, nil
+}
+
+// 将图片恢复为指定的历史版本
+// 当前内容保存为新的历史版本，恢复后的内容作为新版本，版本号加1并重新进入审核；恢复的内容重新计入空间额度
+func (s *PictureVersionService) RestorePictureVersion(req *reqPicture.PictureVersionRestoreRequest, loginUser *entity.User) (*resPicture.PictureVO, *ecode.ErrorWithCode) {
+	version, err := s.getVersion(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	pic, space, err := s.getPictureWithAuth(version.PictureID, loginUser)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPictureNotArchived(pic); err != nil {
+		return nil, err
+	}
+	if space != nil && space.TotalSize+version.PicSize > space.MaxSize {
+		return nil, ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "空间图片大小已满")
+	}
+	tx := s.PictureRepo.BeginTransaction()
+	//1.当前内容保存为历史版本
+	if originErr := s.archive(tx, pic); originErr != nil {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	//2.恢复的内容增加一次引用，由图片持有
+	if version.ContentHash != "" {
+		if _, originErr := s.ContentRepo.Acquire(tx, version.ToContent()); originErr != nil {
+			tx.Rollback()
+			return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+		}
+	}
+	//3.用历史版本的内容覆盖图片，名称、标签等信息保持不变
+	pic.URL = version.URL
+	pic.ThumbnailURL = version.ThumbnailURL
+	pic.PicSize = version.PicSize
+	pic.PicWidth = version.PicWidth
+	pic.PicHeight = version.PicHeight
+	pic.PicScale = version.PicScale
+	pic.PicFormat = version.PicFormat
+	pic.PicColor = version.PicColor
+	pic.PHash = version.PHash
+	pic.DHash = version.DHash
+	pic.Palette = version.Palette
+	pic.ContentHash = version.ContentHash
+	pic.Renditions = version.Renditions
+	pic.RenditionProfile = version.RenditionProfile
+	pic.FrameCount = version.FrameCount
+	pic.IsAnimated = version.FrameCount > 1
+	pic.Version = max(pic.Version, 1) + 1
+	pic.EditTime = time.Now()
+	NewPictureService().FillReviewParamsInPic(pic, loginUser)
+	if originErr := s.PictureRepo.SavePicture(tx, pic); originErr != nil {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	metadata := version.GetMetadata()
+	var originErr error
+	if metadata != nil {
+		originErr = s.MetadataRepo.Save(tx, metadata)
+	} else {
+		originErr = s.MetadataRepo.DeleteByPictureId(tx, pic.ID)
+	}
+	if originErr != nil {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	originErr = s.ColorRepo.ReplaceByPicture(tx, pic.ID, pictureColorRows(pic.ID, pic.SpaceID, pic.GetPalette()))
+	if originErr != nil {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	//4.删除超出保留数量的历史版本
+	prunedSize, released, originErr := s.prune(tx, pic.ID)
+	if originErr != nil {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	//5.锁定空间后重新校验额度，避免并发请求基于过期的空间数据超出额度，再修改空间额度
+	if space != nil {
+		locked, originErr := NewSpaceService().SpaceRepo.LockSpaceById(tx, space.ID)
+		if originErr != nil || locked == nil {
+			tx.Rollback()
+			return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+		}
+		if locked.TotalSize+version.PicSize-prunedSize > locked.MaxSize {
+			tx.Rollback()
+			return nil, ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "空间图片大小已满")
+		}
+		updateMap := map[string]interface{}{
+			"total_size": gorm.Expr("total_size + ?", version.PicSize-prunedSize),
+		}
+		if err := NewSpaceService().SpaceRepo.UpdateSpaceById(tx, space.ID, updateMap); err != nil {
+			tx.Rollback()
+			return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+		}
+	}
+	if originErr := tx.Commit().Error; originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	deleteReleasedContents(released)
+	picVO := resPicture.EntityToVO(*pic, resUser.GetUserVO(*loginUser))
+	picVO.Metadata = metadata
+	picVO = NewPictureService().SignPictureVO(picVO, GetPermissionList(space, loginUser))
+	return &picVO, nil
+}
+
+// archive 将图片当前的内容保存为历史版本，图片持有的存储对象引用转交给历史版本
+func (s *PictureVersionService) archive(tx *gorm.DB, pic *entity.Picture) error {
+	originKey := ""
+	if pic.ContentHash != "" {
+		content, err := s.ContentRepo.FindByHash(tx, pic.ContentHash, pic.RenditionProfile, pic.SpaceID != 0)
+		if err != nil {
+			return err
+		}
+		if content != nil {
+			originKey = content.OriginKey
+		}
+	}
+	metadata, err := s.MetadataRepo.FindByPictureIds(tx, []uint64{pic.ID})
+	if err != nil {
+		return err
+	}
+	return s.VersionRepo.Create(tx, entity.NewPictureVersion(pic, originKey, metadata[pic.ID]))
+}
+
+// prune 删除超出保留数量的历史版本，返回删除的总体积和引用归零的内容
+func (s *PictureVersionService) prune(tx *gorm.DB, picId uint64) (int64, []*entity.PictureContent, error) {
+	expired, err := s.VersionRepo.ListExpired(tx, picId, s.MaxVersions())
+	if err != nil {
+		return 0, nil, err
+	}
+	return deleteVersions(tx, expired)
+}
+
+// deleteVersions 删除历史版本记录并释放其存储对象的引用，返回删除的总体积和引用归零的内容
+// 调用方负责在事务提交后删除引用归零的存储对象
+func deleteVersions(tx *gorm.DB, versions []entity.PictureVersion) (int64, []*entity.PictureContent, error) {
+	if len(versions) == 0 {
+		return 0, nil, nil
+	}
+	contentRepo := repository.NewPictureContentRepository()
+	var size int64
+	var released []*entity.PictureContent
+	ids := make([]uint64, 0, len(versions))
+	for _, v := range versions {
+		ids = append(ids, v.ID)
+		size += v.PicSize
+		//没有内容哈希的旧数据只删除记录，对象由存储回收任务清理
+		if v.ContentHash == "" {
+			continue
+		}
+		content, err := contentRepo.Release(tx, v.ContentHash, v.RenditionProfile, v.SpaceID != 0)
+		if err != nil {
+			return 0, nil, err
+		}
+		if content != nil {
+			released = append(released, content)
+		}
+	}
+	if err := repository.NewPictureVersionRepository().DeleteByIds(tx, ids); err != nil {
+		return 0, nil, err
+	}
+	return size, released, nil
+}
+
+// 删除引用归零的存储对象，需在事务提交后调用
+func deleteReleasedContents(released []*entity.PictureContent) {
+	for _, content := range released {
+		manager.DeletePictureObjects(content.OriginKey, content.URL, content.ThumbnailURL,
+			entity.ParseRenditions(content.Renditions))
+	}
+}
+
+func (s *PictureVersionService) getVersion(id uint64) (*entity.PictureVersion, *ecode.ErrorWithCode) {
+	version, originErr := s.VersionRepo.FindById(nil, id)
+	if originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	if version == nil {
+		return nil, ecode.GetErrWithDetail(ecode.NOT_FOUND_ERROR, "历史版本不存在")
+	}
+	return version, nil
+}
+
+// 获取图片及其空间，并校验是否有修改图片的权限
+func (s *PictureVersionService) getPictureWithAuth(picId uint64, loginUser *entity.User) (*entity.Picture, *entity.Space, *ecode.ErrorWithCode) {
+	pictureService := NewPictureService()
+	pic, err := pictureService.GetPictureById(picId)
+	if err != nil {
+		return nil, nil, err
+	}
+	var space *entity.Space
+	if pic.SpaceID != 0 {
+		var originErr error
+		space, originErr = repository.NewSpaceRepository().GetSpaceById(nil, pic.SpaceID)
+		if originErr != nil {
+			return nil, nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+		}
+		if space == nil {
+			return nil, nil, ecode.GetErrWithDetail(ecode.NOT_FOUND_ERROR, "空间不存在")
+		}
+	}
+	if err := pictureService.CheckPictureAuth(loginUser, pic, space); err != nil {
+		return nil, nil, err
+	}
+	return pic, space, nil
+}
+
+// 空间中的历史版本同样是私有对象，签发临时访问地址
+func (s *PictureVersionService) signVersionVO(vo resPicture.PictureVersionVO, permissionList []string) resPicture.PictureVersionVO {
+	if vo.SpaceID == 0 {
+		return vo
+	}
+	canView := slices.Contains(permissionList, "picture:view")
+	sign := func(objectURL string) string {
+		if !canView {
+			return ""
+		}
+		return manager.SignObjectURL(objectURL)
+	}
+	vo.URL = sign(vo.URL)
+	vo.ThumbnailURL = sign(vo.ThumbnailURL)
+	for i := range vo.Renditions {
+		vo.Renditions[i].URL = sign(vo.Renditions[i].URL)
+	}
+	return vo
+}
@@ -0,0 +1,376 @@
+package service
+
+import (
+	"backend/config"
+	"backend/internal/common"
+	"backend/internal/consts"
+	"backend/internal/ecode"
+	"backend/internal/manager"
+	"backend/internal/model/entity"
+	reqPicture "backend/internal/model/request/picture"
+	resPicture "backend/internal/model/response/picture"
+	"backend/internal/repository"
+	"backend/pkg/mysql"
+	"backend/pkg/redlock"
+	"backend/pkg/storage"
+	"log"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/go-redsync/redsync/v4"
+	"gorm.io/gorm"
+)
+
+// 回收站：删除的图片为软删除，删除时即释放空间额度，在保留期内可以恢复（重新校验额度）或彻底删除
+// 超过保留期的图片由后台任务彻底删除，同时释放存储对象的引用，引用归零的对象随之删除
+
+type RecycleBinService struct {
+	PictureRepo  *repository.PictureRepository
+	SpaceRepo    *repository.SpaceRepository
+	ContentRepo  *repository.PictureContentRepository
+	MetadataRepo *repository.PictureMetadataRepository
+	ColorRepo    *repository.PictureColorRepository
+	TagRepo      *repository.TagRepository
+	VersionRepo  *repository.PictureVersionRepository
+	ArchiveRepo  *repository.PictureArchiveRepository
+}
+
+func NewRecycleBinService() *RecycleBinService {
+	return &RecycleBinService{
+		PictureRepo:  repository.NewPictureRepository(),
+		SpaceRepo:    repository.NewSpaceRepository(),
+		ContentRepo:  repository.NewPictureContentRepository(),
+		MetadataRepo: repository.NewPictureMetadataRepository(),
+		ColorRepo:    repository.NewPictureColorRepository(),
+		TagRepo:      repository.NewTagRepository(),
+		VersionRepo:  repository.NewPictureVersionRepository(),
+		ArchiveRepo:  repository.NewPictureArchiveRepository(),
+	}
+}
+
+// 后台协程，按配置的间隔定期彻底删除超过保留期的图片
+func RecycleBinBackgroundService() {
+	if RecycleRetentionDays() <= 0 {
+		return
+	}
+	interval := consts.RECYCLE_INTERVAL_HOURS * time.Hour
+	if cfg := config.LoadConfig().RecycleConfig; cfg != nil && cfg.IntervalHours > 0 {
+		interval = time.Duration(cfg.IntervalHours) * time.Hour
+	}
+	log.Printf("启动回收站清理服务，间隔 %s，保留 %d 天", interval, RecycleRetentionDays())
+	s := NewRecycleBinService()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		purged, err := s.PurgeExpired()
+		if err != nil {
+			log.Println("回收站清理失败，错误为", err.Msg)
+			continue
+		}
+		log.Printf("回收站清理完成，彻底删除 %d 张图片", purged)
+	}
+}
+
+// 回收站的保留天数，为0时不自动清理
+// 优先使用recycle.retention_days，未配置时兼容storage.gc.retention_days
+func RecycleRetentionDays() int {
+	if cfg := config.LoadConfig().RecycleConfig; cfg != nil && cfg.RetentionDays != 0 {
+		return max(cfg.RetentionDays, 0)
+	}
+	if cfg := config.LoadConfig().StorageConfig; cfg != nil && cfg.GC != nil && cfg.GC.RetentionDays > 0 {
+		return cfg.GC.RetentionDays
+	}
+	return consts.STORAGE_GC_RETENTION_DAYS
+}
+
+// 删除时间早于该时间的图片可以彻底删除，不自动清理时返回零值
+func recycleDeletedBefore() time.Time {
+	days := RecycleRetentionDays()
+	if days <= 0 {
+		return time.Time{}
+	}
+	return time.Now().AddDate(0, 0, -days)
+}
+
+// 分页获取回收站中的图片，公共图库中普通用户只能看到自己删除的图片，空间中需要有删除图片的权限
+func (s *RecycleBinService) ListRecyclePictures(req *reqPicture.PictureRecycleQueryRequest, loginUser *entity.User) (*resPicture.ListRecyclePictureResponse, *ecode.ErrorWithCode) {
+	if req.Current <= 0 {
+		req.Current = 1
+	}
+	if req.PageSize <= 0 || req.PageSize > 50 {
+		req.PageSize = 20
+	}
+	query := mysql.LoadDB().Unscoped().Model(&entity.Picture{}).Where("is_delete IS NOT NULL")
+	var space *entity.Space
+	if req.SpaceID == 0 {
+		query = query.Where("(space_id IS NULL OR space_id = 0)")
+		if loginUser.UserRole != consts.ADMIN_ROLE {
+			query = query.Where("user_id = ?", loginUser.ID)
+		}
+	} else {
+		var err *ecode.ErrorWithCode
+		space, err = NewSpaceService().GetSpaceById(req.SpaceID)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(GetPermissionList(space, loginUser), "picture:delete") {
+			return nil, ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "没有空间权限")
+		}
+		query = query.Where("space_id = ?", req.SpaceID)
+	}
+	query = query.Session(&gorm.Session{})
+	var total int64
+	if originErr := query.Count(&total).Error; originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	var pictures []entity.Picture
+	originErr := query.Order("is_delete DESC").Offset((req.Current - 1) * req.PageSize).Limit(req.PageSize).
+		Find(&pictures).Error
+	if originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	pictureService := NewPictureService()
+	permissionList := GetPermissionList(space, loginUser)
+	picVOs := pictureService.GetPictureVOList(pictures)
+	days := RecycleRetentionDays()
+	records := make([]resPicture.RecyclePictureVO, 0, len(picVOs))
+	for i, picVO := range picVOs {
+		record := resPicture.RecyclePictureVO{
+			PictureVO:  pictureService.SignPictureVO(picVO, permissionList),
+			DeleteTime: pictures[i].IsDelete.Time,
+		}
+		if days > 0 {
+			expireTime := record.DeleteTime.AddDate(0, 0, days)
+			record.ExpireTime = &expireTime
+		}
+		records = append(records, record)
+	}
+	return &resPicture.ListRecyclePictureResponse{
+		PageResponse: common.PageResponse{
+			Total:   int(total),
+			Current: req.Current,
+			Pages:   int(math.Ceil(float64(total) / float64(req.PageSize))),
+			Size:    req.PageSize,
+		},
+		Records: records,
+	}, nil
+}
+
+// 批量恢复回收站中的图片，逐张校验空间的数量和大小额度，额度不足的图片恢复失败
+func (s *RecycleBinService) RestorePictures(req *reqPicture.PictureRecycleRequest, loginUser *entity.User) ([]resPicture.RecycleResult, *ecode.ErrorWithCode) {
+	return s.batch(req, func(id uint64) *ecode.ErrorWithCode {
+		return s.restorePicture(id, loginUser)
+	})
+}
+
+// 批量彻底删除回收站中的图片，引用归零的存储对象随之删除
+func (s *RecycleBinService) PurgePictures(req *reqPicture.PictureRecycleRequest, loginUser *entity.User) ([]resPicture.RecycleResult, *ecode.ErrorWithCode) {
+	return s.batch(req, func(id uint64) *ecode.ErrorWithCode {
+		released, err := s.purgePicture(id, loginUser)
+		if err != nil {
+			return err
+		}
+		deleteReleasedContents(released)
+		return nil
+	})
+}
+
+// 彻底删除超过保留期的图片，多实例部署时同一时间只有一个实例执行
+func (s *RecycleBinService) PurgeExpired() (int, *ecode.ErrorWithCode) {
+	deletedBefore := recycleDeletedBefore()
+	if deletedBefore.IsZero() {
+		return 0, nil
+	}
+	lock := redlock.GetRedSync().NewMutex("chg:recycle:purge:lock", redsync.WithExpiry(time.Hour), redsync.WithTries(1))
+	if err := lock.Lock(); err != nil {
+		return 0, ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "回收站清理任务正在执行")
+	}
+	defer lock.Unlock()
+	purged := 0
+	for {
+		pics, originErr := s.PictureRepo.ListDeletedBefore(nil, deletedBefore, consts.RECYCLE_PURGE_BATCH)
+		if originErr != nil {
+			return purged, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+		}
+		if len(pics) == 0 {
+			return purged, nil
+		}
+		for _, pic := range pics {
+			released, err := s.purgePicture(pic.ID, nil)
+			if err != nil {
+				return purged, err
+			}
+			deleteReleasedContents(released)
+			purged++
+		}
+	}
+}
+
+func (s *RecycleBinService) batch(req *reqPicture.PictureRecycleRequest, fn func(id uint64) *ecode.ErrorWithCode) ([]resPicture.RecycleResult, *ecode.ErrorWithCode) {
+	if len(req.PictureIdList) == 0 {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "图片ID列表为空")
+	}
+	if len(req.PictureIdList) > consts.RECYCLE_MAX_BATCH {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "一次最多处理100张图片")
+	}
+	results := make([]resPicture.RecycleResult, 0, len(req.PictureIdList))
+	for _, id := range req.PictureIdList {
+		result := resPicture.RecycleResult{ID: id, Success: true}
+		if err := fn(id); err != nil {
+			result.Success = false
+			result.Message = err.Msg
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// 恢复一张图片，在事务中锁定图片和空间，校验额度后重新计入
+func (s *RecycleBinService) restorePicture(id uint64, loginUser *entity.User) *ecode.ErrorWithCode {
+	tx := s.PictureRepo.BeginTransaction()
+	pic, space, err := s.lockDeletedPicture(tx, id, loginUser)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if space != nil {
+		//历史版本随图片一起恢复，同样计入大小
+		versionSize, originErr := s.VersionRepo.SumSizeByPictureId(tx, pic.ID)
+		if originErr != nil {
+			tx.Rollback()
+			return ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+		}
+		if space.TotalCount+1 > space.MaxCount {
+			tx.Rollback()
+			return ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "空间图片数量已满")
+		}
+		if space.TotalSize+pic.PicSize+versionSize > space.MaxSize {
+			tx.Rollback()
+			return ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "空间图片大小已满")
+		}
+		updateMap := map[string]interface{}{
+			"total_count": gorm.Expr("total_count + 1"),
+			"total_size":  gorm.Expr("total_size + ?", pic.PicSize+versionSize),
+		}
+		if originErr := s.SpaceRepo.UpdateSpaceById(tx, space.ID, updateMap); originErr != nil {
+			tx.Rollback()
+			return ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+		}
+	}
+	if originErr := s.PictureRepo.RestoreById(tx, pic.ID); originErr != nil {
+		tx.Rollback()
+		return ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	if originErr := tx.Commit().Error; originErr != nil {
+		return ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	return nil
+}
+
+// 彻底删除一张回收站中的图片及其元数据和历史版本，并释放存储对象的引用，返回引用归零的内容
+// loginUser为nil时表示由后台任务调用，不校验权限
+func (s *RecycleBinService) purgePicture(id uint64, loginUser *entity.User) ([]*entity.PictureContent, *ecode.ErrorWithCode) {
+	tx := s.PictureRepo.BeginTransaction()
+	pic, _, err := s.lockDeletedPicture(tx, id, loginUser)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if originErr := s.PictureRepo.HardDeleteById(tx, pic.ID); originErr != nil {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	if originErr := s.MetadataRepo.DeleteByPictureId(tx, pic.ID); originErr != nil {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	if originErr := s.ColorRepo.DeleteByPictureId(tx, pic.ID); originErr != nil {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	if originErr := s.TagRepo.DeleteByPictureId(tx, pic.ID); originErr != nil {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	versions, originErr := s.VersionRepo.ListByPictureId(tx, pic.ID)
+	if originErr != nil {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	_, released, originErr := deleteVersions(tx, versions)
+	if originErr != nil {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	//已归档的图片不再引用内容，存储对象由归档记录持有
+	var archivedKeys []string
+	if pic.ArchiveStatus != consts.PICTURE_ARCHIVE_NONE {
+		archive, originErr := s.ArchiveRepo.FindByPictureId(tx, pic.ID)
+		if originErr != nil {
+			tx.Rollback()
+			return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+		}
+		if archive != nil {
+			archivedKeys = archive.GetObjectKeys()
+			if originErr := s.ArchiveRepo.DeleteByPictureId(tx, pic.ID); originErr != nil {
+				tx.Rollback()
+				return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+			}
+		}
+	} else if pic.ContentHash != "" {
+		content, originErr := s.ContentRepo.Release(tx, pic.ContentHash, pic.RenditionProfile, pic.SpaceID != 0)
+		if originErr != nil {
+			tx.Rollback()
+			return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+		}
+		if content != nil {
+			released = append(released, content)
+		}
+	}
+	if originErr := tx.Commit().Error; originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	if archivedKeys != nil {
+		manager.DeleteArchivedObjects(archivedKeys)
+		if thumbnailKey, ok := storage.ObjectKey(pic.ThumbnailURL); ok {
+			manager.DeleteObjects([]string{thumbnailKey})
+		}
+	}
+	return released, nil
+}
+
+// 在事务中锁定回收站中的图片及其空间，loginUser不为nil时校验权限
+// 公共图库仅本人或管理员可以操作，空间中需要有删除图片的权限
+func (s *RecycleBinService) lockDeletedPicture(tx *gorm.DB, id uint64, loginUser *entity.User) (*entity.Picture, *entity.Space, *ecode.ErrorWithCode) {
+	pic, originErr := s.PictureRepo.LockDeletedById(tx, id)
+	if originErr != nil {
+		return nil, nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	if pic == nil {
+		return nil, nil, ecode.GetErrWithDetail(ecode.NOT_FOUND_ERROR, "图片不在回收站中")
+	}
+	var space *entity.Space
+	if pic.SpaceID != 0 {
+		space, originErr = s.SpaceRepo.LockSpaceById(tx, pic.SpaceID)
+		if originErr != nil {
+			return nil, nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+		}
+	}
+	if loginUser == nil {
+		return pic, space, nil
+	}
+	if pic.SpaceID == 0 {
+		if pic.UserID != loginUser.ID && loginUser.UserRole != consts.ADMIN_ROLE {
+			return nil, nil, ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "没有权限")
+		}
+	} else {
+		if space == nil {
+			return nil, nil, ecode.GetErrWithDetail(ecode.NOT_FOUND_ERROR, "空间不存在")
+		}
+		if !slices.Contains(GetPermissionList(space, loginUser), "picture:delete") {
+			return nil, nil, ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "没有空间权限")
+		}
+	}
+	return pic, space, nil
+}
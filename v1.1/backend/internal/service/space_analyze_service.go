@@ -1,7 +1,6 @@
 package service
 
 import (
-	"encoding/json"
 	"gorm.io/gorm"
 	"math"
 	"sort"
@@ -159,38 +158,25 @@ func (s *SpaceAnalyzeService) GetSpaceTagAnalyze(req *reqSpaceAnalyze.SpaceTagAn
 	if err != nil {
 		return nil, err
 	}
-	//查询原始标签
-	var OriginTags []string
-	if originErr := query.
-		Where("tags IS NOT NULL").
-		Where("tags != ''").
-		Pluck("tags", &OriginTags).Error; originErr != nil {
+	//按标签关联统计，全空间分析时不同空间的同名标签（不区分大小写）合并计数，名称使用图片最多的写法
+	counts, originErr := repository.NewTagRepository().CountByPictures(nil, query.Select("id"))
+	if originErr != nil {
 		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库查询失败")
 	}
-	//OriginTags = [
-	//`["风景","旅游"]`,
-	//`["人物","摄影"]`,
-	//`["美食"]`
-	//]
-	TagCount := make(map[string]int64)
-	//解析标签
-	for _, tags := range OriginTags {
-		var tagList []string
-		if err := json.Unmarshal([]byte(tags), &tagList); err != nil {
-			return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "标签解析失败")
+	result := make([]resSpaceAnalyze.SpaceTagAnalyzeResponse, 0, len(counts))
+	index := make(map[string]int, len(counts))
+	for _, c := range counts {
+		key := tagKey(c.Name)
+		if i, ok := index[key]; ok {
+			result[i].Count += c.PictureCount
+			continue
 		}
-		for _, tag := range tagList {
-			TagCount[tag]++
-		}
-	}
-	var result []resSpaceAnalyze.SpaceTagAnalyzeResponse
-	for tag, count := range TagCount {
-		res := resSpaceAnalyze.SpaceTagAnalyzeResponse{
-			Tag:   tag,
-			Count: count,
-		}
-		result = append(result, res)
+		index[key] = len(result)
+		result = append(result, resSpaceAnalyze.SpaceTagAnalyzeResponse{Tag: c.Name, Count: c.PictureCount})
 	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Count > result[j].Count
+	})
 	return result, nil
 }
 
@@ -111,6 +111,9 @@ func (s *SpaceService) EditSpace(space *reqSpace.SpaceEditRequest, loginUser *en
 	updateMap := make(map[string]interface{}, 8)
 	//填充数据
 	updateMap["space_name"] = space.SpaceName
+	if space.KeepGPS != nil {
+		updateMap["keep_gps"] = *space.KeepGPS
+	}
 	updateMap["edit_time"] = time.Now()
 	//更新数据库数据
 	if err := s.SpaceRepo.UpdateSpaceById(nil, space.ID, updateMap); err != nil {
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"backend/internal/consts"
+	"backend/internal/ecode"
+	"backend/internal/manager"
+	"backend/internal/model/entity"
+	reqSpace "backend/internal/model/request/space"
+	"backend/internal/repository"
+	"backend/pkg/imageproc"
+	"backend/pkg/storage"
+	"encoding/json"
+	"log"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 空间水印：开启后新上传图片对外展示的主图、缩略图和衍生版本添加水印，渲染接口对没有编辑权限的成员同样添加水印
+// 原图不添加水印，只有拥有编辑权限的成员可以获取
+
+type SpaceWatermarkService struct {
+	SpaceRepo *repository.SpaceRepository
+}
+
+func NewSpaceWatermarkService() *SpaceWatermarkService {
+	return &SpaceWatermarkService{
+		SpaceRepo: repository.NewSpaceRepository(),
+	}
+}
+
+// 修改空间的水印设置，需要有空间的管理权限；修改只对之后上传的图片生效
+func (s *SpaceWatermarkService) EditWatermark(req *reqSpace.SpaceWatermarkEditRequest, loginUser *entity.User) (*entity.SpaceWatermark, *ecode.ErrorWithCode) {
+	space, err := s.getSpaceWithAuth(req.SpaceID, loginUser)
+	if err != nil {
+		return nil, err
+	}
+	setting := &entity.SpaceWatermark{
+		Enabled:  req.Enabled,
+		Type:     req.Type,
+		Text:     strings.TrimSpace(req.Text),
+		Color:    strings.ToUpper(req.Color),
+		Position: req.Position,
+		Opacity:  req.Opacity,
+		Scale:    req.Scale,
+	}
+	//水印图片只能通过上传接口设置
+	if old := space.GetWatermark(); old != nil {
+		setting.ImageKey = old.ImageKey
+	}
+	if err := s.validWatermark(setting); err != nil {
+		return nil, err
+	}
+	if err := s.saveWatermark(space.ID, setting); err != nil {
+		return nil, err
+	}
+	return setting, nil
+}
+
+// 上传空间的水印图片，图片统一转为PNG保存，需要有空间的管理权限
+// 上传后水印类型切换为图片，是否开启保持不变
+func (s *SpaceWatermarkService) UploadWatermarkImage(spaceId uint64, data []byte, loginUser *entity.User) (*entity.SpaceWatermark, *ecode.ErrorWithCode) {
+	space, err := s.getSpaceWithAuth(spaceId, loginUser)
+	if err != nil {
+		return nil, err
+	}
+	key, err := manager.SaveWatermarkImage(space.ID, data)
+	if err != nil {
+		return nil, err
+	}
+	setting := space.GetWatermark()
+	if setting == nil {
+		setting = &entity.SpaceWatermark{}
+	}
+	oldKey := setting.ImageKey
+	setting.Type = consts.WATERMARK_TYPE_IMAGE
+	setting.ImageKey = key
+	if err := s.validWatermark(setting); err != nil {
+		return nil, err
+	}
+	if err := s.saveWatermark(space.ID, setting); err != nil {
+		if originErr := storage.GetStore().DeleteObject(key); originErr != nil {
+			log.Println("删除水印图片失败，错误为", originErr)
+		}
+		return nil, err
+	}
+	if oldKey != "" {
+		if originErr := storage.GetStore().DeleteObject(oldKey); originErr != nil {
+			log.Println("删除旧的水印图片失败，错误为", originErr)
+		}
+	}
+	return setting, nil
+}
+
+// 校验水印设置并填充默认值
+func (s *SpaceWatermarkService) validWatermark(setting *entity.SpaceWatermark) *ecode.ErrorWithCode {
+	switch setting.Type {
+	case consts.WATERMARK_TYPE_TEXT:
+		if setting.Enabled && setting.Text == "" {
+			return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "水印文字不能为空")
+		}
+	case consts.WATERMARK_TYPE_IMAGE:
+		if setting.Enabled && setting.ImageKey == "" {
+			return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "请先上传水印图片")
+		}
+	case "":
+		setting.Type = consts.WATERMARK_TYPE_TEXT
+		if setting.Enabled && setting.Text == "" {
+			return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "水印文字不能为空")
+		}
+	default:
+		return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "不支持的水印类型")
+	}
+	if utf8.RuneCountInString(setting.Text) > consts.WATERMARK_TEXT_MAX_LEN {
+		return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "水印文字过长")
+	}
+	if setting.Color == "" {
+		setting.Color = consts.WATERMARK_DEFAULT_COLOR
+	}
+	if _, ok := manager.ParseHexColor(setting.Color); !ok {
+		return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "水印颜色格式错误，例如 #FFFFFF")
+	}
+	if setting.Position == "" {
+		setting.Position = imageproc.WatermarkBottomRight
+	}
+	if !imageproc.IsWatermarkPosition(setting.Position) {
+		return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "不支持的水印位置")
+	}
+	if setting.Opacity == 0 {
+		setting.Opacity = consts.WATERMARK_DEFAULT_ALPHA
+	}
+	if setting.Opacity < 0 || setting.Opacity > 1 {
+		return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "不透明度需在0~1之间")
+	}
+	if setting.Scale == 0 {
+		setting.Scale = consts.WATERMARK_DEFAULT_SCALE
+	}
+	if setting.Scale < 0 || setting.Scale > 1 {
+		return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "水印比例需在0~1之间")
+	}
+	return nil
+}
+
+func (s *SpaceWatermarkService) saveWatermark(spaceId uint64, setting *entity.SpaceWatermark) *ecode.ErrorWithCode {
+	data, originErr := json.Marshal(setting)
+	if originErr != nil {
+		return ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "水印设置序列化失败")
+	}
+	updateMap := map[string]interface{}{
+		"watermark": string(data),
+		"edit_time": time.Now(),
+	}
+	if originErr := s.SpaceRepo.UpdateSpaceById(nil, spaceId, updateMap); originErr != nil {
+		return ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "更新失败")
+	}
+	return nil
+}
+
+// 获取空间并校验是否有空间的管理权限
+func (s *SpaceWatermarkService) getSpaceWithAuth(spaceId uint64, loginUser *entity.User) (*entity.Space, *ecode.ErrorWithCode) {
+	if spaceId == 0 {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "参数错误")
+	}
+	space, err := NewSpaceService().GetSpaceById(spaceId)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(GetPermissionList(space, loginUser), "spaceUser:manage") {
+		return nil, ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "没有空间管理权限")
+	}
+	return space, nil
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"backend/config"
+	"backend/internal/consts"
+	"backend/internal/ecode"
+	"backend/internal/manager"
+	"backend/internal/model/entity"
+	resStorage "backend/internal/model/response/storage"
+	"backend/internal/repository"
+	"backend/pkg/redlock"
+	"backend/pkg/storage"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/go-redsync/redsync/v4"
+)
+
+type StorageGCService struct {
+	PictureRepo *repository.PictureRepository
+	UserRepo    *repository.UserRepository
+	VersionRepo *repository.PictureVersionRepository
+}
+
+func NewStorageGCService() *StorageGCService {
+	return &StorageGCService{
+		PictureRepo: repository.NewPictureRepository(),
+		UserRepo:    repository.NewUserRepository(),
+		VersionRepo: repository.NewPictureVersionRepository(),
+	}
+}
+
+// 后台协程，按配置的间隔定期执行存储回收
+func StorageGCBackgroundService() {
+	cfg := config.LoadConfig().StorageConfig
+	if cfg == nil || cfg.GC == nil || !cfg.GC.Enabled {
+		return
+	}
+	interval := time.Duration(cfg.GC.IntervalHours) * time.Hour
+	if interval <= 0 {
+		interval = consts.STORAGE_GC_INTERVAL_HOURS * time.Hour
+	}
+	log.Printf("启动存储回收服务，间隔 %s，演练模式 %v", interval, cfg.GC.DryRun)
+	s := NewStorageGCService()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		report, err := s.RunStorageGC(cfg.GC.DryRun)
+		if err != nil {
+			log.Println("存储回收失败，错误为", err.Msg)
+			continue
+		}
+		log.Printf("存储回收完成，扫描 %d，孤儿 %d，删除 %d，失败 %d",
+			report.Scanned, report.Orphaned, report.Deleted, report.Failed)
+	}
+}
+
+// 执行一次存储回收：扫描存储中的对象，与图片和用户表比对，删除或上报不再被引用的对象
+// 软删除的记录在保留期内仍视为引用，以便恢复
+func (s *StorageGCService) RunStorageGC(dryRun bool) (*resStorage.StorageGCReport, *ecode.ErrorWithCode) {
+	//同一时间只允许一个实例执行，拿不到锁直接返回
+	lock := redlock.GetRedSync().NewMutex("chg:storage:gc:lock", redsync.WithExpiry(time.Hour), redsync.WithTries(1))
+	if err := lock.Lock(); err != nil {
+		return nil, ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "存储回收任务正在执行")
+	}
+	defer lock.Unlock()
+
+	report := &resStorage.StorageGCReport{
+		DryRun:    dryRun,
+		Orphans:   []string{},
+		StartTime: time.Now().UnixMilli(),
+	}
+	//回收站中未超过保留期的图片仍视为引用，回收站不自动清理时全部视为引用
+	deletedBefore := recycleDeletedBefore()
+	//彻底删除超过保留期的图片记录，并释放其存储对象的引用
+	if !dryRun {
+		//回收站清理正在由后台任务执行时跳过，不影响本次孤儿扫描
+		purged, err := NewRecycleBinService().PurgeExpired()
+		if err != nil {
+			log.Println("回收站清理未执行，原因为", err.Msg)
+		}
+		report.Purged = purged
+	}
+	referenced, err := s.getReferencedKeys(deletedBefore)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	collect := func(obj storage.ObjectInfo) error {
+		report.Orphaned++
+		report.OrphanSize += obj.Size
+		if len(report.Orphans) < consts.STORAGE_GC_REPORT_LIMIT {
+			report.Orphans = append(report.Orphans, obj.Key)
+		}
+		if dryRun {
+			return nil
+		}
+		if err := storage.GetStore().DeleteObject(obj.Key); err != nil {
+			log.Println("删除孤儿对象失败，错误为", err)
+			report.Failed++
+		} else {
+			report.Deleted++
+		}
+		return nil
+	}
+	//1.图片和头像对象，不被任何记录引用的视为孤儿
+	for _, prefix := range consts.StorageGCPrefixes {
+		originErr := storage.ListObjects(prefix, func(obj storage.ObjectInfo) error {
+			report.Scanned++
+			if now.Sub(obj.LastModified) < consts.STORAGE_GC_GRACE_PERIOD {
+				return nil
+			}
+			if _, ok := referenced[manager.ObjectBaseKey(obj.Key)]; ok {
+				return nil
+			}
+			return collect(obj)
+		})
+		if err := s.listError(originErr); err != nil {
+			return nil, err
+		}
+	}
+	//2.分片上传的残留，超过会话有效期即可删除
+	originErr := storage.ListObjects(consts.UPLOAD_SESSION_PREFIX+"/", func(obj storage.ObjectInfo) error {
+		report.Scanned++
+		if now.Sub(obj.LastModified) < consts.UPLOAD_SESSION_EXPIRE {
+			return nil
+		}
+		return collect(obj)
+	})
+	if err := s.listError(originErr); err != nil {
+		return nil, err
+	}
+	//3.渲染结果，超过保留时长即可删除，再次访问时重新生成
+	originErr = storage.ListObjects(consts.RENDER_VARIANT_PREFIX+"/", func(obj storage.ObjectInfo) error {
+		report.Scanned++
+		if now.Sub(obj.LastModified) < consts.RENDER_VARIANT_RETENTION {
+			return nil
+		}
+		return collect(obj)
+	})
+	if err := s.listError(originErr); err != nil {
+		return nil, err
+	}
+	//4.导出的压缩包，超过任务保留时长即可删除
+	originErr = storage.ListObjects(consts.EXPORT_PREFIX+"/", func(obj storage.ObjectInfo) error {
+		report.Scanned++
+		if now.Sub(obj.LastModified) < consts.EXPORT_JOB_EXPIRE {
+			return nil
+		}
+		return collect(obj)
+	})
+	if err := s.listError(originErr); err != nil {
+		return nil, err
+	}
+	report.EndTime = time.Now().UnixMilli()
+	return report, nil
+}
+
+// 获取仍被引用的对象公共前缀集合
+func (s *StorageGCService) getReferencedKeys(deletedAfter time.Time) (map[string]struct{}, *ecode.ErrorWithCode) {
+	pics, originErr := s.PictureRepo.ListObjectURLs(nil, deletedAfter)
+	if originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	versions, originErr := s.VersionRepo.ListObjectURLs(nil)
+	if originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	avatars, originErr := s.UserRepo.ListAvatarURLs(nil, deletedAfter)
+	if originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	referenced := make(map[string]struct{}, len(pics)+len(avatars))
+	matched := 0
+	add := func(objectURL string) {
+		if key, ok := storage.ObjectKey(objectURL); ok {
+			referenced[manager.ObjectBaseKey(key)] = struct{}{}
+			matched++
+		}
+	}
+	for _, pic := range pics {
+		add(pic.URL)
+		add(pic.ThumbnailURL)
+		for _, r := range entity.ParseRenditions(pic.Renditions) {
+			add(r.URL)
+		}
+	}
+	for _, v := range versions {
+		add(v.URL)
+		add(v.ThumbnailURL)
+		for _, r := range entity.ParseRenditions(v.Renditions) {
+			add(r.URL)
+		}
+	}
+	for _, avatar := range avatars {
+		add(avatar)
+	}
+	//有记录但没有一个地址属于当前存储，多半是存储域名配置变更，此时回收会误删全部对象
+	if len(pics) > 0 && matched == 0 {
+		return nil, ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "图片地址与当前存储不匹配，已停止回收")
+	}
+	return referenced, nil
+}
+
+func (s *StorageGCService) listError(err error) *ecode.ErrorWithCode {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, storage.ErrListNotSupported) {
+		return ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "当前存储不支持列举对象")
+	}
+	log.Println("列举对象失败，错误为", err)
+	return ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "列举对象失败")
+}
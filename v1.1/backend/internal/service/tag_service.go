@@ -0,0 +1,412 @@
+package service
+
+import (
+	"backend/internal/consts"
+	"backend/internal/ecode"
+	"backend/internal/model/entity"
+	reqPicture "backend/internal/model/request/picture"
+	resPicture "backend/internal/model/response/picture"
+	"backend/internal/repository"
+	"backend/pkg/redis"
+	"backend/pkg/search"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// 标签：每个空间（公共图库为0）有独立的标签表，图片与标签通过picture_tags关联，按标签筛选和统计都使用关联表
+// 图片的tags字段保存标签名称的JSON副本，用于展示、全文搜索和导出，修改标签时同步更新
+
+type TagService struct {
+	TagRepo     *repository.TagRepository
+	PictureRepo *repository.PictureRepository
+}
+
+func NewTagService() *TagService {
+	return &TagService{
+		TagRepo:     repository.NewTagRepository(),
+		PictureRepo: repository.NewPictureRepository(),
+	}
+}
+
+// 把历史图片tags字段中的标签迁移到标签表，已有标签关联的图片不会重复处理
+// 迁移完成前按标签筛选会缺少历史图片，因此在注册路由前同步执行；完成后写入标记，之后启动直接跳过
+// 迁移之后新写入的标签都同步维护关联表，标记丢失时重新迁移也只会处理没有关联的图片
+func PictureTagMigrateService() {
+	ctx := context.Background()
+	if n, err := redis.GetRedisClient().Exists(ctx, consts.TAG_MIGRATE_DONE_KEY).Result(); err == nil && n > 0 {
+		return
+	}
+	count, err := NewTagService().MigratePictureTags()
+	if err != nil {
+		log.Println("迁移图片标签失败，错误为", err)
+		return
+	}
+	if count > 0 {
+		log.Printf("迁移图片标签完成，共 %d 张", count)
+	}
+	if err := redis.GetRedisClient().Set(ctx, consts.TAG_MIGRATE_DONE_KEY, time.Now().Format(time.RFC3339), 0).Err(); err != nil {
+		log.Println("写入标签迁移完成标记失败，错误为", err)
+	}
+}
+
+// 比较标签名称时使用的键，去掉首尾空白并转为小写
+func tagKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// 整理标签：去掉首尾空白和空标签，不区分大小写去重，保留第一次出现的写法和顺序
+func normalizeTags(names []string) []string {
+	res := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		key := tagKey(name)
+		if name == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		res = append(res, name)
+	}
+	return res
+}
+
+// 校验整理后的标签，用于用户提交的标签
+func validateTags(names []string) *ecode.ErrorWithCode {
+	if len(names) > consts.TAG_MAX_COUNT {
+		return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, fmt.Sprintf("标签不能超过%d个", consts.TAG_MAX_COUNT))
+	}
+	for _, name := range names {
+		if utf8.RuneCountInString(name) > consts.TAG_NAME_MAX_LEN {
+			return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, fmt.Sprintf("标签不能超过%d个字符", consts.TAG_NAME_MAX_LEN))
+		}
+	}
+	if len(formatTags(names)) > consts.TAGS_MAX_SIZE {
+		return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "标签总长度过长")
+	}
+	return nil
+}
+
+// 截断并整理标签，超出数量或总长度的标签丢弃，用于导入和迁移等不能拒绝的数据
+func fitTags(names []string) []string {
+	truncated := make([]string, 0, len(names))
+	for _, name := range names {
+		truncated = append(truncated, truncateRunes(strings.TrimSpace(name), consts.TAG_NAME_MAX_LEN))
+	}
+	res := normalizeTags(truncated)
+	if len(res) > consts.TAG_MAX_COUNT {
+		res = res[:consts.TAG_MAX_COUNT]
+	}
+	for len(res) > 0 && len(formatTags(res)) > consts.TAGS_MAX_SIZE {
+		res = res[:len(res)-1]
+	}
+	return res
+}
+
+// 把标签序列化为图片tags字段的格式，没有标签时为[]
+func formatTags(names []string) string {
+	if len(names) == 0 {
+		return "[]"
+	}
+	data, _ := json.Marshal(names)
+	return string(data)
+}
+
+// 解析图片tags字段，格式错误时返回nil
+func parseTags(tags string) []string {
+	var names []string
+	if err := json.Unmarshal([]byte(tags), &names); err != nil {
+		return nil
+	}
+	return names
+}
+
+// 设置图片的标签关联，标签不存在时在图片所在空间创建；names需已整理
+// 数据库的排序规则下视为相同的名称对应同一个标签，只保留一次
+func (s *TagService) setPictureTags(tx *gorm.DB, picIds []uint64, spaceId uint64, names []string) error {
+	tagIds := make([]uint64, 0, len(names))
+	for _, name := range names {
+		tag, err := s.TagRepo.FindOrCreate(tx, spaceId, name)
+		if err != nil {
+			return err
+		}
+		if tag == nil {
+			return fmt.Errorf("标签 %s 创建失败", name)
+		}
+		if !slices.Contains(tagIds, tag.ID) {
+			tagIds = append(tagIds, tag.ID)
+		}
+	}
+	for _, picId := range picIds {
+		if err := s.TagRepo.ReplacePictureTags(tx, picId, tagIds); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 按标签关联重新生成图片的tags字段，用于重命名、合并和删除标签之后
+func (s *TagService) refreshPictureTags(tx *gorm.DB, picIds []uint64) error {
+	for start := 0; start < len(picIds); start += consts.TAG_REFRESH_BATCH {
+		batch := picIds[start:min(start+consts.TAG_REFRESH_BATCH, len(picIds))]
+		names, err := s.TagRepo.ListNamesByPictureIds(tx, batch)
+		if err != nil {
+			return err
+		}
+		for _, id := range batch {
+			if err := s.PictureRepo.UpdateTags(tx, id, formatTags(names[id])); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 把历史图片tags字段中的标签写入标签表和关联表，返回迁移的图片数量
+// 过长的标签被截断，tags字段同步改为整理后的标签；没有有效标签的图片跳过
+func (s *TagService) MigratePictureTags() (int, error) {
+	var lastId uint64
+	count := 0
+	for {
+		pics, err := s.TagRepo.ListPicturesWithoutRelation(nil, lastId, consts.TAG_MIGRATE_BATCH)
+		if err != nil {
+			return count, err
+		}
+		if len(pics) == 0 {
+			return count, nil
+		}
+		for _, pic := range pics {
+			lastId = pic.ID
+			names := fitTags(parseTags(pic.Tags))
+			if len(names) == 0 {
+				continue
+			}
+			if err := s.migratePicture(&pic, names); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+}
+
+// 在同一事务中写入一张图片的标签关联和整理后的tags字段
+func (s *TagService) migratePicture(pic *entity.Picture, names []string) error {
+	tx := s.TagRepo.BeginTransaction()
+	if err := s.setPictureTags(tx, []uint64{pic.ID}, pic.SpaceID, names); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if tags := formatTags(names); tags != pic.Tags {
+		if err := s.PictureRepo.UpdateTags(tx, pic.ID, tags); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// 校验用户对空间中标签的权限，spaceId为0表示公共图库
+func (s *TagService) checkTagAuth(spaceId uint64, loginUser *entity.User, permission string) *ecode.ErrorWithCode {
+	if loginUser == nil {
+		return ecode.GetErrWithDetail(ecode.NOT_LOGIN_ERROR, "用户未登录")
+	}
+	var space *entity.Space
+	if spaceId != 0 {
+		var err *ecode.ErrorWithCode
+		space, err = NewSpaceService().GetSpaceById(spaceId)
+		if err != nil {
+			return err
+		}
+	}
+	if !slices.Contains(GetPermissionList(space, loginUser), permission) {
+		return ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "没有权限")
+	}
+	return nil
+}
+
+// 标签自动补全，按前缀匹配空间中的标签（不区分大小写），按图片数量从多到少返回，需要对空间有查看权限
+// 前缀为空时返回最常用的标签
+func (s *TagService) SuggestTags(spaceId uint64, prefix string, limit int, loginUser *entity.User) ([]resPicture.PictureTagVO, *ecode.ErrorWithCode) {
+	if limit < 0 || limit > consts.TAG_SUGGEST_MAX_LIMIT {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "数量超出范围")
+	}
+	if limit == 0 {
+		limit = consts.TAG_SUGGEST_DEFAULT_LIMIT
+	}
+	if err := s.checkTagAuth(spaceId, loginUser, "picture:view"); err != nil {
+		return nil, err
+	}
+	rows, originErr := s.TagRepo.Suggest(nil, spaceId, search.EscapeLike(strings.TrimSpace(prefix))+"%", limit)
+	if originErr != nil {
+		log.Print(originErr)
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	res := make([]resPicture.PictureTagVO, 0, len(rows))
+	for _, row := range rows {
+		res = append(res, resPicture.PictureTagVO{ID: row.ID, Name: row.Name, Count: row.PictureCount})
+	}
+	return res, nil
+}
+
+// 重命名空间中的标签，需要对空间有编辑图片的权限；新名称已被其他标签使用时需要改用合并
+func (s *TagService) RenameTag(req *reqPicture.PictureTagRenameRequest, loginUser *entity.User) (*resPicture.PictureTagOperateResponse, *ecode.ErrorWithCode) {
+	//1.参数校验
+	name, newName := strings.TrimSpace(req.Name), strings.TrimSpace(req.NewName)
+	if name == "" || newName == "" {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "标签名称不能为空")
+	}
+	if err := validateTags([]string{newName}); err != nil {
+		return nil, err
+	}
+	if err := s.checkTagAuth(req.SpaceID, loginUser, "picture:edit"); err != nil {
+		return nil, err
+	}
+	//2.修改名称并更新图片的标签副本
+	tx := s.TagRepo.BeginTransaction()
+	tag, originErr := s.TagRepo.FindByName(tx, req.SpaceID, name)
+	if originErr != nil {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	if tag == nil {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.NOT_FOUND_ERROR, "标签不存在")
+	}
+	//只修改大小写时查到的是同一个标签
+	exist, originErr := s.TagRepo.FindByName(tx, req.SpaceID, newName)
+	if originErr != nil {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	if exist != nil && exist.ID != tag.ID {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "标签已存在，请使用合并标签")
+	}
+	if tag.Name == newName {
+		tx.Rollback()
+		return &resPicture.PictureTagOperateResponse{}, nil
+	}
+	if originErr := s.TagRepo.Rename(tx, tag.ID, newName); originErr != nil {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	picIds, originErr := s.TagRepo.ListPictureIds(tx, []uint64{tag.ID})
+	if originErr != nil {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	return s.refreshAndCommit(tx, picIds)
+}
+
+// 把多个标签合并为目标标签，需要对空间有编辑图片的权限；目标标签不存在时创建，源标签合并后删除
+func (s *TagService) MergeTags(req *reqPicture.PictureTagMergeRequest, loginUser *entity.User) (*resPicture.PictureTagOperateResponse, *ecode.ErrorWithCode) {
+	//1.参数校验
+	targetName := strings.TrimSpace(req.TargetName)
+	sourceNames := normalizeTags(req.SourceNames)
+	if targetName == "" || len(sourceNames) == 0 {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "标签名称不能为空")
+	}
+	if len(sourceNames) > consts.TAG_MAX_COUNT {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, fmt.Sprintf("一次最多合并%d个标签", consts.TAG_MAX_COUNT))
+	}
+	if err := validateTags([]string{targetName}); err != nil {
+		return nil, err
+	}
+	if err := s.checkTagAuth(req.SpaceID, loginUser, "picture:edit"); err != nil {
+		return nil, err
+	}
+	//2.查询标签
+	tx := s.TagRepo.BeginTransaction()
+	target, originErr := s.TagRepo.FindOrCreate(tx, req.SpaceID, targetName)
+	if originErr != nil || target == nil {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	sourceIds := make([]uint64, 0, len(sourceNames))
+	for _, name := range sourceNames {
+		tag, originErr := s.TagRepo.FindByName(tx, req.SpaceID, name)
+		if originErr != nil {
+			tx.Rollback()
+			return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+		}
+		if tag == nil {
+			tx.Rollback()
+			return nil, ecode.GetErrWithDetail(ecode.NOT_FOUND_ERROR, fmt.Sprintf("标签%s不存在", name))
+		}
+		//目标标签本身也在源标签中时跳过
+		if tag.ID != target.ID && !slices.Contains(sourceIds, tag.ID) {
+			sourceIds = append(sourceIds, tag.ID)
+		}
+	}
+	if len(sourceIds) == 0 {
+		tx.Rollback()
+		return &resPicture.PictureTagOperateResponse{}, nil
+	}
+	//3.移动图片关联并删除源标签，受影响的图片需在移动前查出
+	picIds, originErr := s.TagRepo.ListPictureIds(tx, sourceIds)
+	if originErr != nil {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	for _, id := range sourceIds {
+		if originErr := s.TagRepo.MoveRelations(tx, id, target.ID); originErr != nil {
+			tx.Rollback()
+			return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+		}
+		if originErr := s.TagRepo.DeleteById(tx, id); originErr != nil {
+			tx.Rollback()
+			return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+		}
+	}
+	return s.refreshAndCommit(tx, picIds)
+}
+
+// 删除空间中的标签，同时从所有图片中移除，需要对空间有编辑图片的权限
+func (s *TagService) DeleteTag(req *reqPicture.PictureTagDeleteRequest, loginUser *entity.User) (*resPicture.PictureTagOperateResponse, *ecode.ErrorWithCode) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "标签名称不能为空")
+	}
+	if err := s.checkTagAuth(req.SpaceID, loginUser, "picture:edit"); err != nil {
+		return nil, err
+	}
+	tx := s.TagRepo.BeginTransaction()
+	tag, originErr := s.TagRepo.FindByName(tx, req.SpaceID, name)
+	if originErr != nil {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	if tag == nil {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.NOT_FOUND_ERROR, "标签不存在")
+	}
+	picIds, originErr := s.TagRepo.ListPictureIds(tx, []uint64{tag.ID})
+	if originErr != nil {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	if originErr := s.TagRepo.DeleteById(tx, tag.ID); originErr != nil {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	return s.refreshAndCommit(tx, picIds)
+}
+
+// 更新受影响图片的标签副本并提交事务
+func (s *TagService) refreshAndCommit(tx *gorm.DB, picIds []uint64) (*resPicture.PictureTagOperateResponse, *ecode.ErrorWithCode) {
+	if originErr := s.refreshPictureTags(tx, picIds); originErr != nil {
+		tx.Rollback()
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	if originErr := tx.Commit().Error; originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库错误")
+	}
+	return &resPicture.PictureTagOperateResponse{Affected: len(picIds)}, nil
+}
@@ -0,0 +1,455 @@
+package service
+
+import (
+	"backend/internal/consts"
+	"backend/internal/ecode"
+	"backend/internal/manager"
+	"backend/internal/model/dto/file"
+	"backend/internal/model/entity"
+	reqPicture "backend/internal/model/request/picture"
+	resPicture "backend/internal/model/response/picture"
+	"backend/internal/repository"
+	"backend/pkg/redis"
+	"backend/pkg/redlock"
+	"backend/pkg/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redsync/redsync/v4"
+	"github.com/google/uuid"
+)
+
+// 分片上传会话，保存在redis中，分片数据保存在对象存储中
+type uploadSession struct {
+	UploadID   string    `json:"uploadId"`
+	UserID     uint64    `json:"userId"`
+	SpaceID    uint64    `json:"spaceId"`
+	PictureID  uint64    `json:"pictureId"`
+	FileName   string    `json:"fileName"`
+	FileSize   int64     `json:"fileSize"`
+	PartSize   int64     `json:"partSize"`
+	PartCount  int       `json:"partCount"`
+	ExpireTime time.Time `json:"expireTime"`
+}
+
+// 预签名直传记录，确认上传时使用
+type presignUpload struct {
+	UploadID   string    `json:"uploadId"`
+	UserID     uint64    `json:"userId"`
+	SpaceID    uint64    `json:"spaceId"`
+	PictureID  uint64    `json:"pictureId"`
+	Key        string    `json:"key"`
+	FileSize   int64     `json:"fileSize"`
+	ExpireTime time.Time `json:"expireTime"`
+}
+
+type UploadSessionService struct {
+	SpaceRepo *repository.SpaceRepository
+}
+
+func NewUploadSessionService() *UploadSessionService {
+	return &UploadSessionService{
+		SpaceRepo: repository.NewSpaceRepository(),
+	}
+}
+
+func uploadSessionKey(uploadId string) string {
+	return fmt.Sprintf("chg:upload:session:%s", uploadId)
+}
+
+func uploadSessionPartsKey(uploadId string) string {
+	return fmt.Sprintf("chg:upload:session:%s:parts", uploadId)
+}
+
+func presignUploadKey(uploadId string) string {
+	return fmt.Sprintf("chg:upload:presign:%s", uploadId)
+}
+
+func uploadPartObjectKey(uploadId string, partNumber int) string {
+	return fmt.Sprintf("%s/%s/%d", consts.UPLOAD_SESSION_PREFIX, uploadId, partNumber)
+}
+
+// 初始化分片上传会话，校验图片类型、单文件大小和空间剩余额度
+func (s *UploadSessionService) InitUploadSession(req *reqPicture.UploadSessionInitRequest, loginUser *entity.User) (*resPicture.UploadSessionVO, *ecode.ErrorWithCode) {
+	if err := manager.ValidPictureType(req.FileName); err != nil {
+		return nil, err
+	}
+	if err := s.checkUploadLimit(req.SpaceID, req.FileSize, loginUser); err != nil {
+		return nil, err
+	}
+
+	partSize := int64(consts.UPLOAD_PART_SIZE)
+	session := &uploadSession{
+		UploadID:   uuid.NewString(),
+		UserID:     loginUser.ID,
+		SpaceID:    req.SpaceID,
+		PictureID:  req.ID,
+		FileName:   req.FileName,
+		FileSize:   req.FileSize,
+		PartSize:   partSize,
+		PartCount:  int((req.FileSize + partSize - 1) / partSize),
+		ExpireTime: time.Now().Add(consts.UPLOAD_SESSION_EXPIRE),
+	}
+	data, _ := json.Marshal(session)
+	if err := redis.GetRedisClient().Set(context.Background(), uploadSessionKey(session.UploadID), data, consts.UPLOAD_SESSION_EXPIRE).Err(); err != nil {
+		log.Println("上传会话写入redis失败，错误为", err)
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "创建上传会话失败")
+	}
+	return session.toVO(nil), nil
+}
+
+// 上传单个分片，重复上传同一分片会覆盖之前的数据
+func (s *UploadSessionService) UploadPart(uploadId string, partNumber int, part *multipart.FileHeader, loginUser *entity.User) (*resPicture.UploadSessionVO, *ecode.ErrorWithCode) {
+	if part == nil {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "分片为空")
+	}
+	session, err := s.getSession(uploadId, loginUser)
+	if err != nil {
+		return nil, err
+	}
+	if partNumber < 1 || partNumber > session.PartCount {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "分片序号错误")
+	}
+	//除最后一片外，每片大小必须等于分片大小
+	expectSize := session.PartSize
+	if partNumber == session.PartCount {
+		expectSize = session.FileSize - session.PartSize*int64(session.PartCount-1)
+	}
+	if part.Size != expectSize {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, fmt.Sprintf("分片大小错误，应为%d字节", expectSize))
+	}
+	src, originErr := part.Open()
+	if originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "分片打开失败")
+	}
+	defer src.Close()
+	if originErr := storage.GetStore().PutObject(uploadPartObjectKey(uploadId, partNumber), src, "application/octet-stream"); originErr != nil {
+		log.Println("分片上传失败，错误为", originErr)
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "分片上传失败")
+	}
+	//记录已上传的分片，过期时间与会话一致
+	ctx := context.Background()
+	partsKey := uploadSessionPartsKey(uploadId)
+	if originErr := redis.GetRedisClient().SAdd(ctx, partsKey, partNumber).Err(); originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "记录分片失败")
+	}
+	redis.GetRedisClient().ExpireAt(ctx, partsKey, session.ExpireTime)
+	return s.GetUploadSession(uploadId, loginUser)
+}
+
+// 获取上传会话及已上传的分片，用于断点续传
+func (s *UploadSessionService) GetUploadSession(uploadId string, loginUser *entity.User) (*resPicture.UploadSessionVO, *ecode.ErrorWithCode) {
+	session, err := s.getSession(uploadId, loginUser)
+	if err != nil {
+		return nil, err
+	}
+	parts, err := s.getUploadedParts(uploadId)
+	if err != nil {
+		return nil, err
+	}
+	return session.toVO(parts), nil
+}
+
+// 完成分片上传，按序合并分片并走普通的图片上传流程
+func (s *UploadSessionService) CompleteUploadSession(uploadId string, loginUser *entity.User) (*resPicture.PictureVO, *ecode.ErrorWithCode) {
+	//加锁，防止重复提交导致图片重复入库，合并大文件耗时较长，处理期间定期续期
+	lock := redlock.GetRedSync().NewMutex(fmt.Sprintf("chg:upload:session:lock:%s", uploadId), redsync.WithExpiry(consts.UPLOAD_LOCK_EXPIRE))
+	if err := lock.Lock(); err != nil {
+		return nil, ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "上传会话正在处理中")
+	}
+	defer lock.Unlock()
+	defer redlock.KeepAlive(lock, consts.UPLOAD_LOCK_EXTEND_INTERVAL)()
+
+	session, err := s.getSession(uploadId, loginUser)
+	if err != nil {
+		return nil, err
+	}
+	parts, err := s.getUploadedParts(uploadId)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) != session.PartCount {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, fmt.Sprintf("分片未上传完成，已上传%d/%d", len(parts), session.PartCount))
+	}
+	reader := &partsReader{uploadId: uploadId, partCount: session.PartCount}
+	defer reader.Close()
+	picVO, err := NewPictureService().UploadPicture(&file.PictureStream{
+		Reader:   reader,
+		FileName: session.FileName,
+		Size:     session.FileSize,
+	}, &reqPicture.PictureUploadRequest{
+		ID:      session.PictureID,
+		SpaceID: session.SpaceID,
+	}, loginUser)
+	if err != nil {
+		return nil, err
+	}
+	s.cleanSession(session)
+	return picVO, nil
+}
+
+// 取消分片上传，删除已上传的分片
+func (s *UploadSessionService) AbortUploadSession(uploadId string, loginUser *entity.User) *ecode.ErrorWithCode {
+	lock := redlock.GetRedSync().NewMutex(fmt.Sprintf("chg:upload:session:lock:%s", uploadId))
+	if err := lock.Lock(); err != nil {
+		return ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "上传会话正在处理中")
+	}
+	defer lock.Unlock()
+
+	session, err := s.getSession(uploadId, loginUser)
+	if err != nil {
+		return err
+	}
+	s.cleanSession(session)
+	return nil
+}
+
+// 申请预签名直传，图片数据由客户端直接PUT到对象存储，不再经过服务端
+func (s *UploadSessionService) PresignUpload(req *reqPicture.PresignUploadRequest, loginUser *entity.User) (*resPicture.PresignUploadVO, *ecode.ErrorWithCode) {
+	if req.SpaceID == 0 {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "直传仅支持私有空间和团队空间")
+	}
+	if err := manager.ValidPictureType(req.FileName); err != nil {
+		return nil, err
+	}
+	if err := s.checkUploadLimit(req.SpaceID, req.FileSize, loginUser); err != nil {
+		return nil, err
+	}
+	fileType := req.FileName[strings.LastIndex(req.FileName, ".")+1:]
+	record := &presignUpload{
+		UploadID:   uuid.NewString(),
+		UserID:     loginUser.ID,
+		SpaceID:    req.SpaceID,
+		PictureID:  req.ID,
+		Key:        manager.GenUploadPath(fmt.Sprintf("space/%d", req.SpaceID), fileType),
+		FileSize:   req.FileSize,
+		ExpireTime: time.Now().Add(consts.UPLOAD_PRESIGN_EXPIRE),
+	}
+	uploadURL, originErr := storage.PresignPutObject(record.Key, consts.UPLOAD_PRESIGN_EXPIRE)
+	if errors.Is(originErr, storage.ErrPresignNotSupported) {
+		return nil, ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "当前存储不支持直传，请使用分片上传")
+	}
+	if originErr != nil {
+		log.Println("生成预签名URL失败，错误为", originErr)
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "生成上传地址失败")
+	}
+	data, _ := json.Marshal(record)
+	if err := redis.GetRedisClient().Set(context.Background(), presignUploadKey(record.UploadID), data, consts.UPLOAD_PRESIGN_CONFIRM_TTL).Err(); err != nil {
+		log.Println("直传记录写入redis失败，错误为", err)
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "生成上传地址失败")
+	}
+	return &resPicture.PresignUploadVO{
+		UploadID:   record.UploadID,
+		UploadURL:  uploadURL,
+		Method:     http.MethodPut,
+		Key:        record.Key,
+		ExpireTime: record.ExpireTime.UnixMilli(),
+	}, nil
+}
+
+// 确认直传完成，校验对象存在且大小一致后解析图片，并在同一事务中写入图片记录和空间额度
+func (s *UploadSessionService) ConfirmPresignUpload(uploadId string, loginUser *entity.User) (*resPicture.PictureVO, *ecode.ErrorWithCode) {
+	//解析和处理大图耗时较长，处理期间定期续期
+	lock := redlock.GetRedSync().NewMutex(fmt.Sprintf("chg:upload:presign:lock:%s", uploadId), redsync.WithExpiry(consts.UPLOAD_LOCK_EXPIRE))
+	if err := lock.Lock(); err != nil {
+		return nil, ecode.GetErrWithDetail(ecode.OPERATION_ERROR, "上传正在确认中")
+	}
+	defer lock.Unlock()
+	defer redlock.KeepAlive(lock, consts.UPLOAD_LOCK_EXTEND_INTERVAL)()
+
+	if uploadId == "" {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "上传ID为空")
+	}
+	ctx := context.Background()
+	data, originErr := redis.GetRedisClient().Get(ctx, presignUploadKey(uploadId)).Bytes()
+	if redis.IsNilErr(originErr) {
+		return nil, ecode.GetErrWithDetail(ecode.NOT_FOUND_ERROR, "上传记录不存在或已过期")
+	}
+	if originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "读取上传记录失败")
+	}
+	record := &presignUpload{}
+	if originErr := json.Unmarshal(data, record); originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "上传记录解析失败")
+	}
+	if record.UserID != loginUser.ID {
+		return nil, ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "无权访问该上传记录")
+	}
+	picVO, err := NewPictureService().UploadPicture(&file.PictureObject{
+		Key:  record.Key,
+		Size: record.FileSize,
+	}, &reqPicture.PictureUploadRequest{
+		ID:      record.PictureID,
+		SpaceID: record.SpaceID,
+	}, loginUser)
+	if err != nil {
+		return nil, err
+	}
+	redis.GetRedisClient().Del(ctx, presignUploadKey(uploadId))
+	return picVO, nil
+}
+
+// 校验单文件大小以及空间的上传权限和剩余额度
+// 公共图库使用统一的单文件限制，私有空间和团队空间按空间等级限制
+func (s *UploadSessionService) checkUploadLimit(spaceId uint64, fileSize int64, loginUser *entity.User) *ecode.ErrorWithCode {
+	if fileSize <= 0 {
+		return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "文件大小错误")
+	}
+	maxFileSize := int64(consts.UPLOAD_PUBLIC_MAX_FILE_SIZE)
+	if spaceId != 0 {
+		space, err := s.SpaceRepo.GetSpaceById(nil, spaceId)
+		if err != nil {
+			return ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "数据库异常")
+		}
+		if space == nil {
+			return ecode.GetErrWithDetail(ecode.NOT_FOUND_ERROR, "空间不存在")
+		}
+		if !slices.Contains(GetPermissionList(space, loginUser), "picture:upload") {
+			return ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "没有空间权限")
+		}
+		spaceLevel := consts.GetSpaceLevelByValue(space.SpaceLevel)
+		if spaceLevel == nil {
+			return ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "空间级别错误")
+		}
+		maxFileSize = spaceLevel.MaxFileSize
+		if space.TotalCount >= space.MaxCount {
+			return ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "空间图片数量已满")
+		}
+		if fileSize > space.MaxSize-space.TotalSize {
+			return ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "空间剩余额度不足")
+		}
+	}
+	if fileSize > maxFileSize {
+		return ecode.GetErrWithDetail(ecode.PARAMS_ERROR, fmt.Sprintf("文件过大，不能超过%dMB", maxFileSize/1024/1024))
+	}
+	return nil
+}
+
+// 获取上传会话，只允许创建者访问
+func (s *UploadSessionService) getSession(uploadId string, loginUser *entity.User) (*uploadSession, *ecode.ErrorWithCode) {
+	if uploadId == "" {
+		return nil, ecode.GetErrWithDetail(ecode.PARAMS_ERROR, "上传会话ID为空")
+	}
+	data, originErr := redis.GetRedisClient().Get(context.Background(), uploadSessionKey(uploadId)).Bytes()
+	if redis.IsNilErr(originErr) {
+		return nil, ecode.GetErrWithDetail(ecode.NOT_FOUND_ERROR, "上传会话不存在或已过期")
+	}
+	if originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "读取上传会话失败")
+	}
+	session := &uploadSession{}
+	if originErr := json.Unmarshal(data, session); originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "上传会话解析失败")
+	}
+	if session.UserID != loginUser.ID {
+		return nil, ecode.GetErrWithDetail(ecode.NO_AUTH_ERROR, "无权访问该上传会话")
+	}
+	return session, nil
+}
+
+// 获取已上传的分片序号，升序排列
+func (s *UploadSessionService) getUploadedParts(uploadId string) ([]int, *ecode.ErrorWithCode) {
+	members, originErr := redis.GetRedisClient().SMembers(context.Background(), uploadSessionPartsKey(uploadId)).Result()
+	if originErr != nil {
+		return nil, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "读取分片记录失败")
+	}
+	parts := make([]int, 0, len(members))
+	for _, m := range members {
+		if n, err := strconv.Atoi(m); err == nil {
+			parts = append(parts, n)
+		}
+	}
+	sort.Ints(parts)
+	return parts, nil
+}
+
+// 删除分片数据和会话记录，删除失败的分片留给存储回收任务处理
+func (s *UploadSessionService) cleanSession(session *uploadSession) {
+	store := storage.GetStore()
+	for i := 1; i <= session.PartCount; i++ {
+		if err := store.DeleteObject(uploadPartObjectKey(session.UploadID, i)); err != nil {
+			log.Println("分片删除失败，错误为", err)
+		}
+	}
+	redis.GetRedisClient().Del(context.Background(), uploadSessionKey(session.UploadID), uploadSessionPartsKey(session.UploadID))
+}
+
+func (session *uploadSession) toVO(parts []int) *resPicture.UploadSessionVO {
+	if parts == nil {
+		parts = []int{}
+	}
+	return &resPicture.UploadSessionVO{
+		UploadID:      session.UploadID,
+		FileName:      session.FileName,
+		FileSize:      session.FileSize,
+		PartSize:      session.PartSize,
+		PartCount:     session.PartCount,
+		UploadedParts: parts,
+		ExpireTime:    session.ExpireTime.UnixMilli(),
+	}
+}
+
+// partsReader 按序依次读取对象存储中的分片，避免一次性打开全部分片
+type partsReader struct {
+	uploadId  string
+	partCount int
+	next      int
+	cur       io.ReadCloser
+}
+
+func (r *partsReader) Read(p []byte) (int, error) {
+	for {
+		if r.cur == nil {
+			if r.next >= r.partCount {
+				return 0, io.EOF
+			}
+			r.next++
+			rc, err := storage.GetStore().GetObject(uploadPartObjectKey(r.uploadId, r.next))
+			if err != nil {
+				return 0, err
+			}
+			r.cur = rc
+		}
+		n, err := r.cur.Read(p)
+		if err == io.EOF {
+			r.cur.Close()
+			r.cur = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+// Seek 只支持回到开头，复用的内容失效需要重新上传时使用
+func (r *partsReader) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekStart {
+		return 0, errors.New("partsReader只支持回到开头")
+	}
+	if err := r.Close(); err != nil {
+		return 0, err
+	}
+	r.cur = nil
+	r.next = 0
+	return 0, nil
+}
+
+func (r *partsReader) Close() error {
+	if r.cur != nil {
+		return r.cur.Close()
+	}
+	return nil
+}
@@ -13,6 +13,7 @@ import (
 	"backend/pkg/jwt"
 	"backend/pkg/mysql"
 	"backend/pkg/redis"
+	"backend/pkg/scanner"
 	//"backend/pkg/session"
 	"context"
 	"fmt"
@@ -462,7 +463,7 @@ func (s *UserService) UploadAvatar(file *multipart.FileHeader, userId uint64) (b
 	//2.获取文件路径
 	//定义前缀
 	uploadPrefix := fmt.Sprintf("avatar/%d", userId)
-	result, err := manager.UploadPicture(file, uploadPrefix)
+	result, err := manager.UploadPicture(file, uploadPrefix, manager.UploadOptions{StripGPS: true, Scanner: scanner.Get()})
 	if err != nil {
 		return false, ecode.GetErrWithDetail(ecode.SYSTEM_ERROR, "文件上传失败")
 	}
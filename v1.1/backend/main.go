@@ -11,8 +11,9 @@ import (
 	"backend/pkg/mq"
 	"backend/pkg/mysql"
 	"backend/pkg/redis"
+	"backend/pkg/scanner"
 	"backend/pkg/snowflake"
-	"backend/pkg/tcos"
+	"backend/pkg/storage"
 	"backend/router"
 	"context"
 	"fmt"
@@ -83,11 +84,17 @@ func main() {
 	}
 	zap.L().Info("缓存初始化成功")
 
-	// 7. 初始化腾讯云COS
-	if err := tcos.Init(); err != nil {
-		zap.L().Fatal("COS初始化失败", zap.Error(err))
+	// 7. 初始化对象存储（COS / S3 / 本地文件系统）
+	if err := storage.Init(); err != nil {
+		zap.L().Fatal("对象存储初始化失败", zap.Error(err))
 	}
-	zap.L().Info("COS初始化成功")
+	zap.L().Info("对象存储初始化成功", zap.String("type", storage.GetStore().Type()))
+
+	// 7.1 初始化上传内容安全检查
+	if err := scanner.Init(); err != nil {
+		zap.L().Fatal("内容扫描初始化失败", zap.Error(err))
+	}
+	zap.L().Info("内容扫描初始化成功", zap.Bool("enabled", scanner.Get() != nil))
 
 	// 8. 初始化Casbin (必须在MySQL之后)
 	if _, err := casbin.InitCasbinGorm(mysql.LoadDB()); err != nil {
@@ -107,6 +114,19 @@ func main() {
 	go func() {
 		service.OutPaintingBackgroundService()
 	}()
+	// 启动存储回收任务，未开启时直接返回
+	go service.StorageGCBackgroundService()
+	// 启动回收站清理任务，彻底删除超过保留期的图片
+	go service.RecycleBinBackgroundService()
+	// 启动冷存储归档任务和归档恢复的消费者，归档未开启时只处理恢复
+	go service.ArchiveBackgroundService()
+	go service.ArchiveRestoreBackgroundService()
+	// 为历史图片补算感知哈希，用于本地以图搜图
+	go service.PictureHashBackfillService()
+	// 为历史图片提取调色板并建立颜色索引，用于按颜色搜索
+	go service.PictureColorBackfillService()
+	// 把历史图片的标签迁移到标签表，按标签筛选依赖迁移结果，在注册路由前完成，迁移过后启动时直接跳过
+	service.PictureTagMigrateService()
 
 	// 11. 注册路由
 	r := router.Setup(config.Conf.Mode)
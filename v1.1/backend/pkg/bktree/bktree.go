@@ -0,0 +1,98 @@
+// Package bktree 实现以汉明距离为度量的BK树，用于按感知哈希查找相似图片
+// 查询半径为r时，利用三角不等式只需访问与当前节点距离在[d-r, d+r]内的子树
+package bktree
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// Match 查询结果
+type Match struct {
+	ID       uint64
+	Hash     uint64
+	Distance int
+}
+
+type node struct {
+	hash     uint64
+	ids      []uint64 // 哈希完全相同的元素放在同一节点
+	children map[int]*node
+}
+
+// Tree BK树，非并发安全，构建完成后只读时可以并发查询
+type Tree struct {
+	root *node
+	size int
+}
+
+// New 创建空树
+func New() *Tree {
+	return &Tree{}
+}
+
+// Len 元素数量
+func (t *Tree) Len() int {
+	return t.size
+}
+
+// Add 添加一个元素
+func (t *Tree) Add(hash uint64, id uint64) {
+	t.size++
+	if t.root == nil {
+		t.root = &node{hash: hash, ids: []uint64{id}}
+		return
+	}
+	cur := t.root
+	for {
+		d := distance(cur.hash, hash)
+		if d == 0 {
+			cur.ids = append(cur.ids, id)
+			return
+		}
+		child, ok := cur.children[d]
+		if !ok {
+			if cur.children == nil {
+				cur.children = make(map[int]*node)
+			}
+			cur.children[d] = &node{hash: hash, ids: []uint64{id}}
+			return
+		}
+		cur = child
+	}
+}
+
+// Search 查找与hash距离不超过radius的元素，按距离升序、ID升序排列
+func (t *Tree) Search(hash uint64, radius int) []Match {
+	var result []Match
+	if t.root == nil {
+		return result
+	}
+	stack := []*node{t.root}
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		d := distance(cur.hash, hash)
+		if d <= radius {
+			for _, id := range cur.ids {
+				result = append(result, Match{ID: id, Hash: cur.hash, Distance: d})
+			}
+		}
+		for cd, child := range cur.children {
+			if cd >= d-radius && cd <= d+radius {
+				stack = append(stack, child)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Distance != result[j].Distance {
+			return result[i].Distance < result[j].Distance
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+func distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
@@ -0,0 +1,56 @@
+package bktree
+
+import (
+	"math/bits"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestSearchMatchesLinearScan(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	tree := New()
+	hashes := make([]uint64, 2000)
+	for i := range hashes {
+		hashes[i] = r.Uint64()
+		// 制造一部分彼此接近的哈希
+		if i%3 == 0 && i > 0 {
+			hashes[i] = hashes[i-1] ^ (1 << (i % 64))
+		}
+		tree.Add(hashes[i], uint64(i))
+	}
+	if tree.Len() != len(hashes) {
+		t.Fatalf("Len = %d", tree.Len())
+	}
+	for _, radius := range []int{0, 3, 10} {
+		query := hashes[r.IntN(len(hashes))]
+		want := 0
+		for _, h := range hashes {
+			if bits.OnesCount64(h^query) <= radius {
+				want++
+			}
+		}
+		got := tree.Search(query, radius)
+		if len(got) != want {
+			t.Fatalf("radius %d: got %d matches, want %d", radius, len(got), want)
+		}
+		for i := 1; i < len(got); i++ {
+			if got[i].Distance < got[i-1].Distance {
+				t.Fatal("结果未按距离排序")
+			}
+		}
+	}
+}
+
+func TestDuplicateHashes(t *testing.T) {
+	tree := New()
+	tree.Add(0xff, 2)
+	tree.Add(0xff, 1)
+	tree.Add(0xfe, 3)
+	got := tree.Search(0xff, 0)
+	if len(got) != 2 || got[0].ID != 1 || got[1].ID != 2 {
+		t.Fatalf("Search = %+v", got)
+	}
+	if len(New().Search(0, 64)) != 0 {
+		t.Fatal("空树应返回空结果")
+	}
+}
@@ -0,0 +1,194 @@
+// Package colorspace 提供颜色解析、sRGB到CIELAB的转换、CIEDE2000色差和颜色索引的分桶
+// ΔE2000 与人眼感知的色差更接近：约1为刚好可辨，2~10为相近，大于50时几乎是相反的颜色
+package colorspace
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// RGB 8位sRGB颜色
+type RGB struct {
+	R, G, B uint8
+}
+
+// Lab CIELAB颜色，D65白点，L范围0~100，a、b大约在-128~127之间
+type Lab struct {
+	L, A, B float64
+}
+
+// ParseHex 解析十六进制颜色，支持 #RRGGBB、0xRRGGBB、RRGGBB 和 #RGB，格式错误时返回错误
+func ParseHex(s string) (RGB, error) {
+	hex := strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(hex, "#"):
+		hex = hex[1:]
+	case strings.HasPrefix(hex, "0x"), strings.HasPrefix(hex, "0X"):
+		hex = hex[2:]
+	}
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return RGB{}, fmt.Errorf("颜色格式错误: %q", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return RGB{}, fmt.Errorf("颜色格式错误: %q", s)
+	}
+	return RGB{uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+}
+
+// Hex 格式化为 0xRRGGBB，与图片主色调的格式一致
+func (c RGB) Hex() string {
+	return fmt.Sprintf("0x%02X%02X%02X", c.R, c.G, c.B)
+}
+
+// Lab 转换为CIELAB
+func (c RGB) Lab() Lab {
+	r, g, b := linearize(c.R), linearize(c.G), linearize(c.B)
+	// sRGB -> XYZ (D65)，并按白点归一化
+	x := (0.4124564*r + 0.3575761*g + 0.1804375*b) / 0.95047
+	y := 0.2126729*r + 0.7151522*g + 0.0721750*b
+	z := (0.0193339*r + 0.1191920*g + 0.9503041*b) / 1.08883
+	fx, fy, fz := labF(x), labF(y), labF(z)
+	return Lab{L: 116*fy - 16, A: 500 * (fx - fy), B: 200 * (fy - fz)}
+}
+
+func linearize(v uint8) float64 {
+	c := float64(v) / 255
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+func labF(t float64) float64 {
+	const epsilon = 216.0 / 24389
+	const kappa = 24389.0 / 27
+	if t > epsilon {
+		return math.Cbrt(t)
+	}
+	return (kappa*t + 16) / 116
+}
+
+// Chroma 彩度
+func (c Lab) Chroma() float64 {
+	return math.Hypot(c.A, c.B)
+}
+
+// DeltaE2000 计算两个颜色的CIEDE2000色差，kL、kC、kH均取1
+func DeltaE2000(c1, c2 Lab) float64 {
+	const pow25To7 = 6103515625.0 // 25^7
+	rad := math.Pi / 180
+
+	cBar := (c1.Chroma() + c2.Chroma()) / 2
+	cBar7 := math.Pow(cBar, 7)
+	g := 0.5 * (1 - math.Sqrt(cBar7/(cBar7+pow25To7)))
+	a1p, a2p := (1+g)*c1.A, (1+g)*c2.A
+	c1p, c2p := math.Hypot(a1p, c1.B), math.Hypot(a2p, c2.B)
+	h1p, h2p := hueAngle(c1.B, a1p), hueAngle(c2.B, a2p)
+
+	dLp := c2.L - c1.L
+	dCp := c2p - c1p
+	var dhp float64
+	if c1p*c2p != 0 {
+		dhp = h2p - h1p
+		if dhp > 180 {
+			dhp -= 360
+		} else if dhp < -180 {
+			dhp += 360
+		}
+	}
+	dHp := 2 * math.Sqrt(c1p*c2p) * math.Sin(dhp/2*rad)
+
+	lBarp := (c1.L + c2.L) / 2
+	cBarp := (c1p + c2p) / 2
+	hBarp := h1p + h2p
+	if c1p*c2p != 0 {
+		if math.Abs(h1p-h2p) > 180 {
+			if hBarp < 360 {
+				hBarp += 360
+			} else {
+				hBarp -= 360
+			}
+		}
+		hBarp /= 2
+	}
+	t := 1 - 0.17*math.Cos((hBarp-30)*rad) + 0.24*math.Cos(2*hBarp*rad) +
+		0.32*math.Cos((3*hBarp+6)*rad) - 0.20*math.Cos((4*hBarp-63)*rad)
+	dTheta := 30 * math.Exp(-math.Pow((hBarp-275)/25, 2))
+	cBarp7 := math.Pow(cBarp, 7)
+	rc := 2 * math.Sqrt(cBarp7/(cBarp7+pow25To7))
+	l50 := (lBarp - 50) * (lBarp - 50)
+	sl := 1 + 0.015*l50/math.Sqrt(20+l50)
+	sc := 1 + 0.045*cBarp
+	sh := 1 + 0.015*cBarp*t
+	rt := -math.Sin(2*dTheta*rad) * rc
+
+	dl, dc, dh := dLp/sl, dCp/sc, dHp/sh
+	return math.Sqrt(dl*dl + dc*dc + dh*dh + rt*dc*dh)
+}
+
+func hueAngle(b, a float64) float64 {
+	if a == 0 && b == 0 {
+		return 0
+	}
+	h := math.Atan2(b, a) * 180 / math.Pi
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+// 分桶参数：按RGB空间划分为边长为bucketSize的立方体，共BucketCount个桶
+// ΔE2000与Lab空间中的欧氏距离相差很大（例如蓝色区域中欧氏距离超过100的两种颜色色差可能只有15），无法按距离估计附近的桶，
+// 因此直接计算目标颜色与每个桶中心的色差。桶内颜色与中心的色差最大为8（接近灰色时色相不连续），
+// ΔE2000不满足三角不等式，余量取10，阈值不超过20时随机测试中没有遗漏
+const (
+	bucketBits   = 3
+	bucketSize   = 1 << bucketBits
+	bucketsPerCh = 256 / bucketSize
+	bucketMargin = 10.0
+
+	// BucketCount 桶的总数
+	BucketCount = bucketsPerCh * bucketsPerCh * bucketsPerCh
+)
+
+// 每个桶中心颜色的Lab值
+var bucketCenters = func() []Lab {
+	centers := make([]Lab, BucketCount)
+	for i := range centers {
+		r, g, b := i/(bucketsPerCh*bucketsPerCh), i/bucketsPerCh%bucketsPerCh, i%bucketsPerCh
+		centers[i] = RGB{center(r), center(g), center(b)}.Lab()
+	}
+	return centers
+}()
+
+func center(i int) uint8 {
+	return uint8(i*bucketSize + bucketSize/2)
+}
+
+// Bucket 颜色所在的桶编号
+func Bucket(c RGB) int {
+	return (int(c.R)>>bucketBits*bucketsPerCh+int(c.G)>>bucketBits)*bucketsPerCh + int(c.B)>>bucketBits
+}
+
+// NearBuckets 可能包含与颜色的ΔE2000不超过threshold的颜色的桶，按编号递增排列，用于按颜色搜索时只读取这些桶中的索引
+func NearBuckets(c Lab, threshold float64) []int {
+	limit := threshold + bucketMargin
+	// ΔE2000不小于 |ΔL|/S_L，而S_L不超过1.75，亮度相差过大的桶不需要计算色差
+	maxDL := limit * 1.75
+	var buckets []int
+	for i, center := range bucketCenters {
+		if math.Abs(center.L-c.L) > maxDL {
+			continue
+		}
+		if DeltaE2000(c, center) <= limit {
+			buckets = append(buckets, i)
+		}
+	}
+	return buckets
+}
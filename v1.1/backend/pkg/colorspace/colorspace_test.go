@@ -0,0 +1,113 @@
+package colorspace
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestParseHex(t *testing.T) {
+	cases := map[string]RGB{
+		"#FF0000":  {255, 0, 0},
+		"0x736246": {0x73, 0x62, 0x46},
+		"00ff7f":   {0, 255, 127},
+		"#abc":     {0xaa, 0xbb, 0xcc},
+	}
+	for s, want := range cases {
+		got, err := ParseHex(s)
+		if err != nil || got != want {
+			t.Errorf("ParseHex(%q) = %v, %v", s, got, err)
+		}
+	}
+	for _, s := range []string{"", "#12345", "0xGG0000", "red", "#1234567", "0x"} {
+		if _, err := ParseHex(s); err == nil {
+			t.Errorf("ParseHex(%q) 应返回错误", s)
+		}
+	}
+	if (RGB{0x73, 0x62, 0x46}).Hex() != "0x736246" {
+		t.Fatal("Hex格式错误")
+	}
+}
+
+// Sharma 等人给出的CIEDE2000测试数据
+func TestDeltaE2000(t *testing.T) {
+	cases := []struct {
+		a, b Lab
+		want float64
+	}{
+		{Lab{50, 2.6772, -79.7751}, Lab{50, 0, -82.7485}, 2.0425},
+		{Lab{50, -1.3802, -84.2814}, Lab{50, 0, -82.7485}, 1.0000},
+		{Lab{50, 0, 0}, Lab{50, -1, 2}, 2.3669},
+		{Lab{50, 2.49, -0.001}, Lab{50, -2.49, 0.0009}, 7.1792},
+		{Lab{50, 2.5, 0}, Lab{73, 25, -18}, 27.1492},
+		{Lab{60.2574, -34.0099, 36.2677}, Lab{60.4626, -34.1751, 39.4387}, 1.2644},
+		{Lab{22.7233, 20.0904, -46.694}, Lab{23.0331, 14.973, -42.5619}, 2.0373},
+		{Lab{90.9257, -0.5406, -0.9208}, Lab{88.6381, -0.8985, -0.7239}, 1.5381},
+	}
+	for _, c := range cases {
+		if got := DeltaE2000(c.a, c.b); math.Abs(got-c.want) > 1e-4 {
+			t.Errorf("DeltaE2000(%v, %v) = %.4f, want %.4f", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestLab(t *testing.T) {
+	white := RGB{255, 255, 255}.Lab()
+	if math.Abs(white.L-100) > 0.01 || math.Abs(white.A) > 0.01 || math.Abs(white.B) > 0.01 {
+		t.Fatalf("白色的Lab错误: %+v", white)
+	}
+	red := RGB{255, 0, 0}.Lab()
+	if math.Abs(red.L-53.24) > 0.05 || math.Abs(red.A-80.09) > 0.05 || math.Abs(red.B-67.20) > 0.05 {
+		t.Fatalf("红色的Lab错误: %+v", red)
+	}
+}
+
+// 桶内颜色与桶中心的色差不超过bucketMargin
+func TestBucketMargin(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < 200000; i++ {
+		c := RGB{uint8(r.IntN(256)), uint8(r.IntN(256)), uint8(r.IntN(256))}
+		if d := DeltaE2000(c.Lab(), bucketCenters[Bucket(c)]); d > bucketMargin {
+			t.Fatalf("%v 与所在桶中心的色差为 %.2f", c, d)
+		}
+	}
+	if Bucket(RGB{0, 0, 0}) != 0 || Bucket(RGB{255, 255, 255}) != BucketCount-1 {
+		t.Fatal("桶编号错误")
+	}
+}
+
+// 随机颜色对中ΔE2000不超过阈值的，候选桶中必须包含对方所在的桶
+func TestNearBucketsCoverDeltaE(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	randomRGB := func() RGB { return RGB{uint8(r.IntN(256)), uint8(r.IntN(256)), uint8(r.IntN(256))} }
+	offset := func(v uint8, spread float64) uint8 {
+		return uint8(min(max(int(v)+int(r.NormFloat64()*spread), 0), 255))
+	}
+	for _, threshold := range []float64{5, 10, 20} {
+		checked := 0
+		for i := 0; i < 200; i++ {
+			query := randomRGB()
+			q := query.Lab()
+			buckets := NearBuckets(q, threshold)
+			if len(buckets) == 0 || len(buckets) == BucketCount {
+				t.Fatalf("threshold %.0f: %v 的候选桶数量为 %d", threshold, query, len(buckets))
+			}
+			// 在查询颜色附近随机取点，提高命中阈值的比例
+			for j := 0; j < 500; j++ {
+				spread := threshold * 3
+				candidate := RGB{offset(query.R, spread), offset(query.G, spread), offset(query.B, spread)}
+				if DeltaE2000(q, candidate.Lab()) > threshold {
+					continue
+				}
+				checked++
+				if _, ok := slices.BinarySearch(buckets, Bucket(candidate)); !ok {
+					t.Fatalf("threshold %.0f: %v 与 %v 的色差为 %.2f，但不在候选桶中", threshold, query, candidate, DeltaE2000(q, candidate.Lab()))
+				}
+			}
+		}
+		if checked == 0 {
+			t.Fatalf("threshold %.0f: 没有检查任何颜色对", threshold)
+		}
+	}
+}
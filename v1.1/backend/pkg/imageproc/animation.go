@@ -0,0 +1,332 @@
+package imageproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"io"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/webp"
+)
+
+// 动图支持：解码GIF和WebP动图的全部帧，并将其编码为WebP动图
+// 解码得到的每一帧都是合成后的完整画布，编码时不再做帧间优化
+
+const (
+	MaxAnimationFrames = 500        // 动图最大帧数
+	MaxAnimationPixels = 50_000_000 // 所有帧像素数之和的上限，避免解码占用过多内存
+	minFrameDelay      = 20         // 帧时长下限（毫秒），与浏览器行为一致，更短的按默认值显示
+	defaultFrameDelay  = 100        // 未设置或过短时的帧时长（毫秒）
+)
+
+// Frame 动图中的一帧
+type Frame struct {
+	Image image.Image // 合成后的完整画布
+	Delay int         // 显示时长，单位毫秒
+}
+
+// Animation 多帧图片
+type Animation struct {
+	Frames    []Frame
+	LoopCount int // 循环次数，0表示无限循环
+}
+
+// MapFrames 对每一帧做相同的处理，返回新的动图
+func (a *Animation) MapFrames(fn func(image.Image) image.Image) *Animation {
+	frames := make([]Frame, len(a.Frames))
+	for i, f := range a.Frames {
+		frames[i] = Frame{Image: fn(f.Image), Delay: f.Delay}
+	}
+	return &Animation{Frames: frames, LoopCount: a.LoopCount}
+}
+
+// DecodeAnimation 解码GIF或WebP动图的全部帧，静态图片（包括只有一帧的动图）返回nil
+func DecodeAnimation(data []byte) (*Animation, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		return decodeGIFAnimation(data)
+	case isAnimatedWebP(data):
+		return decodeWebPAnimation(data, MaxAnimationFrames)
+	}
+	return nil, nil
+}
+
+// EncodeAnimatedWebP 将动图编码为无损WebP动图
+func EncodeAnimatedWebP(w io.Writer, anim *Animation) error {
+	if anim == nil || len(anim.Frames) == 0 {
+		return fmt.Errorf("动图没有帧")
+	}
+	bounds := anim.Frames[0].Image.Bounds()
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+	//VP8X：动画和透明标记，画布尺寸
+	vp8x := make([]byte, 10)
+	vp8x[0] = 0x02 | 0x10
+	putUint24(vp8x[4:], bounds.Dx()-1)
+	putUint24(vp8x[7:], bounds.Dy()-1)
+	writeRIFFChunk(&body, "VP8X", vp8x)
+	//ANIM：透明背景色和循环次数
+	animChunk := make([]byte, 6)
+	binary.LittleEndian.PutUint16(animChunk[4:], uint16(min(max(anim.LoopCount, 0), 0xFFFF)))
+	writeRIFFChunk(&body, "ANIM", animChunk)
+	for i, f := range anim.Frames {
+		if f.Image.Bounds().Size() != bounds.Size() {
+			return fmt.Errorf("第%d帧尺寸与画布不一致", i+1)
+		}
+		var buf bytes.Buffer
+		if err := nativewebp.Encode(&buf, f.Image, nil); err != nil {
+			return err
+		}
+		bitstream, ok := findRIFFChunk(buf.Bytes(), "VP8L")
+		if !ok {
+			return fmt.Errorf("第%d帧编码结果缺少VP8L数据", i+1)
+		}
+		//ANMF：帧位置、尺寸、时长，帧为完整画布，不与上一帧混合
+		var frame bytes.Buffer
+		header := make([]byte, 16)
+		putUint24(header[6:], bounds.Dx()-1)
+		putUint24(header[9:], bounds.Dy()-1)
+		putUint24(header[12:], min(max(f.Delay, 0), 0xFFFFFF))
+		header[15] = 0x02
+		frame.Write(header)
+		writeRIFFChunk(&frame, "VP8L", bitstream)
+		writeRIFFChunk(&body, "ANMF", frame.Bytes())
+	}
+	header := make([]byte, 8)
+	copy(header, "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(body.Len()))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(body.Bytes())
+	return err
+}
+
+func decodeGIFAnimation(data []byte) (*Animation, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("图片解码失败: %w", err)
+	}
+	if len(g.Image) <= 1 {
+		return nil, nil
+	}
+	width, height := g.Config.Width, g.Config.Height
+	if width == 0 || height == 0 {
+		b := g.Image[0].Bounds()
+		width, height = b.Max.X, b.Max.Y
+	}
+	if err := checkAnimationSize(width, height, len(g.Image)); err != nil {
+		return nil, err
+	}
+	canvas := image.NewNRGBA(image.Rect(0, 0, width, height))
+	anim := &Animation{Frames: make([]Frame, 0, len(g.Image))}
+	//GIF的LoopCount为重复次数，-1表示只播放一次
+	switch {
+	case g.LoopCount < 0:
+		anim.LoopCount = 1
+	case g.LoopCount > 0:
+		anim.LoopCount = g.LoopCount + 1
+	}
+	for i, frame := range g.Image {
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		var previous *image.NRGBA
+		if disposal == gif.DisposalPrevious {
+			previous = cloneNRGBA(canvas)
+		}
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		delay := 0
+		if i < len(g.Delay) {
+			delay = g.Delay[i] * 10
+		}
+		anim.Frames = append(anim.Frames, Frame{Image: cloneNRGBA(canvas), Delay: normalizeDelay(delay)})
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return anim, nil
+}
+
+// isAnimatedWebP 判断是否为带动画标记的WebP扩展格式
+func isAnimatedWebP(data []byte) bool {
+	vp8x, ok := findRIFFChunk(data, "VP8X")
+	return ok && len(vp8x) >= 10 && vp8x[0]&0x02 != 0
+}
+
+// decodeWebPAnimation 解码WebP动图的前limit帧
+func decodeWebPAnimation(data []byte, limit int) (*Animation, error) {
+	vp8x, _ := findRIFFChunk(data, "VP8X")
+	width, height := int(uint24(vp8x[4:]))+1, int(uint24(vp8x[7:]))+1
+	canvas := image.NewNRGBA(image.Rect(0, 0, width, height))
+	anim := &Animation{}
+	var dispose image.Rectangle
+	err := walkRIFFChunks(data, func(chunkType string, payload []byte) (bool, error) {
+		switch chunkType {
+		case "ANIM":
+			if len(payload) >= 6 {
+				anim.LoopCount = int(binary.LittleEndian.Uint16(payload[4:]))
+			}
+		case "ANMF":
+			if len(payload) < 16 {
+				return false, fmt.Errorf("WebP动图帧数据不完整")
+			}
+			if err := checkAnimationSize(width, height, len(anim.Frames)+1); err != nil {
+				return false, err
+			}
+			x, y := int(uint24(payload))*2, int(uint24(payload[3:]))*2
+			w, h := int(uint24(payload[6:]))+1, int(uint24(payload[9:]))+1
+			flags := payload[15]
+			frame, err := decodeWebPFrame(payload[16:], w, h)
+			if err != nil {
+				return false, err
+			}
+			//上一帧要求显示后清除所在区域
+			if !dispose.Empty() {
+				draw.Draw(canvas, dispose, image.Transparent, image.Point{}, draw.Src)
+			}
+			rect := image.Rect(x, y, x+w, y+h)
+			op := draw.Over
+			if flags&0x02 != 0 {
+				op = draw.Src
+			}
+			draw.Draw(canvas, rect, frame, frame.Bounds().Min, op)
+			anim.Frames = append(anim.Frames, Frame{Image: cloneNRGBA(canvas), Delay: normalizeDelay(int(uint24(payload[12:])))})
+			dispose = image.Rectangle{}
+			if flags&0x01 != 0 {
+				dispose = rect
+			}
+			return len(anim.Frames) < limit, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(anim.Frames) == 0 {
+		return nil, fmt.Errorf("WebP动图没有帧")
+	}
+	if len(anim.Frames) == 1 && limit > 1 {
+		return nil, nil
+	}
+	return anim, nil
+}
+
+// decodeWebPFrame 将ANMF中的帧数据包装为独立的WebP文件后解码
+func decodeWebPFrame(frameData []byte, width, height int) (image.Image, error) {
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+	if _, ok := findChunkIn(frameData, "ALPH"); ok {
+		//有独立透明通道的有损帧需要VP8X头
+		vp8x := make([]byte, 10)
+		vp8x[0] = 0x10
+		putUint24(vp8x[4:], width-1)
+		putUint24(vp8x[7:], height-1)
+		writeRIFFChunk(&body, "VP8X", vp8x)
+	}
+	body.Write(frameData)
+	var file bytes.Buffer
+	file.WriteString("RIFF")
+	_ = binary.Write(&file, binary.LittleEndian, uint32(body.Len()))
+	file.Write(body.Bytes())
+	img, err := webp.Decode(&file)
+	if err != nil {
+		return nil, fmt.Errorf("WebP动图帧解码失败: %w", err)
+	}
+	return img, nil
+}
+
+func checkAnimationSize(width, height, frames int) error {
+	if frames > MaxAnimationFrames {
+		return fmt.Errorf("动图帧数超过%d", MaxAnimationFrames)
+	}
+	if int64(width)*int64(height)*int64(frames) > MaxAnimationPixels {
+		return fmt.Errorf("动图尺寸过大")
+	}
+	return nil
+}
+
+func normalizeDelay(delay int) int {
+	if delay < minFrameDelay {
+		return defaultFrameDelay
+	}
+	return delay
+}
+
+func cloneNRGBA(src *image.NRGBA) *image.NRGBA {
+	dst := image.NewNRGBA(src.Rect)
+	copy(dst.Pix, src.Pix)
+	return dst
+}
+
+// walkRIFFChunks 依次遍历WebP文件中的块，fn返回false时停止
+func walkRIFFChunks(data []byte, fn func(chunkType string, payload []byte) (bool, error)) error {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return fmt.Errorf("不是WebP文件")
+	}
+	return walkChunks(data[12:], fn)
+}
+
+func walkChunks(data []byte, fn func(chunkType string, payload []byte) (bool, error)) error {
+	i := 0
+	for i+8 <= len(data) {
+		chunkType := string(data[i : i+4])
+		length := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + length
+		if length < 0 || end > len(data) {
+			return fmt.Errorf("WebP块 %s 数据不完整", chunkType)
+		}
+		next, err := fn(chunkType, data[i+8:end])
+		if err != nil || !next {
+			return err
+		}
+		i = end + length%2
+	}
+	return nil
+}
+
+// findRIFFChunk 查找WebP文件中第一个指定类型的块
+func findRIFFChunk(data []byte, chunkType string) ([]byte, bool) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, false
+	}
+	return findChunkIn(data[12:], chunkType)
+}
+
+func findChunkIn(data []byte, chunkType string) ([]byte, bool) {
+	var found []byte
+	ok := false
+	_ = walkChunks(data, func(t string, payload []byte) (bool, error) {
+		if t == chunkType {
+			found, ok = payload, true
+			return false, nil
+		}
+		return true, nil
+	})
+	return found, ok
+}
+
+func writeRIFFChunk(w *bytes.Buffer, chunkType string, payload []byte) {
+	w.WriteString(chunkType)
+	_ = binary.Write(w, binary.LittleEndian, uint32(len(payload)))
+	w.Write(payload)
+	if len(payload)%2 == 1 {
+		w.WriteByte(0)
+	}
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+func putUint24(b []byte, v int) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
@@ -0,0 +1,74 @@
+package imageproc
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+)
+
+func TestAnimation(t *testing.T) {
+	colors := []color.RGBA{{R: 0xFF, A: 0xFF}, {G: 0xFF, A: 0xFF}, {B: 0xFF, A: 0xFF}}
+	pal := color.Palette{color.Transparent, colors[0], colors[1], colors[2]}
+	g := &gif.GIF{LoopCount: 0}
+	for i := range colors {
+		frame := image.NewPaletted(image.Rect(0, 0, 40, 20), pal)
+		for j := range frame.Pix {
+			frame.Pix[j] = uint8(i + 1)
+		}
+		g.Image = append(g.Image, frame)
+		g.Delay = append(g.Delay, 5)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Process(buf.Bytes(), "gif", RenditionSpec{Name: "w20", Width: 20, Format: "webp"},
+		RenditionSpec{Name: "w20j", Width: 20, Format: "jpg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Info.FrameCount != 3 || res.Info.Width != 40 || res.Info.Height != 20 {
+		t.Fatalf("图片信息错误: %+v", res.Info)
+	}
+	// 主图保留动画，帧时长和颜色不变
+	anim, err := DecodeAnimation(res.WebP)
+	if err != nil || anim == nil {
+		t.Fatalf("主图不是动图: %v", err)
+	}
+	for i, f := range anim.Frames {
+		if f.Delay != 50 {
+			t.Fatalf("第%d帧时长错误: %d", i+1, f.Delay)
+		}
+		if got := color.RGBAModel.Convert(f.Image.At(10, 10)); got != colors[i] {
+			t.Fatalf("第%d帧颜色错误: %v", i+1, got)
+		}
+	}
+	// webp衍生版本保留动画，其他格式为静态图
+	if a, _ := DecodeAnimation(res.Renditions[0].Data); a == nil || len(a.Frames) != 3 || res.Renditions[0].Width != 20 {
+		t.Fatal("webp衍生版本应为动图")
+	}
+	// 缩略图为第一帧
+	thumb, err := gif.Decode(bytes.NewReader(res.Thumbnail))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, _, _ := thumb.At(5, 5).RGBA(); r>>8 != 0xFF {
+		t.Fatal("缩略图应为第一帧")
+	}
+	// WebP动图可以直接作为原图解码
+	first, err := DecodeOriented(res.WebP)
+	if err != nil || first.Bounds().Dx() != 40 {
+		t.Fatalf("解码WebP动图第一帧失败: %v", err)
+	}
+
+	// 静态图片不是动图
+	var static bytes.Buffer
+	_ = png.Encode(&static, image.NewNRGBA(image.Rect(0, 0, 4, 4)))
+	if a, err := DecodeAnimation(static.Bytes()); a != nil || err != nil {
+		t.Fatal("静态图片不应识别为动图")
+	}
+}
@@ -0,0 +1,639 @@
+package imageproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"math"
+	"strings"
+	"time"
+)
+
+// EXIF元数据解析，支持JPEG(APP1)、PNG(eXIf块)和WebP(EXIF块)中的TIFF格式元数据
+// 只读取展示和检索需要的字段，格式错误时按没有元数据处理
+
+// Metadata 图片的EXIF元数据
+type Metadata struct {
+	Make         string     // 相机厂商
+	Model        string     // 相机型号
+	LensModel    string     // 镜头型号
+	ExposureTime string     // 曝光时间，例如 1/125
+	FNumber      float64    // 光圈值
+	ISO          int        // 感光度
+	FocalLength  float64    // 焦距，单位mm
+	TakenAt      *time.Time // 拍摄时间
+	Latitude     *float64   // 纬度，南纬为负
+	Longitude    *float64   // 经度，西经为负
+	Orientation  int        // 方向[1,8]，0表示未记录
+}
+
+// EXIF标签
+const (
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagExposureTime     = 0x829A
+	tagFNumber          = 0x829D
+	tagISO              = 0x8827
+	tagDateTimeOriginal = 0x9003
+	tagFocalLength      = 0x920A
+	tagLensModel        = 0xA434
+	tagGPSLatitudeRef   = 0x0001
+	tagGPSLatitude      = 0x0002
+	tagGPSLongitudeRef  = 0x0003
+	tagGPSLongitude     = 0x0004
+)
+
+// 每种TIFF数据类型单个值占用的字节数
+var tiffTypeSize = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+// exif块在文件中的位置，crcAt大于等于0时表示PNG块的CRC位置，修改后需要重新计算
+type exifBlock struct {
+	start, end int
+	crcAt      int
+}
+
+type ifdEntry struct {
+	tag    uint16
+	typ    uint16
+	count  uint32
+	offset int // 值在TIFF数据中的位置
+	size   int
+}
+
+type tiffReader struct {
+	b  []byte
+	bo binary.ByteOrder
+}
+
+// ReadMetadata 读取图片中的EXIF元数据，没有元数据时返回nil
+func ReadMetadata(data []byte) *Metadata {
+	block, ok := findExif(data)
+	if !ok {
+		return nil
+	}
+	t, ifd0, ok := newTIFFReader(data[block.start:block.end])
+	if !ok {
+		return nil
+	}
+	entries, _, ok := t.readIFD(ifd0)
+	if !ok {
+		return nil
+	}
+	meta := &Metadata{}
+	var dateTime string
+	for _, e := range entries {
+		switch e.tag {
+		case tagMake:
+			meta.Make = t.ascii(e)
+		case tagModel:
+			meta.Model = t.ascii(e)
+		case tagOrientation:
+			if o := int(t.uint(e)); o >= 1 && o <= 8 {
+				meta.Orientation = o
+			}
+		case tagDateTime:
+			dateTime = t.ascii(e)
+		case tagExifIFD:
+			t.readExifIFD(uint32(t.uint(e)), meta)
+		case tagGPSIFD:
+			t.readGPSIFD(uint32(t.uint(e)), meta)
+		}
+	}
+	if meta.TakenAt == nil && dateTime != "" {
+		meta.TakenAt = parseExifTime(dateTime)
+	}
+	return meta
+}
+
+// StripGPS 去除图片中EXIF的GPS信息，返回新的数据，没有GPS信息时返回原数据和false
+// 只清空GPS目录的内容，不改变文件长度和其他元数据
+func StripGPS(data []byte) ([]byte, bool) {
+	block, ok := findExif(data)
+	if !ok {
+		return data, false
+	}
+	t, ifd0, ok := newTIFFReader(data[block.start:block.end])
+	if !ok {
+		return data, false
+	}
+	entries, _, ok := t.readIFD(ifd0)
+	if !ok {
+		return data, false
+	}
+	var gpsOffset uint32
+	for _, e := range entries {
+		if e.tag == tagGPSIFD {
+			gpsOffset = uint32(t.uint(e))
+		}
+	}
+	if gpsOffset == 0 {
+		return data, false
+	}
+	gpsEntries, entriesStart, ok := t.readIFD(gpsOffset)
+	if !ok || len(gpsEntries) == 0 {
+		return data, false
+	}
+	stripped := bytes.Clone(data)
+	tiff := stripped[block.start:block.end]
+	// 清空放在目录外的值、目录项，并把目录项数量置为0
+	for _, e := range gpsEntries {
+		clear(tiff[e.offset : e.offset+e.size])
+	}
+	clear(tiff[entriesStart : entriesStart+len(gpsEntries)*12])
+	t.bo.PutUint16(tiff[gpsOffset:], 0)
+	if block.crcAt >= 0 {
+		crc := crc32.ChecksumIEEE(stripped[block.start-4 : block.end])
+		binary.BigEndian.PutUint32(stripped[block.crcAt:], crc)
+	}
+	return stripped, true
+}
+
+// ApplyOrientation 按EXIF方向旋转或翻转图片，使其按正常方向显示
+func ApplyOrientation(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	bounds := img.Bounds()
+	src := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+	w, h := bounds.Dx(), bounds.Dy()
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // 水平翻转
+				dx, dy = w-1-x, y
+			case 3: // 旋转180度
+				dx, dy = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				dx, dy = x, h-1-y
+			case 5: // 沿左上-右下对角线翻转
+				dx, dy = y, x
+			case 6: // 顺时针旋转90度
+				dx, dy = h-1-y, x
+			case 7: // 沿右上-左下对角线翻转
+				dx, dy = h-1-y, w-1-x
+			case 8: // 逆时针旋转90度
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], src.Pix[src.PixOffset(x, y):src.PixOffset(x, y)+4])
+		}
+	}
+	return dst
+}
+
+// DecodeOriented 解码图片并按EXIF方向摆正，动图返回第一帧
+func DecodeOriented(data []byte) (image.Image, error) {
+	if isAnimatedWebP(data) {
+		anim, err := decodeWebPAnimation(data, 1)
+		if err != nil {
+			return nil, err
+		}
+		return anim.Frames[0].Image, nil
+	}
+	img, err := decodeData(data)
+	if err != nil {
+		return nil, err
+	}
+	if meta := ReadMetadata(data); meta != nil {
+		img = ApplyOrientation(img, meta.Orientation)
+	}
+	return img, nil
+}
+
+// findExif 在文件中查找TIFF格式的EXIF数据
+func findExif(data []byte) (exifBlock, bool) {
+	switch {
+	case len(data) > 4 && data[0] == 0xFF && data[1] == 0xD8:
+		return findJPEGExif(data)
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return findPNGExif(data)
+	case len(data) > 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return findWebPExif(data)
+	case bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*")):
+		// TIFF文件本身就是TIFF结构
+		return exifBlock{start: 0, end: len(data), crcAt: -1}, true
+	case len(data) > 12 && string(data[4:8]) == "ftyp":
+		return findHEIFExif(data)
+	}
+	return exifBlock{}, false
+}
+
+func findJPEGExif(data []byte) (exifBlock, bool) {
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			break
+		}
+		marker := data[i+1]
+		// 填充字节和没有长度的标记
+		if marker == 0xFF {
+			i++
+			continue
+		}
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD8) {
+			i += 2
+			continue
+		}
+		// 图像数据开始，之后不会再有元数据
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+		segLen := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + segLen
+		if segLen < 2 || end > len(data) {
+			break
+		}
+		seg := data[i+4 : end]
+		if marker == 0xE1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return exifBlock{start: i + 4 + 6, end: end, crcAt: -1}, true
+		}
+		i = end
+	}
+	return exifBlock{}, false
+}
+
+func findPNGExif(data []byte) (exifBlock, bool) {
+	i := 8
+	for i+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		chunkType := string(data[i+4 : i+8])
+		end := i + 8 + length
+		if length < 0 || end+4 > len(data) {
+			break
+		}
+		if chunkType == "eXIf" {
+			return exifBlock{start: i + 8, end: end, crcAt: end}, true
+		}
+		if chunkType == "IEND" {
+			break
+		}
+		i = end + 4
+	}
+	return exifBlock{}, false
+}
+
+func findWebPExif(data []byte) (exifBlock, bool) {
+	i := 12
+	for i+8 <= len(data) {
+		chunkType := string(data[i : i+4])
+		length := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + length
+		if length < 0 || end > len(data) {
+			break
+		}
+		if chunkType == "EXIF" {
+			start := i + 8
+			// 部分编码器会保留JPEG中的Exif前缀
+			if bytes.HasPrefix(data[start:end], []byte("Exif\x00\x00")) {
+				start += 6
+			}
+			return exifBlock{start: start, end: end, crcAt: -1}, true
+		}
+		i = end + length%2
+	}
+	return exifBlock{}, false
+}
+
+// findHEIFExif 在HEIC/AVIF的meta盒中查找类型为Exif的条目，并通过iloc定位其数据
+func findHEIFExif(data []byte) (exifBlock, bool) {
+	metaStart, metaEnd, ok := findBox(data, 0, len(data), "meta")
+	// meta是FullBox，子盒从版本和标记之后开始
+	if !ok || metaEnd-metaStart < 4 {
+		return exifBlock{}, false
+	}
+	iinfStart, iinfEnd, ok := findBox(data, metaStart+4, metaEnd, "iinf")
+	if !ok {
+		return exifBlock{}, false
+	}
+	itemID, ok := findExifItemID(data[iinfStart:iinfEnd])
+	if !ok {
+		return exifBlock{}, false
+	}
+	ilocStart, ilocEnd, ok := findBox(data, metaStart+4, metaEnd, "iloc")
+	if !ok {
+		return exifBlock{}, false
+	}
+	offset, length, ok := findItemLocation(data[ilocStart:ilocEnd], itemID)
+	if !ok || length < 4 || offset+length > uint64(len(data)) {
+		return exifBlock{}, false
+	}
+	// 条目数据以4字节的TIFF头偏移开始，之后通常是Exif前缀
+	start := offset + 4 + uint64(binary.BigEndian.Uint32(data[offset:]))
+	end := offset + length
+	if start >= end {
+		return exifBlock{}, false
+	}
+	return exifBlock{start: int(start), end: int(end), crcAt: -1}, true
+}
+
+// findBox 在data[from:to]这一层级的盒中查找指定类型，返回盒内容的起止位置
+func findBox(data []byte, from, to int, boxType string) (int, int, bool) {
+	i := from
+	for i+8 <= to {
+		size := uint64(binary.BigEndian.Uint32(data[i:]))
+		header := 8
+		switch size {
+		case 0:
+			size = uint64(to - i)
+		case 1:
+			if i+16 > to {
+				return 0, 0, false
+			}
+			size = binary.BigEndian.Uint64(data[i+8:])
+			header = 16
+		}
+		if size < uint64(header) || size > uint64(to-i) {
+			return 0, 0, false
+		}
+		if string(data[i+4:i+8]) == boxType {
+			return i + header, i + int(size), true
+		}
+		i += int(size)
+	}
+	return 0, 0, false
+}
+
+// findExifItemID 在iinf中查找类型为Exif的条目ID
+func findExifItemID(iinf []byte) (uint32, bool) {
+	if len(iinf) < 6 {
+		return 0, false
+	}
+	entries := iinf[6:]
+	if iinf[0] != 0 {
+		entries = iinf[8:]
+	}
+	for len(entries) >= 8 {
+		size := int(binary.BigEndian.Uint32(entries))
+		if size < 8 || size > len(entries) {
+			return 0, false
+		}
+		infe := entries[8:size]
+		if string(entries[4:8]) == "infe" && len(infe) >= 4 {
+			switch version := infe[0]; {
+			case version == 2 && len(infe) >= 12 && string(infe[8:12]) == "Exif":
+				return uint32(binary.BigEndian.Uint16(infe[4:])), true
+			case version == 3 && len(infe) >= 14 && string(infe[10:14]) == "Exif":
+				return binary.BigEndian.Uint32(infe[4:]), true
+			}
+		}
+		entries = entries[size:]
+	}
+	return 0, false
+}
+
+// findItemLocation 在iloc中查找条目的第一个数据区间，只支持以文件偏移定位的条目
+func findItemLocation(iloc []byte, itemID uint32) (uint64, uint64, bool) {
+	if len(iloc) < 8 {
+		return 0, 0, false
+	}
+	version := iloc[0]
+	offsetSize, lengthSize := int(iloc[4]>>4), int(iloc[4]&0x0F)
+	baseOffsetSize, indexSize := int(iloc[5]>>4), 0
+	if version == 1 || version == 2 {
+		indexSize = int(iloc[5] & 0x0F)
+	}
+	p := 6
+	readN := func(n int) (uint64, bool) {
+		if p+n > len(iloc) {
+			return 0, false
+		}
+		var v uint64
+		for _, c := range iloc[p : p+n] {
+			v = v<<8 | uint64(c)
+		}
+		p += n
+		return v, true
+	}
+	idSize := 2
+	if version == 2 {
+		idSize = 4
+	}
+	count, ok := readN(idSize)
+	if !ok {
+		return 0, 0, false
+	}
+	for range count {
+		id, ok := readN(idSize)
+		if !ok {
+			return 0, 0, false
+		}
+		method := uint64(0)
+		if version == 1 || version == 2 {
+			if method, ok = readN(2); !ok {
+				return 0, 0, false
+			}
+			method &= 0x0F
+		}
+		if _, ok = readN(2); !ok {
+			return 0, 0, false
+		}
+		base, ok := readN(baseOffsetSize)
+		if !ok {
+			return 0, 0, false
+		}
+		extents, ok := readN(2)
+		if !ok {
+			return 0, 0, false
+		}
+		for e := range extents {
+			if _, ok = readN(indexSize); !ok {
+				return 0, 0, false
+			}
+			offset, ok1 := readN(offsetSize)
+			length, ok2 := readN(lengthSize)
+			if !ok1 || !ok2 {
+				return 0, 0, false
+			}
+			if uint32(id) == itemID && e == 0 {
+				return base + offset, length, method == 0
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+func newTIFFReader(b []byte) (*tiffReader, uint32, bool) {
+	if len(b) < 8 {
+		return nil, 0, false
+	}
+	t := &tiffReader{b: b}
+	switch string(b[0:2]) {
+	case "II":
+		t.bo = binary.LittleEndian
+	case "MM":
+		t.bo = binary.BigEndian
+	default:
+		return nil, 0, false
+	}
+	if t.bo.Uint16(b[2:]) != 42 {
+		return nil, 0, false
+	}
+	return t, t.bo.Uint32(b[4:]), true
+}
+
+// readIFD 读取一个目录的全部目录项，同时返回目录项开始的位置
+func (t *tiffReader) readIFD(offset uint32) ([]ifdEntry, int, bool) {
+	if int64(offset)+2 > int64(len(t.b)) {
+		return nil, 0, false
+	}
+	count := int(t.bo.Uint16(t.b[offset:]))
+	start := int(offset) + 2
+	if start+count*12 > len(t.b) {
+		return nil, 0, false
+	}
+	entries := make([]ifdEntry, 0, count)
+	for i := 0; i < count; i++ {
+		p := start + i*12
+		e := ifdEntry{
+			tag:   t.bo.Uint16(t.b[p:]),
+			typ:   t.bo.Uint16(t.b[p+2:]),
+			count: t.bo.Uint32(t.b[p+4:]),
+		}
+		typeSize, ok := tiffTypeSize[e.typ]
+		if !ok || e.count > uint32(len(t.b)) {
+			continue
+		}
+		e.size = typeSize * int(e.count)
+		e.offset = p + 8
+		if e.size > 4 {
+			e.offset = int(t.bo.Uint32(t.b[p+8:]))
+		}
+		if e.offset < 0 || e.offset+e.size > len(t.b) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, start, true
+}
+
+func (t *tiffReader) readExifIFD(offset uint32, meta *Metadata) {
+	entries, _, ok := t.readIFD(offset)
+	if !ok {
+		return
+	}
+	for _, e := range entries {
+		switch e.tag {
+		case tagExposureTime:
+			if n, d := t.rational(e, 0); n > 0 && d > 0 {
+				if n >= d {
+					meta.ExposureTime = fmt.Sprintf("%g", float64(n)/float64(d))
+				} else {
+					meta.ExposureTime = fmt.Sprintf("1/%d", int(math.Round(float64(d)/float64(n))))
+				}
+			}
+		case tagFNumber:
+			meta.FNumber = t.float(e, 0)
+		case tagISO:
+			meta.ISO = int(t.uint(e))
+		case tagDateTimeOriginal:
+			meta.TakenAt = parseExifTime(t.ascii(e))
+		case tagFocalLength:
+			meta.FocalLength = t.float(e, 0)
+		case tagLensModel:
+			meta.LensModel = t.ascii(e)
+		}
+	}
+}
+
+func (t *tiffReader) readGPSIFD(offset uint32, meta *Metadata) {
+	entries, _, ok := t.readIFD(offset)
+	if !ok {
+		return
+	}
+	var latRef, lonRef string
+	var lat, lon *float64
+	for _, e := range entries {
+		switch e.tag {
+		case tagGPSLatitudeRef:
+			latRef = t.ascii(e)
+		case tagGPSLongitudeRef:
+			lonRef = t.ascii(e)
+		case tagGPSLatitude:
+			lat = t.degrees(e)
+		case tagGPSLongitude:
+			lon = t.degrees(e)
+		}
+	}
+	if lat == nil || lon == nil || *lat > 90 || *lon > 180 {
+		return
+	}
+	if latRef == "S" {
+		*lat = -*lat
+	}
+	if lonRef == "W" {
+		*lon = -*lon
+	}
+	meta.Latitude, meta.Longitude = lat, lon
+}
+
+func (t *tiffReader) ascii(e ifdEntry) string {
+	if e.typ != 2 {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(t.b[e.offset:e.offset+e.size]), "\x00"))
+}
+
+// uint 读取SHORT或LONG类型的第一个值
+func (t *tiffReader) uint(e ifdEntry) uint32 {
+	switch {
+	case e.typ == 3 && e.count > 0:
+		return uint32(t.bo.Uint16(t.b[e.offset:]))
+	case (e.typ == 4 || e.typ == 9) && e.count > 0:
+		return t.bo.Uint32(t.b[e.offset:])
+	}
+	return 0
+}
+
+// rational 读取RATIONAL类型的第i个值
+func (t *tiffReader) rational(e ifdEntry, i int) (uint32, uint32) {
+	if (e.typ != 5 && e.typ != 10) || uint32(i) >= e.count {
+		return 0, 0
+	}
+	p := e.offset + i*8
+	return t.bo.Uint32(t.b[p:]), t.bo.Uint32(t.b[p+4:])
+}
+
+func (t *tiffReader) float(e ifdEntry, i int) float64 {
+	n, d := t.rational(e, i)
+	if d == 0 {
+		return 0
+	}
+	return math.Round(float64(n)/float64(d)*100) / 100
+}
+
+// degrees 将度、分、秒三个RATIONAL转化为十进制的度数
+func (t *tiffReader) degrees(e ifdEntry) *float64 {
+	if e.count < 3 {
+		return nil
+	}
+	var v float64
+	for i, unit := range []float64{1, 60, 3600} {
+		n, d := t.rational(e, i)
+		if d == 0 {
+			return nil
+		}
+		v += float64(n) / float64(d) / unit
+	}
+	return &v
+}
+
+func parseExifTime(s string) *time.Time {
+	t, err := time.ParseInLocation("2006:01:02 15:04:05", s, time.Local)
+	if err != nil || t.Year() < 1900 {
+		return nil
+	}
+	return &t
+}
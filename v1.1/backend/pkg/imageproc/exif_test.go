@@ -0,0 +1,114 @@
+package imageproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"testing"
+)
+
+// 构造一段大端序的TIFF数据：IFD0包含厂商、方向和GPS目录，GPS目录包含北纬30.5、东经120.25
+func buildTestTIFF() []byte {
+	bo := binary.BigEndian
+	b := make([]byte, 158)
+	copy(b, "MM")
+	bo.PutUint16(b[2:], 42)
+	bo.PutUint32(b[4:], 8)
+	entry := func(p int, tag, typ uint16, count, value uint32) {
+		bo.PutUint16(b[p:], tag)
+		bo.PutUint16(b[p+2:], typ)
+		bo.PutUint32(b[p+4:], count)
+		bo.PutUint32(b[p+8:], value)
+	}
+	// IFD0
+	bo.PutUint16(b[8:], 3)
+	entry(10, tagMake, 2, 6, 50)
+	entry(22, tagOrientation, 3, 1, 6<<16) // SHORT放在值的高位
+	entry(34, tagGPSIFD, 4, 1, 56)
+	copy(b[50:], "Canon\x00")
+	// GPS IFD
+	bo.PutUint16(b[56:], 4)
+	entry(58, tagGPSLatitudeRef, 2, 2, uint32('N')<<24)
+	entry(70, tagGPSLatitude, 5, 3, 110)
+	entry(82, tagGPSLongitudeRef, 2, 2, uint32('E')<<24)
+	entry(94, tagGPSLongitude, 5, 3, 134)
+	for i, v := range []uint32{30, 1, 30, 1, 0, 1, 120, 1, 15, 1, 0, 1} {
+		bo.PutUint32(b[110+i*4:], v)
+	}
+	return b
+}
+
+func buildTestJPEG(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 40, 20)), nil); err != nil {
+		t.Fatal(err)
+	}
+	tiff := buildTestTIFF()
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(2+6+len(tiff)))
+	app1 = append(append(app1, "Exif\x00\x00"...), tiff...)
+	data := buf.Bytes()
+	return append(append(append([]byte{}, data[:2]...), app1...), data[2:]...)
+}
+
+func TestReadMetadata(t *testing.T) {
+	data := buildTestJPEG(t)
+	meta := ReadMetadata(data)
+	if meta == nil {
+		t.Fatal("应当读取到元数据")
+	}
+	if meta.Make != "Canon" || meta.Orientation != 6 {
+		t.Fatalf("元数据错误: %+v", meta)
+	}
+	if meta.Latitude == nil || *meta.Latitude != 30.5 || meta.Longitude == nil || *meta.Longitude != 120.25 {
+		t.Fatalf("GPS错误: %v %v", meta.Latitude, meta.Longitude)
+	}
+	// 方向为6时需要顺时针旋转90度，宽高互换
+	res, err := Process(data, "jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Info.Width != 20 || res.Info.Height != 40 {
+		t.Fatalf("未按方向摆正: %dx%d", res.Info.Width, res.Info.Height)
+	}
+	if ReadMetadata(res.WebP) != nil {
+		t.Fatal("产物不应携带元数据")
+	}
+}
+
+func TestStripGPS(t *testing.T) {
+	data := buildTestJPEG(t)
+	stripped, ok := StripGPS(data)
+	if !ok || len(stripped) != len(data) {
+		t.Fatalf("去除GPS失败: %v", ok)
+	}
+	meta := ReadMetadata(stripped)
+	if meta == nil || meta.Latitude != nil || meta.Longitude != nil {
+		t.Fatalf("GPS应当被去除: %+v", meta)
+	}
+	if meta.Make != "Canon" || meta.Orientation != 6 {
+		t.Fatalf("其他元数据应当保留: %+v", meta)
+	}
+	if _, err := Decode(bytes.NewReader(stripped)); err != nil {
+		t.Fatalf("去除GPS后图片应当可以解码: %v", err)
+	}
+	// 原数据不受影响
+	if ReadMetadata(data).Latitude == nil {
+		t.Fatal("不应修改原数据")
+	}
+	if _, ok := StripGPS(stripped); ok {
+		t.Fatal("没有GPS时应当返回false")
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	img.Pix[0] = 0xFF // 左上角的像素
+	for o, want := range map[int]image.Point{2: {2, 0}, 3: {2, 1}, 4: {0, 1}, 5: {0, 0}, 6: {1, 0}, 7: {1, 2}, 8: {0, 2}} {
+		dst := ApplyOrientation(img, o).(*image.NRGBA)
+		if dst.Pix[dst.PixOffset(want.X, want.Y)] != 0xFF {
+			t.Fatalf("方向%d: 左上角像素应当移动到%v", o, want)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package imageproc
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/gen2brain/avif"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// 进程内图片处理流水线，替代COS数据万象的 imageMogr2 / imageInfo / imageAve
+// 所有计算都在本地完成，相同输入得到相同输出，不依赖具体的存储后端
+
+const (
+	ThumbnailSize = 256 // 缩略图最大宽高
+	JPEGQuality   = 85  // JPEG编码质量
+	AVIFQuality   = 60  // AVIF编码质量
+	AVIFSpeed     = 8   // AVIF编码速度[0,10]，越快体积越大
+
+	MaxPixels = 100_000_000 // 单张图片像素数上限，超出时在解码前拒绝，避免解码占用过多内存
+)
+
+// PicInfo 图片详细数据结构体
+type PicInfo struct {
+	Format     string
+	Width      int
+	Height     int
+	Size       int64
+	MD5        string
+	FrameCount int // 帧数，静态图片为1
+}
+
+// RenditionSpec 衍生版本的规格
+type RenditionSpec struct {
+	Name    string // 版本名称
+	Width   int    // 最大宽度，只缩小不放大
+	Format  string // 编码格式：jpg/jpeg/png/webp/avif
+	Quality int    // 编码质量[1,100]，为0时使用默认值，png与webp为无损编码时忽略
+}
+
+// Rendition 生成的衍生版本
+type Rendition struct {
+	Spec   RenditionSpec
+	Data   []byte
+	Width  int
+	Height int
+}
+
+// Result 一次处理得到的全部产物
+type Result struct {
+	WebP       []byte         // webp格式的主图
+	Thumbnail  []byte         // 缩略图，编码格式与原图一致
+	Renditions []Rendition    // 按规格生成的衍生版本
+	Info       PicInfo        // 主图信息
+	Format     string         // 原图的真实格式，取值见SourceFormats
+	Color      string         // 主色调，例如：0x736246
+	PHash      uint64         // 感知哈希，基于未加水印的缩略图计算
+	DHash      uint64         // 差异哈希，基于未加水印的缩略图计算
+	Palette    []PaletteColor // 调色板，基于未加水印的缩略图计算
+	Metadata   *Metadata      // 原图的EXIF元数据，没有时为nil
+}
+
+// Process 解码原图，生成webp主图、缩略图和衍生版本，并计算图片信息与主色调
+// 原图带有EXIF方向时先摆正，所有产物都不携带元数据
+// 原图为GIF或WebP动图时主图和webp格式的衍生版本保留动画，缩略图和其他衍生版本使用第一帧
+// thumbnailFormat为缩略图的编码格式，一般由ThumbnailFormat根据原图格式得到
+// 原图需先通过Validate校验，这里只按文件内容识别格式
+func Process(data []byte, thumbnailFormat string, renditions ...RenditionSpec) (*Result, error) {
+	return ProcessWithWatermark(data, thumbnailFormat, nil, renditions...)
+}
+
+// ProcessWithWatermark 与Process相同，并在主图、缩略图和衍生版本上叠加水印，wm为nil时不添加
+// 水印在缩放之后叠加，大小与位置相对于各自的尺寸；主色调基于未添加水印的缩略图计算
+func ProcessWithWatermark(data []byte, thumbnailFormat string, wm *Watermark, renditions ...RenditionSpec) (*Result, error) {
+	format, err := Sniff(data)
+	if err != nil {
+		return nil, err
+	}
+	anim, err := DecodeAnimation(data)
+	if err != nil {
+		return nil, err
+	}
+	var img image.Image
+	var meta *Metadata
+	frameCount := 1
+	if anim != nil {
+		img = anim.Frames[0].Image
+		frameCount = len(anim.Frames)
+	} else {
+		img, err = decodeData(data)
+		if err != nil {
+			return nil, err
+		}
+		meta = ReadMetadata(data)
+		if meta != nil {
+			img = ApplyOrientation(img, meta.Orientation)
+		}
+	}
+	mark := func(img image.Image) image.Image { return img }
+	if wm != nil {
+		mark = wm.Applier()
+	}
+	// 1.生成webp主图
+	var main bytes.Buffer
+	if anim != nil {
+		err = EncodeAnimatedWebP(&main, anim.MapFrames(mark))
+	} else {
+		err = Encode(&main, mark(img), "webp")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("生成webp主图失败: %w", err)
+	}
+	// 2.生成缩略图，只缩小不放大，动图使用第一帧作为封面
+	thumb := Resize(img, ThumbnailSize, ThumbnailSize)
+	var thumbBuf bytes.Buffer
+	if err := Encode(&thumbBuf, mark(thumb), thumbnailFormat); err != nil {
+		return nil, fmt.Errorf("生成缩略图失败: %w", err)
+	}
+	// 3.生成衍生版本
+	results := make([]Rendition, 0, len(renditions))
+	for _, spec := range renditions {
+		var r *Rendition
+		if anim != nil && strings.ToLower(spec.Format) == "webp" {
+			r, err = makeAnimatedRendition(anim, spec, mark)
+		} else {
+			r, err = makeRendition(img, spec, mark)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("生成衍生版本 %s 失败: %w", spec.Name, err)
+		}
+		results = append(results, *r)
+	}
+	// 4.计算图片信息
+	sum := md5.Sum(main.Bytes())
+	bounds := img.Bounds()
+	return &Result{
+		WebP:       main.Bytes(),
+		Thumbnail:  thumbBuf.Bytes(),
+		Renditions: results,
+		Info: PicInfo{
+			Format:     "webp",
+			Width:      bounds.Dx(),
+			Height:     bounds.Dy(),
+			Size:       int64(main.Len()),
+			MD5:        hex.EncodeToString(sum[:]),
+			FrameCount: frameCount,
+		},
+		// 主色调基于缩略图计算，结果与全图平均色几乎一致且开销固定
+		Color:    MainColor(thumb),
+		PHash:    PHash(thumb),
+		DHash:    DHash(thumb),
+		Palette:  Palette(thumb),
+		Format:   format,
+		Metadata: meta,
+	}, nil
+}
+
+// Decode 解码图片，支持jpeg、png、webp、gif、bmp、tiff，动图只解码第一帧
+// HEIF容器中品牌不标准的HEIC和WebP动图请使用DecodeOriented
+func Decode(r io.Reader) (image.Image, error) {
+	// 先读取文件头中的宽高，像素数超出上限时不解码；已读取的文件头与剩余数据拼接后再完整解码
+	var head bytes.Buffer
+	cfg, _, err := image.DecodeConfig(io.TeeReader(r, &head))
+	if err != nil {
+		return nil, fmt.Errorf("图片解码失败: %w", err)
+	}
+	if err := checkPixels(cfg); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(io.MultiReader(&head, r))
+	if err != nil {
+		return nil, fmt.Errorf("图片解码失败: %w", err)
+	}
+	if img.Bounds().Empty() {
+		return nil, fmt.Errorf("图片尺寸为空")
+	}
+	return img, nil
+}
+
+// checkPixels 检查文件头中的宽高，像素数超出MaxPixels时拒绝
+func checkPixels(cfg image.Config) error {
+	if int64(cfg.Width)*int64(cfg.Height) > MaxPixels {
+		return fmt.Errorf("图片像素过多: %dx%d", cfg.Width, cfg.Height)
+	}
+	return nil
+}
+
+// MakeRendition 按规格缩放并编码一个衍生版本
+func MakeRendition(img image.Image, spec RenditionSpec) (*Rendition, error) {
+	return makeRendition(img, spec, nil)
+}
+
+// makeRendition 缩放后经过post处理再编码，post为nil时不处理
+func makeRendition(img image.Image, spec RenditionSpec, post func(image.Image) image.Image) (*Rendition, error) {
+	resized := Resize(img, spec.Width, math.MaxInt32)
+	if post != nil {
+		resized = post(resized)
+	}
+	var buf bytes.Buffer
+	if err := EncodeWithQuality(&buf, resized, spec.Format, spec.Quality); err != nil {
+		return nil, err
+	}
+	bounds := resized.Bounds()
+	return &Rendition{Spec: spec, Data: buf.Bytes(), Width: bounds.Dx(), Height: bounds.Dy()}, nil
+}
+
+// MakeAnimatedRendition 按规格缩放每一帧并编码为WebP动图
+func MakeAnimatedRendition(anim *Animation, spec RenditionSpec) (*Rendition, error) {
+	return makeAnimatedRendition(anim, spec, nil)
+}
+
+// makeAnimatedRendition 每一帧缩放后经过post处理再编码，post为nil时不处理
+func makeAnimatedRendition(anim *Animation, spec RenditionSpec, post func(image.Image) image.Image) (*Rendition, error) {
+	resized := anim.MapFrames(func(img image.Image) image.Image {
+		img = Resize(img, spec.Width, math.MaxInt32)
+		if post != nil {
+			img = post(img)
+		}
+		return img
+	})
+	var buf bytes.Buffer
+	if err := EncodeAnimatedWebP(&buf, resized); err != nil {
+		return nil, err
+	}
+	bounds := resized.Frames[0].Image.Bounds()
+	return &Rendition{Spec: spec, Data: buf.Bytes(), Width: bounds.Dx(), Height: bounds.Dy()}, nil
+}
+
+// Encode 按格式编码图片，format取值为jpg/jpeg/png/webp/avif
+func Encode(w io.Writer, img image.Image, format string) error {
+	return EncodeWithQuality(w, img, format, 0)
+}
+
+// EncodeWithQuality 按格式和质量编码图片，quality为0时使用默认质量
+// webp使用无损编码，png本身无损，两者忽略quality；gif只用于GIF原图的静态缩略图
+func EncodeWithQuality(w io.Writer, img image.Image, format string, quality int) error {
+	switch strings.ToLower(format) {
+	case "jpg", "jpeg":
+		if quality <= 0 {
+			quality = JPEGQuality
+		}
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case "png":
+		return png.Encode(w, img)
+	case "gif":
+		return gif.Encode(w, img, nil)
+	case "webp":
+		return nativewebp.Encode(w, img, nil)
+	case "avif":
+		if quality <= 0 {
+			quality = AVIFQuality
+		}
+		return avif.Encode(w, img, avif.Options{
+			Quality:           quality,
+			QualityAlpha:      quality,
+			Speed:             AVIFSpeed,
+			ChromaSubsampling: image.YCbCrSubsampleRatio420,
+		})
+	default:
+		return fmt.Errorf("不支持的编码格式: %s", format)
+	}
+}
+
+// IsEncodeFormat 判断衍生版本和渲染是否支持输出该格式
+func IsEncodeFormat(format string) bool {
+	switch strings.ToLower(format) {
+	case "jpg", "jpeg", "png", "webp", "avif":
+		return true
+	default:
+		return false
+	}
+}
+
+// ContentType 根据格式获取MIME类型
+func ContentType(format string) string {
+	switch strings.ToLower(format) {
+	case "jpg", "jpeg":
+		return "image/jpeg"
+	default:
+		return "image/" + strings.ToLower(format)
+	}
+}
+
+// Resize 等比缩放图片，使宽高都不超过maxWidth/maxHeight，图片本身更小时原样返回
+// 对应 imageMogr2/thumbnail/<maxWidth>x<maxHeight>>
+func Resize(img image.Image, maxWidth, maxHeight int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxWidth && height <= maxHeight {
+		return img
+	}
+	scale := min(float64(maxWidth)/float64(width), float64(maxHeight)/float64(height))
+	newWidth := max(1, int(float64(width)*scale+0.5))
+	newHeight := max(1, int(float64(height)*scale+0.5))
+	dst := image.NewNRGBA(image.Rect(0, 0, newWidth, newHeight))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// MainColor 计算图片的平均色作为主色调，返回十六进制字符串，例如：0x736246
+// 与COS imageAve的返回格式保持一致
+func MainColor(img image.Image) string {
+	bounds := img.Bounds()
+	var r, g, b, n uint64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			cr, cg, cb, _ := img.At(x, y).RGBA()
+			r += uint64(cr >> 8)
+			g += uint64(cg >> 8)
+			b += uint64(cb >> 8)
+			n++
+		}
+	}
+	if n == 0 {
+		return "0x000000"
+	}
+	return fmt.Sprintf("0x%02X%02X%02X", r/n, g/n, b/n)
+}
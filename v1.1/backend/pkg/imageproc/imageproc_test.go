@@ -0,0 +1,109 @@
+package imageproc
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestProcess(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 800, 400))
+	for y := 0; y < 400; y++ {
+		for x := 0; x < 800; x++ {
+			img.Set(x, y, color.NRGBA{R: 0x73, G: 0x62, B: 0x46, A: 0xFF})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Process(buf.Bytes(), "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Info.Width != 800 || res.Info.Height != 400 || res.Info.Format != "webp" {
+		t.Fatalf("图片信息错误: %+v", res.Info)
+	}
+	if res.Info.Size != int64(len(res.WebP)) {
+		t.Fatalf("图片大小应为webp主图大小")
+	}
+	if res.Color != "0x736246" {
+		t.Fatalf("主色调错误: %s", res.Color)
+	}
+	thumb, err := Decode(bytes.NewReader(res.Thumbnail))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := thumb.Bounds(); b.Dx() != 256 || b.Dy() != 128 {
+		t.Fatalf("缩略图尺寸错误: %v", b)
+	}
+	// 相同输入得到相同输出
+	again, _ := Process(buf.Bytes(), "png")
+	if again.Info.MD5 != res.Info.MD5 {
+		t.Fatal("处理结果不确定")
+	}
+}
+
+func TestDecodeMaxPixels(t *testing.T) {
+	var buf bytes.Buffer
+	_ = gif.Encode(&buf, image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{color.Black, color.White}), nil)
+	if _, err := Decode(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("正常图片解码失败: %v", err)
+	}
+	// 将逻辑屏幕宽高改为65535x65535，应当在解码前被拒绝
+	data := bytes.Clone(buf.Bytes())
+	copy(data[6:10], []byte{0xFF, 0xFF, 0xFF, 0xFF})
+	if _, err := Decode(bytes.NewReader(data)); err == nil || !strings.Contains(err.Error(), "像素过多") {
+		t.Fatalf("超大图片应当被拒绝: %v", err)
+	}
+}
+
+func TestMakeRendition(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 1000, 500))
+	for _, spec := range []RenditionSpec{
+		{Name: "w512", Width: 512, Format: "jpg", Quality: 80},
+		{Name: "w128", Width: 128, Format: "avif"},
+		{Name: "w2048", Width: 2048, Format: "png"},
+	} {
+		r, err := MakeRendition(img, spec)
+		if err != nil {
+			t.Fatalf("%s: %v", spec.Name, err)
+		}
+		// 只缩小不放大
+		wantWidth := min(spec.Width, 1000)
+		if r.Width != wantWidth || r.Height != wantWidth/2 {
+			t.Fatalf("%s 尺寸错误: %dx%d", spec.Name, r.Width, r.Height)
+		}
+		if len(r.Data) == 0 {
+			t.Fatalf("%s 编码结果为空", spec.Name)
+		}
+	}
+}
+
+func TestTransform(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 800, 400))
+	for _, tc := range []struct {
+		width, height int
+		fit           string
+		wantW, wantH  int
+	}{
+		{0, 0, "", 800, 400},
+		{400, 0, FitContain, 400, 200},
+		{0, 100, "", 200, 100},
+		{200, 200, FitContain, 200, 100},
+		{200, 200, FitCover, 200, 200},
+		{1000, 1000, FitCover, 400, 400},
+		{300, 300, FitFill, 300, 300},
+		{2000, 100, FitFill, 800, 100},
+	} {
+		b := Transform(img, tc.width, tc.height, tc.fit).Bounds()
+		if b.Dx() != tc.wantW || b.Dy() != tc.wantH {
+			t.Fatalf("%dx%d %s: 尺寸错误 %dx%d", tc.width, tc.height, tc.fit, b.Dx(), b.Dy())
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package imageproc
+
+import (
+	"backend/pkg/colorspace"
+	"image"
+	"math"
+	"sort"
+)
+
+const (
+	PaletteSize          = 5    // 调色板最多包含的颜色数
+	PaletteMergeDistance = 12.0 // ΔE2000小于该值的颜色合并为一种
+	PaletteMinWeight     = 0.03 // 占比低于该值的颜色不计入调色板
+	paletteBits          = 5    // 统计直方图时每个通道保留的位数
+)
+
+// PaletteColor 调色板中的一种颜色
+type PaletteColor struct {
+	Color  string  `json:"color"`  // 十六进制颜色，例如：0x736246
+	Weight float64 `json:"weight"` // 该颜色的像素占比，(0,1]
+}
+
+// 直方图中的一格，或合并后的一种颜色
+type paletteBin struct {
+	r, g, b uint64 // 像素颜色之和
+	count   uint64
+	lab     colorspace.Lab
+}
+
+func (p *paletteBin) mean() colorspace.RGB {
+	return colorspace.RGB{R: uint8(p.r / p.count), G: uint8(p.g / p.count), B: uint8(p.b / p.count)}
+}
+
+// Palette 提取图片的主要颜色，按占比从高到低排列，最多PaletteSize种
+// 先按每通道paletteBits位统计直方图，再从像素最多的格子开始，把色差小于PaletteMergeDistance的格子合并为一种颜色
+// 半透明（alpha小于128）的像素不参与统计
+func Palette(img image.Image) []PaletteColor {
+	bounds := img.Bounds()
+	const shift = 8 - paletteBits
+	bins := make(map[uint32]*paletteBin)
+	var total uint64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			cr, cg, cb, ca := img.At(x, y).RGBA()
+			if ca < 0x8000 {
+				continue
+			}
+			// RGBA返回预乘alpha的值，还原为不透明时的颜色
+			r, g, b := cr*0xffff/ca>>8, cg*0xffff/ca>>8, cb*0xffff/ca>>8
+			key := r>>shift<<(2*paletteBits) | g>>shift<<paletteBits | b>>shift
+			bin, ok := bins[key]
+			if !ok {
+				bin = &paletteBin{}
+				bins[key] = bin
+			}
+			bin.r += uint64(r)
+			bin.g += uint64(g)
+			bin.b += uint64(b)
+			bin.count++
+			total++
+		}
+	}
+	if total == 0 {
+		return []PaletteColor{}
+	}
+	sorted := make([]*paletteBin, 0, len(bins))
+	for _, bin := range bins {
+		bin.lab = bin.mean().Lab()
+		sorted = append(sorted, bin)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].count != sorted[j].count {
+			return sorted[i].count > sorted[j].count
+		}
+		return sorted[i].lab.L < sorted[j].lab.L
+	})
+	// 合并时以每种颜色中像素最多的格子作为代表色比较色差
+	var merged []*paletteBin
+	for _, bin := range sorted {
+		var target *paletteBin
+		for _, m := range merged {
+			if colorspace.DeltaE2000(m.lab, bin.lab) < PaletteMergeDistance {
+				target = m
+				break
+			}
+		}
+		if target == nil {
+			copied := *bin
+			merged = append(merged, &copied)
+			continue
+		}
+		target.r += bin.r
+		target.g += bin.g
+		target.b += bin.b
+		target.count += bin.count
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].count > merged[j].count })
+	palette := make([]PaletteColor, 0, PaletteSize)
+	for _, m := range merged {
+		weight := float64(m.count) / float64(total)
+		if len(palette) == PaletteSize || (weight < PaletteMinWeight && len(palette) > 0) {
+			break
+		}
+		palette = append(palette, PaletteColor{Color: m.mean().Hex(), Weight: math.Round(weight*1000) / 1000})
+	}
+	return palette
+}
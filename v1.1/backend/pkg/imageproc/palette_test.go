@@ -0,0 +1,38 @@
+package imageproc
+
+import (
+	"backend/pkg/colorspace"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestPalette(t *testing.T) {
+	// 左侧60%为红色（带轻微噪点），右侧40%为蓝色，顶部一行为透明像素
+	img := image.NewNRGBA(image.Rect(0, 0, 100, 50))
+	for y := 0; y < 50; y++ {
+		for x := 0; x < 100; x++ {
+			switch {
+			case y == 0:
+				img.Set(x, y, color.NRGBA{R: 0, G: 255, B: 0, A: 0})
+			case x < 60:
+				img.Set(x, y, color.NRGBA{R: uint8(220 + (x+y)%8), G: 20, B: 30, A: 0xFF})
+			default:
+				img.Set(x, y, color.NRGBA{R: 20, G: 40, B: 200, A: 0xFF})
+			}
+		}
+	}
+	palette := Palette(img)
+	if len(palette) != 2 {
+		t.Fatalf("调色板颜色数量错误: %+v", palette)
+	}
+	if palette[0].Weight != 0.6 || palette[1].Weight != 0.4 {
+		t.Fatalf("调色板占比错误: %+v", palette)
+	}
+	if red, err := colorspace.ParseHex(palette[0].Color); err != nil || red.R < 220 || red.R > 227 || palette[1].Color != "0x1428C8" {
+		t.Fatalf("调色板颜色错误: %+v", palette)
+	}
+	if len(Palette(image.NewNRGBA(image.Rect(0, 0, 4, 4)))) != 0 {
+		t.Fatal("全透明图片的调色板应为空")
+	}
+}
@@ -0,0 +1,114 @@
+package imageproc
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	hashSize = 8  // 哈希由8x8个比特组成，共64位
+	dctSize  = 32 // pHash先缩小到32x32再做DCT
+)
+
+// dctTable[u][x] = cos((2x+1)uπ/2N)，只需要前hashSize个频率
+var dctTable = func() [hashSize][dctSize]float64 {
+	var t [hashSize][dctSize]float64
+	for u := 0; u < hashSize; u++ {
+		for x := 0; x < dctSize; x++ {
+			t[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * dctSize))
+		}
+	}
+	return t
+}()
+
+// PHash 计算感知哈希：缩小为32x32灰度图，取DCT低频的8x8系数，大于中位数的位记为1
+// 对缩放、压缩、轻微调色不敏感，两张图片哈希的汉明距离越小越相似
+func PHash(img image.Image) uint64 {
+	pixels := grayPixels(img, dctSize, dctSize)
+	// 先对每行做一维DCT，再对列做，只计算低频部分
+	var rows [dctSize][hashSize]float64
+	for y := 0; y < dctSize; y++ {
+		for u := 0; u < hashSize; u++ {
+			var sum float64
+			for x := 0; x < dctSize; x++ {
+				sum += pixels[y*dctSize+x] * dctTable[u][x]
+			}
+			rows[y][u] = sum
+		}
+	}
+	coeffs := make([]float64, 0, hashSize*hashSize)
+	for v := 0; v < hashSize; v++ {
+		for u := 0; u < hashSize; u++ {
+			var sum float64
+			for y := 0; y < dctSize; y++ {
+				sum += rows[y][u] * dctTable[v][y]
+			}
+			coeffs = append(coeffs, sum)
+		}
+	}
+	// 直流分量代表整体亮度，不参与中位数计算
+	sorted := append([]float64(nil), coeffs[1:]...)
+	sort.Float64s(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	var hash uint64
+	for i, c := range coeffs {
+		if c > median {
+			hash |= 1 << uint(len(coeffs)-1-i)
+		}
+	}
+	return hash
+}
+
+// DHash 计算差异哈希：缩小为9x8灰度图，每行相邻像素左边比右边亮时记为1
+// 计算量比pHash小，对亮度和对比度变化不敏感，用于结果的二次排序
+func DHash(img image.Image) uint64 {
+	pixels := grayPixels(img, hashSize+1, hashSize)
+	var hash uint64
+	for y := 0; y < hashSize; y++ {
+		for x := 0; x < hashSize; x++ {
+			hash <<= 1
+			if pixels[y*(hashSize+1)+x] > pixels[y*(hashSize+1)+x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// HashDistance 两个哈希之间的汉明距离，范围0~64
+func HashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// FormatHash 将哈希格式化为16位十六进制字符串，用于存储
+func FormatHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// ParseHash 解析FormatHash的结果，为空或格式错误时返回false
+func ParseHash(s string) (uint64, bool) {
+	if len(s) != 16 {
+		return 0, false
+	}
+	hash, err := strconv.ParseUint(s, 16, 64)
+	return hash, err == nil
+}
+
+// 缩放为指定大小的灰度图，返回按行排列的亮度值
+func grayPixels(img image.Image, width, height int) []float64 {
+	dst := image.NewGray(image.Rect(0, 0, width, height))
+	draw.BiLinear.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+	pixels := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			pixels[y*width+x] = float64(dst.Pix[y*dst.Stride+x])
+		}
+	}
+	return pixels
+}
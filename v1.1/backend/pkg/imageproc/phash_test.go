@@ -0,0 +1,58 @@
+package imageproc
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// 生成带有对角渐变和方块的测试图，shift用于制造轻微的亮度变化
+func hashTestImage(width, height int, shift uint8, flip bool) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			fx, fy := x*255/width, y*255/height
+			if flip {
+				fx = 255 - fx
+			}
+			v := uint8(min((fx+fy)/2+int(shift), 255))
+			if fx > 60 && fx < 120 && fy > 60 && fy < 160 {
+				v = 240
+			}
+			img.Set(x, y, color.NRGBA{R: v, G: v / 2, B: 255 - v, A: 0xFF})
+		}
+	}
+	return img
+}
+
+func TestPerceptualHash(t *testing.T) {
+	origin := hashTestImage(400, 300, 0, false)
+	// 缩放加轻微调亮后仍应非常接近
+	similar := hashTestImage(200, 150, 8, false)
+	// 水平翻转后应明显不同
+	different := hashTestImage(400, 300, 0, true)
+
+	if d := HashDistance(PHash(origin), PHash(similar)); d > 6 {
+		t.Fatalf("相似图片的pHash距离过大: %d", d)
+	}
+	if d := HashDistance(DHash(origin), DHash(similar)); d > 6 {
+		t.Fatalf("相似图片的dHash距离过大: %d", d)
+	}
+	if d := HashDistance(PHash(origin), PHash(different)); d < 16 {
+		t.Fatalf("不同图片的pHash距离过小: %d", d)
+	}
+}
+
+func TestFormatHash(t *testing.T) {
+	hash := uint64(0x00f0a5)
+	s := FormatHash(hash)
+	if s != "000000000000f0a5" {
+		t.Fatalf("格式化结果错误: %s", s)
+	}
+	if got, ok := ParseHash(s); !ok || got != hash {
+		t.Fatalf("解析结果错误: %x %v", got, ok)
+	}
+	if _, ok := ParseHash(""); ok {
+		t.Fatal("空字符串不应解析成功")
+	}
+}
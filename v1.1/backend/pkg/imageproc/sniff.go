@@ -0,0 +1,182 @@
+package imageproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+
+	"github.com/gen2brain/avif"
+	"github.com/gen2brain/heic"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+)
+
+// 按文件内容识别图片格式，不信任文件后缀和Content-Type
+// HEIC、AVIF、BMP、TIFF只作为输入格式，处理后统一生成webp主图和标准衍生版本
+
+var (
+	ErrUnsupportedFormat = errors.New("文件不是支持的图片格式")
+	ErrPolyglot          = errors.New("图片中夹带了其他类型的数据")
+)
+
+// 可作为原图上传的格式，与Sniff的返回值一致
+var SourceFormats = []string{"jpg", "png", "gif", "webp", "bmp", "tiff", "heic", "avif"}
+
+// HEIF容器中表示HEIC和AVIF的品牌
+var (
+	heicBrands = []string{"heic", "heix", "heim", "heis", "hevc", "hevx"}
+	avifBrands = []string{"avif", "avis"}
+)
+
+// 除图片本身外不应出现在图片文件中的内容，出现时视为夹带了网页、脚本或压缩包
+var activeContentMarkers = [][]byte{
+	[]byte("<?php"),
+	[]byte("<script"),
+	[]byte("<html"),
+	[]byte("<!doctype html"),
+	[]byte("<svg"),
+}
+
+// Sniff 根据文件头识别图片的真实格式，返回jpg/png/gif/webp/bmp/tiff/heic/avif
+func Sniff(data []byte) (string, error) {
+	switch {
+	case len(data) >= 3 && data[0] == 0xFF && data[1] == 0xD8 && data[2] == 0xFF:
+		return "jpg", nil
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return "png", nil
+	case bytes.HasPrefix(data, []byte("GIF87a")) || bytes.HasPrefix(data, []byte("GIF89a")):
+		return "gif", nil
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return "webp", nil
+	case len(data) >= 26 && string(data[0:2]) == "BM":
+		return "bmp", nil
+	case bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*")):
+		return "tiff", nil
+	case len(data) >= 12 && string(data[4:8]) == "ftyp":
+		return sniffHEIF(data)
+	}
+	return "", ErrUnsupportedFormat
+}
+
+// Validate 识别图片格式并检查是否夹带了其他类型的数据
+func Validate(data []byte) (string, error) {
+	format, err := Sniff(data)
+	if err != nil {
+		return "", err
+	}
+	if err := checkPolyglot(format, data); err != nil {
+		return "", err
+	}
+	return format, nil
+}
+
+// ThumbnailFormat 缩略图的编码格式，能直接编码的格式与原图保持一致，其余格式使用jpg或png
+func ThumbnailFormat(format string) string {
+	switch format {
+	case "jpg", "png", "gif", "webp", "avif":
+		return format
+	case "bmp", "tiff":
+		// 可能带有透明通道
+		return "png"
+	default:
+		return "jpg"
+	}
+}
+
+// sniffHEIF 根据ftyp中的主品牌和兼容品牌区分HEIC与AVIF
+func sniffHEIF(data []byte) (string, error) {
+	size := int(binary.BigEndian.Uint32(data))
+	if size < 16 || size > len(data) {
+		return "", ErrUnsupportedFormat
+	}
+	// 主品牌、次版本号之后是兼容品牌列表
+	brands := []string{string(data[8:12])}
+	for i := 16; i+4 <= size; i += 4 {
+		brands = append(brands, string(data[i:i+4]))
+	}
+	for _, b := range brands {
+		for _, avifBrand := range avifBrands {
+			if b == avifBrand {
+				return "avif", nil
+			}
+		}
+		for _, heicBrand := range heicBrands {
+			if b == heicBrand {
+				return "heic", nil
+			}
+		}
+	}
+	return "", ErrUnsupportedFormat
+}
+
+// checkPolyglot 检查图片是否同时是其他格式的合法文件
+// 1.任何位置出现网页或脚本标记；2.末尾带有zip目录；3.png、gif结束标记之后还有数据
+func checkPolyglot(format string, data []byte) error {
+	lower := bytes.ToLower(data)
+	for _, marker := range activeContentMarkers {
+		if bytes.Contains(lower, marker) {
+			return ErrPolyglot
+		}
+	}
+	// zip从文件末尾读取目录，目录结束标记位于最后64KB+22字节内
+	tail := data[max(0, len(data)-(0xFFFF+22)):]
+	if bytes.Contains(tail, []byte("PK\x05\x06")) {
+		return ErrPolyglot
+	}
+	var end int
+	switch format {
+	case "png":
+		i := bytes.LastIndex(data, []byte("IEND"))
+		if i < 0 {
+			return ErrUnsupportedFormat
+		}
+		end = i + 8
+	case "gif":
+		end = bytes.LastIndexByte(data, 0x3B) + 1
+	default:
+		return nil
+	}
+	if end < len(data) && len(bytes.Trim(data[end:], "\x00")) > 0 {
+		return ErrPolyglot
+	}
+	return nil
+}
+
+// decodeData 按识别出的格式解码图片，未识别的格式交给标准库
+// heic、avif先读取文件头中的宽高，像素数超出上限时不解码
+func decodeData(data []byte) (image.Image, error) {
+	format, _ := Sniff(data)
+	var img image.Image
+	var err error
+	switch format {
+	case "heic":
+		if err := checkConfig(heic.DecodeConfig(bytes.NewReader(data))); err != nil {
+			return nil, err
+		}
+		img, err = heic.Decode(bytes.NewReader(data))
+	case "avif":
+		if err := checkConfig(avif.DecodeConfig(bytes.NewReader(data))); err != nil {
+			return nil, err
+		}
+		img, err = avif.Decode(bytes.NewReader(data))
+	default:
+		return Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("图片解码失败: %w", err)
+	}
+	if img.Bounds().Empty() {
+		return nil, fmt.Errorf("图片尺寸为空")
+	}
+	return img, nil
+}
+
+// checkConfig 检查解码得到的文件头，读取失败或像素数超出上限时返回错误
+func checkConfig(cfg image.Config, err error) error {
+	if err != nil {
+		return fmt.Errorf("图片解码失败: %w", err)
+	}
+	return checkPixels(cfg)
+}
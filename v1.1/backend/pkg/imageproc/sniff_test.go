@@ -0,0 +1,120 @@
+package imageproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/gen2brain/avif"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+func box(boxType string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	b := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(b, uint32(8+len(body)))
+	copy(b[4:], boxType)
+	return append(b, body...)
+}
+
+// 构造只包含Exif条目的HEIC容器，用于校验元数据定位
+func buildTestHEIF() []byte {
+	ftyp := box("ftyp", []byte("mif1\x00\x00\x00\x00mif1heic"))
+	infe := box("infe", []byte{2, 0, 0, 0, 0, 1, 0, 0}, []byte("Exif"), []byte{0})
+	iinf := box("iinf", []byte{0, 0, 0, 0, 0, 1}, infe)
+	exif := append([]byte{0, 0, 0, 6}, "Exif\x00\x00"...)
+	exif = append(exif, buildTestTIFF()...)
+	// iloc版本0：偏移和长度各4字节，没有基础偏移
+	ilocBody := []byte{0, 0, 0, 0, 0x44, 0x00, 0, 1, 0, 1, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(ilocBody[18:], uint32(len(exif)))
+	meta := box("meta", []byte{0, 0, 0, 0}, box("hdlr", make([]byte, 24)), iinf, box("iloc", ilocBody))
+	data := append(ftyp, meta...)
+	binary.BigEndian.PutUint32(data[len(data)-8:], uint32(len(data)+8)) // 数据位于mdat内容开始处
+	return append(data, box("mdat", exif)...)
+}
+
+func TestSniff(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 8))
+	encode := map[string]func(*bytes.Buffer) error{
+		"png":  func(b *bytes.Buffer) error { return png.Encode(b, img) },
+		"bmp":  func(b *bytes.Buffer) error { return bmp.Encode(b, img) },
+		"tiff": func(b *bytes.Buffer) error { return tiff.Encode(b, img, nil) },
+		"avif": func(b *bytes.Buffer) error { return avif.Encode(b, img) },
+	}
+	for format, fn := range encode {
+		var buf bytes.Buffer
+		if err := fn(&buf); err != nil {
+			t.Fatal(err)
+		}
+		got, err := Validate(buf.Bytes())
+		if err != nil || got != format {
+			t.Fatalf("%s 识别错误: %s %v", format, got, err)
+		}
+		res, err := Process(buf.Bytes(), ThumbnailFormat(got))
+		if err != nil {
+			t.Fatalf("%s 处理失败: %v", format, err)
+		}
+		if res.Format != format || res.Info.Width != 16 || res.Info.Height != 8 {
+			t.Fatalf("%s 处理结果错误: %s %+v", format, res.Format, res.Info)
+		}
+	}
+	if f, err := Sniff(buildTestHEIF()); err != nil || f != "heic" {
+		t.Fatalf("heic 识别错误: %s %v", f, err)
+	}
+	if _, err := Sniff([]byte("not an image at all")); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatal("非图片应当被拒绝")
+	}
+
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, img)
+	for name, data := range map[string][]byte{
+		"script":   append(bytes.Clone(buf.Bytes()[:40]), append([]byte("<SCRIPT>alert(1)</script>"), buf.Bytes()[40:]...)...),
+		"trailing": append(bytes.Clone(buf.Bytes()), "payload"...),
+		"zip":      append(bytes.Clone(buf.Bytes()), "PK\x05\x06\x00\x00\x00\x00"...),
+	} {
+		if _, err := Validate(data); !errors.Is(err, ErrPolyglot) {
+			t.Fatalf("%s: 应当识别为夹带数据", name)
+		}
+	}
+}
+
+func TestHEIFMetadata(t *testing.T) {
+	data := buildTestHEIF()
+	meta := ReadMetadata(data)
+	if meta == nil || meta.Make != "Canon" || meta.Latitude == nil {
+		t.Fatalf("未读取到HEIC元数据: %+v", meta)
+	}
+	stripped, ok := StripGPS(data)
+	if !ok || len(stripped) != len(data) {
+		t.Fatal("应当去除GPS信息")
+	}
+	if meta := ReadMetadata(stripped); meta == nil || meta.Latitude != nil || meta.Make != "Canon" {
+		t.Fatalf("去除GPS后元数据错误: %+v", meta)
+	}
+}
+
+func TestDecodeDataMaxPixels(t *testing.T) {
+	var buf bytes.Buffer
+	if err := avif.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 16, 8))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decodeData(buf.Bytes()); err != nil {
+		t.Fatalf("正常图片解码失败: %v", err)
+	}
+	// 将ispe中的宽高改为12000x12000，应当在解码前被拒绝
+	data := bytes.Clone(buf.Bytes())
+	i := bytes.Index(data, []byte("ispe"))
+	if i < 0 {
+		t.Fatal("未找到ispe")
+	}
+	binary.BigEndian.PutUint32(data[i+8:], 12000)
+	binary.BigEndian.PutUint32(data[i+12:], 12000)
+	if _, err := decodeData(data); err == nil || !strings.Contains(err.Error(), "像素过多") {
+		t.Fatalf("超大图片应当被拒绝: %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package imageproc
+
+import (
+	"image"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+// 按指定尺寸变换图片时的缩放方式
+const (
+	FitContain = "contain" // 等比缩放到指定尺寸以内，默认方式
+	FitCover   = "cover"   // 等比缩放铺满指定尺寸，超出部分居中裁剪
+	FitFill    = "fill"    // 拉伸到指定尺寸，不保持宽高比
+)
+
+// IsFitMode 判断缩放方式是否合法
+func IsFitMode(fit string) bool {
+	switch fit {
+	case FitContain, FitCover, FitFill:
+		return true
+	}
+	return false
+}
+
+// Transform 按宽高和缩放方式变换图片，宽高为0表示不限制，只缩小不放大
+// cover和fill需要同时指定宽高，否则按contain处理
+func Transform(img image.Image, width, height int, fit string) image.Image {
+	if width <= 0 && height <= 0 {
+		return img
+	}
+	if width <= 0 || height <= 0 || fit == "" || fit == FitContain {
+		if width <= 0 {
+			width = math.MaxInt32
+		}
+		if height <= 0 {
+			height = math.MaxInt32
+		}
+		return Resize(img, width, height)
+	}
+	bounds := img.Bounds()
+	if fit == FitCover {
+		// 先按目标宽高比居中裁剪，再缩小到目标尺寸
+		srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+		cropWidth, cropHeight := srcWidth, srcWidth*height/width
+		if cropHeight > srcHeight {
+			cropWidth, cropHeight = srcHeight*width/height, srcHeight
+		}
+		cropWidth, cropHeight = max(1, cropWidth), max(1, cropHeight)
+		x := bounds.Min.X + (srcWidth-cropWidth)/2
+		y := bounds.Min.Y + (srcHeight-cropHeight)/2
+		bounds = image.Rect(x, y, x+cropWidth, y+cropHeight)
+		width, height = min(width, cropWidth), min(height, cropHeight)
+	} else {
+		width, height = min(width, bounds.Dx()), min(height, bounds.Dy())
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
@@ -0,0 +1,207 @@
+package imageproc
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sync"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// 水印位置，九宫格之一或平铺
+const (
+	WatermarkTopLeft     = "top-left"
+	WatermarkTop         = "top"
+	WatermarkTopRight    = "top-right"
+	WatermarkLeft        = "left"
+	WatermarkCenter      = "center"
+	WatermarkRight       = "right"
+	WatermarkBottomLeft  = "bottom-left"
+	WatermarkBottom      = "bottom"
+	WatermarkBottomRight = "bottom-right"
+	WatermarkTile        = "tile"
+)
+
+// 计算文字宽度时使用的字号，实际字号按比例换算
+const measureFontSize = 64
+
+// Watermark 叠加到图片上的文字或图片水印
+type Watermark struct {
+	Text     string         // 文字水印内容，Image为nil时使用
+	Color    color.NRGBA    // 文字颜色
+	Font     *opentype.Font // 文字字体，为nil时使用Go Regular，该字体不包含中文字形
+	Image    image.Image    // 图片水印，不为nil时忽略Text
+	Position string         // 水印位置，为空时为右下角
+	Opacity  float64        // 不透明度(0,1]
+	Scale    float64        // 水印宽度占图片宽度的比例(0,1]
+}
+
+// IsWatermarkPosition 判断是否为支持的水印位置
+func IsWatermarkPosition(position string) bool {
+	switch position {
+	case WatermarkTopLeft, WatermarkTop, WatermarkTopRight,
+		WatermarkLeft, WatermarkCenter, WatermarkRight,
+		WatermarkBottomLeft, WatermarkBottom, WatermarkBottomRight, WatermarkTile:
+		return true
+	default:
+		return false
+	}
+}
+
+var (
+	defaultFontOnce sync.Once
+	defaultFont     *opentype.Font
+	defaultFontErr  error
+)
+
+// ParseFont 解析TrueType/OpenType字体文件
+func ParseFont(data []byte) (*opentype.Font, error) {
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("字体解析失败: %w", err)
+	}
+	return f, nil
+}
+
+// Apply 在图片上叠加水印，返回新的图片
+func (w *Watermark) Apply(img image.Image) image.Image {
+	return w.Applier()(img)
+}
+
+// Applier 返回叠加水印的函数，相同尺寸的图片只生成一次水印，用于动图逐帧处理
+// 水印生成失败时原样返回图片，返回的函数不能并发调用
+func (w *Watermark) Applier() func(image.Image) image.Image {
+	var cachedWidth int
+	var cachedMark image.Image
+	return func(img image.Image) image.Image {
+		bounds := img.Bounds()
+		width, height := bounds.Dx(), bounds.Dy()
+		markWidth := max(1, int(float64(width)*w.Scale+0.5))
+		if cachedMark == nil || cachedWidth != markWidth {
+			mark, err := w.render(markWidth)
+			if err != nil || mark == nil {
+				return img
+			}
+			cachedWidth, cachedMark = markWidth, mark
+		}
+		// 水印不超出图片的高度
+		mark := Resize(cachedMark, width, height)
+		dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+		draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+		opacity := math.Max(0, math.Min(w.Opacity, 1))
+		mask := image.NewUniform(color.Alpha{A: uint8(opacity*255 + 0.5)})
+		mb := mark.Bounds()
+		for _, pt := range w.positions(width, height, mb.Dx(), mb.Dy()) {
+			draw.DrawMask(dst, image.Rectangle{Min: pt, Max: pt.Add(mb.Size())}, mark, mb.Min, mask, image.Point{}, draw.Over)
+		}
+		return dst
+	}
+}
+
+// positions 计算水印左上角的坐标，平铺时返回多个坐标
+func (w *Watermark) positions(width, height, markWidth, markHeight int) []image.Point {
+	margin := min(width, height) / 50
+	if w.Position == WatermarkTile {
+		stepX, stepY := markWidth*2, max(markHeight*3, 1)
+		points := make([]image.Point, 0)
+		for row, y := 0, margin; y < height; row, y = row+1, y+stepY {
+			// 奇数行错开半个间隔
+			x := -markWidth + (row%2)*markWidth
+			for ; x < width; x += max(stepX, 1) {
+				points = append(points, image.Pt(x, y))
+			}
+		}
+		return points
+	}
+	left, centerX, right := margin, (width-markWidth)/2, width-markWidth-margin
+	top, centerY, bottom := margin, (height-markHeight)/2, height-markHeight-margin
+	switch w.Position {
+	case WatermarkTopLeft:
+		return []image.Point{{left, top}}
+	case WatermarkTop:
+		return []image.Point{{centerX, top}}
+	case WatermarkTopRight:
+		return []image.Point{{right, top}}
+	case WatermarkLeft:
+		return []image.Point{{left, centerY}}
+	case WatermarkCenter:
+		return []image.Point{{centerX, centerY}}
+	case WatermarkRight:
+		return []image.Point{{right, centerY}}
+	case WatermarkBottomLeft:
+		return []image.Point{{left, bottom}}
+	case WatermarkBottom:
+		return []image.Point{{centerX, bottom}}
+	default:
+		return []image.Point{{right, bottom}}
+	}
+}
+
+// render 生成指定宽度的水印图案
+func (w *Watermark) render(width int) (image.Image, error) {
+	if w.Image != nil {
+		bounds := w.Image.Bounds()
+		if bounds.Empty() {
+			return nil, nil
+		}
+		height := max(1, int(float64(bounds.Dy())*float64(width)/float64(bounds.Dx())+0.5))
+		dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), w.Image, bounds, draw.Src, nil)
+		return dst, nil
+	}
+	if w.Text == "" {
+		return nil, nil
+	}
+	f, err := w.font()
+	if err != nil {
+		return nil, err
+	}
+	// 按测量字号下的文字宽度换算实际字号，使文字宽度接近目标宽度
+	measureFace, err := opentype.NewFace(f, &opentype.FaceOptions{Size: measureFontSize, DPI: 72})
+	if err != nil {
+		return nil, err
+	}
+	advance := font.MeasureString(measureFace, w.Text).Ceil()
+	measureFace.Close()
+	if advance <= 0 {
+		return nil, nil
+	}
+	size := math.Max(6, measureFontSize*float64(width)/float64(advance))
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+	metrics := face.Metrics()
+	// 文字下方叠加一层半透明阴影，使水印在浅色背景上也能看清
+	shadow := max(1, int(size/24))
+	dst := image.NewNRGBA(image.Rect(0, 0,
+		font.MeasureString(face, w.Text).Ceil()+shadow, (metrics.Ascent+metrics.Descent).Ceil()+shadow))
+	drawer := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(color.NRGBA{A: 96}),
+		Face: face,
+		Dot:  fixed.P(shadow, metrics.Ascent.Ceil()+shadow),
+	}
+	drawer.DrawString(w.Text)
+	drawer.Src = image.NewUniform(w.Color)
+	drawer.Dot = fixed.P(0, metrics.Ascent.Ceil())
+	drawer.DrawString(w.Text)
+	return dst, nil
+}
+
+func (w *Watermark) font() (*opentype.Font, error) {
+	if w.Font != nil {
+		return w.Font, nil
+	}
+	defaultFontOnce.Do(func() {
+		defaultFont, defaultFontErr = ParseFont(goregular.TTF)
+	})
+	return defaultFont, defaultFontErr
+}
@@ -0,0 +1,105 @@
+package imageproc
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func solidImage(width, height int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// 统计区域内与背景色不同的像素数量
+func changedPixels(img image.Image, rect image.Rectangle, bg color.NRGBA) int {
+	n := 0
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if color.NRGBAModel.Convert(img.At(x, y)) != bg {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestWatermarkText(t *testing.T) {
+	black := color.NRGBA{A: 0xFF}
+	img := solidImage(400, 200, black)
+	wm := &Watermark{Text: "CanvasCloud", Color: color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}, Opacity: 0.8, Scale: 0.3}
+	marked := wm.Apply(img)
+	if marked.Bounds() != img.Bounds() {
+		t.Fatalf("尺寸不应变化: %v", marked.Bounds())
+	}
+	// 默认位于右下角，左上角保持不变
+	if n := changedPixels(marked, image.Rect(200, 100, 400, 200), black); n == 0 {
+		t.Fatal("右下角没有水印")
+	}
+	if n := changedPixels(marked, image.Rect(0, 0, 200, 100), black); n != 0 {
+		t.Fatalf("左上角不应有水印: %d", n)
+	}
+	// 原图不被修改
+	if changedPixels(img, img.Bounds(), black) != 0 {
+		t.Fatal("原图被修改")
+	}
+}
+
+func TestWatermarkImage(t *testing.T) {
+	white := color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}
+	img := solidImage(300, 300, white)
+	logo := solidImage(20, 10, color.NRGBA{R: 0xFF, A: 0xFF})
+
+	topLeft := (&Watermark{Image: logo, Position: WatermarkTopLeft, Opacity: 1, Scale: 0.2}).Apply(img)
+	// 水印宽度为图片宽度的20%，边距为短边的2%
+	if n := changedPixels(topLeft, topLeft.Bounds(), white); n != 60*30 {
+		t.Fatalf("水印面积错误: %d", n)
+	}
+	if got := color.NRGBAModel.Convert(topLeft.At(6, 6)); got != (color.NRGBA{R: 0xFF, A: 0xFF}) {
+		t.Fatalf("不透明度为1时应完全覆盖: %v", got)
+	}
+
+	tiled := (&Watermark{Image: logo, Position: WatermarkTile, Opacity: 0.5, Scale: 0.1}).Apply(img)
+	for _, rect := range []image.Rectangle{image.Rect(0, 0, 150, 150), image.Rect(150, 150, 300, 300)} {
+		if changedPixels(tiled, rect, white) == 0 {
+			t.Fatalf("平铺时 %v 没有水印", rect)
+		}
+	}
+	if got := color.NRGBAModel.Convert(tiled.At(35, 8)).(color.NRGBA); got.G == 0xFF || got.G == 0 {
+		t.Fatalf("半透明水印应与背景混合: %v", got)
+	}
+}
+
+func TestProcessWithWatermark(t *testing.T) {
+	bg := color.NRGBA{R: 0x73, G: 0x62, B: 0x46, A: 0xFF}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, solidImage(800, 400, bg)); err != nil {
+		t.Fatal(err)
+	}
+	wm := &Watermark{Image: solidImage(10, 10, color.NRGBA{A: 0xFF}), Position: WatermarkCenter, Opacity: 1, Scale: 0.25}
+	res, err := ProcessWithWatermark(buf.Bytes(), "png", wm, RenditionSpec{Name: "w200", Width: 200, Format: "png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 主色调基于未添加水印的图片
+	if res.Color != "0x736246" {
+		t.Fatalf("主色调错误: %s", res.Color)
+	}
+	for name, data := range map[string][]byte{"主图": res.WebP, "缩略图": res.Thumbnail, "衍生版本": res.Renditions[0].Data} {
+		img, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := img.Bounds()
+		if got := color.NRGBAModel.Convert(img.At(b.Dx()/2, b.Dy()/2)).(color.NRGBA); got.R > 0x10 {
+			t.Fatalf("%s中心没有水印: %v", name, got)
+		}
+	}
+}
@@ -118,6 +118,30 @@ func InitMq() error {
 		// 连接失败则终止程序
 	}
 
+	// 7.1 声明归档恢复队列，处理失败的消息进入死信队列
+	_, err = ch.QueueDeclare(
+		consts.MQArchiveRestoreQueueName,
+		true,  // 持久化
+		false, // 非自动删除
+		false, // 非排他
+		false, // 不等待
+		amqp.Table{
+			"x-dead-letter-exchange":    consts.MQDeadLetterExchangeName,
+			"x-dead-letter-routing-key": consts.MQDeadLetterRoutingKey,
+		})
+	if err != nil {
+		return err
+	}
+	err = ch.QueueBind(
+		consts.MQArchiveRestoreQueueName,
+		consts.MQArchiveRestoreRoutingKey,
+		consts.MQExchangeName,
+		false, // 不等待
+		nil)
+	if err != nil {
+		return err
+	}
+
 	// 8. 预创建通道并放入连接池
 	for i := 0; i < cap(connPool.pool); i++ {
 		channel, err := conn.Channel() // 创建新通道
@@ -191,6 +215,11 @@ func ReleaseChannel(ch *amqp.Channel) {
 
 // PublishMessage 向 RabbitMQ 发布消息
 func (connPool *ChannelPool) PublishMessage(message []byte) error {
+	return connPool.PublishMessageWithKey(consts.MQRoutingKey, message)
+}
+
+// PublishMessageWithKey 按指定的路由键发布消息，用于外绘任务之外的其他队列
+func (connPool *ChannelPool) PublishMessageWithKey(routingKey string, message []byte) error {
 	// 从池中获取通道
 	ch := <-connPool.pool
 	// 确保无论发生什么都将通道放回池中
@@ -201,7 +230,7 @@ func (connPool *ChannelPool) PublishMessage(message []byte) error {
 	// 使用通道发布消息到交换机
 	err := ch.Publish(
 		consts.MQExchangeName, // 交换机名称
-		consts.MQRoutingKey,   // 路由键 - 与队列绑定键匹配
+		routingKey,            // 路由键 - 与队列绑定键匹配
 		false,                 // mandatory: 如果为true，找不到路由时返回错误
 		false,                 // immediate: RabbitMQ已弃用(设置为false)
 		amqp.Publishing{ // 消息属性
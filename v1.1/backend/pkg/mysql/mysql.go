@@ -4,6 +4,7 @@ import (
 	"backend/config"
 	"backend/internal/model/entity"
 	"fmt"
+	"log"
 	"net/url"
 	"time"
 
@@ -13,6 +14,9 @@ import (
 
 var db *gorm.DB
 
+// 图片全文索引是否可用，不可用时搜索退化为LIKE查询
+var fullTextReady bool
+
 func Init(cfg *config.MySQLConfig) (err error) {
 	// 构建数据库连接DSN(Data Source Name)字符串
 	// 注意：对密码进行URL编码，防止特殊字符导致解析失败
@@ -54,6 +58,17 @@ func Init(cfg *config.MySQLConfig) (err error) {
 	entity.AutoMigrateSpace(db)
 	entity.AutoMigrateSpaceUser(db)
 	entity.AutoMigratePicture(db)
+	if err := entity.AutoMigratePictureFullText(db); err != nil {
+		log.Printf("图片全文索引创建失败，搜索将使用LIKE查询: %v", err)
+	} else {
+		fullTextReady = true
+	}
+	entity.AutoMigratePictureContent(db)
+	entity.AutoMigratePictureMetadata(db)
+	entity.AutoMigratePictureColor(db)
+	entity.AutoMigrateTag(db)
+	entity.AutoMigratePictureVersion(db)
+	entity.AutoMigratePictureArchive(db)
 	entity.AutoMigrateITask(db)
 	return nil
 }
@@ -74,3 +89,8 @@ func Close() {
 func LoadDB() *gorm.DB {
 	return db.Session(&gorm.Session{})
 }
+
+// FullTextReady 图片全文索引是否可用
+func FullTextReady() bool {
+	return fullTextReady
+}
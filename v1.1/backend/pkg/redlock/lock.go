@@ -1,6 +1,9 @@
 package redlock
 
 import (
+	"log"
+	"time"
+
 	"github.com/go-redsync/redsync/v4"
 	"github.com/go-redsync/redsync/v4/redis/goredis/v9"
 	"github.com/redis/go-redis/v9"
@@ -18,3 +21,29 @@ func InitRedSync(redClient *redis.Client) {
 func GetRedSync() *redsync.Redsync {
 	return rs
 }
+
+// 持有锁期间每隔interval延长一次过期时间，避免耗时操作超过锁的有效期后被其他请求重复执行
+// 返回的函数用于停止续期，应在释放锁之前调用
+func KeepAlive(mutex *redsync.Mutex, interval time.Duration) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if ok, err := mutex.Extend(); !ok || err != nil {
+					log.Printf("分布式锁 %s 续期失败: %v", mutex.Name(), err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
@@ -0,0 +1,283 @@
+package safefetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// 抓取用户提供的远程地址，防止SSRF：
+// 1.只允许http/https，连接建立时校验实际连接的IP，重定向和DNS重绑定后同样生效
+// 2.响应体边读边计数，超过上限立即中断
+// 3.整个请求有超时，下载的临时文件使用随机文件名
+
+var (
+	ErrInvalidURL       = errors.New("URL格式错误")
+	ErrSchemeNotAllowed = errors.New("仅支持 HTTP 或 HTTPS 协议的地址")
+	ErrBlockedAddress   = errors.New("不允许访问内网或保留地址")
+	ErrTooManyRedirects = errors.New("重定向次数过多")
+	ErrTooLarge         = errors.New("文件过大")
+	ErrContentType      = errors.New("文件类型不支持")
+	ErrTimeout          = errors.New("请求超时")
+	ErrUnexpectedStatus = errors.New("远程服务器返回错误状态")
+)
+
+// 除标准库能识别的私有、回环、链路本地等地址外，额外禁止的网段
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // 本网络
+	netip.MustParsePrefix("100.64.0.0/10"),   // 运营商级NAT，部分云厂商的元数据服务位于此网段
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF协议分配
+	netip.MustParsePrefix("192.0.2.0/24"),    // 文档示例
+	netip.MustParsePrefix("198.18.0.0/15"),   // 基准测试
+	netip.MustParsePrefix("198.51.100.0/24"), // 文档示例
+	netip.MustParsePrefix("203.0.113.0/24"),  // 文档示例
+	netip.MustParsePrefix("240.0.0.0/4"),     // 保留地址及广播
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64，可映射到任意IPv4地址
+	netip.MustParsePrefix("2001:db8::/32"),   // 文档示例
+}
+
+// Options 抓取参数
+type Options struct {
+	Timeout      time.Duration // 整个请求（含读取响应体）的超时时间
+	MaxSize      int64         // 响应体大小上限，单位字节
+	MaxRedirects int           // 最多跟随的重定向次数
+	ContentTypes []string      // 允许的Content-Type前缀，为空时不限制；响应没有Content-Type时放行
+	UserAgent    string        // 请求使用的User-Agent，为空时使用Go默认值
+}
+
+// Response 抓取结果
+type Response struct {
+	URL         string // 跟随重定向后的最终地址
+	ContentType string
+	Size        int64
+}
+
+// Fetcher 安全的远程抓取客户端，可以并发使用
+type Fetcher struct {
+	opts   Options
+	client *http.Client
+	// 判断地址是否允许连接，测试时可以替换
+	allowAddr func(netip.Addr) bool
+}
+
+// New 创建抓取客户端
+func New(opts Options) *Fetcher {
+	f := &Fetcher{opts: opts, allowAddr: IsPublicAddr}
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		// 在解析完成、即将建立连接时校验IP，避免DNS重绑定绕过事先的检查
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return ErrBlockedAddress
+			}
+			if !f.allowAddr(addrPort.Addr()) {
+				return ErrBlockedAddress
+			}
+			return nil
+		},
+	}
+	transport := &http.Transport{
+		// 不走环境变量中的代理，否则校验的是代理的地址
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+	f.client = &http.Client{
+		Transport: transport,
+		Timeout:   opts.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > opts.MaxRedirects {
+				return ErrTooManyRedirects
+			}
+			return checkScheme(req.URL)
+		},
+	}
+	return f
+}
+
+// IsPublicAddr 判断是否为允许访问的公网地址
+func IsPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() {
+		return false
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL 校验地址格式和协议，主机为IP字面量时同时校验IP
+// 域名在连接时才解析和校验，这里通过不代表一定能访问
+func CheckURL(rawURL string) error {
+	return checkURL(rawURL, IsPublicAddr)
+}
+
+func checkURL(rawURL string, allowAddr func(netip.Addr) bool) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ErrInvalidURL
+	}
+	if err := checkScheme(u); err != nil {
+		return err
+	}
+	if u.Host == "" {
+		return ErrInvalidURL
+	}
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil && !allowAddr(addr) {
+		return ErrBlockedAddress
+	}
+	return nil
+}
+
+// Fetch 抓取地址内容到内存，适合网页等较小的响应
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) ([]byte, *Response, error) {
+	resp, err := f.do(ctx, rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(f.limit(resp.Body))
+	if err != nil {
+		return nil, nil, f.wrap(err)
+	}
+	return body, f.response(resp, int64(len(body))), nil
+}
+
+// Download 将地址内容流式写入dir下的随机临时文件，返回文件路径，失败时不会留下临时文件
+// 调用方负责在使用后删除文件
+func (f *Fetcher) Download(ctx context.Context, rawURL string, dir string) (string, *Response, error) {
+	resp, err := f.do(ctx, rawURL)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", nil, err
+	}
+	file, err := os.CreateTemp(dir, "fetch-*")
+	if err != nil {
+		return "", nil, err
+	}
+	n, err := io.Copy(file, f.limit(resp.Body))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", nil, f.wrap(err)
+	}
+	return file.Name(), f.response(resp, n), nil
+}
+
+// 发起请求并校验状态码、Content-Type和声明的长度
+func (f *Fetcher) do(ctx context.Context, rawURL string) (*http.Response, error) {
+	if err := checkURL(rawURL, f.allowAddr); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, ErrInvalidURL
+	}
+	if f.opts.UserAgent != "" {
+		req.Header.Set("User-Agent", f.opts.UserAgent)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, f.wrap(err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %d", ErrUnexpectedStatus, resp.StatusCode)
+	}
+	if !f.allowContentType(resp.Header.Get("Content-Type")) {
+		resp.Body.Close()
+		return nil, ErrContentType
+	}
+	if f.opts.MaxSize > 0 && resp.ContentLength > f.opts.MaxSize {
+		resp.Body.Close()
+		return nil, ErrTooLarge
+	}
+	return resp, nil
+}
+
+func (f *Fetcher) allowContentType(contentType string) bool {
+	if len(f.opts.ContentTypes) == 0 || contentType == "" {
+		return true
+	}
+	contentType = strings.ToLower(contentType)
+	for _, prefix := range f.opts.ContentTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// 限制读取的字节数，多读一个字节用于判断是否超出上限
+func (f *Fetcher) limit(r io.Reader) io.Reader {
+	if f.opts.MaxSize <= 0 {
+		return r
+	}
+	return &limitedReader{r: io.LimitReader(r, f.opts.MaxSize+1), remain: f.opts.MaxSize}
+}
+
+func (f *Fetcher) response(resp *http.Response, size int64) *Response {
+	return &Response{
+		URL:         resp.Request.URL.String(),
+		ContentType: resp.Header.Get("Content-Type"),
+		Size:        size,
+	}
+}
+
+// 将底层错误转化为包内定义的错误
+func (f *Fetcher) wrap(err error) error {
+	for _, target := range []error{ErrBlockedAddress, ErrTooManyRedirects, ErrSchemeNotAllowed, ErrTooLarge} {
+		if errors.Is(err, target) {
+			return target
+		}
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return ErrTimeout
+	}
+	return err
+}
+
+func checkScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ErrSchemeNotAllowed
+	}
+	return nil
+}
+
+type limitedReader struct {
+	r      io.Reader
+	remain int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.remain -= int64(n)
+	if l.remain < 0 {
+		return n, ErrTooLarge
+	}
+	return n, err
+}
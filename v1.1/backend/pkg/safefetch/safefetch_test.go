@@ -0,0 +1,113 @@
+package safefetch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"8.8.8.8":          true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"100.100.100.200":  false,
+		"0.0.0.0":          false,
+		"::1":              false,
+		"fd00::1":          false,
+		"::ffff:127.0.0.1": false,
+	} {
+		if got := IsPublicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Fatalf("%s: 期望 %v", addr, want)
+		}
+	}
+	for rawURL, want := range map[string]error{
+		"https://example.com/a.png": nil,
+		"ftp://example.com/a.png":   ErrSchemeNotAllowed,
+		"file:///etc/passwd":        ErrSchemeNotAllowed,
+		"http://169.254.169.254/":   ErrBlockedAddress,
+		"http://[::1]:8080/":        ErrBlockedAddress,
+		"http:///no-host":           ErrInvalidURL,
+	} {
+		if err := CheckURL(rawURL); !errors.Is(err, want) {
+			t.Fatalf("%s: 期望 %v，实际 %v", rawURL, want, err)
+		}
+	}
+}
+
+func TestFetcher(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/img":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("0123456789"))
+		case "/big":
+			w.Header().Set("Content-Type", "image/png")
+			// 不声明长度，只能在读取时发现超限
+			w.(http.Flusher).Flush()
+			w.Write([]byte(strings.Repeat("x", 100)))
+		case "/html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+		case "/redirect":
+			http.Redirect(w, r, "/img", http.StatusFound)
+		case "/slow":
+			time.Sleep(300 * time.Millisecond)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	// 默认禁止访问回环地址
+	f := New(Options{Timeout: time.Second, MaxSize: 20, MaxRedirects: 2, ContentTypes: []string{"image/"}})
+	if _, _, err := f.Fetch(ctx, srv.URL+"/img"); !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("应当禁止访问回环地址: %v", err)
+	}
+
+	f.allowAddr = func(netip.Addr) bool { return true }
+	body, resp, err := f.Fetch(ctx, srv.URL+"/redirect")
+	if err != nil || string(body) != "0123456789" || !strings.HasSuffix(resp.URL, "/img") {
+		t.Fatalf("抓取失败: %v", err)
+	}
+	if _, _, err := f.Fetch(ctx, srv.URL+"/big"); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("应当限制大小: %v", err)
+	}
+	if _, _, err := f.Fetch(ctx, srv.URL+"/html"); !errors.Is(err, ErrContentType) {
+		t.Fatalf("应当限制类型: %v", err)
+	}
+	if _, _, err := f.Fetch(ctx, srv.URL+"/missing"); !errors.Is(err, ErrUnexpectedStatus) {
+		t.Fatalf("应当校验状态码: %v", err)
+	}
+	slow := New(Options{Timeout: 100 * time.Millisecond})
+	slow.allowAddr = f.allowAddr
+	if _, _, err := slow.Fetch(ctx, srv.URL+"/slow"); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("应当超时: %v", err)
+	}
+
+	// 临时文件名随机，失败时不留下文件
+	dir := t.TempDir()
+	path, resp, err := f.Download(ctx, srv.URL+"/img", dir)
+	if err != nil || resp.Size != 10 || filepath.Dir(path) != dir || !strings.HasPrefix(filepath.Base(path), "fetch-") {
+		t.Fatalf("下载失败: %s %v", path, err)
+	}
+	os.Remove(path)
+	if _, _, err := f.Download(ctx, srv.URL+"/big", dir); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("应当限制大小: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatal("失败时应当删除临时文件")
+	}
+}
@@ -0,0 +1,113 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ClamAV clamd守护进程的INSTREAM协议客户端，兼容该协议的其他扫描服务也可以使用
+// 协议：发送 zINSTREAM\0，随后是若干个4字节大端长度+数据的分块，以长度为0的分块结束
+// 返回 stream: OK 表示未发现问题，stream: <名称> FOUND 表示命中，<原因> ERROR 表示扫描失败
+
+const (
+	DefaultTimeout = 10 * time.Second // 默认单次扫描超时时间
+	chunkSize      = 64 << 10         // 每个分块的大小，需小于clamd的StreamMaxLength
+)
+
+// ErrClamAV clamd返回了错误
+var ErrClamAV = errors.New("clamd 扫描失败")
+
+// ClamAV clamd客户端，每次扫描使用一个新连接
+type ClamAV struct {
+	network string
+	address string
+	timeout time.Duration
+	onFound Verdict
+}
+
+// NewClamAV 创建clamd客户端
+// address 形如 tcp://127.0.0.1:3310 或 unix:///var/run/clamav/clamd.ctl，onFound为命中时采用的结论
+func NewClamAV(address string, timeout time.Duration, onFound Verdict) (*ClamAV, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("clamd 地址格式错误: %w", err)
+	}
+	c := &ClamAV{network: u.Scheme, timeout: timeout, onFound: onFound}
+	switch u.Scheme {
+	case "tcp":
+		c.address = u.Host
+	case "unix":
+		c.address = u.Path
+	default:
+		return nil, fmt.Errorf("clamd 地址只支持 tcp:// 或 unix://: %s", address)
+	}
+	if c.address == "" {
+		return nil, fmt.Errorf("clamd 地址格式错误: %s", address)
+	}
+	return c, nil
+}
+
+func (c *ClamAV) Name() string {
+	return "clamav"
+}
+
+// Scan 通过INSTREAM命令扫描数据
+func (c *ClamAV) Scan(ctx context.Context, data []byte) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return Result{}, err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return Result{}, err
+	}
+
+	w := bufio.NewWriter(conn)
+	w.WriteString("zINSTREAM\x00")
+	var size [4]byte
+	for len(data) > 0 {
+		n := min(len(data), chunkSize)
+		binary.BigEndian.PutUint32(size[:], uint32(n))
+		w.Write(size[:])
+		w.Write(data[:n])
+		data = data[n:]
+	}
+	binary.BigEndian.PutUint32(size[:], 0)
+	w.Write(size[:])
+	if err := w.Flush(); err != nil {
+		return Result{}, err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil && len(reply) == 0 {
+		return Result{}, err
+	}
+	return c.parseReply(string(bytes.TrimRight(reply, "\x00\n")))
+}
+
+// parseReply 解析clamd的回复，例如 stream: Eicar-Test-Signature FOUND
+func (c *ClamAV) parseReply(reply string) (Result, error) {
+	reply = strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case reply == "OK":
+		return Result{Verdict: Allow, Scanner: c.Name()}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return Result{Verdict: c.onFound, Scanner: c.Name(), Reason: strings.TrimSuffix(reply, " FOUND")}, nil
+	case strings.HasSuffix(reply, " ERROR"):
+		return Result{}, fmt.Errorf("%w: %s", ErrClamAV, strings.TrimSuffix(reply, " ERROR"))
+	default:
+		return Result{}, fmt.Errorf("%w: 无法识别的回复 %q", ErrClamAV, reply)
+	}
+}
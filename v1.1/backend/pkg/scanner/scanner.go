@@ -0,0 +1,151 @@
+package scanner
+
+import (
+	"backend/config"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 上传内容安全检查，在图片写入存储之前依次交给各个扫描器（杀毒、内容审核等）检查
+// 扫描器只给出结论，隔离和拒绝由调用方处理
+
+// Verdict 扫描结论，数值越大越严重
+type Verdict int
+
+const (
+	Allow      Verdict = iota // 放行
+	Quarantine                // 隔离，原图以私有权限保存到隔离区等待人工复核
+	Reject                    // 拒绝，直接丢弃
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Allow:
+		return "allow"
+	case Quarantine:
+		return "quarantine"
+	case Reject:
+		return "reject"
+	default:
+		return fmt.Sprintf("verdict(%d)", int(v))
+	}
+}
+
+// ParseVerdict 解析配置中的结论，为空时返回def
+func ParseVerdict(s string, def Verdict) (Verdict, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return def, nil
+	case "allow":
+		return Allow, nil
+	case "quarantine":
+		return Quarantine, nil
+	case "reject":
+		return Reject, nil
+	default:
+		return def, fmt.Errorf("不支持的扫描结论: %s", s)
+	}
+}
+
+// Result 扫描结果
+type Result struct {
+	Verdict Verdict
+	Scanner string // 给出结论的扫描器名称
+	Reason  string // 命中的规则或病毒名称，放行时为空
+}
+
+// Scanner 内容扫描器，需要可以并发使用
+type Scanner interface {
+	// Name 扫描器名称，用于日志和结果
+	Name() string
+	// Scan 检查文件内容，无法完成检查时返回error，由调用方按配置决定如何处理
+	Scan(ctx context.Context, data []byte) (Result, error)
+}
+
+// Chain 依次执行多个扫描器，取最严重的结论，遇到拒绝时不再继续
+type Chain struct {
+	scanners []Scanner
+	onError  Verdict // 扫描器出错时采用的结论
+}
+
+// NewChain 创建扫描链，onError为扫描器出错或超时时采用的结论
+func NewChain(onError Verdict, scanners ...Scanner) *Chain {
+	return &Chain{scanners: scanners, onError: onError}
+}
+
+func (c *Chain) Name() string {
+	return "chain"
+}
+
+// Scan 执行全部扫描器，出错时按onError处理并把错误信息写入Reason
+func (c *Chain) Scan(ctx context.Context, data []byte) (Result, error) {
+	result := Result{Verdict: Allow}
+	for _, s := range c.scanners {
+		r, err := s.Scan(ctx, data)
+		if err != nil {
+			r = Result{Verdict: c.onError, Scanner: s.Name(), Reason: "扫描失败: " + err.Error()}
+		}
+		if r.Verdict > result.Verdict {
+			result = r
+		}
+		if result.Verdict == Reject {
+			break
+		}
+	}
+	return result, nil
+}
+
+var defaultScanner Scanner
+
+// Init 根据配置初始化全局扫描器，未配置或未启用时不做检查
+func Init() error {
+	s, err := New(config.LoadConfig().ScannerConfig)
+	if err != nil {
+		return err
+	}
+	defaultScanner = s
+	return nil
+}
+
+// New 根据配置创建扫描链，未启用时返回nil
+func New(cfg *config.ScannerConfig) (Scanner, error) {
+	if cfg == nil || !cfg.Enabled {
+		return nil, nil
+	}
+	onError, err := ParseVerdict(cfg.OnError, Quarantine)
+	if err != nil {
+		return nil, err
+	}
+	var scanners []Scanner
+	if cfg.ClamAV != nil && cfg.ClamAV.Address != "" {
+		onFound, err := ParseVerdict(cfg.ClamAV.OnFound, Reject)
+		if err != nil {
+			return nil, err
+		}
+		timeout := time.Duration(cfg.ClamAV.TimeoutSeconds) * time.Second
+		if timeout <= 0 {
+			timeout = DefaultTimeout
+		}
+		clam, err := NewClamAV(cfg.ClamAV.Address, timeout, onFound)
+		if err != nil {
+			return nil, err
+		}
+		scanners = append(scanners, clam)
+	}
+	if len(scanners) == 0 {
+		return nil, fmt.Errorf("scanner 已启用但没有配置任何扫描器")
+	}
+	return NewChain(onError, scanners...), nil
+}
+
+// Get 获取全局扫描器，未启用时返回nil
+func Get() Scanner {
+	return defaultScanner
+}
+
+// Set 替换全局扫描器，便于测试时注入
+func Set(s Scanner) {
+	defaultScanner = s
+}
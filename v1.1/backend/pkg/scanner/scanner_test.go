@@ -0,0 +1,128 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// 模拟clamd：按INSTREAM协议读取数据，包含EICAR测试串时报告命中，内容为"error"时返回错误
+func startFakeClamd(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				cmd, err := r.ReadString(0)
+				if err != nil || cmd != "zINSTREAM\x00" {
+					conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+				var body bytes.Buffer
+				var size [4]byte
+				for {
+					if _, err := io.ReadFull(r, size[:]); err != nil {
+						return
+					}
+					n := binary.BigEndian.Uint32(size[:])
+					if n == 0 {
+						break
+					}
+					if _, err := io.CopyN(&body, r, int64(n)); err != nil {
+						return
+					}
+				}
+				switch {
+				case bytes.Contains(body.Bytes(), []byte(eicar)):
+					conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+				case body.String() == "error":
+					conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+				default:
+					conn.Write([]byte("stream: OK\x00"))
+				}
+			}(conn)
+		}
+	}()
+	return "tcp://" + ln.Addr().String()
+}
+
+type stubScanner struct {
+	result Result
+	err    error
+}
+
+func (s stubScanner) Name() string { return "stub" }
+
+func (s stubScanner) Scan(context.Context, []byte) (Result, error) {
+	return s.result, s.err
+}
+
+func TestClamAV(t *testing.T) {
+	clam, err := NewClamAV(startFakeClamd(t), time.Second, Quarantine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	// 超过一个分块的干净数据
+	r, err := clam.Scan(ctx, bytes.Repeat([]byte{0xFF}, chunkSize*2+10))
+	if err != nil || r.Verdict != Allow {
+		t.Fatalf("干净数据: %v %v", r, err)
+	}
+	r, err = clam.Scan(ctx, append(bytes.Repeat([]byte{1}, chunkSize), eicar...))
+	if err != nil || r.Verdict != Quarantine || r.Reason != "Eicar-Test-Signature" {
+		t.Fatalf("EICAR: %v %v", r, err)
+	}
+	if _, err = clam.Scan(ctx, []byte("error")); !errors.Is(err, ErrClamAV) {
+		t.Fatalf("期望 ErrClamAV，实际 %v", err)
+	}
+	if _, err := NewClamAV("http://127.0.0.1:3310", time.Second, Reject); err == nil {
+		t.Fatal("不支持的协议应返回错误")
+	}
+}
+
+func TestChain(t *testing.T) {
+	ctx := context.Background()
+	allow := stubScanner{result: Result{Verdict: Allow}}
+	quarantine := stubScanner{result: Result{Verdict: Quarantine, Reason: "可疑"}}
+	reject := stubScanner{result: Result{Verdict: Reject, Reason: "病毒"}}
+	failed := stubScanner{err: errors.New("连接失败")}
+
+	for _, c := range []struct {
+		chain *Chain
+		want  Verdict
+	}{
+		{NewChain(Reject), Allow},
+		{NewChain(Reject, allow, allow), Allow},
+		{NewChain(Reject, allow, quarantine), Quarantine},
+		{NewChain(Reject, quarantine, reject, allow), Reject},
+		{NewChain(Quarantine, allow, failed), Quarantine},
+		{NewChain(Allow, failed), Allow},
+	} {
+		r, err := c.chain.Scan(ctx, nil)
+		if err != nil || r.Verdict != c.want {
+			t.Fatalf("期望 %v，实际 %v %v", c.want, r, err)
+		}
+	}
+	r, _ := NewChain(Reject, failed).Scan(ctx, nil)
+	if !strings.Contains(r.Reason, "连接失败") {
+		t.Fatalf("出错时应记录原因: %v", r)
+	}
+}
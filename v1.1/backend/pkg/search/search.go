@@ -0,0 +1,144 @@
+// Package search 提供全文搜索的查询构造与结果高亮
+// 配合 MySQL FULLTEXT 索引（ngram 分词）使用，ngram 会把文本切成连续的 N 字片段，
+// 因此以短语方式查询一个词即等价于子串匹配，同时可以使用索引并获得相关度
+package search
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MinTermLen ngram_token_size 的默认值，比它短的词无法通过全文索引匹配
+const MinTermLen = 2
+
+// MaxTerms 一次搜索最多使用的关键词数量，多余的忽略
+const MaxTerms = 8
+
+// Terms 将搜索文本按空白和标点拆分为关键词，去除重复项，保留原有顺序
+func Terms(text string) []string {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		// 保留 + # - 等常出现在标签中的符号，例如 c++、c#
+		if r == '+' || r == '#' || r == '-' {
+			return false
+		}
+		return unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r)
+	})
+	terms := make([]string, 0, len(fields))
+	seen := make(map[string]bool)
+	for _, f := range fields {
+		key := strings.ToLower(f)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		terms = append(terms, f)
+		if len(terms) == MaxTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// Split 将关键词分为可以走全文索引的和长度不足、只能用LIKE匹配的两组
+func Split(terms []string) (indexed []string, short []string) {
+	for _, t := range terms {
+		if utf8.RuneCountInString(t) < MinTermLen {
+			short = append(short, t)
+		} else {
+			indexed = append(indexed, t)
+		}
+	}
+	return indexed, short
+}
+
+// BooleanQuery 构造 BOOLEAN MODE 的查询串，每个关键词作为短语匹配
+// required 为true时所有关键词都必须出现（+"a" +"b"），用于过滤；为false时只用于计算相关度
+func BooleanQuery(terms []string, required bool) string {
+	var b strings.Builder
+	for _, t := range terms {
+		// 短语内部只有双引号有特殊含义，直接去掉
+		t = strings.ReplaceAll(t, `"`, "")
+		if t == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		if required {
+			b.WriteByte('+')
+		}
+		b.WriteByte('"')
+		b.WriteString(t)
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+// EscapeLike 转义LIKE中的通配符
+func EscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// Highlight 对文本做HTML转义，并用<em>标签包裹命中的关键词（不区分大小写），没有命中时返回空字符串
+func Highlight(text string, terms []string) string {
+	if text == "" || len(terms) == 0 {
+		return ""
+	}
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	// 收集所有命中区间 [start, end)
+	var spans [][2]int
+	for _, t := range terms {
+		tr := []rune(strings.ToLower(t))
+		if len(tr) == 0 || len(tr) > len(lower) {
+			continue
+		}
+		for i := 0; i+len(tr) <= len(lower); i++ {
+			if equalRunes(lower[i:i+len(tr)], tr) {
+				spans = append(spans, [2]int{i, i + len(tr)})
+			}
+		}
+	}
+	if len(spans) == 0 {
+		return ""
+	}
+	// 合并重叠或相邻的区间
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	merged := spans[:1]
+	for _, s := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if s[0] <= last[1] {
+			if s[1] > last[1] {
+				last[1] = s[1]
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+	var b strings.Builder
+	pos := 0
+	for _, s := range merged {
+		b.WriteString(html.EscapeString(string(runes[pos:s[0]])))
+		b.WriteString("<em>")
+		b.WriteString(html.EscapeString(string(runes[s[0]:s[1]])))
+		b.WriteString("</em>")
+		pos = s[1]
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:])))
+	return b.String()
+}
+
+func equalRunes(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTerms(t *testing.T) {
+	got := Terms("  风景，日落 Sunset sunset c++ ")
+	want := []string{"风景", "日落", "Sunset", "c++"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Terms = %v, want %v", got, want)
+	}
+	if len(Terms("a b c d e f g h i j")) != MaxTerms {
+		t.Fatal("Terms should be limited to MaxTerms")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	indexed, short := Split([]string{"猫", "小猫", "a", "go"})
+	if !reflect.DeepEqual(indexed, []string{"小猫", "go"}) || !reflect.DeepEqual(short, []string{"猫", "a"}) {
+		t.Fatalf("Split = %v %v", indexed, short)
+	}
+}
+
+func TestBooleanQuery(t *testing.T) {
+	if got := BooleanQuery([]string{"小猫", `a"b`}, true); got != `+"小猫" +"ab"` {
+		t.Fatalf("required query = %s", got)
+	}
+	if got := BooleanQuery([]string{"小猫", `"`}, false); got != `"小猫"` {
+		t.Fatalf("optional query = %s", got)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	cases := []struct {
+		text  string
+		terms []string
+		want  string
+	}{
+		{"海边的日落", []string{"日落"}, "海边的<em>日落</em>"},
+		{"Sunset at SEA", []string{"sea", "sun"}, "<em>Sun</em>set at <em>SEA</em>"},
+		{"abcd", []string{"ab", "bc"}, "<em>abc</em>d"},
+		{"<b>猫</b>", []string{"猫"}, "&lt;b&gt;<em>猫</em>&lt;/b&gt;"},
+		{"没有命中", []string{"狗"}, ""},
+	}
+	for _, c := range cases {
+		if got := Highlight(c.text, c.terms); got != c.want {
+			t.Errorf("Highlight(%q, %v) = %q, want %q", c.text, c.terms, got, c.want)
+		}
+	}
+}
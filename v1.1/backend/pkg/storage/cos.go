@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"backend/config"
+	"backend/pkg/tcos"
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/tencentyun/cos-go-sdk-v5"
+)
+
+// 腾讯云COS存储
+type cosStore struct {
+	host string
+}
+
+func newCOSStore() (*cosStore, error) {
+	if err := tcos.Init(); err != nil {
+		return nil, err
+	}
+	return &cosStore{host: config.LoadConfig().Tcos.Host}, nil
+}
+
+func (s *cosStore) PutObject(key string, r io.Reader, contentType string) error {
+	opt := &cos.ObjectPutOptions{
+		ObjectPutHeaderOptions: &cos.ObjectPutHeaderOptions{
+			ContentType: contentType,
+		},
+	}
+	_, err := tcos.LoadDB().Object.Put(context.Background(), key, r, opt)
+	return err
+}
+
+func (s *cosStore) PutPrivateObject(key string, r io.Reader, contentType string) error {
+	opt := &cos.ObjectPutOptions{
+		ACLHeaderOptions: &cos.ACLHeaderOptions{
+			XCosACL: "private",
+		},
+		ObjectPutHeaderOptions: &cos.ObjectPutHeaderOptions{
+			ContentType: contentType,
+		},
+	}
+	_, err := tcos.LoadDB().Object.Put(context.Background(), key, r, opt)
+	return err
+}
+
+func (s *cosStore) PutArchiveObject(key string, r io.Reader, contentType string, storageClass string) error {
+	opt := &cos.ObjectPutOptions{
+		ACLHeaderOptions: &cos.ACLHeaderOptions{
+			XCosACL: "private",
+		},
+		ObjectPutHeaderOptions: &cos.ObjectPutHeaderOptions{
+			ContentType:      contentType,
+			XCosStorageClass: storageClass,
+		},
+	}
+	_, err := tcos.LoadDB().Object.Put(context.Background(), key, r, opt)
+	return err
+}
+
+func (s *cosStore) SignObjectURL(key string, expire time.Duration) (string, error) {
+	c := config.LoadConfig().Tcos
+	u, err := tcos.LoadDB().Object.GetPresignedURL(context.Background(), http.MethodGet, key, c.SecretID, c.SecretKey, expire, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (s *cosStore) GetObject(key string) (io.ReadCloser, error) {
+	return tcos.GetObject(key)
+}
+
+func (s *cosStore) DeleteObject(key string) error {
+	return tcos.DeleteObject(key)
+}
+
+func (s *cosStore) PresignPutObject(key string, expire time.Duration) (string, error) {
+	c := config.LoadConfig().Tcos
+	u, err := tcos.LoadDB().Object.GetPresignedURL(context.Background(), http.MethodPut, key, c.SecretID, c.SecretKey, expire, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (s *cosStore) ListObjects(prefix string, fn func(ObjectInfo) error) error {
+	marker := ""
+	for {
+		res, _, err := tcos.LoadDB().Bucket.Get(context.Background(), &cos.BucketGetOptions{
+			Prefix:  prefix,
+			Marker:  marker,
+			MaxKeys: 1000,
+		})
+		if err != nil {
+			return err
+		}
+		for _, obj := range res.Contents {
+			lastModified, _ := time.Parse(time.RFC3339, obj.LastModified)
+			if err := fn(ObjectInfo{Key: obj.Key, Size: obj.Size, LastModified: lastModified}); err != nil {
+				return err
+			}
+		}
+		if !res.IsTruncated {
+			return nil
+		}
+		marker = res.NextMarker
+	}
+}
+
+func (s *cosStore) ObjectURL(key string) string {
+	return joinURL(s.host, key)
+}
+
+func (s *cosStore) Type() string {
+	return TypeCOS
+}
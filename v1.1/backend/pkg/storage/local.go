@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"backend/config"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 本地文件系统存储，对象按key的目录结构保存在Root下
+type localStore struct {
+	root   string
+	host   string
+	secret []byte // 签名URL使用的密钥
+}
+
+func newLocalStore(cfg *config.LocalStorageConfig) (*localStore, error) {
+	if cfg.Root == "" {
+		return nil, fmt.Errorf("storage.local.root 不能为空")
+	}
+	root, err := filepath.Abs(cfg.Root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("创建存储目录失败: %w", err)
+	}
+	secret := []byte(cfg.SignSecret)
+	if len(secret) == 0 {
+		//未配置时随机生成，重启后之前签发的URL失效
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		log.Println("storage.local.sign_secret 未配置，使用随机密钥")
+	}
+	return &localStore{root: root, host: cfg.Host, secret: secret}, nil
+}
+
+// NewLocalStore 创建本地文件系统存储，供测试和工具使用
+func NewLocalStore(root, host string) (ObjectStore, error) {
+	return newLocalStore(&config.LocalStorageConfig{Root: root, Host: host})
+}
+
+// 将key转化为本地路径，禁止跳出根目录
+func (s *localStore) path(key string) (string, error) {
+	clean := filepath.Clean("/" + filepath.FromSlash(key))
+	p := filepath.Join(s.root, clean)
+	if !strings.HasPrefix(p, s.root+string(os.PathSeparator)) {
+		return "", fmt.Errorf("非法的对象key: %s", key)
+	}
+	return p, nil
+}
+
+func (s *localStore) PutObject(key string, r io.Reader, contentType string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+		return err
+	}
+	//先写临时文件再重命名，避免读到写了一半的文件
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// 本地存储没有访问权限的概念，私有对象由NewLocalFileHandler校验签名后再提供访问
+func (s *localStore) PutPrivateObject(key string, r io.Reader, contentType string) error {
+	return s.PutObject(key, r, contentType)
+}
+
+func (s *localStore) SignObjectURL(key string, expire time.Duration) (string, error) {
+	expires := strconv.FormatInt(time.Now().Add(expire).Unix(), 10)
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("sign", s.sign(key, expires))
+	return s.ObjectURL(key) + "?" + query.Encode(), nil
+}
+
+func (s *localStore) sign(key string, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// 校验签名URL中的参数，过期或签名不一致时返回false
+func (s *localStore) verify(key string, query url.Values) bool {
+	expires := query.Get("expires")
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return false
+	}
+	return hmac.Equal([]byte(query.Get("sign")), []byte(s.sign(key, expires)))
+}
+
+// NewLocalFileHandler 创建本地存储的文件访问处理器，urlPrefix为路由前缀
+// isPrivate返回true的对象必须携带有效的签名参数才能访问
+func NewLocalFileHandler(urlPrefix string, isPrivate func(key string) bool) (http.Handler, error) {
+	s, ok := store.(*localStore)
+	if !ok {
+		return nil, fmt.Errorf("当前存储不是本地存储")
+	}
+	prefix := strings.TrimRight(urlPrefix, "/") + "/"
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, prefix)
+		p, err := s.path(key)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if isPrivate(key) && !s.verify(key, r.URL.Query()) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		http.ServeFile(w, r, p)
+	}), nil
+}
+
+func (s *localStore) GetObject(key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (s *localStore) DeleteObject(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *localStore) ListObjects(prefix string, fn func(ObjectInfo) error) error {
+	//从前缀所在的目录开始遍历，避免扫描整个根目录
+	start := s.root
+	if dir := path.Dir(prefix); dir != "." && dir != "/" {
+		p, err := s.path(dir)
+		if err != nil {
+			return err
+		}
+		start = p
+	}
+	err := filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		//跳过写入中的临时文件
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(ObjectInfo{Key: key, Size: info.Size(), LastModified: info.ModTime()})
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *localStore) ObjectURL(key string) string {
+	return joinURL(s.host, key)
+}
+
+func (s *localStore) Type() string {
+	return TypeLocal
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalStore(t *testing.T) {
+	s, err := NewLocalStore(t.TempDir(), "http://localhost:8001/storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "space/1/2025-01-01_abc.webp"
+	if err := s.PutObject(key, strings.NewReader("hello"), "image/webp"); err != nil {
+		t.Fatal(err)
+	}
+	r, err := s.GetObject(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "hello" {
+		t.Fatalf("读取内容不一致: %q", data)
+	}
+	if got := s.ObjectURL(key); got != "http://localhost:8001/storage/"+key {
+		t.Fatalf("URL错误: %s", got)
+	}
+	// 按前缀列举对象
+	var keys []string
+	err = s.(Lister).ListObjects("space/", func(obj ObjectInfo) error {
+		keys = append(keys, obj.Key)
+		return nil
+	})
+	if err != nil || len(keys) != 1 || keys[0] != key {
+		t.Fatalf("列举对象错误: %v %v", keys, err)
+	}
+	if err := s.DeleteObject(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteObject(key); err != nil {
+		t.Fatalf("删除不存在的对象不应报错: %v", err)
+	}
+	// key中的 .. 不能跳出根目录，只会落在根目录内
+	if err := s.PutObject("../../escape.txt", strings.NewReader("x"), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	r, err = s.GetObject("escape.txt")
+	if err != nil {
+		t.Fatalf("对象应当被限制在根目录内: %v", err)
+	}
+	r.Close()
+}
+
+func TestLocalFileHandler(t *testing.T) {
+	s, err := NewLocalStore(t.TempDir(), "http://localhost:8001/storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetStore(s)
+	defer SetStore(nil)
+	for _, key := range []string{"space/1/a.webp", "public/1/b.webp"} {
+		if err := PutPrivateObject(key, strings.NewReader("hello"), "image/webp"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h, err := NewLocalFileHandler("/storage", func(key string) bool {
+		return strings.HasPrefix(key, "space/")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(target string) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w.Code
+	}
+	if code := get("/storage/public/1/b.webp"); code != http.StatusOK {
+		t.Fatalf("公开对象应当可以直接访问: %d", code)
+	}
+	if code := get("/storage/space/1/a.webp"); code != http.StatusForbidden {
+		t.Fatalf("私有对象未签名时应当拒绝访问: %d", code)
+	}
+	signed, err := SignObjectURL(s.ObjectURL("space/1/a.webp"), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := strings.TrimPrefix(signed, "http://localhost:8001")
+	if code := get(target); code != http.StatusOK {
+		t.Fatalf("签名后的私有对象应当可以访问: %d", code)
+	}
+	// 签名与key绑定，不能用于其他对象
+	if code := get(strings.Replace(target, "a.webp", "c.webp", 1)); code != http.StatusForbidden {
+		t.Fatalf("篡改key后签名应当失效: %d", code)
+	}
+	expired, _ := s.(PrivateStore).SignObjectURL("space/1/a.webp", -time.Minute)
+	if code := get(strings.TrimPrefix(expired, "http://localhost:8001")); code != http.StatusForbidden {
+		t.Fatalf("过期的签名应当失效: %d", code)
+	}
+}
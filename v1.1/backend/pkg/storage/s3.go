@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"backend/config"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3协议兼容存储，可对接AWS S3、MinIO等
+type s3Store struct {
+	client *minio.Client
+	bucket string
+	host   string
+}
+
+func newS3Store(cfg *config.S3StorageConfig) (*s3Store, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建S3客户端失败: %w", err)
+	}
+	//检查存储桶是否存在
+	exists, err := client.BucketExists(context.Background(), cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("连接S3失败: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("存储桶 %s 不存在", cfg.Bucket)
+	}
+	host := cfg.Host
+	if host == "" {
+		scheme := "http"
+		if cfg.UseSSL {
+			scheme = "https"
+		}
+		host = fmt.Sprintf("%s://%s/%s", scheme, cfg.Endpoint, cfg.Bucket)
+	}
+	return &s3Store{client: client, bucket: cfg.Bucket, host: host}, nil
+}
+
+func (s *s3Store) PutObject(key string, r io.Reader, contentType string) error {
+	_, err := s.client.PutObject(context.Background(), s.bucket, key, r, -1, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	return err
+}
+
+// 对象的可见性还受存储桶策略影响，公开读的策略只应覆盖公共图库和头像的前缀
+func (s *s3Store) PutPrivateObject(key string, r io.Reader, contentType string) error {
+	_, err := s.client.PutObject(context.Background(), s.bucket, key, r, -1, minio.PutObjectOptions{
+		ContentType:  contentType,
+		UserMetadata: map[string]string{"x-amz-acl": "private"},
+	})
+	return err
+}
+
+func (s *s3Store) PutArchiveObject(key string, r io.Reader, contentType string, storageClass string) error {
+	_, err := s.client.PutObject(context.Background(), s.bucket, key, r, -1, minio.PutObjectOptions{
+		ContentType:  contentType,
+		StorageClass: storageClass,
+		UserMetadata: map[string]string{"x-amz-acl": "private"},
+	})
+	return err
+}
+
+func (s *s3Store) SignObjectURL(key string, expire time.Duration) (string, error) {
+	u, err := s.client.PresignedGetObject(context.Background(), s.bucket, key, expire, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (s *s3Store) GetObject(key string) (io.ReadCloser, error) {
+	obj, err := s.client.GetObject(context.Background(), s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	//minio的GetObject是惰性的，需要Stat确认对象存在
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (s *s3Store) DeleteObject(key string) error {
+	return s.client.RemoveObject(context.Background(), s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *s3Store) PresignPutObject(key string, expire time.Duration) (string, error) {
+	u, err := s.client.PresignedPutObject(context.Background(), s.bucket, key, expire)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (s *s3Store) ListObjects(prefix string, fn func(ObjectInfo) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return obj.Err
+		}
+		if err := fn(ObjectInfo{Key: obj.Key, Size: obj.Size, LastModified: obj.LastModified}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *s3Store) ObjectURL(key string) string {
+	return joinURL(s.host, key)
+}
+
+func (s *s3Store) Type() string {
+	return TypeS3
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"backend/config"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// 存储后端类型
+const (
+	TypeCOS   = "cos"
+	TypeS3    = "s3"
+	TypeLocal = "local"
+)
+
+// ObjectStore 对象存储的统一抽象，业务代码只依赖该接口，不再直接调用某一家云厂商的SDK
+// key是对象在存储中的唯一标识，例如"space/1/2025-01-01_xxx.webp"
+type ObjectStore interface {
+	// 上传数据流
+	PutObject(key string, r io.Reader, contentType string) error
+	// 获取对象内容流，使用完毕后需要关闭
+	GetObject(key string) (io.ReadCloser, error)
+	// 删除对象，对象不存在时不返回错误
+	DeleteObject(key string) error
+	// 获取对象对外访问的URL
+	ObjectURL(key string) string
+	// 存储后端类型
+	Type() string
+}
+
+// Presigner 支持预签名直传的存储后端，客户端拿到URL后可以不经过服务端直接PUT对象
+type Presigner interface {
+	PresignPutObject(key string, expire time.Duration) (string, error)
+}
+
+// PrivateStore 支持私有对象的存储后端，私有对象不能通过ObjectURL直接访问，只能使用签名后的临时URL
+type PrivateStore interface {
+	// 以私有读权限上传数据流
+	PutPrivateObject(key string, r io.Reader, contentType string) error
+	// 生成带签名的临时访问URL
+	SignObjectURL(key string, expire time.Duration) (string, error)
+}
+
+// ArchiveStore 支持存储类型的存储后端，用于将长期未访问的对象转为低频或归档存储
+type ArchiveStore interface {
+	// 以私有读权限和指定的存储类型上传数据流
+	PutArchiveObject(key string, r io.Reader, contentType string, storageClass string) error
+}
+
+// ObjectInfo 列举对象时返回的对象信息
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// Lister 支持按前缀列举对象的存储后端，用于存储回收等后台任务
+// fn返回错误时停止列举并返回该错误
+type Lister interface {
+	ListObjects(prefix string, fn func(ObjectInfo) error) error
+}
+
+// ErrListNotSupported 当前存储后端不支持列举对象
+var ErrListNotSupported = errors.New("当前存储后端不支持列举对象")
+
+// ErrPresignNotSupported 当前存储后端不支持预签名直传
+var ErrPresignNotSupported = errors.New("当前存储后端不支持预签名上传")
+
+// ErrPrivateNotSupported 当前存储后端不支持私有对象
+var ErrPrivateNotSupported = errors.New("当前存储后端不支持私有对象")
+
+var store ObjectStore
+
+// Init 根据配置初始化对象存储，未配置时默认使用腾讯云COS
+func Init() error {
+	s, err := New(config.LoadConfig().StorageConfig)
+	if err != nil {
+		return err
+	}
+	store = s
+	return nil
+}
+
+// New 根据配置创建对象存储实例
+func New(cfg *config.StorageConfig) (ObjectStore, error) {
+	storageType := TypeCOS
+	if cfg != nil && cfg.Type != "" {
+		storageType = strings.ToLower(cfg.Type)
+	}
+	switch storageType {
+	case TypeCOS:
+		return newCOSStore()
+	case TypeS3:
+		if cfg.S3 == nil {
+			return nil, fmt.Errorf("storage.s3 配置缺失")
+		}
+		return newS3Store(cfg.S3)
+	case TypeLocal:
+		if cfg.Local == nil {
+			return nil, fmt.Errorf("storage.local 配置缺失")
+		}
+		return newLocalStore(cfg.Local)
+	default:
+		return nil, fmt.Errorf("不支持的存储类型: %s", storageType)
+	}
+}
+
+// GetStore 获取全局对象存储实例
+func GetStore() ObjectStore {
+	return store
+}
+
+// SetStore 替换全局对象存储实例，便于测试时注入本地存储
+func SetStore(s ObjectStore) {
+	store = s
+}
+
+// PresignPutObject 使用全局存储生成预签名上传URL
+func PresignPutObject(key string, expire time.Duration) (string, error) {
+	p, ok := store.(Presigner)
+	if !ok {
+		return "", ErrPresignNotSupported
+	}
+	return p.PresignPutObject(key, expire)
+}
+
+// PutPrivateObject 使用全局存储上传私有对象
+func PutPrivateObject(key string, r io.Reader, contentType string) error {
+	p, ok := store.(PrivateStore)
+	if !ok {
+		return ErrPrivateNotSupported
+	}
+	return p.PutPrivateObject(key, r, contentType)
+}
+
+// PutArchiveObject 使用全局存储上传归档对象，存储类型为空或后端不支持存储类型时作为普通私有对象上传
+func PutArchiveObject(key string, r io.Reader, contentType string, storageClass string) error {
+	if a, ok := store.(ArchiveStore); ok && storageClass != "" {
+		return a.PutArchiveObject(key, r, contentType, storageClass)
+	}
+	return PutPrivateObject(key, r, contentType)
+}
+
+// SignObjectURL 使用全局存储为对象URL生成临时访问URL，URL不属于当前存储时原样返回
+func SignObjectURL(objectURL string, expire time.Duration) (string, error) {
+	key, ok := ObjectKey(objectURL)
+	if !ok {
+		return objectURL, nil
+	}
+	p, ok := store.(PrivateStore)
+	if !ok {
+		return "", ErrPrivateNotSupported
+	}
+	return p.SignObjectURL(key, expire)
+}
+
+// ListObjects 使用全局存储按前缀列举对象
+func ListObjects(prefix string, fn func(ObjectInfo) error) error {
+	l, ok := store.(Lister)
+	if !ok {
+		return ErrListNotSupported
+	}
+	return l.ListObjects(prefix, fn)
+}
+
+// ObjectKey 从对象URL中解析出key，URL不属于当前存储时返回false
+// 会去掉URL中的查询参数，兼容旧数据中带有处理参数的URL
+func ObjectKey(objectURL string) (string, bool) {
+	if i := strings.IndexByte(objectURL, '?'); i >= 0 {
+		objectURL = objectURL[:i]
+	}
+	prefix := store.ObjectURL("")
+	if objectURL == "" || !strings.HasPrefix(objectURL, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(objectURL, prefix), true
+}
+
+// joinURL 拼接访问域名和对象key
+func joinURL(host, key string) string {
+	return strings.TrimRight(host, "/") + "/" + strings.TrimLeft(key, "/")
+}
@@ -1,9 +1,12 @@
 package router
 
 import (
+	"backend/config"
 	_ "backend/docs"
+	"backend/internal/manager"
 	"backend/internal/manager/websocket"
 	"backend/internal/midwares"
+	"backend/pkg/storage"
 	"backend/router/v1"
 	"fmt"
 	"github.com/gin-contrib/cors" // 修复导入路径错误
@@ -55,6 +58,21 @@ func Setup(mode string) *gin.Engine {
 	// 单独注册websocket路由
 	r.GET("/ws/picture/edit", midwares.JWTAuthMiddleware(), websocket.PictureEditHandShake)
 
+	// 本地文件系统存储时，由本服务直接提供图片访问，私有对象需要校验签名
+	if storage.GetStore() != nil && storage.GetStore().Type() == storage.TypeLocal {
+		local := config.LoadConfig().StorageConfig.Local
+		prefix := local.URLPrefix
+		if prefix == "" {
+			prefix = "/storage"
+		}
+		handler, err := storage.NewLocalFileHandler(prefix, manager.IsPrivateObjectKey)
+		if err != nil {
+			panic(err)
+		}
+		r.GET(prefix+"/*key", gin.WrapH(handler))
+		r.HEAD(prefix+"/*key", gin.WrapH(handler))
+	}
+
 	// Swagger文档路由
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 	return r
@@ -15,6 +15,7 @@ func RegisterV1Routes(apiV1 *gin.RouterGroup) {
 	registerSpaceAnalyzeRoutes(apiV1)
 	registerFileRoutes(apiV1)
 	registerPictureRoutes(apiV1)
+	registerStorageRoutes(apiV1)
 }
 
 func registerUserRoutes(apiV1 *gin.RouterGroup) {
@@ -43,6 +44,8 @@ func registerSpaceRoutes(apiV1 *gin.RouterGroup) {
 	{
 		spaceAPI.POST("/update", midwares.JWTAuthMiddleware(), midwares.AuthCheck(consts.ADMIN_ROLE), controller.UpdateSpace)
 		spaceAPI.POST("/edit", midwares.JWTAuthMiddleware(), controller.EditSpace)
+		spaceAPI.POST("/watermark/edit", midwares.JWTAuthMiddleware(), controller.EditSpaceWatermark)
+		spaceAPI.POST("/watermark/image", midwares.JWTAuthMiddleware(), controller.UploadSpaceWatermarkImage)
 		spaceAPI.POST("/list/page", midwares.JWTAuthMiddleware(), midwares.AuthCheck(consts.ADMIN_ROLE), controller.ListSpaceByPage)
 		spaceAPI.POST("/list/page/vo", midwares.JWTAuthMiddleware(), controller.ListSpaceVOByPage)
 		spaceAPI.POST("/add", midwares.JWTAuthMiddleware(), midwares.JWTAuthMiddleware(), controller.AddSpace)
@@ -92,17 +95,42 @@ func registerPictureRoutes(apiV1 *gin.RouterGroup) {
 	{
 		pictureAPI.POST("/upload", midwares.JWTAuthMiddleware(), controller.UploadPicture)
 		pictureAPI.POST("/upload/url", midwares.JWTAuthMiddleware(), controller.UploadPictureByUrl)
+		pictureAPI.POST("/upload/session/init", midwares.JWTAuthMiddleware(), controller.InitUploadSession)
+		pictureAPI.POST("/upload/session/part", midwares.JWTAuthMiddleware(), controller.UploadSessionPart)
+		pictureAPI.GET("/upload/session/get", midwares.JWTAuthMiddleware(), controller.GetUploadSession)
+		pictureAPI.POST("/upload/session/complete", midwares.JWTAuthMiddleware(), controller.CompleteUploadSession)
+		pictureAPI.POST("/upload/session/abort", midwares.JWTAuthMiddleware(), controller.AbortUploadSession)
+		pictureAPI.POST("/upload/presign", midwares.JWTAuthMiddleware(), controller.PresignUpload)
+		pictureAPI.POST("/upload/presign/confirm", midwares.JWTAuthMiddleware(), controller.ConfirmPresignUpload)
 		pictureAPI.POST("/upload/batch", midwares.JWTAuthMiddleware(), midwares.AuthCheck(consts.ADMIN_ROLE), controller.UploadPictureByBatch)
 		pictureAPI.POST("/delete", midwares.JWTAuthMiddleware(), controller.DeletePicture)
 		pictureAPI.POST("/update", midwares.JWTAuthMiddleware(), controller.UpdatePicture)
 		pictureAPI.POST("/edit", midwares.JWTAuthMiddleware(), controller.EditPicture)
 		pictureAPI.GET("/get", midwares.JWTAuthMiddleware(), midwares.AuthCheck(consts.ADMIN_ROLE), controller.GetPictureById)
 		pictureAPI.GET("/get/vo", midwares.JWTAuthMiddleware(), controller.GetPictureVOById)
+		pictureAPI.GET("/render/:id", midwares.JWTAuthUnlessSigned(), controller.RenderPicture)
+		pictureAPI.GET("/original", midwares.JWTAuthMiddleware(), controller.GetPictureOriginal)
+		pictureAPI.GET("/version/list", midwares.JWTAuthMiddleware(), controller.ListPictureVersions)
+		pictureAPI.GET("/version/get", midwares.JWTAuthMiddleware(), controller.GetPictureVersion)
+		pictureAPI.POST("/version/restore", midwares.JWTAuthMiddleware(), controller.RestorePictureVersion)
+		pictureAPI.POST("/recycle/list", midwares.JWTAuthMiddleware(), controller.ListRecyclePictures)
+		pictureAPI.POST("/recycle/restore", midwares.JWTAuthMiddleware(), controller.RestoreRecyclePictures)
+		pictureAPI.POST("/recycle/purge", midwares.JWTAuthMiddleware(), controller.PurgeRecyclePictures)
+		pictureAPI.POST("/archive/restore", midwares.JWTAuthMiddleware(), controller.RestoreArchivedPicture)
+		pictureAPI.POST("/export/create", midwares.JWTAuthMiddleware(), controller.CreatePictureExportJob)
+		pictureAPI.GET("/export/get", midwares.JWTAuthMiddleware(), controller.GetPictureExportJob)
+		pictureAPI.POST("/import/create", midwares.JWTAuthMiddleware(), controller.CreatePictureImportJob)
+		pictureAPI.GET("/import/get", midwares.JWTAuthMiddleware(), controller.GetPictureImportJob)
+		pictureAPI.POST("/archive/run", midwares.JWTAuthMiddleware(), midwares.AuthCheck(consts.ADMIN_ROLE), controller.RunPictureArchive)
 		pictureAPI.POST("/list/page", midwares.JWTAuthMiddleware(), midwares.AuthCheck(consts.ADMIN_ROLE), controller.ListPictureByPage)
 		pictureAPI.POST("/list/page/vo", controller.ListPictureVOByPage)
 		pictureAPI.POST("/list/page/vo/cache", controller.ListPictureVOByPageWithCache)
 		pictureAPI.POST("/list/page/vo/procache", controller.ProListPictureVOByPageWithCache)
 		pictureAPI.GET("/tag_category", controller.ListPictureTagCategory)
+		pictureAPI.GET("/tag/suggest", midwares.JWTAuthMiddleware(), controller.SuggestPictureTags)
+		pictureAPI.POST("/tag/rename", midwares.JWTAuthMiddleware(), controller.RenamePictureTag)
+		pictureAPI.POST("/tag/merge", midwares.JWTAuthMiddleware(), controller.MergePictureTags)
+		pictureAPI.POST("/tag/delete", midwares.JWTAuthMiddleware(), controller.DeletePictureTag)
 		pictureAPI.POST("/review", midwares.JWTAuthMiddleware(), midwares.AuthCheck(consts.ADMIN_ROLE), controller.DoPictureReview)
 		pictureAPI.POST("/search/picture", midwares.JWTAuthMiddleware(), controller.SearchPictureByPicture)
 		// 修复：移除颜色搜索的重复JWTAuthMiddleware
@@ -116,3 +144,11 @@ func registerPictureRoutes(apiV1 *gin.RouterGroup) {
 
 	}
 }
+
+func registerStorageRoutes(apiV1 *gin.RouterGroup) {
+	// @Tags Storage
+	storageAPI := apiV1.Group("/storage", midwares.JWTAuthMiddleware(), midwares.AuthCheck(consts.ADMIN_ROLE))
+	{
+		storageAPI.POST("/gc", controller.RunStorageGC)
+	}
+}